@@ -0,0 +1,532 @@
+package main
+
+import (
+	"fmt"
+	"monster/pkg/common"
+	"monster/pkg/common/define"
+	"monster/pkg/common/event"
+	"monster/pkg/common/gameres"
+	"monster/pkg/filesystem/fileparser"
+	"monster/pkg/game/resources"
+	"monster/pkg/game/subengine/campaignmanager"
+	"monster/pkg/game/subengine/eventmanager"
+	"monster/pkg/game/subengine/itemmanager"
+	"monster/pkg/game/subengine/lootmanager"
+	"monster/pkg/game/subengine/maprenderer"
+	"monster/pkg/game/subengine/maprenderer/base"
+	"monster/pkg/game/subengine/powermanager"
+	"monster/pkg/game/subengine/stats"
+	"monster/pkg/subengine/render/headless"
+	"monster/pkg/utils"
+	"monster/pkg/utils/parsing"
+	"path/filepath"
+	"sort"
+)
+
+// 检查过程中用到的游戏资源
+type Linter struct {
+	report   *Report
+	modules  common.Modules
+	gresf    gameres.Factory
+	stats    gameres.Stats
+	items    gameres.ItemManager
+	powers   gameres.PowerManager
+	loot     gameres.LootManager
+	camp     gameres.CampaignManager
+	eventm   gameres.EventManager
+	maps     map[string]struct{} // 已检查的地图
+	tilesets map[string]struct{} // 已检查的瓷砖定义
+	statuses map[define.StatusId]*statusUse
+}
+
+// 状态的使用情况
+type statusUse struct {
+	required bool
+	set      bool
+	where    string // 第一次被要求的位置
+}
+
+// 加载器内部发生panic时转成错误
+func protect(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if val, ok := r.(error); ok {
+				err = val
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+
+	return f()
+}
+
+func run(cmdLineMods []string) (*Report, error) {
+	s := modules.Settings()
+	p := modules.Platform()
+
+	var mods common.ModManager
+	err := protect(func() error {
+		mods = modules.NewMods(p, s, cmdLineMods)
+		return s.LoadSettings(mods)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	msg := modules.NewMsg()
+	font := modules.NewFont(s, mods)
+	defer font.Close()
+	anim := modules.NewAnim()
+	defer anim.Close()
+
+	eset := modules.NewEset()
+	if err := eset.Load(s, mods, msg, font); err != nil {
+		return nil, err
+	}
+
+	render := modules.NewRender(s, eset)
+	defer render.Close()
+
+	lint := &Linter{
+		report:   NewReport(),
+		modules:  modules,
+		gresf:    resources.NewFactory(),
+		maps:     map[string]struct{}{},
+		tilesets: map[string]struct{}{},
+		statuses: map[define.StatusId]*statusUse{},
+	}
+
+	// 加载器遇到错误时记录下来继续解析，报告全部错误
+	fileparser.SetErrorHandler(func(err error) {
+		lint.loadError("", err)
+	})
+	defer fileparser.SetErrorHandler(nil)
+
+	lint.checkDepends()
+	lint.loadData()
+	lint.checkItems()
+	lint.checkPowers()
+	lint.checkLoot()
+	lint.checkMaps()
+	lint.checkAnimations()
+	lint.checkStatuses()
+	lint.checkImages(render.(*headless.RenderDevice))
+
+	return lint.report, nil
+}
+
+// 加载错误，带位置的错误报告到对应的文件行
+func (this *Linter) loadError(what string, err error) {
+	where := what
+	if loadErr, ok := common.AsLoadError(err); ok {
+		where = fmt.Sprintf("%s:%d", loadErr.File, loadErr.Line)
+		err = loadErr.Err
+	}
+
+	this.report.Add(CAT_LOAD, where, "%s", err)
+}
+
+// mod依赖和版本冲突
+func (this *Linter) checkDepends() {
+	mods := this.modules.Mods()
+
+	for _, name := range mods.GetModDirs() {
+		for _, msg := range mods.GetDependsErrors(name) {
+			this.report.Add(CAT_DEPENDS, "mods/"+name, "%s", msg)
+		}
+	}
+}
+
+// 按依赖顺序加载数据
+func (this *Linter) loadData() {
+	this.camp = campaignmanager.New()
+	this.eventm = eventmanager.New()
+
+	if err := protect(func() error {
+		this.stats = stats.New(this.modules)
+		return nil
+	}); err != nil {
+		this.loadError("stats", err)
+		return
+	}
+
+	if err := protect(func() error {
+		items, err := itemmanager.New(this.modules, this.stats)
+		if err != nil {
+			return err
+		}
+
+		this.items = items
+		return nil
+	}); err != nil {
+		this.loadError("items", err)
+	}
+
+	if err := protect(func() error {
+		powers, err := powermanager.New(this.modules, this.stats)
+		if err != nil {
+			return err
+		}
+
+		this.powers = powers
+		return nil
+	}); err != nil {
+		this.loadError("powers", err)
+	}
+
+	if this.items != nil {
+		if err := protect(func() error {
+			loot, err := lootmanager.New(this.modules, this.items)
+			if err != nil {
+				return err
+			}
+
+			this.loot = loot
+			return nil
+		}); err != nil {
+			this.loadError("loot", err)
+		}
+	}
+}
+
+func (this *Linter) hasItem(id int) bool {
+	if this.items == nil {
+		return true
+	}
+
+	_, ok := this.items.GetItems()[(define.ItemId)(id)]
+	return ok
+}
+
+func (this *Linter) hasPower(id define.PowerId) bool {
+	if this.powers == nil || id == 0 {
+		return true
+	}
+
+	_, ok := this.powers.GetPowers()[id]
+	return ok
+}
+
+// 物品引用的技能
+func (this *Linter) checkItems() {
+	if this.items == nil {
+		return
+	}
+
+	var ids []int
+	for id, _ := range this.items.GetItems() {
+		ids = append(ids, (int)(id))
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		it := this.items.GetItems()[(define.ItemId)(id)]
+		where := fmt.Sprintf("item %d", id)
+
+		if !this.hasPower(it.Power) {
+			this.report.Add(CAT_REFERENCE, where, "unknown power %d", it.Power)
+		}
+
+		for _, pair := range it.ReplacePower {
+			if !this.hasPower(pair.First) {
+				this.report.Add(CAT_REFERENCE, where, "replace_power: unknown power %d", pair.First)
+			}
+
+			if !this.hasPower(pair.Second) {
+				this.report.Add(CAT_REFERENCE, where, "replace_power: unknown power %d", pair.Second)
+			}
+		}
+
+		if it.PickupStatus != "" {
+			this.setStatus(this.camp.RegisterStatus(it.PickupStatus))
+		}
+	}
+}
+
+// 技能引用的技能
+func (this *Linter) checkPowers() {
+	if this.powers == nil {
+		return
+	}
+
+	var ids []int
+	for id, _ := range this.powers.GetPowers() {
+		ids = append(ids, (int)(id))
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		pwr := this.powers.GetPowers()[(define.PowerId)(id)]
+		if pwr == nil {
+			continue
+		}
+
+		where := fmt.Sprintf("power %d", id)
+
+		if !this.hasPower(pwr.PostPower) {
+			this.report.Add(CAT_REFERENCE, where, "post_power: unknown power %d", pwr.PostPower)
+		}
+
+		if !this.hasPower(pwr.WallPower) {
+			this.report.Add(CAT_REFERENCE, where, "wall_power: unknown power %d", pwr.WallPower)
+		}
+	}
+}
+
+// 战利品表里的物品
+func (this *Linter) checkLoot() {
+	if this.loot == nil {
+		return
+	}
+
+	var names []string
+	for name, _ := range this.loot.GetLootTables() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, ec := range this.loot.GetLootTables()[name] {
+			if ec.Type == event.LOOT && !this.hasItem(ec.Id) {
+				this.report.Add(CAT_REFERENCE, name, "unknown item %d", ec.Id)
+			}
+		}
+	}
+}
+
+// 加载全部地图和地图使用的瓷砖定义
+func (this *Linter) checkMaps() {
+	mods := this.modules.Mods()
+
+	// 对话和任务里设置的状态
+	this.collectStatuses("npcs")
+	this.collectStatuses("quests")
+
+	filenames, err := mods.List("maps")
+	if err != nil {
+		this.report.Add(CAT_LOAD, "maps", "%s", err)
+		return
+	}
+
+	for _, filename := range filenames {
+		name := "maps/" + filepath.Base(filename)
+		if _, ok := this.maps[name]; ok {
+			continue
+		}
+		this.maps[name] = struct{}{}
+
+		this.checkMap(name)
+	}
+}
+
+func (this *Linter) checkMap(name string) {
+	m := base.ConstructMap()
+	defer m.ClearEvents()
+
+	if this.loot == nil {
+		this.report.Add(CAT_LOAD, name, "skipped, loot tables failed to load")
+		return
+	}
+
+	err := protect(func() error {
+		return m.Load(this.modules, this.loot, this.camp, this.eventm, this.gresf, name)
+	})
+	if err != nil {
+		this.loadError("", err)
+		return
+	}
+
+	for _, evnt := range m.GetEvents() {
+		for _, ec := range evnt.Components {
+			this.checkEventComponent(name, ec)
+		}
+	}
+
+	this.checkTileSet(m.GetTileSet())
+}
+
+func (this *Linter) checkEventComponent(where string, ec event.Component) {
+	switch ec.Type {
+	case event.REQUIRES_ITEM, event.REQUIRES_NOT_ITEM, event.REMOVE_ITEM, event.REWARD_ITEM, event.LOOT:
+		if !this.hasItem(ec.Id) {
+			this.report.Add(CAT_REFERENCE, where, "event: unknown item %d", ec.Id)
+		}
+	case event.POWER:
+		if !this.hasPower((define.PowerId)(ec.Id)) {
+			this.report.Add(CAT_REFERENCE, where, "event: unknown power %d", ec.Id)
+		}
+	case event.REWARD_LOOT:
+		if this.loot != nil {
+			if _, ok := this.loot.GetLootTables()[ec.S]; !ok {
+				this.report.Add(CAT_REFERENCE, where, "event: unknown loot table '%s'", ec.S)
+			}
+		}
+	case event.REQUIRES_STATUS, event.REQUIRES_NOT_STATUS:
+		this.requireStatus(ec.Status, where)
+	case event.SET_STATUS, event.UNSET_STATUS:
+		this.setStatus(ec.Status)
+	case event.INTERMAP:
+		if ec.S == "" {
+			break
+		}
+
+		_, err := this.modules.Mods().Locate(this.modules.Settings(), ec.S)
+		if utils.IsNotExist(err) {
+			this.report.Add(CAT_MAP_TARGET, where, "event: intermap target '%s' does not exist", ec.S)
+		}
+	}
+}
+
+func (this *Linter) checkTileSet(name string) {
+	if name == "" {
+		return
+	}
+
+	if _, ok := this.tilesets[name]; ok {
+		return
+	}
+	this.tilesets[name] = struct{}{}
+
+	tset := maprenderer.NewTileSet()
+	defer tset.Reset()
+
+	err := protect(func() error {
+		return tset.Load(this.modules, name)
+	})
+	if err != nil {
+		this.loadError("", err)
+	}
+}
+
+// 加载animations/下的全部动画定义
+func (this *Linter) checkAnimations() {
+	mods := this.modules.Mods()
+	settings := this.modules.Settings()
+	render := this.modules.Render()
+	resf := this.modules.Resf()
+
+	filenames, err := mods.List("animations")
+	if err != nil {
+		this.report.Add(CAT_LOAD, "animations", "%s", err)
+		return
+	}
+
+	done := map[string]struct{}{}
+	for _, filename := range filenames {
+		name := "animations/" + filepath.Base(filename)
+		if _, ok := done[name]; ok {
+			continue
+		}
+		done[name] = struct{}{}
+
+		err := protect(func() error {
+			set, err := resf.New("animationset").(common.AnimationSet).Init(settings, mods, render, name)
+			if err != nil {
+				return err
+			}
+
+			set.Close()
+			return nil
+		})
+		if err != nil {
+			this.loadError("", err)
+		}
+	}
+}
+
+// 收集dir下文件里设置的状态
+func (this *Linter) collectStatuses(dir string) {
+	mods := this.modules.Mods()
+
+	filenames, err := mods.List(dir)
+	if err != nil {
+		return
+	}
+
+	done := map[string]struct{}{}
+	for _, filename := range filenames {
+		name := dir + "/" + filepath.Base(filename)
+		if _, ok := done[name]; ok {
+			continue
+		}
+		done[name] = struct{}{}
+
+		infile := fileparser.New()
+		if err := infile.Open(name, true, mods); err != nil {
+			continue
+		}
+
+		for infile.Next(mods) {
+			if infile.Key() != "set_status" {
+				continue
+			}
+
+			val := infile.Val()
+			var first string
+			first, val = parsing.PopFirstString(val, "")
+			for first != "" {
+				this.setStatus(this.camp.RegisterStatus(first))
+				first, val = parsing.PopFirstString(val, "")
+			}
+		}
+
+		infile.Close()
+	}
+}
+
+func (this *Linter) statusUse(id define.StatusId) *statusUse {
+	ptr, ok := this.statuses[id]
+	if !ok {
+		ptr = &statusUse{}
+		this.statuses[id] = ptr
+	}
+
+	return ptr
+}
+
+func (this *Linter) requireStatus(id define.StatusId, where string) {
+	if id == 0 {
+		return
+	}
+
+	ptr := this.statusUse(id)
+	if !ptr.required {
+		ptr.required = true
+		ptr.where = where
+	}
+}
+
+func (this *Linter) setStatus(id define.StatusId) {
+	if id == 0 {
+		return
+	}
+
+	this.statusUse(id).set = true
+}
+
+// 被要求但是从来没被设置过的状态
+func (this *Linter) checkStatuses() {
+	var names []string
+	where := map[string]string{}
+
+	for id, ptr := range this.statuses {
+		if ptr.required && !ptr.set {
+			name := this.camp.GetStatusName(id)
+			names = append(names, name)
+			where[name] = ptr.where
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		this.report.Add(CAT_STATUS, where[name], "status '%s' is required but never set", name)
+	}
+}
+
+// 加载过程中找不到的图片
+func (this *Linter) checkImages(render *headless.RenderDevice) {
+	for _, filename := range render.GetMissingImages() {
+		this.report.Add(CAT_IMAGE, filename, "image not found or unreadable")
+	}
+}
@@ -0,0 +1,64 @@
+// modlint 离线检查mod数据
+//
+// 通过ModManager加载mod和依赖，用现有的加载器(无渲染模式)解析
+// 物品，技能，效果，战利品表，地图，瓷砖和动画，报告:
+//   - 无效的key (file:line)
+//   - 引用了不存在的物品/技能/状态/地图
+//   - 找不到的图片
+//   - mod依赖和版本冲突
+//
+// 用法: modlint [-data PATH] [-mods mod1,mod2]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monster/pkg/config/version"
+	"monster/pkg/filesystem/logfile"
+	"os"
+	"strings"
+)
+
+var (
+	modules = NewModules()
+)
+
+func main() {
+	dataPath := flag.String("data", "", "custom data path (contains mods/)")
+	modList := flag.String("mods", "", "comma separated list of mods to check, default is mods.txt")
+	flag.Parse()
+
+	s := modules.NewSettings()
+	p := modules.NewPlatform()
+
+	if *dataPath != "" {
+		path := *dataPath
+		if !strings.HasSuffix(path, "/") {
+			path += "/"
+		}
+		s.SetCustomPathData(path)
+	}
+
+	if err := p.SetPaths(s); err != nil {
+		fmt.Fprintf(os.Stderr, "modlint: %s\n", err)
+		os.Exit(2)
+	}
+
+	logfile.LogInfo(version.CreateVersionStringFull())
+
+	var cmdLineMods []string
+	if *modList != "" {
+		cmdLineMods = strings.Split(*modList, ",")
+	}
+
+	report, err := run(cmdLineMods)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "modlint: %s\n", err)
+		os.Exit(2)
+	}
+
+	report.Print(os.Stdout)
+	if report.Len() > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// 问题分类
+const (
+	CAT_LOAD       = "load"
+	CAT_DEPENDS    = "depends"
+	CAT_IMAGE      = "image"
+	CAT_REFERENCE  = "reference"
+	CAT_STATUS     = "status"
+	CAT_MAP_TARGET = "map"
+)
+
+type Problem struct {
+	Category string
+	Where    string // 文件或 file:line
+	Msg      string
+}
+
+type Report struct {
+	problems []Problem
+}
+
+func NewReport() *Report {
+	return &Report{}
+}
+
+func (this *Report) Add(category, where, format string, args ...interface{}) {
+	this.problems = append(this.problems, Problem{
+		Category: category,
+		Where:    where,
+		Msg:      fmt.Sprintf(format, args...),
+	})
+}
+
+func (this *Report) Len() int {
+	return len(this.problems)
+}
+
+func (this *Report) Print(w io.Writer) {
+	for _, p := range this.problems {
+		if p.Where != "" {
+			fmt.Fprintf(w, "[%s] %s: %s\n", p.Category, p.Where, p.Msg)
+		} else {
+			fmt.Fprintf(w, "[%s] %s\n", p.Category, p.Msg)
+		}
+	}
+
+	fmt.Fprintf(w, "modlint: %d problem(s) found\n", len(this.problems))
+}
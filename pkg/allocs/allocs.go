@@ -45,12 +45,14 @@ type Allocs struct {
 	mu         sync.RWMutex
 	cgoMetrics map[int](map[string]string) // type : (id: stackid)
 	stacks     map[string][]byte           // stackid: stack
+	atlas      map[string]AtlasInfo        // 图集id: 占用
 }
 
 func newAllocs() *Allocs {
 	allocs := &Allocs{
 		cgoMetrics: map[int](map[string]string){},
 		stacks:     map[string][]byte{},
+		atlas:      map[string]AtlasInfo{},
 	}
 
 	// 提前分配内存
@@ -114,6 +116,7 @@ func newAllocs() *Allocs {
 			}
 
 			fmt.Printf("remove %d stack ids, left: %d \n", i, len(allocs.stacks))
+			allocs.printAtlas()
 			allocs.mu.RUnlock()
 			time.Sleep(30 * time.Second)
 		}
@@ -132,6 +135,9 @@ func (this *Allocs) PrintStackInfo() {
 			fmt.Println(id)
 		}
 	}
+
+	fmt.Println("=====", "ATLAS", "=====")
+	this.printAtlas()
 }
 
 func (this *Allocs) PrintAllStack() {
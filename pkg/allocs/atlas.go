@@ -0,0 +1,74 @@
+package allocs
+
+import (
+	"fmt"
+	"sort"
+)
+
+// 纹理图集的占用情况
+type AtlasInfo struct {
+	Used  int // 已用像素
+	Total int // 总像素
+}
+
+func (this AtlasInfo) Occupancy() float32 {
+	if this.Total == 0 {
+		return 0
+	}
+
+	return float32(this.Used) / float32(this.Total)
+}
+
+func (this *Allocs) SetAtlas(id string, used, total int) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.atlas[id] = AtlasInfo{Used: used, Total: total}
+}
+
+func (this *Allocs) DeleteAtlas(id string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	delete(this.atlas, id)
+}
+
+func (this *Allocs) GetAtlas() map[string]AtlasInfo {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	tmp := map[string]AtlasInfo{}
+	for id, info := range this.atlas {
+		tmp[id] = info
+	}
+
+	return tmp
+}
+
+// 调用者持有读锁
+func (this *Allocs) printAtlas() {
+	var ids []string
+	for id, _ := range this.atlas {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		info := this.atlas[id]
+		fmt.Printf("ATLAS %s: %.1f%% (%d/%d)\n", id, info.Occupancy()*100, info.Used, info.Total)
+	}
+}
+
+// default api
+
+func SetAtlas(id string, used, total int) {
+	defaultAllocs.SetAtlas(id, used, total)
+}
+
+func DeleteAtlas(id string) {
+	defaultAllocs.DeleteAtlas(id)
+}
+
+func GetAtlas() map[string]AtlasInfo {
+	return defaultAllocs.GetAtlas()
+}
@@ -82,6 +82,49 @@ func (this *Allocs) ImgLoadTexture(renderer *sdl.Renderer, file string) (*sdl.Te
 	return ptr, nil
 }
 
+// 从内存加载 zip包内的文件
+func (this *Allocs) ImgLoadRW(data []byte) (*sdl.Surface, error) {
+	rw, err := sdl.RWFromMem(data)
+	if err != nil {
+		return nil, err
+	}
+
+	ptr, err := img.LoadRW(rw, true)
+	if err != nil {
+		return nil, err
+	}
+
+	id := fmt.Sprintf("%p", ptr)
+	//id := *(*uint64)(unsafe.Pointer(ptr))
+
+	this.register(SDL_SURFACE, id)
+
+	return ptr, nil
+}
+
+func (this *Allocs) ImgLoadTextureRW(renderer *sdl.Renderer, data []byte) (*sdl.Texture, error) {
+	rw, err := sdl.RWFromMem(data)
+	if err != nil {
+		return nil, err
+	}
+
+	ptr, err := img.LoadTextureRW(renderer, rw, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if ptr == nil {
+		return nil, nil
+	}
+
+	id := fmt.Sprintf("%p", ptr)
+	//id := *(*uint64)(unsafe.Pointer(ptr))
+
+	this.register(SDL_TEXTURE, id)
+
+	return ptr, nil
+}
+
 func (this *Allocs) FontRenderUTF8Blended(font *ttf.Font, text string, color sdl.Color) (*sdl.Surface, error) {
 	ptr, err := font.RenderUTF8Blended(text, color)
 	if err != nil {
@@ -110,6 +153,32 @@ func (this *Allocs) FontRenderUTF8Solid(font *ttf.Font, text string, color sdl.C
 	return ptr, nil
 }
 
+func (this *Allocs) FontRenderGlyphBlended(font *ttf.Font, ch rune, color sdl.Color) (*sdl.Surface, error) {
+	ptr, err := font.RenderGlyphBlended(ch, color)
+	if err != nil {
+		return nil, err
+	}
+
+	id := fmt.Sprintf("%p", ptr)
+
+	this.register(SDL_SURFACE, id)
+
+	return ptr, nil
+}
+
+func (this *Allocs) FontRenderGlyphSolid(font *ttf.Font, ch rune, color sdl.Color) (*sdl.Surface, error) {
+	ptr, err := font.RenderGlyphSolid(ch, color)
+	if err != nil {
+		return nil, err
+	}
+
+	id := fmt.Sprintf("%p", ptr)
+
+	this.register(SDL_SURFACE, id)
+
+	return ptr, nil
+}
+
 func (this *Allocs) SdlCreateRGBSurface(flags uint32, width, height, depth int32, Rmask, Gmask, Bmask, Amask uint32) (*sdl.Surface, error) {
 	ptr, err := sdl.CreateRGBSurface(flags, width, height, depth, Rmask, Gmask, Bmask, Amask)
 	if err != nil {
@@ -152,6 +221,26 @@ func (this *Allocs) TtfOpenFont(file string, size int) (*ttf.Font, error) {
 	return ptr, err
 }
 
+// data需要在字体关闭前一直保留
+func (this *Allocs) TtfOpenFontRW(data []byte, size int) (*ttf.Font, error) {
+	rw, err := sdl.RWFromMem(data)
+	if err != nil {
+		return nil, err
+	}
+
+	ptr, err := ttf.OpenFontRW(rw, 1, size)
+	if err != nil {
+		return nil, err
+	}
+
+	id := fmt.Sprintf("%p", ptr)
+	//id := *(*uint64)(unsafe.Pointer(ptr))
+
+	this.register(SDL_FONT, id)
+
+	return ptr, err
+}
+
 // 对外api
 func SdlCreateWindow(title string, x, y, w, h int32, flags uint32) (*sdl.Window, error) {
 	return defaultAllocs.SdlCreateWindow(title, x, y, w, h, flags)
@@ -181,6 +270,14 @@ func FontRenderUTF8Solid(font *ttf.Font, text string, color sdl.Color) (*sdl.Sur
 	return defaultAllocs.FontRenderUTF8Solid(font, text, color)
 }
 
+func FontRenderGlyphBlended(font *ttf.Font, ch rune, color sdl.Color) (*sdl.Surface, error) {
+	return defaultAllocs.FontRenderGlyphBlended(font, ch, color)
+}
+
+func FontRenderGlyphSolid(font *ttf.Font, ch rune, color sdl.Color) (*sdl.Surface, error) {
+	return defaultAllocs.FontRenderGlyphSolid(font, ch, color)
+}
+
 func SdlCreateRGBSurface(flags uint32, width, height, depth int32, Rmask, Gmask, Bmask, Amask uint32) (*sdl.Surface, error) {
 	return defaultAllocs.SdlCreateRGBSurface(flags, width, height, depth, Rmask, Gmask, Bmask, Amask)
 }
@@ -192,3 +289,15 @@ func SdlCreateTextureFromSurface(renderer *sdl.Renderer, surface *sdl.Surface) (
 func TtfOpenFont(file string, size int) (*ttf.Font, error) {
 	return defaultAllocs.TtfOpenFont(file, size)
 }
+
+func ImgLoadRW(data []byte) (*sdl.Surface, error) {
+	return defaultAllocs.ImgLoadRW(data)
+}
+
+func ImgLoadTextureRW(renderer *sdl.Renderer, data []byte) (*sdl.Texture, error) {
+	return defaultAllocs.ImgLoadTextureRW(renderer, data)
+}
+
+func TtfOpenFontRW(data []byte, size int) (*ttf.Font, error) {
+	return defaultAllocs.TtfOpenFontRW(data, size)
+}
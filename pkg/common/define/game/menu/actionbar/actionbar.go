@@ -16,3 +16,9 @@ const (
 	MENU_POWERS
 	MENU_LOG
 )
+
+// 冷却图层样式
+const (
+	COOLDOWN_VERTICAL = iota // 从下往上
+	COOLDOWN_RADIAL          // 顺时针扇形
+)
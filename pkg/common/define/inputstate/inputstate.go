@@ -1,7 +1,7 @@
 package inputstate
 
 const (
-	KEY_COUNT      = 31
+	KEY_COUNT      = 32
 	KEY_COUNT_USER = KEY_COUNT - 4
 )
 
@@ -33,6 +33,7 @@ const (
 	ACTIONBAR_FORWARD
 	ACTIONBAR_USE
 	DEVELOPER_MENU
+	SCREENSHOT
 	CTRL
 	SHIFT
 	ALT
@@ -42,3 +43,21 @@ const (
 const (
 	MOUSE_BIND_OFFSET = 2
 )
+
+// 文本输入时的编辑命令
+const (
+	TEXTEDIT_LEFT = iota
+	TEXTEDIT_RIGHT
+	TEXTEDIT_HOME
+	TEXTEDIT_END
+	TEXTEDIT_SELECT_LEFT
+	TEXTEDIT_SELECT_RIGHT
+	TEXTEDIT_SELECT_HOME
+	TEXTEDIT_SELECT_END
+	TEXTEDIT_SELECT_ALL
+	TEXTEDIT_BACKSPACE
+	TEXTEDIT_DELETE
+	TEXTEDIT_COPY
+	TEXTEDIT_CUT
+	TEXTEDIT_PASTE
+)
@@ -10,7 +10,7 @@ const (
 	VIDEO_COUNT     = 11
 	AUDIO_COUNT     = 2
 	INTERFACE_COUNT = 16
-	INPUT_COUNT     = 9
+	INPUT_COUNT     = 10
 	MISC_COUNT      = 2
 )
 
@@ -62,6 +62,7 @@ const (
 	INPUT_JOYSTICK_DEADZONE
 	INPUT_TOUCH_CONTROLS
 	INPUT_TOUCH_SCALE
+	INPUT_MOVEMENT_TYPE
 )
 
 const (
@@ -3,6 +3,7 @@ package renderable
 const (
 	BLEND_NORMAL = iota
 	BLEND_ADD
+	BLEND_MULTIPLY
 )
 
 const (
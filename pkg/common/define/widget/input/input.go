@@ -0,0 +1,9 @@
+package input
+
+const (
+	DEFAULT_FILE = "images/menus/input.png"
+)
+
+const (
+	PADDING = 4 // 文字和边框的距离
+)
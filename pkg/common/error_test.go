@@ -1,6 +1,7 @@
 package common
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
@@ -23,3 +24,19 @@ func Test_error_equal(t *testing.T) {
 		fmt.Println(k, v)
 	}
 }
+
+func Test_load_error(t *testing.T) {
+	r := require.New(t)
+
+	err := NewLoadError("maps/a.txt", 12, "header", "tileset", Err_no_such_file_or_dir)
+	r.Equal("maps/a.txt:12 [header] tileset: no such file or dir", err.Error())
+
+	wrapped := fmt.Errorf("load map: %w", err)
+	loadErr, ok := AsLoadError(wrapped)
+	r.Equal(true, ok)
+	r.Equal(uint32(12), loadErr.Line)
+	r.Equal(true, errors.Is(wrapped, Err_no_such_file_or_dir))
+
+	_, ok = AsLoadError(Err_normal_exit)
+	r.Equal(false, ok)
+}
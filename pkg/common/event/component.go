@@ -66,6 +66,7 @@ const (
 	QUEST_TEXT               = 59
 	WAS_INSIDE_EVENT_AREA    = 60
 	NPC_TAKE_A_PARTY         = 61
+	LIGHT                    = 62
 )
 
 type Component struct {
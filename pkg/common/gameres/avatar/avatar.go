@@ -1,15 +1,54 @@
 package avatar
 
+import (
+	"monster/pkg/common/color"
+	"monster/pkg/common/item"
+	"monster/pkg/common/light"
+)
+
 const (
 	MSG_NORMAL = 0
 	MSG_UNIQUE = 1
 )
 
 type LayerGfx struct {
-	Gfx  string
-	Type string
+	Gfx       string
+	Type      string
+	Color     color.Color // 运行时染色
+	Alpha     uint8
+	Glow      string // 发光层的动画，加法混合
+	GlowColor color.Color
+	Light     light.Light // 图层带的光源
+	Particles string      // 图层带的粒子发射器
 }
 
 func ConstructLayerGfx() LayerGfx {
-	return LayerGfx{}
+	return LayerGfx{
+		Color:     color.Construct(255, 255, 255),
+		Alpha:     255,
+		GlowColor: color.Construct(255, 255, 255),
+		Light:     light.Construct(),
+	}
+}
+
+// 使用装备物品的图层设置
+func (this *LayerGfx) SetItem(it *item.Item) {
+	this.Gfx = it.Gfx
+	this.Color = it.GfxColor
+	this.Alpha = it.GfxAlpha
+	this.Glow = it.GlowGfx
+	this.GlowColor = it.GlowColor
+	this.Light = it.Light
+	this.Particles = it.Particles
+}
+
+// hero_layers里没有配置的方向使用默认顺序
+func FillDefaultLayerOrder(layerDef [][]uint, layerCount int) {
+	for dir, _ := range layerDef {
+		if len(layerDef[dir]) == 0 {
+			for i := 0; i < layerCount; i++ {
+				layerDef[dir] = append(layerDef[dir], (uint)(i))
+			}
+		}
+	}
 }
@@ -4,6 +4,7 @@ import (
 	"monster/pkg/common"
 	"monster/pkg/common/color"
 	"monster/pkg/common/gameres/power"
+	"monster/pkg/common/light"
 	"monster/pkg/common/timer"
 )
 
@@ -50,6 +51,8 @@ type Effect struct {
 	MagnitudeMax    int // 最大
 	AnimationName   string
 	Animation       common.Animation
+	Particles       string
+	Light           light.Light
 	Item            bool
 	Trigger         int // 触发方式
 	RenderAbove     bool
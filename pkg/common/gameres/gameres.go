@@ -12,6 +12,7 @@ import (
 	"monster/pkg/common/gameres/power"
 	"monster/pkg/common/gameres/statblock"
 	"monster/pkg/common/item"
+	"monster/pkg/common/light"
 	"monster/pkg/common/point"
 	"monster/pkg/common/timer"
 )
@@ -20,13 +21,13 @@ type GameRes interface {
 	Stats() Stats
 	NewStats(common.Modules) Stats
 	Items() ItemManager
-	NewItems(common.Modules, Stats) ItemManager
+	NewItems(common.Modules, Stats) (ItemManager, error)
 	Camp() CampaignManager
 	NewCamp() CampaignManager
 	EventManager() EventManager
 	NewEventManager() EventManager
 	Loot() LootManager
-	NewLoot(common.Modules, ItemManager) LootManager
+	NewLoot(common.Modules, ItemManager) (LootManager, error)
 	Pc() Avatar
 	NewPc(common.Modules, MapRenderer, Stats, PowerManager, Factory) Avatar
 	Menu() MenuManager
@@ -34,7 +35,7 @@ type GameRes interface {
 	Mapr() MapRenderer
 	NewMapr(common.Modules, Factory) MapRenderer
 	Powers() PowerManager
-	NewPowers(common.Modules, Stats) PowerManager
+	NewPowers(common.Modules, Stats) (PowerManager, error)
 
 	// 工厂方法
 	Menuf() Factory
@@ -65,7 +66,22 @@ type Menu interface {
 
 type MenuConfirm interface {
 	Menu
-	Init(modules common.Modules, buttonMsg, boxMsg string) MenuConfirm
+	Init(modules common.Modules, buttonMsg, boxMsg string) (MenuConfirm, error)
+	GetConfirmClicked() bool
+	GetCancelClicked() bool
+}
+
+type MenuMovementType interface {
+	Menu
+	Init(common.Modules) (MenuMovementType, error)
+	Open(common.Modules)
+	GetConfirmed() bool
+}
+
+type MenuLayout interface {
+	Menu
+	Init(common.Modules, string) (MenuLayout, error)
+	GetClicked() string
 }
 
 type MenuConfig interface {
@@ -102,6 +118,7 @@ type StatBlock interface {
 	GetHero() bool
 	SetHero(bool)
 	SetCharacterClass(string)
+	GetCharacterClass() string
 	SetCharacterSubclass(string)
 	GetPrimary(int) int
 	SetPrimary(int, int)
@@ -215,13 +232,21 @@ type EffectManager interface {
 	GetCurrentColor(color.Color) color.Color
 	GetCurrentAlpha(uint8) uint8
 	ClearTriggerEffects(trigger int)
+	GetParticles() []string
+	GetLights() []light.Light
 }
 
 type CampaignManager interface {
 	Close()
 	RegisterStatus(string) define.StatusId
+	GetStatusName(define.StatusId) string
 	SetStatus(s define.StatusId)
 	ResetAllStatuses()
+	SetInventory(MenuInventory)
+	RewardItem(define.ItemId, int)
+	RemoveItem(define.ItemId, int) bool
+	RewardCurrency(int)
+	RemoveCurrency(int)
 }
 
 type EventManager interface {
@@ -233,6 +258,7 @@ type EventManager interface {
 
 type LootManager interface {
 	ParseLoot(common.Modules, string, *event.Component, []event.Component) []event.Component
+	GetLootTables() map[string][]event.Component
 	Close(common.Modules)
 }
 
@@ -249,11 +275,22 @@ type MenuInventory interface {
 	GetChangedEquipment() bool
 	SetCurrency(int)
 	GetCurrency() int
+	AddItem(define.ItemId, int)
+	RemoveItem(define.ItemId, int) bool
+	GetItemCountCarried(define.ItemId) int
+	SetEquipped(slotType string, id define.ItemId)
+	GetEquipped(slotType string) define.ItemId
 }
 
 type MenuActionBar interface {
 	Menu
 	Init(common.Modules, PowerManager) MenuActionBar
+	SetInventory(MenuInventory)
+	Set([]define.PowerId)
+	GetHotkeys() []define.PowerId
+	CheckAction(common.Modules) []power.ActionData
+	LoadLayout(common.Modules, Avatar, PowerManager) error
+	SaveLayout(common.Modules) error
 }
 
 type MenuManager interface {
@@ -287,6 +324,18 @@ type Map interface {
 	RegisterDelayedEvent(event.Event)
 }
 
+type ParticleManager interface {
+	AddEmitter(modules common.Modules, filename string, pos fpoint.FPoint) (int, error)
+	AttachEmitter(modules common.Modules, filename string, follow func() (fpoint.FPoint, bool)) (int, error)
+	StopEmitter(int)
+	RemoveEmitter(int)
+	HasEmitter(int) bool
+	Logic()
+	AddRenders(common.Modules, []common.Renderable) []common.Renderable
+	Clear()
+	Close()
+}
+
 type MapRenderer interface {
 	Map
 	Clear()
@@ -297,7 +346,12 @@ type MapRenderer interface {
 	GetTeleportMapName() string
 	SetTeleportDestination(fpoint.FPoint)
 	GetTeleportDestination() fpoint.FPoint
+	SetCutscene(bool)
+	GetCutscene() bool
+	SetCutsceneFile(string)
+	GetCutsceneFile() string
 	Load(modules common.Modules, loot LootManager, camp CampaignManager, eventManager EventManager, gresf Factory, fname string) error
+	LoadAsync(modules common.Modules, ldr common.Loader, loot LootManager, camp CampaignManager, eventManager EventManager, gresf Factory, fname string)
 	Render(modules common.Modules, r []common.Renderable, rDead []common.Renderable) error
 	Logic(common.Modules)
 	ExecuteOnLoadEvent(common.Modules, EventManager, CampaignManager)
@@ -307,6 +361,9 @@ type MapRenderer interface {
 	GetHeroPos() fpoint.FPoint
 	GetCam() MapCamera
 	GetFilename() string
+	SetMapParallax(common.Modules, string) error
+	GetParticles() ParticleManager
+	AddLight(light.Light)
 }
 
 type Entity interface {
@@ -321,8 +378,10 @@ type Avatar interface {
 	Init(common.Modules, MapRenderer, Stats, PowerManager)
 	GetLayerReferenceOrder() []string
 	LoadGraphics(common.Modules, []avatar.LayerGfx) error
-	Logic(common.Modules, MapRenderer, CampaignManager)
+	SetLayerTint(layerType string, c color.Color, alpha uint8)
+	Logic(common.Modules, []power.ActionData, MapRenderer, CampaignManager)
 	AddRenders(modules common.Modules, r []common.Renderable) []common.Renderable
+	AddLights([]light.Light) []light.Light
 	GetPowerCastTimersSize() int
 	GetPowerCastTimer(define.PowerId) *timer.Timer
 	GetPowerCooldownTimer(define.PowerId) *timer.Timer
@@ -332,6 +391,9 @@ type PowerManager interface {
 	VerifyId(powerId define.PowerId, allowZero bool) define.PowerId
 	GetPower(define.PowerId) *power.Power
 	GetPowers() map[define.PowerId]*power.Power
+	Activate(common.Modules, Stats, define.PowerId, StatBlock, fpoint.FPoint) bool
+	GetUsedItems() []define.ItemId
+	ClearUsedItems()
 	Close()
 }
 
@@ -343,9 +405,14 @@ type GameState interface {
 	SetForceRefreshBackground(bool)
 	GetForceRefreshBackground() bool
 	ShowLoading(common.Modules) error
+	RenderLoading(common.Modules) error
+	SetLoader(common.Loader)
 	GetHasBackground() bool
 	Render(common.Modules, GameRes) error
 	GetRequestedGameState() GameState
+	ClearRequestedGameState()
+	SetSuspended(bool)
+	GetSuspended() bool
 	GetReloadBackgrounds() bool
 	IncrLoadCounter()
 	DecrLoadCounter()
@@ -370,6 +437,15 @@ type GameStateNewGame interface {
 	GameState
 }
 
+type GameStateCredits interface {
+	GameState
+}
+
+type GameStateCutscene interface {
+	GameState
+	Load(common.Modules, string) error
+}
+
 type GameStatePlay interface {
 	GameState
 	ResetGame(common.Modules, GameRes)
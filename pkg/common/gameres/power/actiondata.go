@@ -0,0 +1,15 @@
+package power
+
+import (
+	"monster/pkg/common/define"
+)
+
+// 动作栏请求释放的技能
+type ActionData struct {
+	Power  define.PowerId
+	Hotkey int // 动作栏的槽
+}
+
+func ConstructActionData() ActionData {
+	return ActionData{}
+}
@@ -2,7 +2,9 @@ package item
 
 import (
 	"math"
+	"monster/pkg/common/color"
 	"monster/pkg/common/define"
+	"monster/pkg/common/light"
 )
 
 const (
@@ -40,7 +42,13 @@ type Item struct {
 	RequiresClass  string
 	Bonus          []BonusData
 	//TODO sfx
-	Gfx           string // 装备了该物品的动画文件
+	Gfx           string      // 装备了该物品的动画文件
+	GfxColor      color.Color // 装备图层的染色
+	GfxAlpha      uint8
+	GlowGfx       string // 叠加的发光动画，加法混合，比如附魔武器
+	GlowColor     color.Color
+	Light         light.Light // 装备后角色身上的光源，半径为0表示没有
+	Particles     string      // 装备后跟随角色的粒子发射器
 	LootAnimation []LootAnimation
 	Power         define.PowerId
 	ReplacePower  []ReplacePowerPair // 装备了该物品，技能替换
@@ -64,6 +72,10 @@ func New(damageTypeNum int) *Item {
 func Construct(damageTypeNum int) Item {
 	return Item{
 		BookIsReadable: true,
+		GfxColor:       color.Construct(255, 255, 255),
+		GfxAlpha:       255,
+		GlowColor:      color.Construct(255, 255, 255),
+		Light:          light.Construct(),
 		MaxQuantity:    math.MaxInt,
 		DmgMin:         make([]int, damageTypeNum),
 		DmgMax:         make([]int, damageTypeNum),
@@ -0,0 +1,72 @@
+package light
+
+import (
+	"math"
+	"monster/pkg/common/color"
+	"monster/pkg/common/fpoint"
+)
+
+// 光源，半径为地图单位
+type Light struct {
+	Pos     fpoint.FPoint
+	Radius  float32
+	Color   color.Color
+	Flicker float32 // 闪烁幅度 0-1
+	Seed    int     // 闪烁相位，避免所有光源同步
+}
+
+func Construct() Light {
+	return Light{
+		Pos:   fpoint.Construct(),
+		Color: color.Construct(255, 255, 255),
+	}
+}
+
+func (this *Light) IsValid() bool {
+	return this.Radius > 0
+}
+
+// 某一帧的亮度 [1-Flicker, 1]，相邻帧平滑变化
+func (this *Light) Intensity(frame int) float32 {
+	if this.Flicker <= 0 {
+		return 1
+	}
+
+	// 两个噪声值之间插值，每8帧一个新值
+	const step = 8
+	i := frame / step
+	t := (float32)(frame%step) / step
+	n := noise(i+this.Seed)*(1-t) + noise(i+1+this.Seed)*t
+
+	return 1 - this.Flicker*n
+}
+
+// 按亮度缩放后的颜色
+func (this *Light) ColorAt(frame int) color.Color {
+	k := this.Intensity(frame)
+
+	return color.Construct(
+		(uint8)((float32)(this.Color.R)*k+0.5),
+		(uint8)((float32)(this.Color.G)*k+0.5),
+		(uint8)((float32)(this.Color.B)*k+0.5),
+	)
+}
+
+// 整数哈希到 [0, 1]
+func noise(i int) float32 {
+	x := (uint32)(i)*0x9E3779B1 + 0x7F4A7C15
+	x ^= x >> 15
+	x *= 0x85EBCA77
+	x ^= x >> 13
+
+	return (float32)(x&0xFFFF) / 0xFFFF
+}
+
+// 径向衰减，d为到中心的距离与半径之比
+func Falloff(d float32) float32 {
+	if d >= 1 {
+		return 0
+	}
+
+	return (float32)(math.Pow((float64)(1-d*d), 2))
+}
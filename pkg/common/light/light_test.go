@@ -0,0 +1,30 @@
+package light
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntensity(t *testing.T) {
+	r := require.New(t)
+
+	l := Construct()
+	r.Equal(float32(1), l.Intensity(10))
+
+	l.Flicker = 0.5
+	for frame := 0; frame < 100; frame++ {
+		k := l.Intensity(frame)
+		r.GreaterOrEqual(k, float32(0.5))
+		r.LessOrEqual(k, float32(1))
+	}
+}
+
+func TestFalloff(t *testing.T) {
+	r := require.New(t)
+
+	r.Equal(float32(1), Falloff(0))
+	r.Equal(float32(0), Falloff(1))
+	r.Equal(float32(0), Falloff(2))
+	r.Greater(Falloff(0.3), Falloff(0.6))
+}
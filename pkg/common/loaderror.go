@@ -0,0 +1,81 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 资源加载错误，带上出错的文件位置
+type LoadError struct {
+	File    string
+	Line    uint32
+	Section string
+	Key     string
+	Err     error
+}
+
+func NewLoadError(file string, line uint32, section, key string, err error) *LoadError {
+	return &LoadError{
+		File:    file,
+		Line:    line,
+		Section: section,
+		Key:     key,
+		Err:     err,
+	}
+}
+
+// file:line: [section] key: msg
+func (this *LoadError) Error() string {
+	where := this.File
+	if this.Line > 0 {
+		where = fmt.Sprintf("%s:%d", where, this.Line)
+	}
+
+	if this.Section != "" {
+		where += " [" + this.Section + "]"
+	}
+
+	if this.Key != "" {
+		where += " " + this.Key
+	}
+
+	return where + ": " + this.Err.Error()
+}
+
+func (this *LoadError) Unwrap() error {
+	return this.Err
+}
+
+// 错误链里第一个加载错误
+func AsLoadError(err error) (*LoadError, bool) {
+	var loadErr *LoadError
+	if errors.As(err, &loadErr) {
+		return loadErr, true
+	}
+
+	return nil, false
+}
+
+// 不影响加载结果的问题，只需提示
+type LoadWarning struct {
+	Err error
+}
+
+func NewLoadWarning(err error) *LoadWarning {
+	return &LoadWarning{
+		Err: err,
+	}
+}
+
+func (this *LoadWarning) Error() string {
+	return this.Err.Error()
+}
+
+func (this *LoadWarning) Unwrap() error {
+	return this.Err
+}
+
+func IsLoadWarning(err error) bool {
+	var warn *LoadWarning
+	return errors.As(err, &warn)
+}
@@ -1,7 +1,9 @@
 package common
 
 import (
+	"io/fs"
 	"monster/pkg/common/color"
+	"monster/pkg/common/define"
 	"monster/pkg/common/define/widget/slot"
 	"monster/pkg/common/fpoint"
 	"monster/pkg/common/labelinfo"
@@ -42,6 +44,7 @@ type Modules interface {
 
 type Settings interface {
 	LoadSettings(ModManager) error
+	SaveSettings() error
 	LogSettings()
 	Get(string) interface{}
 	Set(string, interface{})
@@ -50,12 +53,14 @@ type Settings interface {
 	SetPathConf(string)
 	SetPathUser(string)
 	SetPathData(string)
+	SetCustomPathData(string)
 	SetSafeVideo(bool)
 	SetViewH(int)
 	SetViewW(int)
 	SetViewHHalf(int)
 	SetViewWHalf(int)
 	SetViewScaling(float32)
+	SetViewOffset(point.Point)
 
 	GetPathConf() string
 	GetPathData() string
@@ -66,6 +71,7 @@ type Settings interface {
 	GetViewHHalf() int
 	GetViewWHalf() int
 	GetViewScaling() float32
+	GetViewOffset() point.Point
 	GetMouseScaled() bool
 	UpdateScreenVars(EngineSettings)
 	GetLoadSlot() string
@@ -106,7 +112,11 @@ type Mod interface {
 type ModManager interface {
 	List(string) ([]string, error)
 	Locate(Settings, string) (string, error)
+	Open(string) (fs.File, error)
+	ReadFile(string) ([]byte, error)
+	Close()
 	ApplyDepends() error
+	GetDependsErrors(string) []string
 	ClearModList()
 	GetModList() []Mod
 	GetModDirs() []string
@@ -151,6 +161,8 @@ type HeroClass interface {
 	GetName() string
 	GetDescription() string
 	GetHeroOptions() []int
+	GetActionbar() []define.PowerId
+	GetEquipment() []define.ItemId
 }
 
 type EngineSettings interface {
@@ -181,7 +193,7 @@ type Animation interface {
 }
 
 type AnimationSet interface {
-	Init(Settings, ModManager, RenderDevice, string) AnimationSet
+	Init(Settings, ModManager, RenderDevice, string) (AnimationSet, error)
 	GetAnimation(string) Animation
 	GetName() string
 	GetAnimationFrameCount(name string) uint16
@@ -195,6 +207,7 @@ type AnimationManager interface {
 	IncreaseCount(string)
 	DecreaseCount(string)
 	GetAnimationSet(Settings, ModManager, RenderDevice, Factory, string) (AnimationSet, error)
+	Preload(Loader, Settings, ModManager, RenderDevice, string) // 在加载协程里解码动画用到的图片
 }
 
 type MessageEngine interface {
@@ -213,6 +226,7 @@ type Renderable interface {
 	SetBlendMode(uint8)
 	SetColorMod(color.Color)
 	SetAlphaMod(uint8)
+	SetEmissive(bool)
 	GetImage() Image
 	GetSrc() rect.Rect
 	GetType() uint8
@@ -222,6 +236,7 @@ type Renderable interface {
 	GetBlendMode() uint8
 	GetColorMod() color.Color
 	GetAlphaMod() uint8
+	IsEmissive() bool
 }
 
 type Sprite interface {
@@ -293,14 +308,27 @@ type RenderDevice interface {
 	CreateImage(width, height int) (Image, error)
 	FreeImage(string)
 	LoadImage(Settings, ModManager, string) (Image, error)
+	PreloadImage(Settings, ModManager, string) error            // 可以在加载协程里调用
+	LoadAtlasImage(Settings, ModManager, string) (Image, error) // 打包进图集，只读
+	GetRenderStats() (drawCalls int, stateChanges int)          // 上一帧
+	GetTextCacheStats() (textHits, textMisses, glyphHits, glyphMisses int)
 	DrawRectangle(p0, p1 point.Point, color color.Color) error
+	DrawLine(x0, y0, x1, y1 int, color color.Color) error
+	DrawFilledRect(r rect.Rect, color color.Color) error                 // 半透明混合
+	DrawFilledTriangles(points []fpoint.FPoint, color color.Color) error // 每三个点一个三角形，半透明混合
 	Render(Sprite) error
 	Render1(r Renderable, dest rect.Rect) error
 	RenderToImage(srcImage Image, src rect.Rect, destImage Image, dest rect.Rect) (rect.Rect, error)
 	RenderTextToImage(fontStyle FontStyle, text string, color color.Color, blended bool) (Image, error)
+	BeginTarget(Image) error // 离屏绘制到图片，可嵌套
+	EndTarget() error
+	Screenshot(filename string) // 下一帧提交时保存成png
+	SetColorblind(bool)
+	FlashScreen(c color.Color, frames int)
 	FillRect() error
 	Curs() CursorManager
 	SetBackgroundColor(color.Color)
+	GetBackgroundColor() color.Color
 	GetRefreshRate() int
 	CreateRenderDeviceList(MessageEngine) ([]string, []string)
 }
@@ -311,6 +339,7 @@ type SDLHardwareRenderDevice interface {
 
 type FontStyle interface {
 	Ttfont() interface{}
+	GetId() uint64
 }
 
 type FontEngine interface {
@@ -326,6 +355,20 @@ type FontEngine interface {
 	Render(RenderDevice, string, int, int, int, Image, int, color.Color) error
 	RenderInternal(RenderDevice, string, int, int, int, Image, color.Color) error
 	RenderShadowed(renderDevice RenderDevice, text string, x, y, justify int, target Image, width int, color color.Color) error
+	GetFont() string
+	HasFont(string) bool
+	SetMarkupSource(FontMarkupSource)
+	CalcMarkupSize(text string, width int) point.Point
+	RenderMarkup(renderDevice RenderDevice, text string, x, y, justify int, target Image, width int, color color.Color) error
+	RenderMarkupShadowed(renderDevice RenderDevice, text string, x, y, justify int, target Image, width int, color color.Color) error
+}
+
+// 富文本里图标和按键的来源
+type FontMarkupSource interface {
+	Eset() EngineSettings
+	Msg() MessageEngine
+	Inpt() InputState
+	Icons() IconManager
 }
 
 type Factory interface {
@@ -342,6 +385,7 @@ type Widget interface {
 	GetTablistNavRight() bool
 	SetTablistNavRight(bool)
 	SetFocusable(bool)
+	GetFocusable() bool
 	SetScrollType(int)
 	GetScrollType() int
 	SetPosX(int)
@@ -369,11 +413,16 @@ type Widget interface {
 type WidgetTablist interface {
 	Close()
 	Init() WidgetTablist
+	Lock()
 	Unlock()
 	GetNext(m Modules, inner bool, dir int) (Widget, bool)
 	GetPrev(m Modules, inner bool, dir int) (Widget, bool)
 	SetIngoreNoMouse(bool)
+	SetScrollType(int)
+	SetInput(left, right, activate int)
 	Add(Widget)
+	Remove(Widget)
+	Clear()
 	Logic(Modules) error
 	GetCurrent() int
 	Defocus()
@@ -398,6 +447,7 @@ type WidgetLabel interface {
 	SetColor(color.Color)
 	SetHidden(bool)
 	SetMaxWidth(int)
+	SetMarkup(bool)
 	GetBounds(Modules) rect.Rect
 	SetFromLabelInfo(labelinfo.LabelInfo)
 }
@@ -454,6 +504,17 @@ type WidgetCheckBox interface {
 	CheckClick(Modules)
 }
 
+type WidgetInput interface {
+	Widget
+	Init(Modules, string) WidgetInput
+	SetText(string)
+	GetText() string
+	SetMaxLength(int)
+	SetEnabled(bool)
+	IsEditing() bool
+	Logic(Modules) bool
+}
+
 type WidgetSlider interface {
 	Widget
 	Init(Modules, string) WidgetSlider
@@ -477,6 +538,7 @@ type WidgetListBox interface {
 	Init(Modules, int, string) WidgetListBox
 	SetScrollbarOffset(int)
 	SetMultiSelect(bool)
+	SetCanDrag(bool)
 	Append(Modules, string, string)
 	SetHeight(Modules, int)
 	Sort()
@@ -503,6 +565,7 @@ type WidgetSlot interface {
 	RenderSelection(modules Modules) error
 	SetAmount(modules Modules, amount, maxAmount int) error
 	SetIcon(iconId int, overlayId slot.CLICK_TYPE)
+	CheckClick(modules Modules) slot.CLICK_TYPE
 }
 
 type InputState interface {
@@ -535,8 +598,20 @@ type InputState interface {
 	GetPressing(int) bool
 	Handle(Modules) error
 	GetBindingName(int) string
+	GetBindingFromName(string) int
 	GetBindingString(msg MessageEngine, key int, getShortString bool) string
+	GetMovementString(Settings, MessageEngine) string
+	GetAttackString(MessageEngine) string
 	GetRefreshHotkeys() bool
+	StartTextInput()
+	StopTextInput()
+	SetTextInputRect(Settings, rect.Rect)
+	GetInKeys() string
+	GetTextEdit() []int
+	GetComposition() (string, int)
+	GetClipboardText() string
+	SetClipboardText(string)
+	GetJoystickCount() int
 }
 
 type Tooltipm interface {
@@ -555,6 +630,18 @@ type IconManager interface {
 	GetTextOffset() point.Point
 }
 
+// 异步加载
+type Loader interface {
+	Add(work, finish func() error)
+	OnDone(func() error)
+	Logic() (bool, error)
+	Wait() error
+	GetCounter() int
+	GetTotal() int
+	GetProgress() float32
+	Close()
+}
+
 //  衔接
 
 type GameSwitcher interface {
@@ -702,6 +702,27 @@ func (this *HeroClass) GetHeroOptions() []int {
 	return tmp
 }
 
+// 职业默认的动作栏
+func (this *HeroClass) GetActionbar() []define.PowerId {
+	tmp := make([]define.PowerId, len(this.actionbar))
+	copy(tmp, this.actionbar)
+	return tmp
+}
+
+// 职业初始装备的物品
+func (this *HeroClass) GetEquipment() []define.ItemId {
+	var list []define.ItemId
+	id, strVal := parsing.PopFirstInt(this.equipment, "")
+	for id > 0 || strVal != "" {
+		if id > 0 {
+			list = append(list, (define.ItemId)(id))
+		}
+		id, strVal = parsing.PopFirstInt(strVal, "")
+	}
+
+	return list
+}
+
 func (this *HeroClasses) get(key string) []common.HeroClass {
 	if key == "list" {
 		tmpList := make([]common.HeroClass, len(this.list))
@@ -772,8 +793,11 @@ func (this *HeroClasses) load(settings common.Settings, mods common.ModManager,
 			}
 			this.list[lenList-1].primary[primStatIndex] = parsing.ToInt(strVal, 0)
 		case "actionbar":
+			strVal := infile.Val()
 			for i := 0; i < actionbar.SLOT_MAX; i++ {
-				this.list[lenList-1].actionbar[i] = parsing.ToPowerId(infile.Val(), 0)
+				var first string
+				first, strVal = parsing.PopFirstString(strVal, "")
+				this.list[lenList-1].actionbar[i] = parsing.ToPowerId(first, 0)
 			}
 		case "powers":
 			power := ""
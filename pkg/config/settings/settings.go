@@ -4,12 +4,15 @@ import (
 	"math"
 	"monster/pkg/common"
 	"monster/pkg/common/define/enginesettings"
+	"monster/pkg/common/point"
 	"monster/pkg/filesystem/fileparser"
 	"monster/pkg/utils/parsing"
 
 	"monster/pkg/filesystem/logfile"
+	"os"
 	"reflect"
 	"strconv"
+	"strings"
 )
 
 type ConfigEntry struct {
@@ -39,6 +42,7 @@ type Settings struct {
 	viewWHalf   int
 	viewHHalf   int
 	viewScaling float32
+	viewOffset  point.Point // 画面在窗口里的偏移，窗口坐标，黑边的宽度
 
 	mouseScaled   bool
 	showHud       bool
@@ -56,7 +60,7 @@ func New() *Settings {
 		viewWHalf:   0,
 		viewHHalf:   0,
 		viewScaling: 1.0,
-		mouseScaled: true,
+		mouseScaled: false, // 由InputState按视口换算
 		showHud:     true,
 	}
 
@@ -104,6 +108,8 @@ func New() *Settings {
 	s.setConfigDefault(41, &ConfigEntry{"max_render_size", "0", int(0), "Overrides the maximum height (in pixels) of the internal render surface | 0 = ignore this setting"})
 	s.setConfigDefault(42, &ConfigEntry{"touch_controls", "0", false, "Enables touch screen controls | 0 = disable, 1 = enable"})
 	s.setConfigDefault(43, &ConfigEntry{"touch_scale", "1.0", float32(0), "Factor used to scale the touch controls | 1.0 = 100 percent scale"})
+	s.setConfigDefault(44, &ConfigEntry{"virtual_resolution", "0", false, "Render at a fixed internal height (max_render_size) and letterbox to the window | 0 = disable, 1 = enable"})
+	s.setConfigDefault(45, &ConfigEntry{"ui_scale", "1.0", float32(0), "Factor used to scale the interface, useful on HiDPI screens | 1.0 = 100 percent scale"})
 
 	return s
 }
@@ -125,7 +131,9 @@ func (this *Settings) LoadSettings(mods common.ModManager) error {
 	foundSettings = true
 
 	if !foundSettings {
-		this.saveSettings()
+		if err := this.SaveSettings(); err != nil {
+			return err
+		}
 	} else {
 		for infile.Next(mods) {
 			if entry, ok := this.settings[infile.Key()]; ok {
@@ -146,8 +154,33 @@ func (this *Settings) LoadSettings(mods common.ModManager) error {
 	return nil
 }
 
-func (this *Settings) saveSettings() {
-	//TODO
+// 保存到 conf_path/settings.txt
+func (this *Settings) SaveSettings() error {
+	f, err := os.OpenFile(this.pathConf+"settings.txt", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var sb strings.Builder
+	sb.WriteString("## monster settings file ##\n")
+	sb.WriteString("## A blank value will use the default value ##\n")
+
+	for i := 0; i < len(this.config); i++ {
+		conf, ok := this.config[i]
+		if !ok {
+			continue
+		}
+
+		sb.WriteString("\n")
+		if conf.comment != "" {
+			sb.WriteString("# " + conf.comment + "\n")
+		}
+		sb.WriteString(conf.name + "=" + this.configValueToString(conf.storage) + "\n")
+	}
+
+	_, err = f.WriteString(sb.String())
+	return err
 }
 
 func (this *Settings) loadMobileDefault() {
@@ -246,6 +279,10 @@ func (this *Settings) GetCustomPathData() string {
 	return this.customPathData
 }
 
+func (this *Settings) SetCustomPathData(s string) {
+	this.customPathData = s
+}
+
 func (this *Settings) SetSafeVideo(val bool) {
 	this.safeVideo = true
 	return
@@ -304,6 +341,14 @@ func (this *Settings) GetViewScaling() float32 {
 	return this.viewScaling
 }
 
+func (this *Settings) SetViewOffset(val point.Point) {
+	this.viewOffset = val
+}
+
+func (this *Settings) GetViewOffset() point.Point {
+	return this.viewOffset
+}
+
 func (this *Settings) GetShowHud() bool {
 	return this.showHud
 }
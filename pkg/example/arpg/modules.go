@@ -67,6 +67,10 @@ func (this *Modules) Mods() common.ModManager {
 }
 
 func (this *Modules) NewMods(platform common.Platform, settings common.Settings, modList []string) common.ModManager {
+	if this.mods != nil {
+		this.mods.Close()
+	}
+
 	this.mods = modmanager.New(platform, settings, modList)
 	return this.mods
 }
@@ -90,6 +94,7 @@ func (this *Modules) NewFont(settings common.Settings, mods common.ModManager) c
 	}
 
 	this.font = sdlfont.NewFontEngine(settings, mods)
+	this.font.SetMarkupSource(this)
 
 	return this.font
 }
@@ -2,13 +2,22 @@ package fileparser
 
 import (
 	"bufio"
+	"fmt"
 	"io/fs"
 	"monster/pkg/common"
+	"monster/pkg/filesystem/logfile"
 	"monster/pkg/utils/parsing"
-	"os"
 	"strings"
 )
 
+// 非空时加载错误都交给它，加载器跳过出错的行继续解析
+// modlint用它收集全部错误，只能在加载开始前设置
+var errorHandler func(error)
+
+func SetErrorHandler(f func(error)) {
+	errorHandler = f
+}
+
 // APPEND: combine files (需要合并的文件)
 // section: 文件里: [section]
 
@@ -17,7 +26,7 @@ type FileParser struct {
 	filenames    []string
 	currentIndex uint32
 	lineNumber   uint32
-	infile       *os.File
+	infile       fs.File
 	includeFp    *FileParser
 	section      string
 	newSection   bool
@@ -63,7 +72,7 @@ func (this *FileParser) Open(filename string, isModFile bool, mods common.ModMan
 	// 逆序从其他mod到default 找到文件位置
 	// 找到第一个非append的文件
 	for i := len(this.filenames); i > 0; i-- {
-		if f, err := os.Open(this.filenames[i-1]); err == nil {
+		if f, err := mods.Open(this.filenames[i-1]); err == nil {
 			this.infile = f
 			scanner := bufio.NewScanner(this.infile)
 			if scanner.Scan() && strings.TrimSpace(scanner.Text()) != "APPEND" {
@@ -84,8 +93,10 @@ func (this *FileParser) Open(filename string, isModFile bool, mods common.ModMan
 
 				if testLine != "APPEND" {
 					this.currentIndex = (uint32)(i - 1)
-					if _, err = this.infile.Seek(0, 0); err != nil {
-						this.infile.Close()
+
+					// 重新打开 从头读取 (zip包内的文件不能Seek)
+					this.infile.Close()
+					if this.infile, err = mods.Open(this.filenames[i-1]); err != nil {
 						this.infile = nil
 						return err
 					}
@@ -189,7 +200,7 @@ func (this *FileParser) Next(mods common.ModManager) bool {
 					if err := this.includeFp.Open(tmp, this.isModFile, mods); err != nil {
 						this.includeFp.Close()
 						this.includeFp = nil
-						panic(err)
+						panic(this.Errorf("INCLUDE '%s': %w", tmp, err))
 					}
 
 					this.includeFp.section = this.section
@@ -202,7 +213,7 @@ func (this *FileParser) Next(mods common.ModManager) bool {
 		}
 
 		if err := this.scanner.Err(); err != nil {
-			panic(err)
+			panic(this.Error(err))
 		}
 
 		this.infile.Close()
@@ -217,7 +228,7 @@ func (this *FileParser) Next(mods common.ModManager) bool {
 		this.lineNumber = 0
 		currentFilename := this.filenames[this.currentIndex]
 
-		if f, err := os.Open(currentFilename); err != nil {
+		if f, err := mods.Open(currentFilename); err != nil {
 			return false
 		} else {
 			this.infile = f
@@ -258,3 +269,68 @@ func (this *FileParser) GetRawLine() string {
 func (this *FileParser) IncrementLineNum() {
 	this.lineNumber++
 }
+
+// 当前正在解析的文件
+func (this *FileParser) GetFilename() string {
+	if this.includeFp != nil && this.includeFp.infile != nil {
+		return this.includeFp.GetFilename()
+	}
+
+	if (int)(this.currentIndex) < len(this.filenames) {
+		return this.filenames[this.currentIndex]
+	}
+
+	return ""
+}
+
+// 当前正在解析的行号
+func (this *FileParser) GetLineNumber() uint32 {
+	if this.includeFp != nil && this.includeFp.infile != nil {
+		return this.includeFp.GetLineNumber()
+	}
+
+	return this.lineNumber
+}
+
+// 带上当前位置的加载错误
+func (this *FileParser) Errorf(format string, args ...interface{}) error {
+	return this.Error(fmt.Errorf(format, args...))
+}
+
+// 给错误加上当前位置，已经是加载错误则保持不变
+func (this *FileParser) Error(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := common.AsLoadError(err); ok {
+		return err
+	}
+
+	return common.NewLoadError(this.GetFilename(), this.GetLineNumber(), this.GetSection(), this.Key(), err)
+}
+
+// 报告当前行的加载错误，返回nil表示已经处理，加载器应跳过这一行继续
+// 提示类错误只记录，其它错误没有设置处理函数时原样返回
+func (this *FileParser) Report(err error) error {
+	err = this.Error(err)
+	if err == nil {
+		return nil
+	}
+
+	if errorHandler != nil {
+		errorHandler(err)
+		return nil
+	}
+
+	if common.IsLoadWarning(err) {
+		logfile.LogError("%s", err)
+		return nil
+	}
+
+	return err
+}
+
+func (this *FileParser) Reportf(format string, args ...interface{}) error {
+	return this.Report(fmt.Errorf(format, args...))
+}
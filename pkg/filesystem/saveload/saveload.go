@@ -14,11 +14,18 @@ func new() *SaveLoad {
 	return &SaveLoad{}
 }
 
-func (this *SaveLoad) saveGame() {
-	if this.gameSlot <= 0 {
-		return
-	}
+// 存档根目录 saves/<save_prefix>
+func SaveRoot(settings common.Settings, eset common.EngineSettings) string {
+	return settings.GetPathUser() + "saves/" + eset.Get("misc", "save_prefix").(string)
+}
+
+// 当前游戏使用的存档序号，0表示还没有
+func GetGameSlot() int {
+	return defaultSaveLoad.gameSlot
+}
 
+func SetGameSlot(slot int) {
+	defaultSaveLoad.gameSlot = slot
 }
 
 func (this *SaveLoad) createSaveDir(slot int, settings common.Settings) error {
@@ -0,0 +1,366 @@
+package saveload
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+ * 存档目录 saves/<save_prefix>/<N>/，N从1开始
+ * 每次覆盖存档前，旧文件复制到 <N>/backup/，只保留一份
+ */
+
+const (
+	AVATAR_FILE    = "avatar.txt"
+	ACTIONBAR_FILE = "actionbar.txt" // 技能栏布局
+	BACKUP_DIR     = "backup"
+)
+
+func SlotPath(root string, id int) string {
+	return filepath.Join(root, strconv.Itoa(id))
+}
+
+func BackupPath(root string, id int) string {
+	return filepath.Join(SlotPath(root, id), BACKUP_DIR)
+}
+
+// 全部存档序号，从小到大
+func ListSlots(root string) ([]int, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var ids []int
+	for _, e := range entries {
+		id, err := strconv.Atoi(e.Name())
+		if err != nil || id < 1 || strconv.Itoa(id) != e.Name() {
+			continue
+		}
+
+		if fi, err := os.Stat(filepath.Join(root, e.Name())); err == nil && fi.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// 第一个未被占用的序号，同名的文件也算占用
+func NextFreeSlot(root string) (int, error) {
+	for id := 1; ; id++ {
+		_, err := os.Lstat(SlotPath(root, id))
+		if os.IsNotExist(err) {
+			return id, nil
+		} else if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// 序号有效且目录存在，返回目录和它本身的信息
+func checkSlot(root string, id int) (string, os.FileInfo, error) {
+	if id < 1 {
+		return "", nil, fmt.Errorf("SaveLoad: invalid slot %d.", id)
+	}
+
+	path := SlotPath(root, id)
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if fi.Mode()&os.ModeSymlink == 0 && !fi.IsDir() {
+		return "", nil, fmt.Errorf("SaveLoad: slot %d is not a directory.", id)
+	}
+
+	return path, fi, nil
+}
+
+// 删除存档，先改名再删除，中途失败也不会留下半个存档
+func DeleteSlot(root string, id int) error {
+	path, fi, err := checkSlot(root, id)
+	if err != nil {
+		return err
+	}
+
+	// 链接只删除链接本身
+	if fi.Mode()&os.ModeSymlink != 0 {
+		return os.Remove(path)
+	}
+
+	trash := path + ".deleted"
+	os.RemoveAll(trash)
+
+	err = os.Rename(path, trash)
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(trash)
+}
+
+// 复制存档到下一个空序号，不复制备份
+func CopySlot(root string, id int) (int, error) {
+	src, _, err := checkSlot(root, id)
+	if err != nil {
+		return 0, err
+	}
+
+	dest, err := NextFreeSlot(root)
+	if err != nil {
+		return 0, err
+	}
+
+	tmp := SlotPath(root, dest) + ".tmp"
+	os.RemoveAll(tmp)
+
+	err = copyDir(src, tmp, BACKUP_DIR)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return 0, err
+	}
+
+	err = os.Rename(tmp, SlotPath(root, dest))
+	if err != nil {
+		os.RemoveAll(tmp)
+		return 0, err
+	}
+
+	return dest, nil
+}
+
+// 用当前文件替换备份
+func BackupSlot(root string, id int) error {
+	src, _, err := checkSlot(root, id)
+	if err != nil {
+		return err
+	}
+
+	backup := BackupPath(root, id)
+	tmp := backup + ".tmp"
+	os.RemoveAll(tmp)
+
+	err = copyDir(src, tmp, BACKUP_DIR, BACKUP_DIR+".tmp")
+	if err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+
+	err = os.RemoveAll(backup)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, backup)
+}
+
+// 存档已经写过角色文件，只有目录的序号不算
+func HasSave(root string, id int) bool {
+	if id < 1 {
+		return false
+	}
+
+	fi, err := os.Stat(filepath.Join(SlotPath(root, id), AVATAR_FILE))
+	return err == nil && !fi.IsDir()
+}
+
+func HasBackup(root string, id int) bool {
+	fi, err := os.Stat(filepath.Join(BackupPath(root, id), AVATAR_FILE))
+	return err == nil && !fi.IsDir()
+}
+
+// 用备份替换当前文件，备份保留
+// 先在<N>.tmp里拼好新存档再交换目录，中途失败原存档不变
+func RestoreBackup(root string, id int) error {
+	path, fi, err := checkSlot(root, id)
+	if err != nil {
+		return err
+	}
+
+	if !HasBackup(root, id) {
+		return fmt.Errorf("SaveLoad: slot %d has no backup.", id)
+	}
+
+	backup := BackupPath(root, id)
+
+	// 链接保留，交换它指向的目录
+	if fi.Mode()&os.ModeSymlink != 0 {
+		path, err = filepath.EvalSymlinks(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	tmp := path + ".tmp"
+	old := path + ".old"
+	os.RemoveAll(tmp)
+	os.RemoveAll(old)
+
+	err = copyDir(backup, tmp)
+	if err == nil {
+		err = copyDir(backup, filepath.Join(tmp, BACKUP_DIR))
+	}
+
+	if err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+
+	err = os.Rename(path, old)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+
+	err = os.Rename(tmp, path)
+	if err != nil {
+		// 换回原存档
+		os.Rename(old, path)
+		os.RemoveAll(tmp)
+		return err
+	}
+
+	return os.RemoveAll(old)
+}
+
+// 备份后修改角色名
+func RenameHero(root string, id int, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.ContainsAny(name, "\r\n") {
+		return fmt.Errorf("SaveLoad: invalid hero name.")
+	}
+
+	path, _, err := checkSlot(root, id)
+	if err != nil {
+		return err
+	}
+
+	filename := filepath.Join(path, AVATAR_FILE)
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	found := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !found && strings.HasPrefix(line, "name=") {
+			line = "name=" + name
+			found = true
+		}
+
+		out.WriteString(line + "\n")
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if !found {
+		return fmt.Errorf("SaveLoad: slot %d has no hero name.", id)
+	}
+
+	return WriteSlotFile(root, id, AVATAR_FILE, out.Bytes())
+}
+
+// 写入存档里的文件，先备份整个存档
+func WriteSlotFile(root string, id int, name string, data []byte) error {
+	path, _, err := checkSlot(root, id)
+	if err != nil {
+		return err
+	}
+
+	err = BackupSlot(root, id)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(filepath.Join(path, name), data)
+}
+
+// 先写临时文件再替换
+func writeFile(filename string, data []byte) error {
+	tmp := filename + ".tmp"
+	err := os.WriteFile(tmp, data, 0666)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filename)
+}
+
+func copyDir(src, dest string, skip ...string) error {
+	err := os.MkdirAll(dest, 0777)
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		skipped := false
+		for _, s := range skip {
+			if e.Name() == s {
+				skipped = true
+				break
+			}
+		}
+
+		if skipped {
+			continue
+		}
+
+		from := filepath.Join(src, e.Name())
+		to := filepath.Join(dest, e.Name())
+
+		if e.IsDir() {
+			err = copyDir(from, to)
+		} else {
+			err = copyFile(from, to)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
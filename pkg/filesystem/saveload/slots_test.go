@@ -0,0 +1,91 @@
+package saveload
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeSlot(r *require.Assertions, root string, id int, avatar string) {
+	r.NoError(os.MkdirAll(SlotPath(root, id), 0777))
+	r.NoError(os.WriteFile(filepath.Join(SlotPath(root, id), AVATAR_FILE), []byte(avatar), 0666))
+}
+
+func readAvatar(r *require.Assertions, root string, id int) string {
+	data, err := os.ReadFile(filepath.Join(SlotPath(root, id), AVATAR_FILE))
+	r.NoError(err)
+	return string(data)
+}
+
+func Test_slots(t *testing.T) {
+	r := require.New(t)
+
+	root := t.TempDir()
+	writeSlot(r, root, 1, "name=Alice\nxp=10\n")
+	writeSlot(r, root, 3, "name=Bob\n")
+	r.NoError(os.MkdirAll(filepath.Join(root, "01"), 0777))
+	r.NoError(os.WriteFile(filepath.Join(root, "2"), nil, 0666))
+
+	ids, err := ListSlots(root)
+	r.NoError(err)
+	r.Equal([]int{1, 3}, ids)
+
+	next, err := NextFreeSlot(root)
+	r.NoError(err)
+	r.Equal(4, next) // 2是文件
+
+	r.True(HasSave(root, 1))
+	r.False(HasSave(root, 2)) // 2是文件
+	r.False(HasSave(root, 0))
+
+	// 改名前自动备份
+	r.False(HasBackup(root, 1))
+	r.NoError(RenameHero(root, 1, " Carol "))
+	r.Equal("name=Carol\nxp=10\n", readAvatar(r, root, 1))
+	r.True(HasBackup(root, 1))
+	r.Error(RenameHero(root, 1, "a\nb"))
+
+	// 复制不带备份
+	dest, err := CopySlot(root, 1)
+	r.NoError(err)
+	r.Equal(4, dest)
+	r.Equal("name=Carol\nxp=10\n", readAvatar(r, root, 4))
+	r.False(HasBackup(root, 4))
+
+	// 损坏后恢复
+	r.NoError(os.WriteFile(filepath.Join(SlotPath(root, 1), AVATAR_FILE), []byte("garbage"), 0666))
+	r.NoError(os.WriteFile(filepath.Join(SlotPath(root, 1), "extra.txt"), nil, 0666))
+	r.NoError(RestoreBackup(root, 1))
+	r.Equal("name=Alice\nxp=10\n", readAvatar(r, root, 1))
+	r.True(HasBackup(root, 1)) // 备份保留
+	r.NoFileExists(filepath.Join(SlotPath(root, 1), "extra.txt"))
+	r.Error(RestoreBackup(root, 3))
+
+	// 写入前备份整个存档
+	r.NoError(WriteSlotFile(root, 4, ACTIONBAR_FILE, []byte("actionbar=1\n")))
+	r.True(HasBackup(root, 4))
+	r.NoFileExists(filepath.Join(BackupPath(root, 4), ACTIONBAR_FILE))
+	r.NoError(WriteSlotFile(root, 4, ACTIONBAR_FILE, []byte("actionbar=2\n")))
+	data, err := os.ReadFile(filepath.Join(BackupPath(root, 4), ACTIONBAR_FILE))
+	r.NoError(err)
+	r.Equal("actionbar=1\n", string(data))
+	r.Error(WriteSlotFile(root, 2, ACTIONBAR_FILE, nil))
+
+	r.NoError(DeleteSlot(root, 3))
+	ids, err = ListSlots(root)
+	r.NoError(err)
+	r.Equal([]int{1, 4}, ids)
+
+	next, err = NextFreeSlot(root)
+	r.NoError(err)
+	r.Equal(3, next)
+
+	r.Error(DeleteSlot(root, 0))
+	r.Error(DeleteSlot(root, 3))
+
+	entries, err := os.ReadDir(root)
+	r.NoError(err)
+	r.Len(entries, 4) // 1 4 01 2
+}
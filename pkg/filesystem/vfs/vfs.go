@@ -0,0 +1,155 @@
+// 虚拟文件系统
+//
+// 每个mod一个fs.FS，目录和zip包用同样的方式访问。
+// 对外仍然使用完整路径，zip包内的文件路径为: .../mods/name.zip/images/x.png
+package vfs
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	ZIP_EXT = ".zip"
+)
+
+// 一个挂载的mod
+type ModFS struct {
+	fs.FS
+	root   string    // 路径前缀
+	closer io.Closer // zip包需要关闭
+}
+
+// 目录形式的mod
+func OpenDir(root string) *ModFS {
+	return &ModFS{
+		FS:   os.DirFS(root),
+		root: root,
+	}
+}
+
+// zip包形式的mod
+func OpenZip(root string) (*ModFS, error) {
+	r, err := zip.OpenReader(root)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ModFS{
+		FS:     r,
+		root:   root,
+		closer: r,
+	}, nil
+}
+
+func (this *ModFS) GetRoot() string {
+	return this.root
+}
+
+// mod内的相对路径转为完整路径
+func (this *ModFS) Path(name string) string {
+	return this.root + "/" + name
+}
+
+// 文件是否存在(不包括目录)
+func (this *ModFS) FileExists(name string) (bool, error) {
+	fi, err := fs.Stat(this, name)
+	if err != nil && isNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return !fi.IsDir(), nil
+}
+
+// 是否是目录
+func (this *ModFS) IsDirectory(name string) (bool, error) {
+	fi, err := fs.Stat(this, name)
+	if err != nil && isNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return fi.IsDir(), nil
+}
+
+func (this *ModFS) Close() error {
+	if this.closer != nil {
+		err := this.closer.Close()
+		this.closer = nil
+		return err
+	}
+
+	return nil
+}
+
+// ==================== vfs ===================
+// 可以在加载协程里并发读取
+type VFS struct {
+	mu     sync.RWMutex
+	mounts []*ModFS
+}
+
+func New() *VFS {
+	return &VFS{}
+}
+
+func (this *VFS) Mount(m *ModFS) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.mounts = append(this.mounts, m)
+}
+
+// 完整路径找到所属的mod
+func (this *VFS) resolve(name string) (*ModFS, string, bool) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	for _, m := range this.mounts {
+		if strings.HasPrefix(name, m.root+"/") {
+			return m, name[len(m.root)+1:], true
+		}
+	}
+
+	return nil, "", false
+}
+
+// 打开完整路径的文件，不在mod内的直接从磁盘打开
+func (this *VFS) Open(name string) (fs.File, error) {
+	if m, rel, ok := this.resolve(name); ok {
+		return m.Open(rel)
+	}
+
+	return os.Open(name)
+}
+
+func (this *VFS) ReadFile(name string) ([]byte, error) {
+	if m, rel, ok := this.resolve(name); ok {
+		return fs.ReadFile(m, rel)
+	}
+
+	return os.ReadFile(name)
+}
+
+func (this *VFS) Close() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	for _, m := range this.mounts {
+		m.Close()
+	}
+
+	this.mounts = nil
+}
+
+func isNotExist(err error) bool {
+	return errors.Is(err, fs.ErrNotExist)
+}
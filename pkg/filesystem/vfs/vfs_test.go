@@ -0,0 +1,71 @@
+package vfs
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_zip_and_dir(t *testing.T) {
+	r := require.New(t)
+
+	tmp := t.TempDir()
+
+	// 目录形式
+	dirRoot := filepath.Join(tmp, "mods", "base")
+	r.NoError(os.MkdirAll(filepath.Join(dirRoot, "items"), 0777))
+	r.NoError(os.WriteFile(filepath.Join(dirRoot, "items", "items.txt"), []byte("dir"), 0666))
+
+	// zip包形式
+	zipRoot := filepath.Join(tmp, "mods", "extra"+ZIP_EXT)
+	f, err := os.Create(zipRoot)
+	r.NoError(err)
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("items/items.txt")
+	r.NoError(err)
+	_, err = w.Write([]byte("APPEND\nzip"))
+	r.NoError(err)
+	r.NoError(zw.Close())
+	r.NoError(f.Close())
+
+	v := New()
+	defer v.Close()
+
+	d := OpenDir(dirRoot)
+	v.Mount(d)
+	z, err := OpenZip(zipRoot)
+	r.NoError(err)
+	v.Mount(z)
+
+	ok, err := z.FileExists("items/items.txt")
+	r.NoError(err)
+	r.True(ok)
+
+	ok, err = z.IsDirectory("items")
+	r.NoError(err)
+	r.True(ok)
+
+	ok, err = z.FileExists("items/missing.txt")
+	r.NoError(err)
+	r.False(ok)
+
+	data, err := v.ReadFile(d.Path("items/items.txt"))
+	r.NoError(err)
+	r.Equal("dir", string(data))
+
+	file, err := v.Open(z.Path("items/items.txt"))
+	r.NoError(err)
+	data, err = io.ReadAll(file)
+	r.NoError(err)
+	r.NoError(file.Close())
+	r.Equal("APPEND\nzip", string(data))
+
+	// 不在mod内的直接读磁盘
+	data, err = v.ReadFile(filepath.Join(dirRoot, "items", "items.txt"))
+	r.NoError(err)
+	r.Equal("dir", string(data))
+}
@@ -0,0 +1,434 @@
+package base
+
+import (
+	"fmt"
+	"monster/pkg/common"
+	"monster/pkg/common/define"
+	"monster/pkg/common/define/widget/button"
+	"monster/pkg/common/define/widget/checkbox"
+	"monster/pkg/common/define/widget/input"
+	"monster/pkg/common/define/widget/listbox"
+	"monster/pkg/common/define/widget/slider"
+	"monster/pkg/common/rect"
+	"monster/pkg/filesystem/fileparser"
+	"monster/pkg/utils/parsing"
+	"sort"
+)
+
+/*
+ * 菜单文件里的组件布局，每个组件一个[widget]段落
+ * 相同id的段落会合并，mod可以用APPEND修改已有组件或添加新组件
+ *
+ * [widget]
+ * id=button_ok
+ * type=button                    # 新建组件，省略则修改代码里注册的同名组件
+ * pos=x,y,align                  # 相对窗口，指定了对齐方式则相对屏幕
+ * label=x,y,justify,valign,style # 文字组件的位置
+ * text=OK
+ * gfx=images/menus/buttons/button_default.png
+ * tooltip=...
+ * tab_order=1                    # 小的在前，-1不加入tablist
+ * hidden=false
+ * enabled=true
+ * height=5                       # listbox的行数
+ * max_length=20                  # input的最大长度
+ *
+ * 代码自己绘制的组件用RegisterExternal注册，布局只修改位置、文字等，
+ * 不负责绘制、对齐、关闭和tablist，hidden和tab_order对它们无效
+ */
+
+type layoutEntry struct {
+	id   string
+	keys [][2]string
+}
+
+type layoutWidget struct {
+	id       string
+	widget   common.Widget
+	hidden   bool
+	tabOrder int
+	external bool // 由代码自己绘制和关闭
+}
+
+type MenuLayout struct {
+	entries []*layoutEntry
+	current *layoutEntry // 正在解析的段落
+	widgets []*layoutWidget
+}
+
+// 关闭布局创建和注册的组件，外部组件由代码自己关闭
+func (this *MenuLayout) Close() {
+	for _, lw := range this.widgets {
+		if !lw.external {
+			lw.widget.Close()
+		}
+	}
+
+	this.widgets = nil
+	this.entries = nil
+	this.current = nil
+}
+
+func (this *MenuLayout) find(id string) *layoutWidget {
+	for _, lw := range this.widgets {
+		if lw.id == id {
+			return lw
+		}
+	}
+
+	return nil
+}
+
+func (this *MenuLayout) register(id string, w common.Widget, external bool) error {
+	if this.find(id) != nil {
+		return fmt.Errorf("Menu: widget '%s' already registered.", id)
+	}
+
+	this.widgets = append(this.widgets, &layoutWidget{id: id, widget: w, external: external})
+	return nil
+}
+
+// 注册代码创建的组件，菜单文件可以修改它的位置等，之后由布局负责绘制和关闭
+func (this *MenuLayout) Register(id string, w common.Widget) error {
+	return this.register(id, w, false)
+}
+
+// 注册代码自己绘制的组件，菜单文件只能修改它的位置等
+func (this *MenuLayout) RegisterExternal(id string, w common.Widget) error {
+	return this.register(id, w, true)
+}
+
+// 解析[widget]段落的键值，Build时再创建组件
+// 返回的错误是加载器需要中止的错误，可以跳过的已经报告
+func (this *MenuLayout) ParseKey(infile *fileparser.FileParser) (bool, error) {
+	if infile.GetSection() != "widget" {
+		return false, nil
+	}
+
+	err := this.parseKey(infile.IsNewSection(), infile.Key(), infile.Val())
+	if err != nil {
+		return true, infile.Reportf("Menu: %s", err)
+	}
+
+	return true, nil
+}
+
+func (this *MenuLayout) parseKey(newSection bool, key, val string) error {
+	if newSection {
+		this.current = nil
+	}
+
+	if key == "id" {
+		this.current = nil
+		for _, entry := range this.entries {
+			if entry.id == val {
+				this.current = entry
+				break
+			}
+		}
+
+		if this.current == nil {
+			this.current = &layoutEntry{id: val}
+			this.entries = append(this.entries, this.current)
+		}
+
+		return nil
+	}
+
+	if this.current == nil {
+		return fmt.Errorf("widget '%s' has no id.", key)
+	}
+
+	this.current.keys = append(this.current.keys, [2]string{key, val})
+	return nil
+}
+
+// 按解析的段落创建或修改组件，再按tab_order加入tablist，tablist为空则不加入
+func (this *MenuLayout) Build(modules common.Modules, tablist common.WidgetTablist) error {
+	entries := this.entries
+	this.entries = nil
+	this.current = nil
+
+	for _, entry := range entries {
+		lw := this.find(entry.id)
+		if lw == nil {
+			w, err := newLayoutWidget(modules, entry)
+			if err != nil {
+				return err
+			}
+
+			lw = &layoutWidget{id: entry.id, widget: w}
+			this.widgets = append(this.widgets, lw)
+		}
+
+		err := applyLayoutKeys(modules, lw, entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	if tablist == nil {
+		return nil
+	}
+
+	for _, lw := range this.getFocusable() {
+		tablist.Add(lw.widget)
+	}
+
+	return nil
+}
+
+// 按tab_order排序的可选中组件
+func (this *MenuLayout) getFocusable() []*layoutWidget {
+	var focusable []*layoutWidget
+	for _, lw := range this.widgets {
+		if _, ok := lw.widget.(common.WidgetLabel); ok {
+			continue
+		}
+
+		if lw.external || lw.hidden || lw.tabOrder < 0 {
+			continue
+		}
+
+		focusable = append(focusable, lw)
+	}
+
+	sort.SliceStable(focusable, func(i, j int) bool {
+		return focusable[i].tabOrder < focusable[j].tabOrder
+	})
+
+	return focusable
+}
+
+func newLayoutWidget(modules common.Modules, entry *layoutEntry) (common.Widget, error) {
+	type1 := ""
+	gfx := ""
+	height := 1
+	for _, kv := range entry.keys {
+		switch kv[0] {
+		case "type":
+			type1 = kv[1]
+		case "gfx":
+			gfx = kv[1]
+		case "height":
+			height = parsing.ToInt(kv[1], 1)
+		}
+	}
+
+	if type1 == "" {
+		return nil, fmt.Errorf("Menu: widget '%s' has no type.", entry.id)
+	}
+
+	defaultGfx := func(filename string) string {
+		if gfx != "" {
+			return gfx
+		}
+		return filename
+	}
+
+	widgetf := modules.Widgetf()
+
+	switch type1 {
+	case "button":
+		return widgetf.New("button").(common.WidgetButton).Init(modules, defaultGfx(button.DEFAULT_FILE)), nil
+	case "label":
+		return widgetf.New("label").(common.WidgetLabel).Init(modules), nil
+	case "checkbox":
+		return widgetf.New("checkbox").(common.WidgetCheckBox).Init(modules, defaultGfx(checkbox.DEFAULT_FILE)), nil
+	case "slider":
+		return widgetf.New("slider").(common.WidgetSlider).Init(modules, defaultGfx(slider.DEFAULT_FILE)), nil
+	case "input":
+		return widgetf.New("input").(common.WidgetInput).Init(modules, defaultGfx(input.DEFAULT_FILE)), nil
+	case "listbox":
+		return widgetf.New("listbox").(common.WidgetListBox).Init(modules, height, defaultGfx(listbox.DEFAULT_FILE)), nil
+	case "horizontallist":
+		return widgetf.New("horizontallist").(common.WidgetHorizontalList).Init(modules), nil
+	}
+
+	return nil, fmt.Errorf("Menu: '%s' is not a valid widget type.", type1)
+}
+
+func applyLayoutKeys(modules common.Modules, lw *layoutWidget, entry *layoutEntry) error {
+	for _, kv := range entry.keys {
+		key, val := kv[0], kv[1]
+
+		switch key {
+		case "type", "gfx", "height":
+			// 创建时已处理
+		case "pos":
+			x, strVal := parsing.PopFirstInt(val, "")
+			y, strVal := parsing.PopFirstInt(strVal, "")
+			align, _ := parsing.PopFirstString(strVal, "")
+			lw.widget.SetPosBase(x, y, parsing.ToAlignment(align, lw.widget.GetAlignment()))
+		case "label":
+			if l, ok := lw.widget.(common.WidgetLabel); ok {
+				l.SetFromLabelInfo(parsing.PopLabelInfo(val))
+			} else {
+				return fmt.Errorf("Menu: widget '%s' is not a label.", lw.id)
+			}
+		case "text":
+			switch w := lw.widget.(type) {
+			case common.WidgetLabel:
+				w.SetText(modules.Msg().Get(val))
+			case common.WidgetButton:
+				w.SetLabel(modules, modules.Msg().Get(val))
+			default:
+				return fmt.Errorf("Menu: widget '%s' has no text.", lw.id)
+			}
+		case "tooltip":
+			if w, ok := lw.widget.(interface{ SetTooltip(string) }); ok {
+				w.SetTooltip(modules.Msg().Get(val))
+			} else {
+				return fmt.Errorf("Menu: widget '%s' has no tooltip.", lw.id)
+			}
+		case "tab_order":
+			lw.tabOrder = parsing.ToInt(val, 0)
+		case "hidden":
+			lw.hidden = parsing.ToBool(val)
+		case "enabled":
+			if w, ok := lw.widget.(interface{ SetEnabled(bool) }); ok {
+				w.SetEnabled(parsing.ToBool(val))
+			}
+		case "max_length":
+			if w, ok := lw.widget.(common.WidgetInput); ok {
+				w.SetMaxLength(parsing.ToInt(val, 0))
+			}
+		default:
+			return fmt.Errorf("Menu: '%s' is not a valid widget key.", key)
+		}
+	}
+
+	return nil
+}
+
+// 组件跟随窗口位置，对齐到屏幕的组件不跟随
+func (this *MenuLayout) Align(modules common.Modules, area rect.Rect) {
+	for _, lw := range this.widgets {
+		if lw.external {
+			continue
+		}
+
+		offsetX, offsetY := area.X, area.Y
+		if lw.widget.GetAlignment() != define.ALIGN_TOPLEFT {
+			offsetX, offsetY = 0, 0
+		}
+
+		lw.widget.SetPos1(modules, offsetX, offsetY)
+
+		if w, ok := lw.widget.(interface{ Refresh(common.Modules) }); ok {
+			w.Refresh(modules)
+		}
+	}
+}
+
+func (this *MenuLayout) Render(modules common.Modules) error {
+	for _, lw := range this.widgets {
+		if lw.external || lw.hidden {
+			continue
+		}
+
+		err := lw.widget.Render(modules)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (this *MenuLayout) GetWidget(id string) common.Widget {
+	if lw := this.find(id); lw != nil {
+		return lw.widget
+	}
+
+	return nil
+}
+
+// 返回被点击的按钮id，没有则为空，外部组件由代码自己检查
+func (this *MenuLayout) CheckClick(modules common.Modules) string {
+	for _, lw := range this.widgets {
+		if lw.external || lw.hidden {
+			continue
+		}
+
+		if b, ok := lw.widget.(common.WidgetButton); ok && b.CheckClick(modules) {
+			return lw.id
+		}
+	}
+
+	return ""
+}
+
+// 注册代码创建的组件，菜单文件可以修改它的位置等，之后由菜单负责关闭
+func (this *Menu) RegisterWidget(id string, w common.Widget) error {
+	return this.layout.Register(id, w)
+}
+
+// 注册菜单自己绘制的组件，菜单文件只能修改它的位置等
+func (this *Menu) RegisterExternalWidget(id string, w common.Widget) error {
+	return this.layout.RegisterExternal(id, w)
+}
+
+func (this *Menu) ParseLayoutKey(infile *fileparser.FileParser) (bool, error) {
+	return this.layout.ParseKey(infile)
+}
+
+func (this *Menu) BuildLayout(modules common.Modules) error {
+	return this.layout.Build(modules, this.tablist)
+}
+
+// 只包含菜单键和组件段落的菜单文件
+func (this *Menu) LoadMenuFile(modules common.Modules, filename string) error {
+	mods := modules.Mods()
+
+	infile := fileparser.New()
+	err := infile.Open(filename, true, mods)
+	if err != nil {
+		return err
+	}
+	defer infile.Close()
+
+	for infile.Next(mods) {
+		ok, err := this.ParseLayoutKey(infile)
+		if err != nil {
+			return err
+		} else if ok {
+			continue
+		}
+
+		if infile.Key() == "background" {
+			err = this.SetBackground(modules, infile.Val())
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !this.ParseMenuKey(infile.Key(), infile.Val()) {
+			err = infile.Reportf("Menu: '%s' is not a valid key.", infile.Key())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return this.BuildLayout(modules)
+}
+
+func (this *Menu) GetWidget(id string) common.Widget {
+	return this.layout.GetWidget(id)
+}
+
+func (this *Menu) GetButton(id string) common.WidgetButton {
+	w, _ := this.GetWidget(id).(common.WidgetButton)
+	return w
+}
+
+func (this *Menu) GetLabel(id string) common.WidgetLabel {
+	w, _ := this.GetWidget(id).(common.WidgetLabel)
+	return w
+}
+
+func (this *Menu) CheckLayoutClick(modules common.Modules) string {
+	return this.layout.CheckClick(modules)
+}
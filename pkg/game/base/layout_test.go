@@ -0,0 +1,142 @@
+package base
+
+import (
+	"monster/pkg/common"
+	"monster/pkg/common/define"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeWidget struct {
+	common.Widget
+
+	x, y, align int
+	closed      bool
+}
+
+func (this *fakeWidget) Close() {
+	this.closed = true
+}
+
+func (this *fakeWidget) SetPosBase(x, y, a int) {
+	this.x, this.y, this.align = x, y, a
+}
+
+func (this *fakeWidget) GetAlignment() int {
+	return this.align
+}
+
+type fakeTablist struct {
+	common.WidgetTablist
+
+	widgets []common.Widget
+}
+
+func (this *fakeTablist) Add(w common.Widget) {
+	this.widgets = append(this.widgets, w)
+}
+
+func parseKeys(r *require.Assertions, layout *MenuLayout, keys [][2]string) {
+	for i, kv := range keys {
+		r.NoError(layout.parseKey(i == 0, kv[0], kv[1]))
+	}
+}
+
+func TestMenuLayoutParseKey(t *testing.T) {
+	r := require.New(t)
+
+	layout := MenuLayout{}
+
+	// 没有id
+	r.Error(layout.parseKey(true, "pos", "1,2"))
+
+	parseKeys(r, &layout, [][2]string{{"id", "a"}, {"pos", "1,2"}})
+	parseKeys(r, &layout, [][2]string{{"id", "b"}, {"hidden", "true"}})
+
+	// 相同id的段落合并
+	parseKeys(r, &layout, [][2]string{{"id", "a"}, {"tab_order", "3"}})
+
+	r.Len(layout.entries, 2)
+	r.Equal([][2]string{{"pos", "1,2"}, {"tab_order", "3"}}, layout.entries[0].keys)
+	r.Equal([][2]string{{"hidden", "true"}}, layout.entries[1].keys)
+
+	// 新段落没有id
+	r.Error(layout.parseKey(true, "pos", "3,4"))
+}
+
+func TestMenuLayoutBuild(t *testing.T) {
+	r := require.New(t)
+
+	a := &fakeWidget{}
+	b := &fakeWidget{}
+	c := &fakeWidget{}
+	ext := &fakeWidget{}
+
+	layout := MenuLayout{}
+	r.NoError(layout.Register("a", a))
+	r.NoError(layout.Register("b", b))
+	r.NoError(layout.Register("c", c))
+	r.NoError(layout.RegisterExternal("ext", ext))
+	r.Error(layout.Register("a", a))
+	r.Error(layout.RegisterExternal("ext", ext))
+
+	parseKeys(r, &layout, [][2]string{{"id", "a"}, {"pos", "10,20,center"}, {"tab_order", "2"}})
+	parseKeys(r, &layout, [][2]string{{"id", "b"}, {"tab_order", "1"}})
+	parseKeys(r, &layout, [][2]string{{"id", "c"}, {"hidden", "true"}})
+	parseKeys(r, &layout, [][2]string{{"id", "ext"}, {"pos", "5,6"}})
+
+	tablist := &fakeTablist{}
+	r.NoError(layout.Build(nil, tablist))
+
+	r.Equal(10, a.x)
+	r.Equal(20, a.y)
+	r.Equal(define.ALIGN_CENTER, a.align)
+	r.Equal(5, ext.x)
+	r.Equal(6, ext.y)
+
+	// 按tab_order排序，隐藏的和外部组件不加入
+	r.Equal([]common.Widget{b, a}, tablist.widgets)
+	r.Equal(a, layout.GetWidget("a"))
+	r.Nil(layout.GetWidget("missing"))
+
+	// 外部组件由代码自己关闭
+	layout.Close()
+	r.True(a.closed)
+	r.True(b.closed)
+	r.True(c.closed)
+	r.False(ext.closed)
+	r.Nil(layout.GetWidget("a"))
+}
+
+func TestMenuLayoutBuildWithoutTablist(t *testing.T) {
+	r := require.New(t)
+
+	a := &fakeWidget{}
+
+	layout := MenuLayout{}
+	r.NoError(layout.Register("a", a))
+	parseKeys(r, &layout, [][2]string{{"id", "a"}, {"pos", "1,2"}})
+
+	r.NoError(layout.Build(nil, nil))
+	r.Equal(1, a.x)
+	r.Equal(2, a.y)
+}
+
+func TestMenuLayoutBuildErrors(t *testing.T) {
+	r := require.New(t)
+
+	a := &fakeWidget{}
+
+	// 未知的键
+	layout := MenuLayout{}
+	r.NoError(layout.Register("a", a))
+	parseKeys(r, &layout, [][2]string{{"id", "a"}, {"size", "1,2"}})
+	r.Error(layout.Build(nil, nil))
+
+	// 新组件没有类型
+	layout = MenuLayout{}
+	parseKeys(r, &layout, [][2]string{{"id", "b"}, {"pos", "1,2"}})
+	r.Error(layout.Build(nil, nil))
+	r.Nil(layout.GetWidget("b"))
+}
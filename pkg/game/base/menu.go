@@ -17,6 +17,7 @@ type Menu struct {
 	tablist        common.WidgetTablist
 	background     common.Sprite
 	windowAreaBase point.Point // 锚点
+	layout         MenuLayout  // 菜单文件里的组件
 }
 
 func ConstructMenu(modules common.Modules) Menu {
@@ -30,6 +31,8 @@ func ConstructMenu(modules common.Modules) Menu {
 }
 
 func (this *Menu) clear() {
+	this.layout.Close()
+
 	if this.background != nil {
 		this.background.Close()
 		this.background = nil
@@ -101,7 +104,7 @@ func (this *Menu) Render(modules common.Modules) error {
 		}
 	}
 
-	return nil
+	return this.layout.Render(modules)
 }
 
 // 在窗口的显示位置 对齐到某个屏幕位置
@@ -119,6 +122,8 @@ func (this *Menu) Align(modules common.Modules) error {
 		this.background.SetDestFromRect(this.windowArea)
 	}
 
+	this.layout.Align(modules, this.windowArea)
+
 	return nil
 }
 
@@ -17,8 +17,10 @@ type State struct {
 	forceRefreshBackground bool
 	SaveSettingsOnExit     bool
 	loadCounter            int
+	loader                 common.Loader     // 异步加载中，按未完成的任务数显示进度条
 	requestedGameState     gameres.GameState // 准备切换到该场景
 	exitRequested          bool
+	suspended              bool // 被其他状态暂时接管，切换时不关闭
 	loadingTip             common.WidgetTooltip
 	loadingTipBuf          tooltipdata.TooltipData
 }
@@ -77,16 +79,45 @@ func (this *State) SetRequestedGameState(modules common.Modules, gameRes gameres
 	this.requestedGameState.RefreshWidgets(modules, gameRes) // 刷新组件的位置和大小
 }
 
+// 丢弃切换请求，不关闭请求的状态
+func (this *State) ClearRequestedGameState() {
+	this.requestedGameState = nil
+}
+
+func (this *State) SetSuspended(val bool) {
+	this.suspended = val
+}
+
+func (this *State) GetSuspended() bool {
+	return this.suspended
+}
+
 func (this *State) SetLoadingFrame() {
 	this.loadCounter = 2
 }
 
-// 渲染加载文字
+// 渲染加载文字并立即提交
 func (this *State) ShowLoading(modules common.Modules) error {
 	render := modules.Render()
-	settings := modules.Settings()
 	inpt := modules.Inpt()
 
+	err := this.RenderLoading(modules)
+	if err != nil {
+		return err
+	}
+
+	err = render.CommitFrame(inpt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// 渲染加载文字和进度条
+func (this *State) RenderLoading(modules common.Modules) error {
+	settings := modules.Settings()
+
 	if this.loadingTip == nil {
 		return nil
 	}
@@ -101,14 +132,59 @@ func (this *State) ShowLoading(modules common.Modules) error {
 		return err
 	}
 
-	err = render.CommitFrame(inpt)
+	if this.loader != nil {
+		err = this.renderProgressBar(modules)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 屏幕下方居中
+func (this *State) renderProgressBar(modules common.Modules) error {
+	render := modules.Render()
+	settings := modules.Settings()
+	font := modules.Font()
+
+	w := settings.GetViewW() / 2
+	h := 8
+	x := (settings.GetViewW() - w) / 2
+	y := settings.GetViewH() - h*4
+
+	c := font.GetColor(fontengine.COLOR_WIDGET_NORMAL)
+
+	err := render.DrawRectangle(point.Construct(x, y), point.Construct(x+w, y+h), c)
 	if err != nil {
 		return err
 	}
 
+	// 工作协程里会追加任务，总数可能变大
+	total := this.loader.GetTotal()
+	fill := 0
+	if total > 0 {
+		fill = (w - 2) * (total - this.loader.GetCounter()) / total
+	}
+
+	if fill <= 0 {
+		return nil
+	}
+
+	for i := 1; i < h; i++ {
+		err = render.DrawLine(x+1, y+i, x+fill, y+i, c)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+func (this *State) SetLoader(ldr common.Loader) {
+	this.loader = ldr
+}
+
 func (this *State) SetForceRefreshBackground(val bool) {
 	this.forceRefreshBackground = val
 }
@@ -117,6 +193,10 @@ func (this *State) GetForceRefreshBackground() bool {
 	return this.forceRefreshBackground
 }
 
+func (this *State) SetHasBackground(val bool) {
+	this.hasBackground = val
+}
+
 func (this *State) GetHasBackground() bool {
 	return this.hasBackground
 }
@@ -2,18 +2,32 @@ package menu
 
 import (
 	"fmt"
+	"math"
 	"monster/pkg/common"
+	"monster/pkg/common/color"
 	"monster/pkg/common/define"
 	"monster/pkg/common/define/fontengine"
 	"monster/pkg/common/define/game/menu/actionbar"
 	"monster/pkg/common/define/game/menu/powers"
 	"monster/pkg/common/define/inputstate"
+	"monster/pkg/common/define/widget"
+	"monster/pkg/common/define/widget/slot"
+	"monster/pkg/common/define/widget/tablist"
+	"monster/pkg/common/fpoint"
 	"monster/pkg/common/gameres"
+	"monster/pkg/common/gameres/power"
 	"monster/pkg/common/point"
 	"monster/pkg/common/rect"
 	"monster/pkg/filesystem/fileparser"
+	"monster/pkg/filesystem/logfile"
+	"monster/pkg/filesystem/saveload"
 	"monster/pkg/game/base"
+	"monster/pkg/utils"
 	"monster/pkg/utils/parsing"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 type ActionBar struct {
@@ -41,8 +55,12 @@ type ActionBar struct {
 	requiresAttention []bool
 	slotActivated     []bool // 槽里是否有技能或物品
 	slotCooldownSize  []int
+	cooldownStyle     int // 冷却图层样式
+	inv               gameres.MenuInventory
+	powers            gameres.PowerManager
 
-	dragPrevSlot int
+	dragPrevSlot int // 拖动中的槽
+	dragPower    define.PowerId
 	twoStepSlot  int
 }
 
@@ -69,11 +87,16 @@ func (this *ActionBar) Init(modules common.Modules, powerManager gameres.PowerMa
 	this.tooltipLength = powers.TOOLTIP_LONG_MENU
 	this.dragPrevSlot = -1
 	this.twoStepSlot = -1
+	this.cooldownStyle = actionbar.COOLDOWN_VERTICAL
+	this.powers = powerManager
 
 	this.menuLabels = make([]string, actionbar.MENU_COUNT)
 	this.requiresAttention = make([]bool, actionbar.MENU_COUNT)
-	// TODO
-	// tablist
+
+	// tablist 用动作条专用的左右和激活键，平时锁定
+	this.GetTablist().SetScrollType(widget.SCROLL_HORIZONTAL)
+	this.GetTablist().SetInput(inputstate.ACTIONBAR_BACK, inputstate.ACTIONBAR_FORWARD, inputstate.ACTIONBAR)
+	this.GetTablist().Lock()
 
 	this.menus = make([]common.WidgetSlot, actionbar.MENU_COUNT)
 
@@ -111,6 +134,14 @@ func (this *ActionBar) Init(modules common.Modules, powerManager gameres.PowerMa
 			continue
 		}
 
+		// 额外的组件
+		ok, err := this.Menu.ParseLayoutKey(infile)
+		if err != nil {
+			panic(err)
+		} else if ok {
+			continue
+		}
+
 		var x, y int
 		switch key {
 		case "slot":
@@ -190,13 +221,60 @@ func (this *ActionBar) Init(modules common.Modules, powerManager gameres.PowerMa
 			} else {
 				panic(fmt.Sprintf("MenuActionBar: '%s' is not a valid tooltip_length setting.\n", val))
 			}
+		case "cooldown_style":
+			if val == "vertical" {
+				this.cooldownStyle = actionbar.COOLDOWN_VERTICAL
+			} else if val == "radial" {
+				this.cooldownStyle = actionbar.COOLDOWN_RADIAL
+			} else {
+				panic(fmt.Sprintf("MenuActionBar: '%s' is not a valid cooldown_style setting.\n", val))
+			}
 		default:
 			panic(fmt.Sprintf("MenuActionBar: '%s' is not a valid key.\n", key))
 		}
 	}
 
-	// TODO
+	// 自己的组件也可以在[widget]段落里修改位置，id和上面的键名相同
+	registerExternal := func(id string, w common.Widget) {
+		err := this.Menu.RegisterExternalWidget(id, w)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	for i, ptr := range this.slots {
+		if ptr == nil {
+			continue
+		}
+
+		switch i {
+		case 10:
+			registerExternal("slot_M1", ptr)
+		case 11:
+			registerExternal("slot_M2", ptr)
+		default:
+			registerExternal(fmt.Sprintf("slot%d", i+1), ptr)
+		}
+	}
+
+	registerExternal("char_menu", this.menus[actionbar.MENU_CHARACTER])
+	registerExternal("inv_menu", this.menus[actionbar.MENU_INVENTORY])
+	registerExternal("powers_menu", this.menus[actionbar.MENU_POWERS])
+	registerExternal("log_menu", this.menus[actionbar.MENU_LOG])
+
+	err = this.Menu.BuildLayout(modules)
+	if err != nil {
+		panic(err)
+	}
+
 	// tablist
+	for _, ptr := range this.slots {
+		this.GetTablist().Add(ptr)
+	}
+
+	for _, ptr := range this.menus {
+		this.GetTablist().Add(ptr)
+	}
 
 	this.slotsCount = len(this.slots)
 	this.hotkeys = make([]define.PowerId, this.slotsCount)
@@ -296,59 +374,90 @@ func (this *ActionBar) Close() {
 func (this *ActionBar) Logic(modules common.Modules, pc gameres.Avatar, powers gameres.PowerManager) error {
 
 	eset := modules.Eset()
+	inpt := modules.Inpt()
+	settings := modules.Settings()
 
-	// TODO
-	// tablist
+	// tablist 按动作条键切换是否用键盘选择
+	if !inpt.UsingMouse(settings) && inpt.GetPressing(inputstate.ACTIONBAR_USE) && !inpt.GetLock(inputstate.ACTIONBAR_USE) {
+		inpt.SetLock(inputstate.ACTIONBAR_USE, true)
+
+		if this.GetTablist().GetCurrent() == -1 {
+			this.GetTablist().Unlock()
+			this.GetTablist().GetNext(modules, false, tablist.WIDGET_SELECT_AUTO)
+		} else {
+			this.GetTablist().Defocus()
+			this.GetTablist().Lock()
+		}
+	}
+
+	err := this.GetTablist().Logic(modules)
+	if err != nil {
+		return err
+	}
 
 	if pc.GetPowerCastTimersSize() == 0 {
 		// 没有技能
 		return nil
 	}
 
+	// 拖动调整槽
+	this.logicDrag(modules)
+
+	esetIconSize := eset.Get("resolutions", "icon_size").(int)
+
 	for i := 0; i < this.slotsCount; i++ {
 		if this.slots[i] == nil {
 			continue
 		}
 
 		if this.hotkeys[i] > 0 {
-			power := powers.GetPower(this.hotkeysMod[i])
+			pow := powers.GetPower(this.hotkeysMod[i])
 
-			if len(power.RequiredItems) == 0 {
+			if len(pow.RequiredItems) == 0 {
 
 				// 技能
 				this.SetItemCount(modules, i, -1, false)
 			} else {
 
 				// 消耗物品, 吃药
-				for j := 0; j < len(power.RequiredItems); j++ {
-					// TODO
-					// menu inventory
+				for j := 0; j < len(pow.RequiredItems); j++ {
+					if pow.RequiredItems[j].Equipped {
+						// TODO
+						// 装备中的道具
+						continue
+					}
+
+					count := 0
+					if this.inv != nil {
+						count = this.inv.GetItemCountCarried(pow.RequiredItems[j].Id)
+					}
 
-					if power.RequiredItems[j].Quantity > 0 {
+					this.SetItemCount(modules, i, count, false)
+
+					if pow.RequiredItems[j].Quantity > 0 {
 						break
 					}
 				}
 
 			}
 
-			// TODO
-			// pc statsblock
 			this.slotEnabled[i] = pc.GetPowerCooldownTimer(this.hotkeysMod[i]).IsEnd() &&
 				pc.GetPowerCastTimer(this.hotkeysMod[i]).IsEnd() &&
-				(this.twoStepSlot == -1 || this.twoStepSlot == i)
+				(this.twoStepSlot == -1 || this.twoStepSlot == i) &&
+				this.slotItemCount[i] != 0 &&
+				this.canAfford(pc.GetStats(), pow)
 
-			this.slots[i].SetIcon(power.Icon, -1)
+			this.slots[i].SetIcon(pow.Icon, -1)
 
 		} else {
 			this.slotEnabled[i] = true
 		}
 
 		// 处理技能释放，技能冷却定时器
-		esetIconSize := eset.Get("resolutions", "icon_size").(int)
 		if this.hotkeysMod[i] != 0 && !pc.GetPowerCastTimer(this.hotkeysMod[i]).IsEnd() && pc.GetPowerCastTimer(this.hotkeysMod[i]).GetDuration() > 0 {
-			this.slotCooldownSize[i] = esetIconSize * (int)(pc.GetPowerCastTimer(this.hotkeysMod[i]).GetDuration()) / (int)(pc.GetPowerCastTimer(this.hotkeysMod[i]).GetDuration())
+			this.slotCooldownSize[i] = esetIconSize * (int)(pc.GetPowerCastTimer(this.hotkeysMod[i]).GetCurrent()) / (int)(pc.GetPowerCastTimer(this.hotkeysMod[i]).GetDuration())
 		} else if this.hotkeysMod[i] != 0 && !pc.GetPowerCooldownTimer(this.hotkeysMod[i]).IsEnd() && pc.GetPowerCooldownTimer(this.hotkeysMod[i]).GetDuration() > 0 {
-			this.slotCooldownSize[i] = esetIconSize * (int)(pc.GetPowerCooldownTimer(this.hotkeysMod[i]).GetDuration()) / (int)(pc.GetPowerCooldownTimer(this.hotkeysMod[i]).GetDuration())
+			this.slotCooldownSize[i] = esetIconSize * (int)(pc.GetPowerCooldownTimer(this.hotkeysMod[i]).GetCurrent()) / (int)(pc.GetPowerCooldownTimer(this.hotkeysMod[i]).GetDuration())
 		} else {
 			this.slotCooldownSize[i] = esetIconSize
 
@@ -365,6 +474,86 @@ func (this *ActionBar) Logic(modules common.Modules, pc gameres.Avatar, powers g
 	return nil
 }
 
+// 英雄是否付得起技能的消耗
+func (this *ActionBar) canAfford(stats gameres.StatBlock, pow *power.Power) bool {
+	if pow.RequiresMP > stats.GetMP() {
+		return false
+	}
+
+	if !pow.Sacrifice && pow.RequiresHP > 0 && pow.RequiresHP >= stats.GetHP() {
+		return false
+	}
+
+	for _, pri := range pow.RequiredItems {
+		if pri.Id <= 0 || pri.Equipped {
+			continue
+		}
+
+		if this.inv == nil || this.inv.GetItemCountCarried(pri.Id) < pri.Quantity {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 左键拖动槽里的技能，放下时和目标槽交换
+func (this *ActionBar) logicDrag(modules common.Modules) {
+	inpt := modules.Inpt()
+	mouse := inpt.GetMouse()
+
+	if this.dragPrevSlot == -1 {
+		if !inpt.GetPressing(inputstate.MAIN1) || inpt.GetLock(inputstate.MAIN1) {
+			return
+		}
+
+		index := this.slotAt(mouse)
+		if index == -1 || this.hotkeys[index] == 0 || this.locked[index] || this.preventChanging[index] {
+			return
+		}
+
+		inpt.SetLock(inputstate.MAIN1, true)
+		this.dragPrevSlot = index
+		this.dragPower = this.hotkeys[index]
+		return
+	}
+
+	if inpt.GetPressing(inputstate.MAIN1) {
+		// 拖动中
+		return
+	}
+
+	inpt.SetLock(inputstate.MAIN1, false)
+
+	from := this.dragPrevSlot
+	this.dragPrevSlot = -1
+	this.dragPower = 0
+
+	to := this.slotAt(mouse)
+	if to == -1 || to == from || this.locked[to] || this.preventChanging[to] {
+		return
+	}
+
+	this.hotkeys[from], this.hotkeys[to] = this.hotkeys[to], this.hotkeys[from]
+	this.hotkeysMod[from], this.hotkeysMod[to] = this.hotkeysMod[to], this.hotkeysMod[from]
+
+	err := this.SaveLayout(modules)
+	if err != nil {
+		logfile.LogError("MenuActionBar: %s", err)
+	}
+}
+
+// 鼠标所在的槽
+func (this *ActionBar) slotAt(mouse point.Point) int {
+	for i := 0; i < this.slotsCount; i++ {
+		if this.slots[i] != nil && utils.IsWithinRect(this.slots[i].GetPos(), mouse) {
+			return i
+		}
+	}
+
+	return -1
+}
+
 func (this *ActionBar) Align(modules common.Modules) error {
 	msg := modules.Msg()
 	inpt := modules.Inpt()
@@ -471,6 +660,7 @@ func (this *ActionBar) Render(modules common.Modules) error {
 	settings := modules.Settings()
 	widgetf := modules.Widgetf()
 	font := modules.Font()
+	icons := modules.Icons()
 
 	err := this.Menu.Render(modules)
 	if err != nil {
@@ -505,19 +695,20 @@ func (this *ActionBar) Render(modules common.Modules) error {
 
 		// 冷却或禁用图层
 		if !this.slotEnabled[i] {
-			clip := rect.Construct(0, 0, esetIconSize, esetIconSize)
-
+			size := esetIconSize
 			if this.twoStepSlot == -1 || this.twoStepSlot == i {
-				clip.H = this.slotCooldownSize[i]
+				size = this.slotCooldownSize[i]
 			}
 
-			if this.spriteDisabled != nil && clip.H > 0 {
-				this.spriteDisabled.SetClipFromRect(clip)
-				this.spriteDisabled.SetDestFromRect(this.slots[i].GetPos())
-				err := render.Render(this.spriteDisabled)
-				if err != nil {
-					return err
-				}
+			var err error
+			if this.cooldownStyle == actionbar.COOLDOWN_RADIAL && size < esetIconSize {
+				err = this.renderRadial(render, this.slots[i].GetPos(), float64(size)/float64(esetIconSize))
+			} else {
+				err = this.renderVertical(render, this.slots[i].GetPos(), size)
+			}
+
+			if err != nil {
+				return err
 			}
 		}
 
@@ -527,6 +718,19 @@ func (this *ActionBar) Render(modules common.Modules) error {
 		}
 	}
 
+	// 拖动中的图标
+	if this.dragPrevSlot != -1 && this.dragPower != 0 {
+		pow := this.powers.GetPower(this.dragPower)
+		if pow != nil && icons != nil {
+			mouse := modules.Inpt().GetMouse()
+			icons.SetIcon(eset, pow.Icon, point.Construct(mouse.X-esetIconSize/2, mouse.Y-esetIconSize/2))
+			err := icons.Render(render)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	for i := 0; i < actionbar.MENU_COUNT; i++ {
 		err := this.menus[i].Render(modules)
 		if err != nil {
@@ -559,6 +763,61 @@ func (this *ActionBar) Render(modules common.Modules) error {
 	return nil
 }
 
+// 从上往下遮住剩余的冷却
+func (this *ActionBar) renderVertical(render common.RenderDevice, dest rect.Rect, size int) error {
+	if this.spriteDisabled == nil || size <= 0 {
+		return nil
+	}
+
+	clip := rect.Construct(0, 0, dest.W, size)
+	this.spriteDisabled.SetClipFromRect(clip)
+	this.spriteDisabled.SetDestFromRect(dest)
+	return render.Render(this.spriteDisabled)
+}
+
+// 从12点方向开始顺时针，遮住剩余的冷却
+// 从图标中心到边框画扇形，经过的角都作为扇形的顶点
+func (this *ActionBar) renderRadial(render common.RenderDevice, dest rect.Rect, frac float64) error {
+	if frac <= 0 {
+		return nil
+	}
+
+	hw := float64(dest.W) / 2
+	hh := float64(dest.H) / 2
+	center := fpoint.Construct(float32(float64(dest.X)+hw), float32(float64(dest.Y)+hh))
+
+	// 角度对应的边框上的点，0为12点方向，顺时针增加
+	edge := func(angle float64) fpoint.FPoint {
+		dx, dy := math.Sin(angle), -math.Cos(angle)
+		scale := math.Inf(1)
+		if dx != 0 {
+			scale = hw / math.Abs(dx)
+		}
+		if dy != 0 {
+			scale = math.Min(scale, hh/math.Abs(dy))
+		}
+
+		return fpoint.Construct(center.X+float32(dx*scale), center.Y+float32(dy*scale))
+	}
+
+	begin := (1 - frac) * 2 * math.Pi
+	corner := math.Atan2(hw, hh)
+	angles := []float64{begin}
+	for _, a := range []float64{corner, math.Pi - corner, math.Pi + corner, 2*math.Pi - corner} {
+		if a > begin {
+			angles = append(angles, a)
+		}
+	}
+	angles = append(angles, 2*math.Pi)
+
+	var points []fpoint.FPoint
+	for i := 0; i+1 < len(angles); i++ {
+		points = append(points, center, edge(angles[i]), edge(angles[i+1]))
+	}
+
+	return render.DrawFilledTriangles(points, color.Construct(0, 0, 0, 160))
+}
+
 // 给某个槽添加数量
 func (this *ActionBar) SetItemCount(modules common.Modules, index, count int, isEquipped bool) {
 	if index >= this.slotsCount || this.slots[index] == nil {
@@ -584,3 +843,168 @@ func (this *ActionBar) SetItemCount(modules common.Modules, index, count int, is
 		this.slots[index].SetAmount(modules, 0, 0)
 	}
 }
+
+// 用来统计槽中的道具数量
+func (this *ActionBar) SetInventory(inv gameres.MenuInventory) {
+	this.inv = inv
+}
+
+// 设置所有槽的技能
+func (this *ActionBar) Set(hotkeys []define.PowerId) {
+	for i := 0; i < this.slotsCount; i++ {
+		this.hotkeys[i] = 0
+		if i < len(hotkeys) && this.powers.VerifyId(hotkeys[i], true) != 0 {
+			this.hotkeys[i] = hotkeys[i]
+		}
+
+		this.hotkeysMod[i] = this.hotkeys[i]
+	}
+}
+
+func (this *ActionBar) GetHotkeys() []define.PowerId {
+	tmp := make([]define.PowerId, this.slotsCount)
+	copy(tmp, this.hotkeys)
+	return tmp
+}
+
+// 本帧请求释放的技能
+func (this *ActionBar) CheckAction(modules common.Modules) []power.ActionData {
+	inpt := modules.Inpt()
+	settings := modules.Settings()
+
+	var actions []power.ActionData
+
+	if this.dragPrevSlot != -1 {
+		return actions
+	}
+
+	mouse := inpt.GetMouse()
+	inBar := utils.IsWithinRect(this.GetWindowArea(), mouse)
+
+	// 鼠标移动时，移动键需要配合shift
+	mmSlot := actionbar.SLOT_MAIN1
+	if settings.Get("mouse_move_swap").(bool) {
+		mmSlot = actionbar.SLOT_MAIN2
+	}
+
+	for i := 0; i < this.slotsCount; i++ {
+		if this.slots[i] == nil {
+			continue
+		}
+
+		have := false
+		if this.slots[i].CheckClick(modules) == slot.ACTIVATED {
+			have = true
+		}
+
+		if i < actionbar.SLOT_MAIN1 {
+			if inpt.GetPressing(inputstate.BAR_1+i) && !inpt.GetLock(inputstate.BAR_1+i) {
+				have = true
+			}
+		} else if !inBar {
+			key := inputstate.MAIN1 + i - actionbar.SLOT_MAIN1
+			if inpt.GetPressing(key) && !inpt.GetLock(key) {
+				if !settings.Get("mouse_move").(bool) || i != mmSlot || inpt.GetPressing(inputstate.SHIFT) {
+					have = true
+				}
+			}
+		}
+
+		if !have || this.hotkeysMod[i] == 0 {
+			continue
+		}
+
+		if !this.slotEnabled[i] {
+			// 失败提示
+			this.slotFailCooldown[i] = settings.Get("max_fps").(int) / 2
+			continue
+		}
+
+		action := power.ConstructActionData()
+		action.Power = this.hotkeysMod[i]
+		action.Hotkey = i
+		actions = append(actions, action)
+	}
+
+	return actions
+}
+
+// 每个存档单独保存，存档还没写过时序号为0，避免留下只有布局的存档目录
+func (this *ActionBar) getLayoutSlot(modules common.Modules) (string, int) {
+	settings := modules.Settings()
+	eset := modules.Eset()
+
+	root := saveload.SaveRoot(settings, eset)
+	slot := saveload.GetGameSlot()
+	if !saveload.HasSave(root, slot) {
+		return root, 0
+	}
+
+	return root, slot
+}
+
+// 加载玩家保存的布局，没有则使用职业的默认布局
+func (this *ActionBar) LoadLayout(modules common.Modules, pc gameres.Avatar, powers gameres.PowerManager) error {
+	mods := modules.Mods()
+	eset := modules.Eset()
+
+	this.Clear1(powers, false)
+
+	root, slot := this.getLayoutSlot(modules)
+
+	infile := fileparser.New()
+	err := os.ErrNotExist
+	if slot > 0 {
+		err = infile.Open(filepath.Join(saveload.SlotPath(root, slot), saveload.ACTIONBAR_FILE), false, mods)
+	}
+
+	if err != nil && utils.IsNotExist(err) {
+		className := pc.GetStats().GetCharacterClass()
+		for _, hc := range eset.Get("hero_classes", "list").([]common.HeroClass) {
+			if hc.GetName() == className {
+				this.Set(hc.GetActionbar())
+				break
+			}
+		}
+
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer infile.Close()
+
+	for infile.Next(mods) {
+		switch infile.Key() {
+		case "actionbar":
+			var hotkeys []define.PowerId
+			strVal := infile.Val()
+			for strVal != "" {
+				var first string
+				first, strVal = parsing.PopFirstString(strVal, "")
+				hotkeys = append(hotkeys, parsing.ToPowerId(first, 0))
+			}
+
+			this.Set(hotkeys)
+		default:
+			logfile.LogError("MenuActionBar: '%s' is not a valid key.", infile.Key())
+		}
+	}
+
+	return nil
+}
+
+// 保存槽的布局
+func (this *ActionBar) SaveLayout(modules common.Modules) error {
+	root, slot := this.getLayoutSlot(modules)
+	if slot <= 0 {
+		return nil
+	}
+
+	var vals []string
+	for _, id := range this.hotkeys {
+		vals = append(vals, strconv.Itoa(int(id)))
+	}
+
+	data := []byte("actionbar=" + strings.Join(vals, ",") + "\n")
+	return saveload.WriteSlotFile(root, slot, saveload.ACTIONBAR_FILE, data)
+}
@@ -12,6 +12,7 @@ import (
 	"monster/pkg/common/define/inputstate"
 	"monster/pkg/common/define/modmanager"
 	"monster/pkg/common/define/platform"
+	"monster/pkg/common/define/widget"
 	"monster/pkg/common/define/widget/button"
 	"monster/pkg/common/define/widget/checkbox"
 	"monster/pkg/common/define/widget/listbox"
@@ -23,6 +24,7 @@ import (
 	"monster/pkg/common/timer"
 	"monster/pkg/config/version"
 	"monster/pkg/filesystem/fileparser"
+	"monster/pkg/game/base"
 	"monster/pkg/utils"
 	"monster/pkg/utils/parsing"
 	"monster/pkg/utils/tools"
@@ -139,6 +141,10 @@ type Config struct {
 	// 确认弹窗
 	inputConfirm    *Confirm
 	defaultsConfirm *Confirm
+	dependsConfirm  *Confirm // 启用依赖的mod
+	restartConfirm  *Confirm // mod改动需要重启
+	movementType    *MovementType
+	pendingDepends  []string // 等待确认启用的依赖
 
 	// 组件组织
 	optionTab   []int
@@ -146,12 +152,15 @@ type Config struct {
 
 	// 组件
 	tabControl      common.WidgetTabControl // 标签选择控制器
+	tablist         common.WidgetTablist
+	tablistTab      int // tablist对应的标签，切换标签后重建
 	buttons         map[string]common.WidgetButton
 	labels          map[string]common.WidgetLabel
 	checkboxs       map[string]common.WidgetCheckBox
 	sliders         map[string]common.WidgetSlider
 	horizontalLists map[string]common.WidgetHorizontalList
 	listboxs        map[string]common.WidgetListBox
+	layout          base.MenuLayout // 菜单文件里的[widget]段落
 
 	// 背景
 	background common.Sprite // 菜单的背景
@@ -210,13 +219,36 @@ func (this *Config) Init(modules common.Modules, isGameState bool) gameres.MenuC
 	// 标签控制器
 	this.tabControl = widgetf.New("tabcontrol").(common.WidgetTabControl).Init(modules)
 
+	// 上下在标签、滚动盒子和按钮之间移动，左右交给标签控制器和滑块
+	this.tablist = widgetf.New("tablist").(common.WidgetTablist).Init()
+	this.tablist.SetScrollType(widget.SCROLL_VERTICAL)
+	this.tablistTab = -1
+
 	this.buttons["ok"] = widgetf.New("button").(common.WidgetButton).Init(modules, button.DEFAULT_FILE)
 	this.buttons["defaults"] = widgetf.New("button").(common.WidgetButton).Init(modules, button.DEFAULT_FILE)
 	this.buttons["cancel"] = widgetf.New("button").(common.WidgetButton).Init(modules, button.DEFAULT_FILE)
 
 	// 确认弹窗
-	this.inputConfirm = NewConfirm(modules, msg.Get("Clear"), msg.Get("Assign:"))
-	this.defaultsConfirm = NewConfirm(modules, msg.Get("Defaults"), msg.Get("Reset ALL settings?"))
+	var err error
+	this.inputConfirm, err = NewConfirm(modules, msg.Get("Clear"), msg.Get("Assign:"))
+	if err != nil {
+		panic(err)
+	}
+
+	this.defaultsConfirm, err = NewConfirm(modules, msg.Get("Defaults"), msg.Get("Reset ALL settings?"))
+	if err != nil {
+		panic(err)
+	}
+
+	this.restartConfirm, err = NewConfirm(modules, msg.Get("Restart"), msg.Get("Changing mods requires a restart."))
+	if err != nil {
+		panic(err)
+	}
+
+	this.movementType, err = NewMovementType(modules)
+	if err != nil {
+		panic(err)
+	}
 
 	// 定义组件
 	this.labels["pause_continue"] = widgetf.New("label").(common.WidgetLabel).Init(modules)
@@ -301,6 +333,8 @@ func (this *Config) Init(modules common.Modules, isGameState bool) gameres.MenuC
 	this.checkboxs["touch_controls"] = widgetf.New("checkbox").(common.WidgetCheckBox).Init(modules, checkbox.DEFAULT_FILE)
 	this.labels["touch_scale"] = widgetf.New("label").(common.WidgetLabel).Init(modules)
 	this.sliders["touch_scale"] = widgetf.New("slider").(common.WidgetSlider).Init(modules, slider.DEFAULT_FILE)
+	this.labels["movement_type"] = widgetf.New("label").(common.WidgetLabel).Init(modules)
+	this.buttons["movement_type"] = widgetf.New("button").(common.WidgetButton).Init(modules, button.DEFAULT_FILE)
 	this.labels["activemods"] = widgetf.New("label").(common.WidgetLabel).Init(modules)
 	this.listboxs["activemods"] = widgetf.New("listbox").(common.WidgetListBox).Init(modules, 10, listbox.DEFAULT_FILE)
 	this.labels["inactivemods"] = widgetf.New("label").(common.WidgetLabel).Init(modules)
@@ -339,6 +373,7 @@ func (this *Config) Init(modules common.Modules, isGameState bool) gameres.MenuC
 	this.buttons["defaults"].SetLabel(modules, msg.Get("Defaults"))
 	this.buttons["cancel"].SetLabel(modules, msg.Get("Cancel"))
 	this.buttons["pause_continue"].SetLabel(modules, msg.Get("Continue"))
+	this.buttons["movement_type"].SetLabel(modules, msg.Get("Change"))
 	this.SetPauseExitText(modules, true)
 	this.buttons["pause_save"].SetLabel(modules, msg.Get("Save Game"))
 	this.SetPauseSaveEnabled(modules, true)
@@ -348,6 +383,7 @@ func (this *Config) Init(modules common.Modules, isGameState bool) gameres.MenuC
 
 	// mods的列表框
 	this.listboxs["activemods"].SetMultiSelect(true)
+	this.listboxs["activemods"].SetCanDrag(true) // 拖动调整加载顺序
 	modList := mods.GetModList()
 	for _, mod := range modList {
 		if mod.GetName() != modmanager.FALLBACK_MOD {
@@ -484,6 +520,8 @@ func (this *Config) Init(modules common.Modules, isGameState bool) gameres.MenuC
 }
 
 func (this *Config) clear() {
+	this.layout.Close()
+
 	// 背景图片精灵
 	if this.background != nil {
 		this.background.Close()
@@ -509,12 +547,32 @@ func (this *Config) clear() {
 		this.defaultsConfirm = nil
 	}
 
+	if this.dependsConfirm != nil {
+		this.dependsConfirm.Close()
+		this.dependsConfirm = nil
+	}
+
+	if this.restartConfirm != nil {
+		this.restartConfirm.Close()
+		this.restartConfirm = nil
+	}
+
+	if this.movementType != nil {
+		this.movementType.Close()
+		this.movementType = nil
+	}
+
 	// 标签控制器
 	if this.tabControl != nil {
 		this.tabControl.Close()
 		this.tabControl = nil
 	}
 
+	if this.tablist != nil {
+		this.tablist.Close()
+		this.tablist = nil
+	}
+
 	// 组件
 	for _, ptr := range this.buttons {
 		ptr.Close()
@@ -570,6 +628,7 @@ func (this *Config) Close() {
 func (this *Config) CreateModTooltip(modules common.Modules, mod common.Mod) string {
 	settings := modules.Settings()
 	msg := modules.Msg()
+	mods := modules.Mods()
 
 	ret := ""
 
@@ -607,6 +666,12 @@ func (this *Config) CreateModTooltip(modules common.Modules, mod common.Mod) str
 			middleSection = true
 			ret += "\n"
 			ret += msg.Get("Engine version:") + " " + engineVer
+
+			// 不兼容当前引擎
+			if !isEngineCompatible(mod) {
+				ret += "\n"
+				ret += msg.Get("Not compatible with engine version") + " " + version.ENGINE.GetString()
+			}
 		}
 
 		if middleSection {
@@ -627,6 +692,11 @@ func (this *Config) CreateModTooltip(modules common.Modules, mod common.Mod) str
 					ret += " (" + dependVer + ")"
 				}
 
+				// 缺失或版本冲突
+				if status := getDependStatus(modules, depend, dependsMin[i], dependsMax[i]); status != "" {
+					ret += " - " + status
+				}
+
 				if i < len(modDepends)-1 {
 					ret += "\n"
 				}
@@ -634,6 +704,16 @@ func (this *Config) CreateModTooltip(modules common.Modules, mod common.Mod) str
 			}
 		}
 
+		// 上次启用失败的原因
+		dependsErrs := mods.GetDependsErrors(mod.GetName())
+		if len(dependsErrs) != 0 {
+			ret += "\n\n"
+			ret += msg.Get("Could not enable:")
+			for _, dependsErr := range dependsErrs {
+				ret += "\n" + strings.TrimPrefix(dependsErr, "ModManager: ")
+			}
+		}
+
 		if ret != "" && strings.HasSuffix(ret, "\n") {
 			ret = strings.TrimSuffix(ret, "\n")
 		}
@@ -642,6 +722,36 @@ func (this *Config) CreateModTooltip(modules common.Modules, mod common.Mod) str
 	return ret
 }
 
+// 是否兼容当前引擎版本
+func isEngineCompatible(mod common.Mod) bool {
+	return version.Compare(mod.GetEngineMinVersion(), version.ENGINE) <= 0 && version.Compare(version.ENGINE, mod.GetEngineMaxVersion()) <= 0
+}
+
+// 依赖的状态，满足时返回空
+func getDependStatus(modules common.Modules, dep string, min, max version.Version) string {
+	mods := modules.Mods()
+	msg := modules.Msg()
+
+	if !tools.FindStr(mods.GetModDirs(), dep) {
+		return msg.Get("missing")
+	}
+
+	depMod, err := mods.LoadMod(dep)
+	if err != nil {
+		return msg.Get("missing")
+	}
+
+	if version.Compare(depMod.GetVersion(), min) < 0 || version.Compare(depMod.GetVersion(), max) > 0 {
+		return msg.Get("installed:") + " " + depMod.GetVersion().GetString()
+	}
+
+	if !isEngineCompatible(depMod) {
+		return msg.Get("incompatible")
+	}
+
+	return ""
+}
+
 func (this *Config) SetPauseExitText(modules common.Modules, enableSave bool) {
 	eset := modules.Eset()
 	msg := modules.Msg()
@@ -670,7 +780,39 @@ func (this *Config) ReadConfig(modules common.Modules) error {
 	}
 	defer infile.Close()
 
+	// 固定位置的组件可以在[widget]段落里修改，滚动盒子里的组件自动排列
+	externals := []struct {
+		id string
+		w  common.Widget
+	}{
+		{"button_ok", this.buttons["ok"]},
+		{"button_defaults", this.buttons["defaults"]},
+		{"button_cancel", this.buttons["cancel"]},
+		{"label_activemods", this.labels["activemods"]},
+		{"listbox_activemods", this.listboxs["activemods"]},
+		{"label_inactivemods", this.labels["inactivemods"]},
+		{"listbox_inactivemods", this.listboxs["inactivemods"]},
+		{"button_activemods_shiftup", this.buttons["activemods_shiftup"]},
+		{"button_activemods_shiftdown", this.buttons["activemods_shiftdown"]},
+		{"button_activemods_deactivate", this.buttons["activemods_deactivate"]},
+		{"button_inactivemods_activate", this.buttons["inactivemods_activate"]},
+	}
+
+	for _, entry := range externals {
+		err = this.layout.RegisterExternal(entry.id, entry.w)
+		if err != nil {
+			return err
+		}
+	}
+
 	for infile.Next(mods) {
+		ok, err := this.layout.ParseKey(infile)
+		if err != nil {
+			return err
+		} else if ok {
+			continue
+		}
+
 		x := 0
 		y := 0
 		a := 0
@@ -779,7 +921,7 @@ func (this *Config) ReadConfig(modules common.Modules) error {
 	this.checkboxs["mouse_aim"].SetTooltip(msg.Get("The player's attacks will be aimed in the direction of the mouse cursor when this is enabled."))
 	this.checkboxs["touch_controls"].SetTooltip(msg.Get("When enabled, a virtual gamepad will be added in-game. Other interactions, such as drag-and-drop behavior, are also altered to better suit touch input."))
 
-	return nil
+	return this.layout.Build(modules, nil)
 }
 
 func (this *Config) AddChildWidget(w common.Widget, tab int) {
@@ -872,6 +1014,7 @@ func (this *Config) arrange(modules common.Modules) error {
 	this.cfgTabs[config.INPUT_TAB].SetOptionWidgets(platform.INPUT_JOYSTICK_DEADZONE, this.labels["joystick_deadzone"], this.sliders["joystick_deadzone"], msg.Get("Joystick Deadzone"))
 	this.cfgTabs[config.INPUT_TAB].SetOptionWidgets(platform.INPUT_TOUCH_CONTROLS, this.labels["touch_controls"], this.checkboxs["touch_controls"], msg.Get("Touch Controls"))
 	this.cfgTabs[config.INPUT_TAB].SetOptionWidgets(platform.INPUT_TOUCH_SCALE, this.labels["touch_scale"], this.sliders["touch_scale"], msg.Get("Touch Gamepad Scaling"))
+	this.cfgTabs[config.INPUT_TAB].SetOptionWidgets(platform.INPUT_MOVEMENT_TYPE, this.labels["movement_type"], this.buttons["movement_type"], msg.Get("Movement type"))
 
 	// ========= keybinds tab =========
 	for i, ptr := range this.keybindsLstb {
@@ -998,8 +1141,8 @@ func (this *Config) arrange(modules common.Modules) error {
 	this.AddChildWidget(this.buttons["activemods_deactivate"], config.MODS_TAB)
 	this.AddChildWidget(this.buttons["inactivemods_activate"], config.MODS_TAB)
 
-	// TODO
 	// tablist
+	this.tablistTab = -1
 
 	this.Update(modules)
 
@@ -1086,6 +1229,23 @@ func (this *Config) updateVideo(modules common.Modules) error {
 	return nil
 }
 
+// 同步界面配置
+func (this *Config) updateInterface(modules common.Modules) {
+	settings := modules.Settings()
+
+	this.checkboxs["show_fps"].SetChecked(settings.Get("show_fps").(bool))
+	this.checkboxs["colorblind"].SetChecked(settings.Get("colorblind").(bool))
+	modules.Render().SetColorblind(settings.Get("colorblind").(bool))
+}
+
+func (this *Config) updateInput(modules common.Modules) {
+	settings := modules.Settings()
+
+	this.checkboxs["mouse_move"].SetChecked(settings.Get("mouse_move").(bool))
+	this.checkboxs["mouse_aim"].SetChecked(settings.Get("mouse_aim").(bool))
+	this.checkboxs["no_mouse"].SetChecked(settings.Get("no_mouse").(bool))
+}
+
 func (this *Config) updateMods(modules common.Modules) error {
 	this.listboxs["activemods"].Refresh(modules)
 	this.listboxs["inactivemods"].Refresh(modules)
@@ -1099,6 +1259,9 @@ func (this *Config) Update(modules common.Modules) error {
 		return err
 	}
 
+	this.updateInterface(modules)
+	this.updateInput(modules)
+
 	err = this.updateMods(modules)
 	if err != nil {
 		return err
@@ -1137,6 +1300,11 @@ func (this *Config) RefreshWidgets(modules common.Modules) error {
 	this.buttons["cancel"].SetPos1(modules, 0, 0)
 
 	this.defaultsConfirm.Align(modules)
+	this.restartConfirm.Align(modules)
+	this.movementType.Align(modules)
+	if this.dependsConfirm != nil {
+		this.dependsConfirm.Align(modules)
+	}
 
 	// 设置每个滚动盒子的位置
 	for i, _ := range this.cfgTabs {
@@ -1145,6 +1313,9 @@ func (this *Config) RefreshWidgets(modules common.Modules) error {
 
 	this.inputConfirm.Align(modules)
 
+	// mod添加的组件跟随菜单框
+	this.layout.Align(modules, rect.Construct(this.frame.X, this.frame.Y))
+
 	return nil
 }
 
@@ -1159,6 +1330,7 @@ func (this *Config) logicInput() {
 //  游戏内退出逻辑
 func (this *Config) logicExit(modules common.Modules) error {
 	inpt := modules.Inpt()
+	settings := modules.Settings()
 
 	err := this.cfgTabs[config.EXIT_TAB].scrollbox.Logic(modules)
 	if err != nil {
@@ -1168,8 +1340,8 @@ func (this *Config) logicExit(modules common.Modules) error {
 	// 父组件的坐标转化到其子组件的坐标
 	mouse, ok := this.cfgTabs[config.EXIT_TAB].scrollbox.InputAssist(inpt.GetMouse())
 
-	// 父组件范围内
-	if ok {
+	// 父组件范围内，或使用键盘
+	if ok || !inpt.UsingMouse(settings) {
 		if this.cfgTabs[config.EXIT_TAB].options[config.EXIT_OPTION_CONTINUE].enabled && this.buttons["pause_continue"].CheckClickAt(modules, mouse.X, mouse.Y) {
 			this.clickedPauseContinue = true
 		} else if this.cfgTabs[config.EXIT_TAB].options[config.EXIT_OPTION_SAVE].enabled && this.buttons["pause_save"].CheckClickAt(modules, mouse.X, mouse.Y) {
@@ -1184,6 +1356,7 @@ func (this *Config) logicExit(modules common.Modules) error {
 
 func (this *Config) logicVideo(modules common.Modules) error {
 	inpt := modules.Inpt()
+	settings := modules.Settings()
 
 	err := this.cfgTabs[config.VIDEO_TAB].scrollbox.Logic(modules)
 	if err != nil {
@@ -1193,8 +1366,8 @@ func (this *Config) logicVideo(modules common.Modules) error {
 	// 父组件的坐标转化到其子组件的坐标
 	mouse, ok := this.cfgTabs[config.VIDEO_TAB].scrollbox.InputAssist(inpt.GetMouse())
 
-	// 父组件范围内
-	if ok {
+	// 父组件范围内，或使用键盘
+	if ok || !inpt.UsingMouse(settings) {
 		if this.horizontalLists["renderer"].CheckClickAt(modules, mouse.X, mouse.Y) {
 			this.newRenderDevice = this.horizontalLists["renderer"].GetValue()
 		}
@@ -1205,6 +1378,39 @@ func (this *Config) logicVideo(modules common.Modules) error {
 	return nil
 }
 
+func (this *Config) logicInputTab(modules common.Modules) error {
+	inpt := modules.Inpt()
+	settings := modules.Settings()
+
+	err := this.cfgTabs[config.INPUT_TAB].scrollbox.Logic(modules)
+	if err != nil {
+		return err
+	}
+
+	mouse, ok := this.cfgTabs[config.INPUT_TAB].scrollbox.InputAssist(inpt.GetMouse())
+
+	// 重新选择操作方式
+	if (ok || !inpt.UsingMouse(settings)) && this.cfgTabs[config.INPUT_TAB].options[platform.INPUT_MOVEMENT_TYPE].enabled && this.buttons["movement_type"].CheckClickAt(modules, mouse.X, mouse.Y) {
+		this.movementType.Open(modules)
+	}
+
+	return nil
+}
+
+// 操作方式弹窗
+func (this *Config) logicMovementType(modules common.Modules) error {
+	err := this.movementType.Logic(modules, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	if this.movementType.GetConfirmed() {
+		this.updateInput(modules)
+	}
+
+	return nil
+}
+
 func (this *Config) logicMods(modules common.Modules) error {
 	if this.listboxs["activemods"].CheckClick(modules) {
 
@@ -1235,23 +1441,240 @@ func (this *Config) logicMods(modules common.Modules) error {
 	} else if this.buttons["inactivemods_activate"].CheckClick(modules) {
 
 		// enable
-		for i := 0; i < this.listboxs["inactivemods"].GetSize(); i++ {
-			if this.listboxs["inactivemods"].IsSelected(i) {
-				if val, ok := this.listboxs["inactivemods"].GetValue(i); ok {
-					tooltip, _ := this.listboxs["inactivemods"].GetTooltip(i)
-					this.listboxs["activemods"].Append(modules, val, tooltip)
-					this.listboxs["inactivemods"].Remove(modules, i)
-				}
-				i--
+		missing, err := this.getMissingDepends(modules)
+		if err != nil {
+			return err
+		}
+
+		if len(missing) != 0 {
+			// 先确认是否一起启用依赖
+			err = this.showDependsConfirm(modules, missing)
+			if err != nil {
+				return err
+			}
+		} else {
+			this.activateSelectedMods(modules)
+		}
+	}
+
+	return nil
+}
+
+// 启用选中的mod
+func (this *Config) activateSelectedMods(modules common.Modules) {
+	for i := 0; i < this.listboxs["inactivemods"].GetSize(); i++ {
+		if this.listboxs["inactivemods"].IsSelected(i) {
+			if val, ok := this.listboxs["inactivemods"].GetValue(i); ok {
+				tooltip, _ := this.listboxs["inactivemods"].GetTooltip(i)
+				this.listboxs["activemods"].Append(modules, val, tooltip)
+				this.listboxs["inactivemods"].Remove(modules, i)
+			}
+			i--
+		}
+	}
+}
+
+// 按名字启用未激活的mod
+func (this *Config) activateMod(modules common.Modules, name string) {
+	for i := 0; i < this.listboxs["inactivemods"].GetSize(); i++ {
+		if val, ok := this.listboxs["inactivemods"].GetValue(i); ok && val == name {
+			tooltip, _ := this.listboxs["inactivemods"].GetTooltip(i)
+			this.listboxs["activemods"].Append(modules, val, tooltip)
+			this.listboxs["inactivemods"].Remove(modules, i)
+			return
+		}
+	}
+}
+
+// 选中的mod所需，但还没有启用的依赖，依赖在前
+func (this *Config) getMissingDepends(modules common.Modules) ([]string, error) {
+	mods := modules.Mods()
+
+	active := map[string]struct{}{}
+	for i := 0; i < this.listboxs["activemods"].GetSize(); i++ {
+		if val, ok := this.listboxs["activemods"].GetValue(i); ok {
+			active[val] = struct{}{}
+		}
+	}
+
+	available := map[string]bool{} // 未激活的mod 是否选中
+	for i := 0; i < this.listboxs["inactivemods"].GetSize(); i++ {
+		if val, ok := this.listboxs["inactivemods"].GetValue(i); ok {
+			available[val] = this.listboxs["inactivemods"].IsSelected(i)
+		}
+	}
+
+	var missing []string
+	visited := map[string]struct{}{}
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		if _, ok := visited[name]; ok {
+			return nil
+		}
+		visited[name] = struct{}{}
+
+		mod, err := mods.LoadMod(name)
+		if err != nil {
+			return err
+		}
+
+		for _, dep := range mod.GetDepends() {
+			if _, ok := active[dep]; ok {
+				continue
+			}
+
+			selected, ok := available[dep]
+			if !ok {
+				// 找不到的依赖 启用时由ApplyDepends报告
+				continue
 			}
 
+			err := visit(dep)
+			if err != nil {
+				return err
+			}
+
+			if !selected && !tools.FindStr(missing, dep) {
+				missing = append(missing, dep)
+			}
+		}
+
+		return nil
+	}
+
+	for i := 0; i < this.listboxs["inactivemods"].GetSize(); i++ {
+		if val, ok := this.listboxs["inactivemods"].GetValue(i); ok && this.listboxs["inactivemods"].IsSelected(i) {
+			err := visit(val)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return missing, nil
+}
+
+func (this *Config) showDependsConfirm(modules common.Modules, missing []string) error {
+	msg := modules.Msg()
+
+	if this.dependsConfirm != nil {
+		this.dependsConfirm.Close()
+		this.dependsConfirm = nil
+	}
+
+	confirm, err := NewConfirm(modules, msg.Get("Enable"), msg.Get("The selected mods require:")+"\n"+strings.Join(missing, ", "))
+	if err != nil {
+		return err
+	}
+
+	this.pendingDepends = missing
+	this.dependsConfirm = confirm
+	this.dependsConfirm.SetVisible(true)
+	return this.dependsConfirm.Align(modules)
+}
+
+// 确认启用依赖
+func (this *Config) logicDependsConfirm(modules common.Modules) error {
+	err := this.dependsConfirm.Logic(modules, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	if this.dependsConfirm.GetConfirmClicked() {
+		// 关闭按钮即取消 不启用
+		if !this.dependsConfirm.GetCancelClicked() {
+			for _, dep := range this.pendingDepends {
+				this.activateMod(modules, dep)
+			}
+
+			this.activateSelectedMods(modules)
+		}
+
+		this.pendingDepends = nil
+		this.dependsConfirm.SetVisible(false)
+	}
+
+	return nil
+}
+
+// 确认重启
+func (this *Config) logicRestartConfirm(modules common.Modules) error {
+	err := this.restartConfirm.Logic(modules, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	if this.restartConfirm.GetConfirmClicked() {
+		if !this.restartConfirm.GetCancelClicked() {
+			this.clickedAccept = true
 		}
+
+		this.restartConfirm.SetVisible(false)
 	}
 
 	return nil
 }
 
+// 激活的mod列表是否有改动
+func (this *Config) getModsChanged(modules common.Modules) bool {
+	mods := modules.Mods()
+
+	var current []string
+	for _, mod := range mods.GetModList() {
+		if mod.GetName() != modmanager.FALLBACK_MOD {
+			current = append(current, mod.GetName())
+		}
+	}
+
+	if len(current) != this.listboxs["activemods"].GetSize() {
+		return true
+	}
+
+	for i, name := range current {
+		if val, ok := this.listboxs["activemods"].GetValue(i); !ok || val != name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 当前标签可聚焦的组件
+func (this *Config) refreshTablist() {
+	this.tablist.Clear()
+	this.tablist.Add(this.tabControl)
+
+	tab := (int)(this.tabControl.GetActiveTab())
+	if tab < config.KEYBINDS_TAB {
+		this.tablist.Add(this.cfgTabs[tab].scrollbox)
+	} else {
+		for i, ptr := range this.childWidget {
+			if this.optionTab[i] == tab {
+				this.tablist.Add(ptr)
+			}
+		}
+	}
+
+	if this.enableGameStateButtons {
+		this.tablist.Add(this.buttons["ok"])
+		this.tablist.Add(this.buttons["defaults"])
+		this.tablist.Add(this.buttons["cancel"])
+	}
+
+	this.tablistTab = tab
+}
+
 func (this *Config) logicMain(modules common.Modules) (bool, error) {
+	if this.tablistTab != (int)(this.tabControl.GetActiveTab()) {
+		this.refreshTablist()
+	}
+
+	err := this.tablist.Logic(modules)
+	if err != nil {
+		return false, err
+	}
+
 	for i, ptr := range this.childWidget {
 		if ptr.GetInFocus() && this.optionTab[i] != config.NO_TAB {
 			this.tabControl.SetActiveTab((uint)(this.optionTab[i]))
@@ -1259,14 +1682,19 @@ func (this *Config) logicMain(modules common.Modules) (bool, error) {
 		}
 	}
 
-	err := this.tabControl.Logic(modules)
+	err = this.tabControl.Logic(modules)
 	if err != nil {
 		return false, err
 	}
 
 	if this.enableGameStateButtons {
 		if this.buttons["ok"].CheckClick(modules) {
-			this.clickedAccept = true
+			if this.getModsChanged(modules) {
+				// mod改动 先提示需要重启
+				this.restartConfirm.SetVisible(true)
+			} else {
+				this.clickedAccept = true
+			}
 			return false, nil
 		}
 
@@ -1300,6 +1728,12 @@ func (this *Config) Logic(modules common.Modules) error {
 	} else if this.inputConfirm.GetVisible() {
 		this.logicInput()
 		return nil
+	} else if this.dependsConfirm != nil && this.dependsConfirm.GetVisible() {
+		return this.logicDependsConfirm(modules)
+	} else if this.restartConfirm.GetVisible() {
+		return this.logicRestartConfirm(modules)
+	} else if this.movementType.GetVisible() {
+		return this.logicMovementType(modules)
 	} else {
 		// 主逻辑
 		ret, err := this.logicMain(modules)
@@ -1331,6 +1765,18 @@ func (this *Config) Logic(modules common.Modules) error {
 			return err
 		}
 
+	case config.AUDIO_TAB, config.INTERFACE_TAB:
+		err := this.cfgTabs[this.activeTab].scrollbox.Logic(modules)
+		if err != nil {
+			return err
+		}
+
+	case config.INPUT_TAB:
+		err := this.logicInputTab(modules)
+		if err != nil {
+			return err
+		}
+
 	case config.MODS_TAB:
 		err := this.logicMods(modules)
 		if err != nil {
@@ -1382,6 +1828,27 @@ func (this *Config) RenderDialogs(modules common.Modules) error {
 		}
 	}
 
+	if this.dependsConfirm != nil && this.dependsConfirm.GetVisible() {
+		err := this.dependsConfirm.Render(modules)
+		if err != nil {
+			return err
+		}
+	}
+
+	if this.restartConfirm.GetVisible() {
+		err := this.restartConfirm.Render(modules)
+		if err != nil {
+			return err
+		}
+	}
+
+	if this.movementType.GetVisible() {
+		err := this.movementType.Render(modules)
+		if err != nil {
+			return err
+		}
+	}
+
 	//TODO
 
 	return nil
@@ -1434,6 +1901,11 @@ func (this *Config) Render(modules common.Modules) error {
 		return err
 	}
 
+	err = this.layout.Render(modules)
+	if err != nil {
+		return err
+	}
+
 	// 弹窗
 	err = this.RenderDialogs(modules)
 	if err != nil {
@@ -1545,6 +2017,11 @@ func (this *Config) ResetSelectedTab(modules common.Modules) error {
 	}
 
 	this.inputConfirm.SetVisible(false)
+	this.restartConfirm.SetVisible(false)
+	if this.dependsConfirm != nil {
+		this.dependsConfirm.SetVisible(false)
+	}
+	this.pendingDepends = nil
 	this.inputConfirmTimer.Reset(timer.END)
 	this.keybindTipTimer.Reset(timer.END)
 
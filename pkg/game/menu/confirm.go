@@ -22,13 +22,18 @@ type Confirm struct {
 	isWithinButtons  bool // 是否悬停在关闭按钮上
 }
 
-func NewConfirm(modules common.Modules, buttonMsg, boxMsg string) *Confirm {
+func NewConfirm(modules common.Modules, buttonMsg, boxMsg string) (*Confirm, error) {
 	c := &Confirm{}
-	c.Init(modules, buttonMsg, boxMsg)
-	return c
+	_, err := c.Init(modules, buttonMsg, boxMsg)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
 }
 
-func (this *Confirm) Init(modules common.Modules, buttonMsg, boxMsg string) gameres.MenuConfirm {
+// 没有menus/confirm.txt时使用默认布局
+func (this *Confirm) Init(modules common.Modules, buttonMsg, boxMsg string) (gameres.MenuConfirm, error) {
 	mods := modules.Mods()
 	widgetf := modules.Widgetf()
 
@@ -39,15 +44,29 @@ func (this *Confirm) Init(modules common.Modules, buttonMsg, boxMsg string) game
 	this.label = widgetf.New("label").(common.WidgetLabel).Init(modules)
 	infile := fileparser.Construct()
 	err := infile.Open("menus/confirm.txt", true, mods)
-	if err != nil {
-		panic(err)
+	if err != nil && !utils.IsNotExist(err) {
+		this.label.Close()
+		return nil, common.NewLoadError("menus/confirm.txt", 0, "", "", err)
+	} else if err == nil {
+		defer infile.Close()
+
+		for infile.Next(mods) {
+			if this.ParseMenuKey(infile.Key(), infile.Val()) {
+				continue
+			}
+
+			_, err = this.ParseLayoutKey(&infile)
+			if err != nil {
+				this.Close()
+				return nil, err
+			}
+		}
 	}
-	defer infile.Close()
 
-	for infile.Next(mods) {
-		if this.ParseMenuKey(infile.Key(), infile.Val()) {
-			continue
-		}
+	err = this.BuildLayout(modules)
+	if err != nil {
+		this.Close()
+		return nil, err
 	}
 
 	if buttonMsg != "" {
@@ -67,7 +86,7 @@ func (this *Confirm) Init(modules common.Modules, buttonMsg, boxMsg string) game
 	this.GetTablist().Add(this.buttonClose)
 	this.SetBackground(modules, "images/menus/confirm_bg.png")
 
-	return this
+	return this, nil
 }
 
 func (this *Confirm) Clear() {
@@ -125,6 +144,7 @@ func (this *Confirm) Logic(modules common.Modules, pc gameres.Avatar, powers gam
 		// 处理键盘跳转逻辑
 		this.GetTablist().Logic(modules)
 		this.confirmClicked = false
+		this.cancelClicked = false // 弹窗可以重复使用
 
 		if this.hasConfirmButton && this.buttonConfirm.CheckClick(modules) {
 			this.confirmClicked = true
@@ -143,6 +163,14 @@ func (this *Confirm) Logic(modules common.Modules, pc gameres.Avatar, powers gam
 	return nil
 }
 
+func (this *Confirm) GetConfirmClicked() bool {
+	return this.confirmClicked
+}
+
+func (this *Confirm) GetCancelClicked() bool {
+	return this.cancelClicked
+}
+
 func (this *Confirm) Render(modules common.Modules) error {
 	err := this.Menu.Render(modules) // 背景
 	if err != nil {
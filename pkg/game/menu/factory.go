@@ -18,6 +18,10 @@ func (obj Factory) New(type1 string) interface{} {
 		return &Confirm{}
 	case "exit":
 		return &Exit{}
+	case "movementtype":
+		return &MovementType{}
+	case "layout":
+		return &Layout{}
 	case "statbar":
 		return &StatBar{}
 	case "inventory":
@@ -2,8 +2,11 @@ package menu
 
 import (
 	"monster/pkg/common"
+	"monster/pkg/common/define"
 	"monster/pkg/common/gameres"
+	"monster/pkg/filesystem/logfile"
 	"monster/pkg/game/base"
+	"monster/pkg/utils"
 )
 
 type Inventory struct {
@@ -11,6 +14,8 @@ type Inventory struct {
 
 	currency         int
 	changedEquipment bool
+	carried          map[define.ItemId]int    // 背包中的道具数量
+	equipped         map[string]define.ItemId // 装备栏，按物品类型
 }
 
 func NewInventory(modules common.Modules) *Inventory {
@@ -26,20 +31,51 @@ func (this *Inventory) Init(modules common.Modules) gameres.MenuInventory {
 
 	// self
 	this.changedEquipment = true
+	this.carried = map[define.ItemId]int{}
+	this.equipped = map[string]define.ItemId{}
+
+	// 界面组件来自菜单文件，按tab_order加入tablist
+	err := this.LoadMenuFile(modules, "menus/inventory.txt")
+	if err != nil && !utils.IsNotExist(err) {
+		logfile.LogError("MenuInventory: %s", err)
+	}
+
+	this.Align(modules)
+
 	return this
 }
 
 func (this *Inventory) Clear() {
+	this.carried = map[define.ItemId]int{}
+	this.equipped = map[string]define.ItemId{}
 }
 
 func (this *Inventory) Close() {
 	this.Menu.Close(this)
 }
 
-func (this *Inventory) Logic(common.Modules, gameres.Avatar, gameres.PowerManager) error {
+func (this *Inventory) Logic(modules common.Modules, pc gameres.Avatar, powers gameres.PowerManager) error {
+	if !this.GetVisible() {
+		return nil
+	}
+
+	this.GetTablist().Logic(modules)
+
+	if this.CheckLayoutClick(modules) == "close" {
+		this.SetVisible(false)
+	}
+
 	return nil
 }
 
+func (this *Inventory) Render(modules common.Modules) error {
+	if !this.GetVisible() {
+		return nil
+	}
+
+	return this.Menu.Render(modules)
+}
+
 func (this *Inventory) SetChangedEquipment(val bool) {
 	this.changedEquipment = val
 }
@@ -55,3 +91,50 @@ func (this *Inventory) SetCurrency(val int) {
 func (this *Inventory) GetCurrency() int {
 	return this.currency
 }
+
+// TODO
+// 背包格子
+func (this *Inventory) AddItem(id define.ItemId, quantity int) {
+	if id <= 0 || quantity <= 0 {
+		return
+	}
+
+	this.carried[id] += quantity
+}
+
+// 数量不足时不扣除
+func (this *Inventory) RemoveItem(id define.ItemId, quantity int) bool {
+	if this.carried[id] < quantity {
+		return false
+	}
+
+	this.carried[id] -= quantity
+	if this.carried[id] == 0 {
+		delete(this.carried, id)
+	}
+
+	return true
+}
+
+func (this *Inventory) GetItemCountCarried(id define.ItemId) int {
+	return this.carried[id]
+}
+
+// id为0时卸下，变化后重新加载角色图层
+func (this *Inventory) SetEquipped(slotType string, id define.ItemId) {
+	if this.equipped[slotType] == id {
+		return
+	}
+
+	if id <= 0 {
+		delete(this.equipped, slotType)
+	} else {
+		this.equipped[slotType] = id
+	}
+
+	this.changedEquipment = true
+}
+
+func (this *Inventory) GetEquipped(slotType string) define.ItemId {
+	return this.equipped[slotType]
+}
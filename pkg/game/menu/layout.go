@@ -0,0 +1,84 @@
+package menu
+
+import (
+	"monster/pkg/common"
+	"monster/pkg/common/gameres"
+	"monster/pkg/game/base"
+)
+
+// 完全由菜单文件描述的菜单，id为close的按钮关闭菜单
+type Layout struct {
+	base.Menu
+	clicked string
+}
+
+func NewLayout(modules common.Modules, filename string) (*Layout, error) {
+	l := &Layout{}
+
+	_, err := l.Init(modules, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// 加载失败时关闭已创建的组件并返回错误
+func (this *Layout) Init(modules common.Modules, filename string) (gameres.MenuLayout, error) {
+	// base
+	this.Menu = base.ConstructMenu(modules)
+
+	// self
+	err := this.LoadMenuFile(modules, filename)
+	if err != nil {
+		this.Close()
+		return nil, err
+	}
+
+	this.Align(modules)
+
+	return this, nil
+}
+
+func (this *Layout) Clear() {
+}
+
+func (this *Layout) Close() {
+	this.Menu.Close(this)
+}
+
+func (this *Layout) Align(modules common.Modules) error {
+	return this.Menu.Align(modules)
+}
+
+func (this *Layout) Logic(modules common.Modules, pc gameres.Avatar, powers gameres.PowerManager) error {
+	if !this.GetVisible() {
+		return nil
+	}
+
+	this.GetTablist().Logic(modules)
+
+	clicked := this.CheckLayoutClick(modules)
+	if clicked == "close" {
+		this.SetVisible(false)
+	} else if clicked != "" {
+		this.clicked = clicked
+	}
+
+	return nil
+}
+
+// 被点击的按钮id，读取后重置
+func (this *Layout) GetClicked() string {
+	clicked := this.clicked
+	this.clicked = ""
+	return clicked
+}
+
+func (this *Layout) Render(modules common.Modules) error {
+	if !this.GetVisible() {
+		return nil
+	}
+
+	return this.Menu.Render(modules)
+}
@@ -0,0 +1,342 @@
+package menu
+
+import (
+	"fmt"
+	"monster/pkg/common"
+	"monster/pkg/common/define/fontengine"
+	"monster/pkg/common/define/inputstate"
+	"monster/pkg/common/define/widget/button"
+	"monster/pkg/common/gameres"
+	"monster/pkg/common/labelinfo"
+	"monster/pkg/filesystem/fileparser"
+	"monster/pkg/filesystem/logfile"
+	"monster/pkg/game/base"
+	"monster/pkg/utils"
+	"monster/pkg/utils/parsing"
+)
+
+const (
+	MOVEMENT_TYPE_KEYBOARD = iota
+	MOVEMENT_TYPE_MOUSE
+	MOVEMENT_TYPE_JOYSTICK
+)
+
+// 首次启动时选择操作方式
+type MovementType struct {
+	base.Menu
+	buttonKeyboard   common.WidgetButton
+	buttonMouse      common.WidgetButton
+	buttonJoystick   common.WidgetButton
+	buttonConfirm    common.WidgetButton
+	labelTitle       common.WidgetLabel
+	labelDescription common.WidgetLabel
+	confirmed        bool
+	original         int // 打开时的操作方式，取消时恢复
+}
+
+func NewMovementType(modules common.Modules) (*MovementType, error) {
+	mt := &MovementType{}
+	_, err := mt.Init(modules)
+	if err != nil {
+		return nil, err
+	}
+
+	return mt, nil
+}
+
+// 没有menus/movement_type.txt时使用默认布局
+func (this *MovementType) Init(modules common.Modules) (gameres.MenuMovementType, error) {
+	mods := modules.Mods()
+	msg := modules.Msg()
+	font := modules.Font()
+	widgetf := modules.Widgetf()
+
+	// base
+	this.Menu = base.ConstructMenu(modules)
+
+	// self
+	this.buttonKeyboard = widgetf.New("button").(common.WidgetButton).Init(modules, button.DEFAULT_FILE)
+	this.buttonKeyboard.SetLabel(modules, msg.Get("Keyboard"))
+	this.buttonMouse = widgetf.New("button").(common.WidgetButton).Init(modules, button.DEFAULT_FILE)
+	this.buttonMouse.SetLabel(modules, msg.Get("Mouse"))
+	this.buttonJoystick = widgetf.New("button").(common.WidgetButton).Init(modules, button.DEFAULT_FILE)
+	this.buttonJoystick.SetLabel(modules, msg.Get("Joystick"))
+	this.buttonConfirm = widgetf.New("button").(common.WidgetButton).Init(modules, button.DEFAULT_FILE)
+	this.buttonConfirm.SetLabel(modules, msg.Get("OK"))
+
+	this.labelTitle = widgetf.New("label").(common.WidgetLabel).Init(modules)
+	this.labelTitle.SetText(msg.Get("Select a movement type:"))
+	this.labelTitle.SetJustify(fontengine.JUSTIFY_CENTER)
+	this.labelTitle.SetColor(font.GetColor(fontengine.COLOR_MENU_NORMAL))
+
+	this.labelDescription = widgetf.New("label").(common.WidgetLabel).Init(modules)
+	this.labelDescription.SetJustify(fontengine.JUSTIFY_CENTER)
+	this.labelDescription.SetMarkup(true)
+	this.labelDescription.SetColor(font.GetColor(fontengine.COLOR_MENU_NORMAL))
+
+	widgets := []struct {
+		id string
+		w  common.Widget
+	}{
+		{"title", this.labelTitle},
+		{"description", this.labelDescription},
+		{"keyboard", this.buttonKeyboard},
+		{"mouse", this.buttonMouse},
+		{"joystick", this.buttonJoystick},
+		{"confirm", this.buttonConfirm},
+	}
+
+	// 注册后由布局关闭，注册失败的组件在这里关闭
+	for i, entry := range widgets {
+		err := this.RegisterWidget(entry.id, entry.w)
+		if err != nil {
+			for _, rest := range widgets[i:] {
+				rest.w.Close()
+			}
+			this.Close()
+			return nil, err
+		}
+	}
+
+	infile := fileparser.New()
+	err := infile.Open("menus/movement_type.txt", true, mods)
+	if err != nil && !utils.IsNotExist(err) {
+		this.Close()
+		return nil, common.NewLoadError("menus/movement_type.txt", 0, "", "", err)
+	} else if err != nil {
+		this.setDefaultLayout()
+	} else {
+		defer infile.Close()
+
+		err = this.readMenuFile(mods, infile)
+		if err != nil {
+			this.Close()
+			return nil, err
+		}
+	}
+
+	this.GetTablist().SetIngoreNoMouse(true)
+
+	err = this.BuildLayout(modules)
+	if err != nil {
+		this.Close()
+		return nil, err
+	}
+
+	// 和确认弹窗一样，没有背景图也能使用
+	err = this.SetBackground(modules, "images/menus/confirm_bg.png")
+	if err != nil {
+		logfile.LogError("MenuMovementType: %s", err)
+	}
+
+	this.refreshDescription(modules)
+	this.Align(modules)
+
+	return this, nil
+}
+
+func (this *MovementType) readMenuFile(mods common.ModManager, infile *fileparser.FileParser) error {
+	for infile.Next(mods) {
+		if this.ParseMenuKey(infile.Key(), infile.Val()) {
+			continue
+		}
+
+		ok, err := this.ParseLayoutKey(infile)
+		if err != nil {
+			return err
+		} else if ok {
+			continue
+		}
+
+		switch infile.Key() {
+		case "title":
+			this.labelTitle.SetFromLabelInfo(parsing.PopLabelInfo(infile.Val()))
+		case "description":
+			this.labelDescription.SetFromLabelInfo(parsing.PopLabelInfo(infile.Val()))
+		case "keyboard":
+			this.setButtonPos(this.buttonKeyboard, infile.Val())
+		case "mouse":
+			this.setButtonPos(this.buttonMouse, infile.Val())
+		case "joystick":
+			this.setButtonPos(this.buttonJoystick, infile.Val())
+		case "confirm":
+			this.setButtonPos(this.buttonConfirm, infile.Val())
+		default:
+			logfile.LogError("MenuMovementType: '%s' is not a valid key.", infile.Key())
+		}
+	}
+
+	return nil
+}
+
+// 屏幕中间的窗口，标题和说明在上，三个选项一行，确认按钮在下
+func (this *MovementType) setDefaultLayout() {
+	const width, height, margin = 400, 200, 16
+
+	this.ParseMenuKey("pos", fmt.Sprintf("%d,%d,%d,%d", -width/2, -height/2, width, height))
+	this.ParseMenuKey("align", "center")
+
+	title := labelinfo.Construct()
+	title.X, title.Y, title.Justify = width/2, margin, fontengine.JUSTIFY_CENTER
+	this.labelTitle.SetFromLabelInfo(title)
+
+	description := title
+	description.Y = margin * 3
+	this.labelDescription.SetFromLabelInfo(description)
+
+	buttonW := this.buttonKeyboard.GetPos().W
+	buttonH := this.buttonKeyboard.GetPos().H
+	x := (width - buttonW*3 - margin*2) / 2
+	for _, b := range []common.WidgetButton{this.buttonKeyboard, this.buttonMouse, this.buttonJoystick} {
+		b.SetPosBase(x, height/2, b.GetAlignment())
+		x += buttonW + margin
+	}
+
+	this.buttonConfirm.SetPosBase((width-this.buttonConfirm.GetPos().W)/2, height-margin-buttonH, this.buttonConfirm.GetAlignment())
+}
+
+func (this *MovementType) setButtonPos(b common.WidgetButton, val string) {
+	x, strVal := parsing.PopFirstInt(val, "")
+	y, _ := parsing.PopFirstInt(strVal, "")
+	b.SetPosBase(x, y, b.GetAlignment())
+}
+
+// 组件由菜单布局关闭
+func (this *MovementType) Clear() {
+}
+
+func (this *MovementType) Close() {
+	this.Menu.Close(this)
+}
+
+func (this *MovementType) Align(modules common.Modules) error {
+	this.Menu.Align(modules)
+
+	this.labelDescription.SetMaxWidth(this.GetWindowArea().W)
+
+	return nil
+}
+
+// 显示弹窗，记下当前的操作方式，没有手柄时禁用手柄选项
+func (this *MovementType) Open(modules common.Modules) {
+	inpt := modules.Inpt()
+
+	this.original = this.getMovementType(modules)
+	this.buttonJoystick.SetEnabled(inpt.GetJoystickCount() > 0)
+	this.refreshDescription(modules)
+	this.SetVisible(true)
+}
+
+// 根据设置判断当前的操作方式
+func (this *MovementType) getMovementType(modules common.Modules) int {
+	settings := modules.Settings()
+
+	if settings.Get("enable_joystick").(bool) && settings.Get("no_mouse").(bool) {
+		return MOVEMENT_TYPE_JOYSTICK
+	} else if settings.Get("mouse_move").(bool) {
+		return MOVEMENT_TYPE_MOUSE
+	}
+
+	return MOVEMENT_TYPE_KEYBOARD
+}
+
+func (this *MovementType) setMovementType(modules common.Modules, type1 int) {
+	settings := modules.Settings()
+
+	switch type1 {
+	case MOVEMENT_TYPE_KEYBOARD:
+		settings.Set("mouse_move", false)
+		settings.Set("mouse_aim", true)
+		settings.Set("no_mouse", false)
+		settings.Set("enable_joystick", false)
+	case MOVEMENT_TYPE_MOUSE:
+		settings.Set("mouse_move", true)
+		settings.Set("mouse_aim", true)
+		settings.Set("no_mouse", false)
+		settings.Set("enable_joystick", false)
+	case MOVEMENT_TYPE_JOYSTICK:
+		settings.Set("mouse_move", false)
+		settings.Set("mouse_aim", false)
+		settings.Set("no_mouse", true)
+		settings.Set("enable_joystick", true)
+	}
+
+	this.refreshDescription(modules)
+}
+
+// 说明当前操作方式的按键
+func (this *MovementType) refreshDescription(modules common.Modules) {
+	msg := modules.Msg()
+	inpt := modules.Inpt()
+	settings := modules.Settings()
+
+	name := msg.Get("Keyboard")
+	switch this.getMovementType(modules) {
+	case MOVEMENT_TYPE_MOUSE:
+		name = msg.Get("Mouse")
+	case MOVEMENT_TYPE_JOYSTICK:
+		name = msg.Get("Joystick")
+	}
+
+	text := msg.Get("Current") + ": [color=menu_bonus]" + name + "[/color]\n"
+	text += msg.Get("Move") + ": " + inpt.GetMovementString(settings, msg) + "  "
+	text += msg.Get("Attack") + ": " + inpt.GetAttackString(msg)
+	this.labelDescription.SetText(text)
+}
+
+func (this *MovementType) Logic(modules common.Modules, pc gameres.Avatar, powers gameres.PowerManager) error {
+	inpt := modules.Inpt()
+	settings := modules.Settings()
+
+	if !this.GetVisible() {
+		return nil
+	}
+
+	// 取消时恢复打开前的操作方式
+	if inpt.GetPressing(inputstate.CANCEL) && !inpt.GetLock(inputstate.CANCEL) {
+		inpt.SetLock(inputstate.CANCEL, true)
+		this.setMovementType(modules, this.original)
+		this.SetVisible(false)
+		return nil
+	}
+
+	this.GetTablist().Logic(modules)
+
+	// 选择先预览，确认后才保存
+	if this.buttonKeyboard.CheckClick(modules) {
+		this.setMovementType(modules, MOVEMENT_TYPE_KEYBOARD)
+	} else if this.buttonMouse.CheckClick(modules) {
+		this.setMovementType(modules, MOVEMENT_TYPE_MOUSE)
+	} else if this.buttonJoystick.CheckClick(modules) && inpt.GetJoystickCount() > 0 {
+		this.setMovementType(modules, MOVEMENT_TYPE_JOYSTICK)
+	}
+
+	if this.buttonConfirm.CheckClick(modules) {
+		settings.Set("move_type_dimissed", true)
+		err := settings.SaveSettings()
+		if err != nil {
+			logfile.LogError("MenuMovementType: %s", err)
+		}
+
+		this.original = this.getMovementType(modules)
+		this.confirmed = true
+		this.SetVisible(false)
+	}
+
+	return nil
+}
+
+// 是否点击了确认，读取后重置
+func (this *MovementType) GetConfirmed() bool {
+	confirmed := this.confirmed
+	this.confirmed = false
+	return confirmed
+}
+
+func (this *MovementType) Render(modules common.Modules) error {
+	if !this.GetVisible() {
+		return nil
+	}
+
+	return this.Menu.Render(modules)
+}
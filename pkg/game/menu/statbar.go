@@ -89,6 +89,14 @@ func (this *StatBar) Init(modules common.Modules, type1 uint16) gameres.MenuStat
 			continue
 		}
 
+		// 额外的组件
+		ok, err := this.Menu.ParseLayoutKey(infile)
+		if err != nil {
+			panic(err)
+		} else if ok {
+			continue
+		}
+
 		switch key {
 		case "bar_pos":
 			this.barPos = parsing.ToRect(val)
@@ -118,6 +126,20 @@ func (this *StatBar) Init(modules common.Modules, type1 uint16) gameres.MenuStat
 		}
 	}
 
+	// 数值文字，[widget]段落里id为text
+	if this.customTextPos {
+		this.label.SetFromLabelInfo(this.textPos)
+	}
+	err = this.Menu.RegisterExternalWidget("text", this.label)
+	if err != nil {
+		panic(err)
+	}
+
+	err = this.Menu.BuildLayout(modules)
+	if err != nil {
+		panic(err)
+	}
+
 	if this.barFillSize.X == -1 || this.barFillSize.Y == -1 {
 		this.barFillSize.X = this.barPos.W
 		this.barFillSize.Y = this.barPos.H
@@ -260,6 +282,17 @@ func (this *StatBar) Render(modules common.Modules) error {
 		}
 	}
 
+	// 配置了文字位置才显示数值
+	if this.customTextPos {
+		this.label.SetText(fmt.Sprintf("%d/%d", this.statCur, this.statMax))
+		this.label.SetPos1(modules, this.GetWindowArea().X, this.GetWindowArea().Y)
+
+		err := this.label.Render(modules)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
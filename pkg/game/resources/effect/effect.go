@@ -9,6 +9,7 @@ import (
 	"monster/pkg/common/gameres"
 	"monster/pkg/common/gameres/effect"
 	"monster/pkg/common/gameres/power"
+	"monster/pkg/common/light"
 )
 
 type Manager struct {
@@ -369,6 +370,8 @@ func (this *Manager) addEffectInternal(modules common.Modules, def effect.Def, d
 	e.ColorMod = def.ColorMod.EncodeRGBA()
 	e.AlphaMod = def.AlphaMod
 	e.AttackSpeedAnim = def.AttackSpeedAnim
+	e.Particles = def.Particles
+	e.Light = def.Light
 	if def.Animation != "" {
 		e.LoadAnimation(modules, def.Animation)
 	}
@@ -592,6 +595,30 @@ func (this *Manager) GetCurrentAlpha(alphaMod uint8) uint8 {
 	return alphaMod
 }
 
+// 生效中的效果用到的粒子发射器文件
+func (this *Manager) GetParticles() []string {
+	var list []string
+	for i, _ := range this.effectList {
+		if this.effectList[i].Particles != "" {
+			list = append(list, this.effectList[i].Particles)
+		}
+	}
+
+	return list
+}
+
+// 生效中的效果带的光源，位置由调用者设置
+func (this *Manager) GetLights() []light.Light {
+	var list []light.Light
+	for i, _ := range this.effectList {
+		if this.effectList[i].Light.IsValid() {
+			list = append(list, this.effectList[i].Light)
+		}
+	}
+
+	return list
+}
+
 func (this *Manager) ClearTriggerEffects(trigger int) {
 	for i := len(this.effectList); i > 0; i-- {
 		if this.effectList[i-1].Trigger > -1 && this.effectList[i-1].Trigger == trigger {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"monster/pkg/common"
 	"monster/pkg/common/gameres"
+	"monster/pkg/common/gameres/avatar"
 	"monster/pkg/common/point"
 	"monster/pkg/common/rect"
 	"monster/pkg/filesystem/fileparser"
@@ -133,6 +134,8 @@ func (this *GameSlotPreview) loadLayerDefinitions(modules common.Modules) error
 		}
 	}
 
+	avatar.FillDefaultLayerOrder(this.layerDef, len(this.layerReferenceOrder))
+
 	return nil
 }
 
@@ -1108,6 +1108,10 @@ func (this *StatBlock) SetCharacterClass(val string) {
 	this.characterClass = val
 }
 
+func (this *StatBlock) GetCharacterClass() string {
+	return this.characterClass
+}
+
 func (this *StatBlock) SetCharacterSubclass(val string) {
 	this.characterSubclass = val
 }
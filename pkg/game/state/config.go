@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"monster/pkg/common"
 	"monster/pkg/common/gameres"
+	"monster/pkg/filesystem/logfile"
 	"monster/pkg/game/base"
 )
 
@@ -75,6 +76,10 @@ func (this *Config) logicAccept(modules common.Modules, gameRes gameres.GameRes)
 
 		mods = modules.NewMods(platform, settings, nil)
 		settings.Set("prev_save_slot", -1)
+
+		// 已确认重启，跳出并重启主循环
+		inpt.SetDone(true)
+		settings.SetSoftReset(true)
 	}
 
 	msg = modules.NewMsg()
@@ -122,7 +127,10 @@ func (this *Config) logicAccept(modules common.Modules, gameRes gameres.GameRes)
 	// 用最新的render去创建 ok
 	tooltipm = modules.NewTooltipm(settings, mods, render)
 
-	// TODO save settings
+	err = settings.SaveSettings()
+	if err != nil {
+		logfile.LogError("GameStateConfig: %s", err)
+	}
 
 	// 请求主逻辑去更换场景，并在switcher里清理当前场景
 	this.SetRequestedGameState(modules, gameRes, NewTitle(modules, gameRes))
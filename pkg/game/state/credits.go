@@ -0,0 +1,301 @@
+package state
+
+import (
+	"monster/pkg/common"
+	"monster/pkg/common/color"
+	"monster/pkg/common/define/fontengine"
+	"monster/pkg/common/define/inputstate"
+	"monster/pkg/common/gameres"
+	"monster/pkg/filesystem/fileparser"
+	"monster/pkg/filesystem/logfile"
+	"monster/pkg/game/base"
+	"monster/pkg/utils/parsing"
+)
+
+const (
+	CREDITS_TEXT = iota
+	CREDITS_HEADER
+	CREDITS_LOGO
+	CREDITS_SPACE
+)
+
+// 制作人员名单的一行
+type CreditsLine struct {
+	type1  int
+	text   string
+	logo   common.Sprite
+	space  int
+	y      int // 排版后的位置，相对第一行
+	height int
+}
+
+type Credits struct {
+	base.State
+
+	lines        []CreditsLine
+	totalHeight  int
+	scroll       float32 // 已滚动的像素
+	speed        float32 // 每帧滚动的像素
+	width        int     // 文字最大宽度
+	headerMargin int     // 标题上方的空白
+	headerFont   string  // 标题使用的字体
+	textFont     string
+}
+
+func NewCredits(modules common.Modules, gameRes gameres.GameRes) (*Credits, error) {
+	c := &Credits{}
+
+	err := c.init(modules, gameRes)
+	if err != nil {
+		c.Close(modules, gameRes)
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (this *Credits) init(modules common.Modules, gameRes gameres.GameRes) error {
+	render := modules.Render()
+	settings := modules.Settings()
+
+	// base
+	this.State = base.ConstructState(modules)
+
+	// self
+	this.speed = 40 / float32(settings.Get("max_fps").(int)) // 每秒40像素
+	this.headerMargin = 24
+	this.headerFont = "font_regular"
+	this.textFont = "font_regular"
+
+	err := this.load(modules)
+	if err != nil {
+		return err
+	}
+
+	err = this.RefreshWidgets(modules, gameRes)
+	if err != nil {
+		return err
+	}
+
+	render.SetBackgroundColor(color.Construct(0, 0, 0, 0))
+	return nil
+}
+
+// 读取所有mod里的credits.txt，按mod顺序排列
+func (this *Credits) load(modules common.Modules) error {
+	mods := modules.Mods()
+	render := modules.Render()
+	settings := modules.Settings()
+
+	filenames, err := mods.List("credits.txt")
+	if err != nil {
+		return err
+	}
+
+	for _, filename := range filenames {
+		infile := fileparser.New()
+		err := infile.Open(filename, false, mods)
+		if err != nil {
+			return err
+		}
+
+		for infile.Next(mods) {
+			line := CreditsLine{}
+
+			switch infile.Key() {
+			case "header_font":
+				// 标题字体，在font_settings.txt中定义
+				this.headerFont = infile.Val()
+				continue
+			case "text_font":
+				this.textFont = infile.Val()
+				continue
+			case "header":
+				line.type1 = CREDITS_HEADER
+				line.text = infile.Val()
+			case "text":
+				line.type1 = CREDITS_TEXT
+				line.text = infile.Val()
+			case "space":
+				line.type1 = CREDITS_SPACE
+				line.space = parsing.ToInt(infile.Val(), 0)
+			case "logo":
+				graphics, err := render.LoadImage(settings, mods, infile.Val())
+				if err != nil {
+					logfile.LogError("GameStateCredits: %s", err)
+					continue
+				}
+
+				line.type1 = CREDITS_LOGO
+				line.logo, err = graphics.CreateSprite()
+				graphics.UnRef()
+				if err != nil {
+					logfile.LogError("GameStateCredits: %s", err)
+					continue
+				}
+			default:
+				err = infile.Reportf("GameStateCredits: '%s' is not a valid key.", infile.Key())
+				if err != nil {
+					infile.Close()
+					return err
+				}
+				continue
+			}
+
+			this.lines = append(this.lines, line)
+		}
+
+		infile.Close()
+	}
+
+	return nil
+}
+
+func (this *Credits) Clear(modules common.Modules, gameRes gameres.GameRes) {
+	for i, _ := range this.lines {
+		if this.lines[i].logo != nil {
+			this.lines[i].logo.Close()
+			this.lines[i].logo = nil
+		}
+	}
+	this.lines = nil
+}
+
+func (this *Credits) Close(modules common.Modules, gameRes gameres.GameRes) {
+	this.State.Close(modules, gameRes, this)
+}
+
+// 根据视口宽度重新排版
+func (this *Credits) RefreshWidgets(modules common.Modules, gameRes gameres.GameRes) error {
+	settings := modules.Settings()
+	font := modules.Font()
+
+	this.width = settings.GetViewW() * 3 / 4
+	y := 0
+
+	for i, _ := range this.lines {
+		line := &this.lines[i]
+
+		switch line.type1 {
+		case CREDITS_HEADER:
+			y += this.headerMargin
+			font.SetFont(this.headerFont)
+			line.height = font.CalcSize(line.text, this.width).Y
+		case CREDITS_TEXT:
+			font.SetFont(this.textFont)
+			line.height = font.CalcSize(line.text, this.width).Y
+		case CREDITS_SPACE:
+			line.height = line.space
+		case CREDITS_LOGO:
+			h, err := line.logo.GetGraphicsHeight()
+			if err != nil {
+				return err
+			}
+			line.height = h
+		}
+
+		line.y = y
+		y += line.height
+	}
+
+	this.totalHeight = y
+	return nil
+}
+
+func (this *Credits) Logic(modules common.Modules, gameRes gameres.GameRes) error {
+	inpt := modules.Inpt()
+	settings := modules.Settings()
+
+	if this.GetRequestedGameState() != nil {
+		return nil
+	}
+
+	if inpt.GetWindowResized() {
+		this.RefreshWidgets(modules, gameRes)
+	}
+
+	if inpt.GetPressing(inputstate.CANCEL) && !inpt.GetLock(inputstate.CANCEL) {
+		inpt.SetLock(inputstate.CANCEL, true)
+		this.SetRequestedGameState(modules, gameRes, NewTitle(modules, gameRes))
+		return nil
+	}
+
+	speed := this.speed
+
+	// 按住加速，向上倒退
+	if inpt.GetPressing(inputstate.DOWN) || inpt.GetPressing(inputstate.ACCEPT) || inpt.GetPressing(inputstate.MAIN1) {
+		speed *= 8
+	} else if inpt.GetPressing(inputstate.UP) {
+		speed = -speed * 8
+	}
+
+	// 滚轮每次滚动三行
+	if inpt.GetScrollDown() {
+		speed += float32(modules.Font().GetLineHeight() * 3)
+	} else if inpt.GetScrollUp() {
+		speed -= float32(modules.Font().GetLineHeight() * 3)
+	}
+
+	this.scroll += speed
+	if this.scroll < 0 {
+		this.scroll = 0
+	}
+
+	// 全部滚出屏幕后回到标题界面
+	if this.scroll > float32(this.totalHeight+settings.GetViewH()) {
+		this.SetRequestedGameState(modules, gameRes, NewTitle(modules, gameRes))
+	}
+
+	return nil
+}
+
+func (this *Credits) Render(modules common.Modules, gameRes gameres.GameRes) error {
+	render := modules.Render()
+	settings := modules.Settings()
+	font := modules.Font()
+
+	// 从屏幕底部开始向上滚动
+	top := settings.GetViewH() - (int)(this.scroll)
+	centerX := settings.GetViewW() / 2
+
+	for i, _ := range this.lines {
+		line := &this.lines[i]
+
+		y := top + line.y
+		if y+line.height < 0 {
+			continue
+		}
+
+		if y > settings.GetViewH() {
+			break
+		}
+
+		switch line.type1 {
+		case CREDITS_HEADER:
+			font.SetFont(this.headerFont)
+			err := font.RenderShadowed(render, line.text, centerX, y, fontengine.JUSTIFY_CENTER, nil, this.width, font.GetColor(fontengine.COLOR_MENU_BONUS))
+			if err != nil {
+				return err
+			}
+		case CREDITS_TEXT:
+			font.SetFont(this.textFont)
+			err := font.Render(render, line.text, centerX, y, fontengine.JUSTIFY_CENTER, nil, this.width, font.GetColor(fontengine.COLOR_MENU_NORMAL))
+			if err != nil {
+				return err
+			}
+		case CREDITS_LOGO:
+			w, err := line.logo.GetGraphicsWidth()
+			if err != nil {
+				return err
+			}
+
+			line.logo.SetDest(centerX-w/2, y)
+			err = render.Render(line.logo)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,394 @@
+package state
+
+import (
+	"fmt"
+	"monster/pkg/common"
+	"monster/pkg/common/color"
+	"monster/pkg/common/define/fontengine"
+	"monster/pkg/common/define/inputstate"
+	"monster/pkg/common/gameres"
+	"monster/pkg/common/rect"
+	"monster/pkg/common/timer"
+	"monster/pkg/filesystem/fileparser"
+	"monster/pkg/filesystem/logfile"
+	"monster/pkg/game/base"
+	"monster/pkg/utils/parsing"
+)
+
+// 过场动画的一幕
+type Scene struct {
+	image    string
+	duration int // 帧数，0表示等待输入
+	fadeIn   int
+	fadeOut  int
+	caption  string // 总是显示的文字
+	voice    string
+	subtitle string // 配音的字幕，开启subtitles才显示
+	music    string
+}
+
+type Cutscene struct {
+	base.State
+
+	scenes    []Scene
+	current   int
+	timer     timer.Timer
+	frame     int // 当前一幕已播放的帧数
+	image     common.Sprite
+	previous  gameres.GameState // 播放完回到的状态，为空则回到标题界面
+	fadeIn    int               // 默认淡入淡出帧数
+	fadeOut   int
+	captionBg color.Color
+	margin    int
+	skippable bool
+	prevBg    color.Color // 进入前的背景色，退出时恢复
+	bgRestore bool        // 需要恢复背景色
+}
+
+func NewCutscene(modules common.Modules, gameRes gameres.GameRes, previous gameres.GameState) *Cutscene {
+	cs := &Cutscene{}
+	cs.init(modules, gameRes, previous)
+
+	return cs
+}
+
+func (this *Cutscene) init(modules common.Modules, gameRes gameres.GameRes, previous gameres.GameState) gameres.GameStateCutscene {
+	render := modules.Render()
+
+	// base
+	this.State = base.ConstructState(modules)
+	this.SetHasBackground(false)
+
+	// self
+	this.previous = previous
+	this.current = -1
+	this.timer = timer.Construct()
+	this.captionBg = color.Construct(0, 0, 0, 200)
+	this.margin = 16
+	this.skippable = true
+
+	this.prevBg = render.GetBackgroundColor()
+	this.bgRestore = true
+	render.SetBackgroundColor(color.Construct(0, 0, 0, 0))
+	return this
+}
+
+// 只恢复一次，之后的状态可能已经设置了自己的背景色
+func (this *Cutscene) restoreBackground(modules common.Modules) {
+	if this.bgRestore {
+		modules.Render().SetBackgroundColor(this.prevBg)
+		this.bgRestore = false
+	}
+}
+
+// 加载cutscenes目录下的过场动画
+func (this *Cutscene) Load(modules common.Modules, filename string) error {
+	mods := modules.Mods()
+	settings := modules.Settings()
+
+	maxFPS := settings.Get("max_fps").(int)
+
+	infile := fileparser.New()
+	err := infile.Open("cutscenes/"+filename, true, mods)
+	if err != nil {
+		return err
+	}
+	defer infile.Close()
+
+	this.scenes = nil
+	for infile.Next(mods) {
+		if infile.IsNewSection() && infile.GetSection() == "scene" {
+			this.scenes = append(this.scenes, Scene{fadeIn: this.fadeIn, fadeOut: this.fadeOut})
+		}
+
+		key := infile.Key()
+		val := infile.Val()
+
+		// 全局设置
+		if infile.GetSection() == "" {
+			switch key {
+			case "fade_in":
+				this.fadeIn = parsing.ToDuration(val, maxFPS)
+			case "fade_out":
+				this.fadeOut = parsing.ToDuration(val, maxFPS)
+			case "caption_background":
+				this.captionBg = parsing.ToRGBA(val)
+			case "caption_margin":
+				this.margin = parsing.ToInt(val, this.margin)
+			case "skippable":
+				this.skippable = parsing.ToBool(val)
+			default:
+				return infile.Errorf("GameStateCutscene: '%s' is not a valid key.", key)
+			}
+			continue
+		}
+
+		if infile.GetSection() != "scene" || len(this.scenes) == 0 {
+			continue
+		}
+
+		scene := &this.scenes[len(this.scenes)-1]
+		switch key {
+		case "image":
+			scene.image = val
+		case "duration":
+			scene.duration = parsing.ToDuration(val, maxFPS)
+		case "fade_in":
+			scene.fadeIn = parsing.ToDuration(val, maxFPS)
+		case "fade_out":
+			scene.fadeOut = parsing.ToDuration(val, maxFPS)
+		case "caption":
+			scene.caption = val
+		case "voice":
+			// file,subtitle
+			scene.voice, scene.subtitle = parsing.PopFirstString(val, "")
+		case "music":
+			scene.music = val
+		default:
+			return infile.Errorf("GameStateCutscene: '%s' is not a valid key.", key)
+		}
+	}
+
+	if len(this.scenes) == 0 {
+		return fmt.Errorf("GameStateCutscene: no scenes defined in '%s'.", filename)
+	}
+
+	return this.nextScene(modules)
+}
+
+func (this *Cutscene) Clear(modules common.Modules, gameRes gameres.GameRes) {
+	// 加载失败直接关闭时没有经过finish
+	this.restoreBackground(modules)
+
+	if this.image != nil {
+		this.image.Close()
+		this.image = nil
+	}
+
+	// 没有回到之前的状态，由自己关闭
+	if this.previous != nil && this.previous.GetSuspended() && this.previous != this.GetRequestedGameState() {
+		this.previous.Close(modules, gameRes)
+	}
+	this.previous = nil
+}
+
+func (this *Cutscene) Close(modules common.Modules, gameRes gameres.GameRes) {
+	this.State.Close(modules, gameRes, this)
+}
+
+func (this *Cutscene) RefreshWidgets(modules common.Modules, gameRes gameres.GameRes) error {
+	this.centerImage(modules)
+	return nil
+}
+
+// 切到下一幕
+func (this *Cutscene) nextScene(modules common.Modules) error {
+	render := modules.Render()
+	mods := modules.Mods()
+	settings := modules.Settings()
+
+	this.current++
+	if this.image != nil {
+		this.image.Close()
+		this.image = nil
+	}
+
+	if this.current >= len(this.scenes) {
+		return nil
+	}
+
+	scene := this.scenes[this.current]
+	this.timer.SetDuration((uint)(scene.duration))
+	this.frame = 0
+
+	// TODO
+	// 播放配音和音乐
+
+	if scene.image == "" {
+		return nil
+	}
+
+	graphics, err := render.LoadImage(settings, mods, scene.image)
+	if err != nil {
+		return err
+	}
+	defer graphics.UnRef()
+
+	this.image, err = graphics.CreateSprite()
+	if err != nil {
+		return err
+	}
+
+	this.centerImage(modules)
+	return nil
+}
+
+// 图片居中
+func (this *Cutscene) centerImage(modules common.Modules) {
+	settings := modules.Settings()
+
+	if this.image == nil {
+		return
+	}
+
+	w, err := this.image.GetGraphicsWidth()
+	if err != nil {
+		logfile.LogError("GameStateCutscene: %s", err)
+		return
+	}
+
+	h, err := this.image.GetGraphicsHeight()
+	if err != nil {
+		logfile.LogError("GameStateCutscene: %s", err)
+		return
+	}
+
+	this.image.SetDest((settings.GetViewW()-w)/2, (settings.GetViewH()-h)/2)
+}
+
+func (this *Cutscene) isDone() bool {
+	return this.current >= len(this.scenes)
+}
+
+// 播放完，切换状态
+func (this *Cutscene) finish(modules common.Modules, gameRes gameres.GameRes) {
+	inpt := modules.Inpt()
+
+	inpt.SetLockAll(true)
+	this.restoreBackground(modules)
+
+	if this.previous != nil {
+		this.SetRequestedGameState(modules, gameRes, this.previous)
+		return
+	}
+
+	this.SetRequestedGameState(modules, gameRes, NewTitle(modules, gameRes))
+}
+
+func (this *Cutscene) Logic(modules common.Modules, gameRes gameres.GameRes) error {
+	inpt := modules.Inpt()
+
+	if this.GetRequestedGameState() != nil {
+		return nil
+	}
+
+	if inpt.GetWindowResized() {
+		this.RefreshWidgets(modules, gameRes)
+	}
+
+	// 跳过整个过场动画
+	if this.skippable && inpt.GetPressing(inputstate.CANCEL) && !inpt.GetLock(inputstate.CANCEL) {
+		inpt.SetLock(inputstate.CANCEL, true)
+		this.current = len(this.scenes)
+	}
+
+	if this.isDone() {
+		this.finish(modules, gameRes)
+		return nil
+	}
+
+	this.frame++
+	advance := false
+
+	// 确认或点击进入下一幕
+	if inpt.GetPressing(inputstate.ACCEPT) && !inpt.GetLock(inputstate.ACCEPT) {
+		inpt.SetLock(inputstate.ACCEPT, true)
+		advance = true
+	} else if inpt.GetPressing(inputstate.MAIN1) && !inpt.GetLock(inputstate.MAIN1) {
+		inpt.SetLock(inputstate.MAIN1, true)
+		advance = true
+	}
+
+	if this.timer.GetDuration() > 0 {
+		this.timer.Tick()
+		if this.timer.IsEnd() {
+			advance = true
+		}
+	}
+
+	if advance {
+		err := this.nextScene(modules)
+		if err != nil {
+			return err
+		}
+
+		if this.isDone() {
+			this.finish(modules, gameRes)
+		}
+	}
+
+	return nil
+}
+
+// 当前的透明度，根据淡入淡出计算
+func (this *Cutscene) getAlpha() uint8 {
+	scene := this.scenes[this.current]
+
+	duration := (int)(this.timer.GetDuration())
+	remaining := (int)(this.timer.GetCurrent())
+
+	alpha := 1.0
+	if scene.fadeIn > 0 && this.frame < scene.fadeIn {
+		alpha = float64(this.frame) / float64(scene.fadeIn)
+	}
+
+	// 等待输入的一幕没有淡出
+	if duration > 0 && scene.fadeOut > 0 && remaining < scene.fadeOut {
+		fade := float64(remaining) / float64(scene.fadeOut)
+		if fade < alpha {
+			alpha = fade
+		}
+	}
+
+	return (uint8)(alpha * 255)
+}
+
+func (this *Cutscene) Render(modules common.Modules, gameRes gameres.GameRes) error {
+	render := modules.Render()
+	settings := modules.Settings()
+	font := modules.Font()
+
+	if this.isDone() {
+		return nil
+	}
+
+	scene := this.scenes[this.current]
+	alpha := this.getAlpha()
+
+	if this.image != nil {
+		this.image.SetAlphaMod(alpha)
+		err := render.Render(this.image)
+		if err != nil {
+			return err
+		}
+	}
+
+	text := scene.caption
+	if scene.subtitle != "" && settings.Get("subtitles").(bool) {
+		if text != "" {
+			text += " "
+		}
+		text += scene.subtitle
+	}
+
+	if text == "" {
+		return nil
+	}
+
+	// 屏幕下方的文字和背景
+	font.SetFont("font_regular")
+	width := settings.GetViewW() - this.margin*2
+	size := font.CalcSize(text, width)
+	top := settings.GetViewH() - size.Y - this.margin*2
+
+	bg := this.captionBg
+	bg.A = (uint8)(int(bg.A) * int(alpha) / 255)
+	err := render.DrawFilledRect(rect.Construct(0, top, settings.GetViewW(), settings.GetViewH()-top), bg)
+	if err != nil {
+		return err
+	}
+
+	c := font.GetColor(fontengine.COLOR_MENU_NORMAL)
+	c.A = alpha
+	return font.Render(render, text, settings.GetViewW()/2, top+this.margin, fontengine.JUSTIFY_CENTER, nil, width, c)
+}
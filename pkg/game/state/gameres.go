@@ -46,9 +46,15 @@ func (this *GameRes) Items() gameres.ItemManager {
 	return this.items
 }
 
-func (this *GameRes) NewItems(modules common.Modules, stats gameres.Stats) gameres.ItemManager {
-	this.items = itemmanager.New(modules, stats)
-	return this.items
+func (this *GameRes) NewItems(modules common.Modules, stats gameres.Stats) (gameres.ItemManager, error) {
+	items, err := itemmanager.New(modules, stats)
+	if err != nil {
+		this.items = nil
+		return nil, err
+	}
+
+	this.items = items
+	return this.items, nil
 }
 
 func (this *GameRes) Camp() gameres.CampaignManager {
@@ -73,9 +79,15 @@ func (this *GameRes) Loot() gameres.LootManager {
 	return this.loot
 }
 
-func (this *GameRes) NewLoot(modules common.Modules, items gameres.ItemManager) gameres.LootManager {
-	this.loot = lootmanager.New(modules, items)
-	return this.loot
+func (this *GameRes) NewLoot(modules common.Modules, items gameres.ItemManager) (gameres.LootManager, error) {
+	loot, err := lootmanager.New(modules, items)
+	if err != nil {
+		this.loot = nil
+		return nil, err
+	}
+
+	this.loot = loot
+	return this.loot, nil
 }
 
 func (this *GameRes) Menu() gameres.MenuManager {
@@ -109,9 +121,15 @@ func (this *GameRes) Powers() gameres.PowerManager {
 	return this.powers
 }
 
-func (this *GameRes) NewPowers(modules common.Modules, ss gameres.Stats) gameres.PowerManager {
-	this.powers = powermanager.New(modules, ss)
-	return this.powers
+func (this *GameRes) NewPowers(modules common.Modules, ss gameres.Stats) (gameres.PowerManager, error) {
+	powers, err := powermanager.New(modules, ss)
+	if err != nil {
+		this.powers = nil
+		return nil, err
+	}
+
+	this.powers = powers
+	return this.powers, nil
 }
 
 // 工厂
@@ -8,7 +8,9 @@ import (
 	"monster/pkg/common/define"
 	"monster/pkg/common/define/fontengine"
 	"monster/pkg/common/define/inputstate"
+	"monster/pkg/common/define/widget"
 	"monster/pkg/common/define/widget/button"
+	"monster/pkg/common/define/widget/input"
 	"monster/pkg/common/define/widget/scrollbar"
 	"monster/pkg/common/gameres"
 	"monster/pkg/common/labelinfo"
@@ -16,12 +18,13 @@ import (
 	"monster/pkg/common/rect"
 	"monster/pkg/common/timer"
 	"monster/pkg/filesystem/fileparser"
+	"monster/pkg/filesystem/logfile"
+	"monster/pkg/filesystem/saveload"
 	"monster/pkg/game/base"
 	"monster/pkg/utils"
 	"monster/pkg/utils/parsing"
-	"monster/pkg/utils/tools"
-	"sort"
 	"strconv"
+	"strings"
 )
 
 type Slot struct {
@@ -37,6 +40,7 @@ type Slot struct {
 	labelClass       common.WidgetLabel
 	labelMap         common.WidgetLabel
 	labelSlotNumber  common.WidgetLabel
+	corrupt          bool // 存档文件无法读取
 }
 
 func NewSlot(modules common.Modules, gameRes gameres.GameRes) *Slot {
@@ -106,9 +110,16 @@ type Load struct {
 	buttonNew      common.WidgetButton
 	buttonLoad     common.WidgetButton
 	buttonDelete   common.WidgetButton
+	buttonCopy     common.WidgetButton
+	buttonRename   common.WidgetButton
+	buttonRestore  common.WidgetButton
+	inputRename    common.WidgetInput // 在选择的存档上修改角色名
 	labelLoading   common.WidgetLabel
 	scrollbar      common.WidgetScrollBar
-	confirm        gameres.MenuConfirm
+	tablist        common.WidgetTablist
+	layout         base.MenuLayout     // 菜单文件里的[widget]段落
+	confirm        gameres.MenuConfirm // 删除存档
+	confirmRestore gameres.MenuConfirm // 恢复备份
 	background     common.Sprite
 	selection      common.Sprite
 	portraitBorder common.Sprite
@@ -116,6 +127,8 @@ type Load struct {
 	slotPos        []rect.Rect   // 每个存档位置
 	gameSlots      []*Slot       // 全部存档
 
+	saveRoot         string // 存档目录
+	renaming         bool
 	loadingRequested bool
 	loading          bool
 	loaded           bool
@@ -138,15 +151,19 @@ type Load struct {
 	gameSlotAlign    int
 }
 
-func NewLoad(modules common.Modules, gameRes gameres.GameRes) *Load {
+func NewLoad(modules common.Modules, gameRes gameres.GameRes) (*Load, error) {
 	load := &Load{}
 
-	load.init(modules, gameRes)
+	err := load.init(modules, gameRes)
+	if err != nil {
+		load.Close(modules, gameRes)
+		return nil, err
+	}
 
-	return load
+	return load, nil
 }
 
-func (this *Load) init(modules common.Modules, gameRes gameres.GameRes) gameres.GameStateLoad {
+func (this *Load) init(modules common.Modules, gameRes gameres.GameRes) error {
 	msg := modules.Msg()
 	widgetf := modules.Widgetf()
 	mods := modules.Mods()
@@ -167,13 +184,20 @@ func (this *Load) init(modules common.Modules, gameRes gameres.GameRes) gameres.
 	this.portraitAlign = define.ALIGN_FRAME_TOPLEFT
 	this.gameSlotAlign = define.ALIGN_FRAME_TOPLEFT
 
-	//TODO item manager
 	if items == nil {
-		items = gameRes.NewItems(modules, stats)
+		var err error
+		items, err = gameRes.NewItems(modules, stats)
+		if err != nil {
+			return err
+		}
 	}
 
+	var err error
 	this.labelLoading = widgetf.New("label").(common.WidgetLabel).Init(modules)
-	this.confirm = menuf.New("confirm").(gameres.MenuConfirm).Init(modules, msg.Get("Delete Save"), msg.Get("Delete this save?"))
+	this.confirm, err = menuf.New("confirm").(gameres.MenuConfirm).Init(modules, msg.Get("Delete Save"), msg.Get("Delete this save?"))
+	if err != nil {
+		return err
+	}
 	this.buttonExit = widgetf.New("button").(common.WidgetButton).Init(modules, button.DEFAULT_FILE)
 	this.buttonExit.SetLabel(modules, msg.Get("Exit to Title"))
 
@@ -189,23 +213,92 @@ func (this *Load) init(modules common.Modules, gameRes gameres.GameRes) gameres.
 	this.buttonDelete.SetLabel(modules, msg.Get("Delete Save"))
 	this.buttonDelete.SetEnabled(false)
 
+	this.buttonCopy = widgetf.New("button").(common.WidgetButton).Init(modules, button.DEFAULT_FILE)
+	this.buttonCopy.SetLabel(modules, msg.Get("Copy Save"))
+	this.buttonCopy.SetEnabled(false)
+
+	this.buttonRename = widgetf.New("button").(common.WidgetButton).Init(modules, button.DEFAULT_FILE)
+	this.buttonRename.SetLabel(modules, msg.Get("Rename"))
+	this.buttonRename.SetEnabled(false)
+
+	this.buttonRestore = widgetf.New("button").(common.WidgetButton).Init(modules, button.DEFAULT_FILE)
+	this.buttonRestore.SetLabel(modules, msg.Get("Restore Backup"))
+	this.buttonRestore.SetEnabled(false)
+
+	this.confirmRestore, err = menuf.New("confirm").(gameres.MenuConfirm).Init(modules, msg.Get("Restore Backup"), msg.Get("Replace this save with its backup?"))
+	if err != nil {
+		return err
+	}
+
+	this.inputRename = widgetf.New("input").(common.WidgetInput).Init(modules, input.DEFAULT_FILE)
+	this.inputRename.SetMaxLength(20)
+
 	this.scrollbar = widgetf.New("scrollbar").(common.WidgetScrollBar).Init(modules, scrollbar.DEFAULT_FILE)
 
-	//TODO tablist
+	// 按钮只左右切换，上下选择存档
+	this.tablist = widgetf.New("tablist").(common.WidgetTablist).Init()
+	this.tablist.SetScrollType(widget.SCROLL_HORIZONTAL)
+	this.tablist.Add(this.buttonExit)
+	this.tablist.Add(this.buttonNew)
+	this.tablist.Add(this.buttonLoad)
+	this.tablist.Add(this.buttonDelete)
+	this.tablist.Add(this.buttonCopy)
+	this.tablist.Add(this.buttonRename)
+	this.tablist.Add(this.buttonRestore)
 
 	this.buttonNew.SetAlignment(define.ALIGN_FRAME_TOPLEFT)
 	this.buttonLoad.SetAlignment(define.ALIGN_FRAME_TOPLEFT)
 	this.buttonDelete.SetAlignment(define.ALIGN_FRAME_TOPLEFT)
+	this.buttonCopy.SetAlignment(define.ALIGN_FRAME_TOPLEFT)
+	this.buttonRename.SetAlignment(define.ALIGN_FRAME_TOPLEFT)
+	this.buttonRestore.SetAlignment(define.ALIGN_FRAME_TOPLEFT)
+
+	// 没有配置的管理按钮依次排在删除按钮下面
+	slotButtons := map[string]common.WidgetButton{
+		"button_copy":    this.buttonCopy,
+		"button_rename":  this.buttonRename,
+		"button_restore": this.buttonRestore,
+	}
+	slotButtonsSet := map[string]bool{}
+
+	// 菜单文件可以用[widget]段落修改这些组件
+	externals := []struct {
+		id string
+		w  common.Widget
+	}{
+		{"button_exit", this.buttonExit},
+		{"button_new", this.buttonNew},
+		{"button_load", this.buttonLoad},
+		{"button_delete", this.buttonDelete},
+		{"button_copy", this.buttonCopy},
+		{"button_rename", this.buttonRename},
+		{"button_restore", this.buttonRestore},
+		{"loading_label", this.labelLoading},
+	}
+
+	for _, entry := range externals {
+		err = this.layout.RegisterExternal(entry.id, entry.w)
+		if err != nil {
+			return err
+		}
+	}
 
 	infile := fileparser.New()
 
-	err := infile.Open("menus/gameload.txt", true, mods)
+	err = infile.Open("menus/gameload.txt", true, mods)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	defer infile.Close()
 
 	for infile.Next(mods) {
+		ok, err := this.layout.ParseKey(infile)
+		if err != nil {
+			return err
+		} else if ok {
+			continue
+		}
+
 		switch infile.Key() {
 		case "button_new":
 			fallthrough
@@ -213,6 +306,12 @@ func (this *Load) init(modules common.Modules, gameRes gameres.GameRes) gameres.
 			fallthrough
 		case "button_delete":
 			fallthrough
+		case "button_copy":
+			fallthrough
+		case "button_rename":
+			fallthrough
+		case "button_restore":
+			fallthrough
 		case "button_exit":
 			first, strVal := "", ""
 			x, y, a := 0, 0, 0
@@ -230,6 +329,9 @@ func (this *Load) init(modules common.Modules, gameRes gameres.GameRes) gameres.
 					this.buttonLoad.SetPosBase(x, y, a)
 				} else if infile.Key() == "button_delete" {
 					this.buttonDelete.SetPosBase(x, y, a)
+				} else {
+					slotButtons[infile.Key()].SetPosBase(x, y, a)
+					slotButtonsSet[infile.Key()] = true
 				}
 			}
 
@@ -259,32 +361,47 @@ func (this *Load) init(modules common.Modules, gameRes gameres.GameRes) gameres.
 			// 文字开始变成省略号的右侧宽
 			this.textTrimBoundary = parsing.ToInt(infile.Val(), 0)
 		default:
-			fmt.Printf("GameStateLoad: '%s' is not a valid key.", infile.Key())
-			panic("bad key")
+			return infile.Errorf("GameStateLoad: '%s' is not a valid key.", infile.Key())
 		}
 	}
 
+	err = this.layout.Build(modules, nil)
+	if err != nil {
+		return err
+	}
+
 	if this.textTrimBoundary == 0 || this.textTrimBoundary > this.gameSlotPos.W {
 		this.textTrimBoundary = this.gameSlotPos.W
 	}
 
+	deletePos := this.buttonDelete.GetPosBase()
+	deleteH := this.buttonDelete.GetPos().H
+	for i, key := range []string{"button_copy", "button_rename", "button_restore"} {
+		if !slotButtonsSet[key] {
+			slotButtons[key].SetPosBase(deletePos.X, deletePos.Y+(i+1)*deleteH, this.buttonDelete.GetAlignment())
+		}
+	}
+
 	// 刷新按钮的标题
 	this.buttonNew.Refresh(modules)
 	this.buttonLoad.Refresh(modules)
 	this.buttonDelete.Refresh(modules)
+	this.buttonCopy.Refresh(modules)
+	this.buttonRename.Refresh(modules)
+	this.buttonRestore.Refresh(modules)
 
 	// 加载图片创建精灵
 	this.loadGraphics(modules)
 
+	// 保存地址
+	this.refreshSavePaths(modules)
+
 	// 加载游戏存档
 	err = this.readGameSlots(modules, gameRes)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	// 保存地址
-	this.refreshSavePaths()
-
 	// 更新头像，存档和滚动条
 	this.RefreshWidgets(modules, gameRes)
 
@@ -310,10 +427,12 @@ func (this *Load) init(modules common.Modules, gameRes gameres.GameRes) gameres.
 	// 设置背景透明
 	render.SetBackgroundColor(color.Construct(0, 0, 0, 0))
 
-	return this
+	return nil
 }
 
 func (this *Load) Clear(modules common.Modules, gameRes gameres.GameRes) {
+	this.layout.Close()
+
 	if this.buttonExit != nil {
 		this.buttonExit.Close()
 		this.buttonExit = nil
@@ -334,11 +453,36 @@ func (this *Load) Clear(modules common.Modules, gameRes gameres.GameRes) {
 		this.buttonDelete = nil
 	}
 
+	if this.buttonCopy != nil {
+		this.buttonCopy.Close()
+		this.buttonCopy = nil
+	}
+
+	if this.buttonRename != nil {
+		this.buttonRename.Close()
+		this.buttonRename = nil
+	}
+
+	if this.buttonRestore != nil {
+		this.buttonRestore.Close()
+		this.buttonRestore = nil
+	}
+
+	if this.inputRename != nil {
+		this.inputRename.Close()
+		this.inputRename = nil
+	}
+
 	if this.scrollbar != nil {
 		this.scrollbar.Close()
 		this.scrollbar = nil
 	}
 
+	if this.tablist != nil {
+		this.tablist.Close()
+		this.tablist = nil
+	}
+
 	if this.labelLoading != nil {
 		this.labelLoading.Close()
 		this.labelLoading = nil
@@ -349,6 +493,11 @@ func (this *Load) Clear(modules common.Modules, gameRes gameres.GameRes) {
 		this.confirm = nil
 	}
 
+	if this.confirmRestore != nil {
+		this.confirmRestore.Close()
+		this.confirmRestore = nil
+	}
+
 	if this.background != nil {
 		this.background.Close()
 		this.background = nil
@@ -399,6 +548,32 @@ func (this *Load) SetSelectedSlot(slot int) {
 	this.selectedSlot = slot
 }
 
+// 上下键选择存档，返回是否改变了选择
+func (this *Load) logicSlotKeys(modules common.Modules) bool {
+	inpt := modules.Inpt()
+
+	slot := this.selectedSlot
+	if inpt.GetPressing(inputstate.UP) && !inpt.GetLock(inputstate.UP) {
+		inpt.SetLock(inputstate.UP, true)
+		slot--
+		if slot < 0 {
+			slot = len(this.gameSlots) - 1
+		}
+	} else if inpt.GetPressing(inputstate.DOWN) && !inpt.GetLock(inputstate.DOWN) {
+		inpt.SetLock(inputstate.DOWN, true)
+		slot++
+		if slot >= len(this.gameSlots) {
+			slot = 0
+		}
+	} else {
+		return false
+	}
+
+	this.SetSelectedSlot(slot)
+	this.ScrollToSelected()
+	return true
+}
+
 // 计算滚动量
 func (this *Load) ScrollToSelected() {
 	if this.visibleSlots == 0 {
@@ -495,7 +670,38 @@ func (this *Load) Logic(modules common.Modules, gameRes gameres.GameRes) error {
 	}
 
 	if this.confirm.GetVisible() {
+		err := this.confirm.Logic(modules, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		if this.confirm.GetConfirmClicked() {
+			// 关闭按钮即取消
+			if !this.confirm.GetCancelClicked() {
+				this.logicSlotFile(modules, gameRes, "delete")
+			}
+			this.confirm.SetVisible(false)
+		}
+	} else if this.confirmRestore.GetVisible() {
+		err := this.confirmRestore.Logic(modules, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		if this.confirmRestore.GetConfirmClicked() {
+			if !this.confirmRestore.GetCancelClicked() {
+				this.logicSlotFile(modules, gameRes, "restore")
+			}
+			this.confirmRestore.SetVisible(false)
+		}
+	} else if this.renaming {
+		this.logicRename(modules, gameRes)
 	} else {
+		err := this.tablist.Logic(modules)
+		if err != nil {
+			return err
+		}
+
 		if this.buttonExit.CheckClick(modules) ||
 			inpt.GetPressing(inputstate.CANCEL) && !inpt.GetLock(inputstate.CANCEL) {
 			inpt.SetLock(inputstate.CANCEL, true)
@@ -514,6 +720,18 @@ func (this *Load) Logic(modules common.Modules, gameRes gameres.GameRes) error {
 
 		} else if this.buttonLoad.CheckClick(modules) {
 		} else if this.buttonDelete.CheckClick(modules) {
+			this.confirm.SetVisible(true)
+		} else if this.buttonCopy.CheckClick(modules) {
+			this.logicSlotFile(modules, gameRes, "copy")
+		} else if this.buttonRename.CheckClick(modules) {
+			this.startRename(modules)
+		} else if this.buttonRestore.CheckClick(modules) {
+			this.confirmRestore.SetVisible(true)
+		} else if len(this.gameSlots) > 0 && this.logicSlotKeys(modules) {
+			err := this.UpdateButtons(modules, gameRes)
+			if err != nil {
+				return err
+			}
 		} else if len(this.gameSlots) > 0 {
 			scrollArea := this.slotPos[0]
 			scrollArea.H = this.slotPos[0].H * this.gameSlotMax
@@ -539,6 +757,114 @@ func (this *Load) Logic(modules common.Modules, gameRes gameres.GameRes) error {
 	return nil
 }
 
+// 删除、复制或恢复选择的存档，之后重新读取存档列表
+func (this *Load) logicSlotFile(modules common.Modules, gameRes gameres.GameRes, action string) {
+	if this.selectedSlot < 0 || this.selectedSlot >= len(this.gameSlots) {
+		return
+	}
+
+	id := (int)(this.gameSlots[this.selectedSlot].id)
+	selectId := id
+
+	var err error
+	switch action {
+	case "delete":
+		err = saveload.DeleteSlot(this.saveRoot, id)
+		selectId = -1
+	case "copy":
+		selectId, err = saveload.CopySlot(this.saveRoot, id)
+	case "restore":
+		err = saveload.RestoreBackup(this.saveRoot, id)
+	}
+
+	if err != nil {
+		logfile.LogError("GameStateLoad: %s", err)
+		return
+	}
+
+	this.reloadGameSlots(modules, gameRes, selectId)
+}
+
+// 开始修改角色名，输入框盖住存档的名字
+func (this *Load) startRename(modules common.Modules) {
+	if this.selectedSlot < 0 || this.selectedSlot >= len(this.gameSlots) {
+		return
+	}
+
+	this.renaming = true
+	this.tablist.Defocus()
+	this.inputRename.SetText(this.gameSlots[this.selectedSlot].stats.GetName())
+	this.inputRename.Focus()
+	this.inputRename.Activate()
+	this.refreshRenamePos(modules)
+}
+
+func (this *Load) logicRename(modules common.Modules, gameRes gameres.GameRes) {
+	inpt := modules.Inpt()
+
+	cancel := inpt.GetPressing(inputstate.CANCEL) && !inpt.GetLock(inputstate.CANCEL)
+
+	this.inputRename.Logic(modules)
+	if this.inputRename.IsEditing() {
+		return
+	}
+
+	this.renaming = false
+	this.inputRename.Defocus()
+
+	name := strings.TrimSpace(this.inputRename.GetText())
+	if cancel || name == "" || name == this.gameSlots[this.selectedSlot].stats.GetName() {
+		return
+	}
+
+	id := (int)(this.gameSlots[this.selectedSlot].id)
+	err := saveload.RenameHero(this.saveRoot, id, name)
+	if err != nil {
+		logfile.LogError("GameStateLoad: %s", err)
+		return
+	}
+
+	this.reloadGameSlots(modules, gameRes, id)
+}
+
+func (this *Load) refreshRenamePos(modules common.Modules) {
+	if this.selectedSlot < this.scrollOffset || this.selectedSlot >= this.scrollOffset+this.visibleSlots {
+		return
+	}
+
+	slotPos := this.slotPos[this.selectedSlot-this.scrollOffset]
+	this.inputRename.SetPosBase(slotPos.X+this.namePos.X, slotPos.Y+this.namePos.Y, define.ALIGN_TOPLEFT)
+	this.inputRename.SetPos1(modules, 0, 0)
+}
+
+// 重新读取存档，选择指定序号的存档
+func (this *Load) reloadGameSlots(modules common.Modules, gameRes gameres.GameRes, selectId int) {
+	for _, ptr := range this.gameSlots {
+		ptr.Close(modules)
+	}
+	this.gameSlots = nil
+	this.selectedSlot = -1
+	this.scrollOffset = 0
+
+	err := this.readGameSlots(modules, gameRes)
+	if err != nil {
+		logfile.LogError("GameStateLoad: %s", err)
+	}
+
+	for i, ptr := range this.gameSlots {
+		if (int)(ptr.id) == selectId {
+			this.SetSelectedSlot(i)
+			this.ScrollToSelected()
+			break
+		}
+	}
+
+	err = this.UpdateButtons(modules, gameRes)
+	if err != nil {
+		logfile.LogError("GameStateLoad: %s", err)
+	}
+}
+
 func (this *Load) Render(modules common.Modules, gameRes gameres.GameRes) error {
 	render := modules.Render()
 	font := modules.Font()
@@ -604,8 +930,21 @@ func (this *Load) Render(modules common.Modules, gameRes gameres.GameRes) error
 		}
 		slotDest := this.background.GetDest()
 
-		if this.gameSlots[offSlot] == nil {
-			// TODO 存档不存在
+		// 无法读取的存档只显示名字和编号
+		if this.gameSlots[offSlot].corrupt {
+			this.gameSlots[offSlot].labelName.SetPos1(modules, this.slotPos[slot].X, this.slotPos[slot].Y)
+			this.gameSlots[offSlot].labelName.SetText(msg.Get("Corrupt save"))
+			this.gameSlots[offSlot].labelName.SetColor(font.GetColor(fontengine.COLOR_MENU_PENALTY))
+			err := this.gameSlots[offSlot].labelName.Render(modules)
+			if err != nil {
+				return err
+			}
+
+			err = this.renderSlotNumber(modules, slot, offSlot, slotDest)
+			if err != nil {
+				return err
+			}
+			continue
 		}
 
 		// 角色名
@@ -625,9 +964,18 @@ func (this *Load) Render(modules common.Modules, gameRes gameres.GameRes) error
 			this.gameSlots[offSlot].labelName.SetMaxWidth(this.textTrimBoundary - (lnBounds.X - slotDest.X))
 		}
 
-		err := this.gameSlots[offSlot].labelName.Render(modules)
-		if err != nil {
-			return err
+		// 修改名字时显示输入框
+		if this.renaming && offSlot == this.selectedSlot {
+			this.refreshRenamePos(modules)
+			err := this.inputRename.Render(modules)
+			if err != nil {
+				return err
+			}
+		} else {
+			err := this.gameSlots[offSlot].labelName.Render(modules)
+			if err != nil {
+				return err
+			}
 		}
 
 		// 等级
@@ -647,7 +995,7 @@ func (this *Load) Render(modules common.Modules, gameRes gameres.GameRes) error
 			this.gameSlots[offSlot].labelLevel.SetMaxWidth(this.textTrimBoundary - (lvBounds.X - slotDest.X))
 		}
 
-		err = this.gameSlots[offSlot].labelLevel.Render(modules)
+		err := this.gameSlots[offSlot].labelLevel.Render(modules)
 		if err != nil {
 			return err
 		}
@@ -692,25 +1040,14 @@ func (this *Load) Render(modules common.Modules, gameRes gameres.GameRes) error
 		}
 
 		// 存档号
-		slotNumberStr := "#" + strconv.FormatInt(int64(offSlot+1), 10)
-
-		this.gameSlots[offSlot].labelSlotNumber.SetPos1(modules, this.slotPos[slot].X, this.slotPos[slot].Y)
-		this.gameSlots[offSlot].labelSlotNumber.SetText(slotNumberStr)
-		this.gameSlots[offSlot].labelSlotNumber.SetColor(font.GetColor(fontengine.COLOR_MENU_NORMAL))
-
-		lsnBounds := this.gameSlots[offSlot].labelSlotNumber.GetBounds(modules)
-		if this.textTrimBoundary > 0 && lsnBounds.X+lsnBounds.W >= this.textTrimBoundary+slotDest.X {
-			this.gameSlots[offSlot].labelSlotNumber.SetMaxWidth(this.textTrimBoundary - (lsnBounds.X - slotDest.X))
-		}
-
-		err = this.gameSlots[offSlot].labelSlotNumber.Render(modules)
+		err = this.renderSlotNumber(modules, slot, offSlot, slotDest)
 		if err != nil {
 			return err
 		}
 	}
 
 	// 选择框
-	if this.selectedSlot >= this.scrollOffset && this.selectedSlot < this.visibleSlots && this.selection != nil {
+	if this.selectedSlot >= this.scrollOffset && this.selectedSlot < this.scrollOffset+this.visibleSlots && this.selection != nil {
 		this.selection.SetDestFromRect(this.slotPos[this.selectedSlot-this.scrollOffset])
 		render.Render(this.selection)
 	}
@@ -723,10 +1060,6 @@ func (this *Load) Render(modules common.Modules, gameRes gameres.GameRes) error
 		}
 	}
 
-	if this.confirm.GetVisible() {
-		//TODO
-	}
-
 	err := this.buttonExit.Render(modules)
 	if err != nil {
 		return err
@@ -747,9 +1080,63 @@ func (this *Load) Render(modules common.Modules, gameRes gameres.GameRes) error
 		return err
 	}
 
+	err = this.buttonCopy.Render(modules)
+	if err != nil {
+		return err
+	}
+
+	err = this.buttonRename.Render(modules)
+	if err != nil {
+		return err
+	}
+
+	err = this.buttonRestore.Render(modules)
+	if err != nil {
+		return err
+	}
+
+	// 菜单文件添加的组件
+	err = this.layout.Render(modules)
+	if err != nil {
+		return err
+	}
+
+	// 弹窗在最上层
+	if this.confirm.GetVisible() {
+		err = this.confirm.Render(modules)
+		if err != nil {
+			return err
+		}
+	}
+
+	if this.confirmRestore.GetVisible() {
+		err = this.confirmRestore.Render(modules)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// 存档编号
+func (this *Load) renderSlotNumber(modules common.Modules, slot, offSlot int, slotDest point.Point) error {
+	font := modules.Font()
+
+	slotNumberStr := "#" + strconv.FormatInt(int64(offSlot+1), 10)
+
+	this.gameSlots[offSlot].labelSlotNumber.SetPos1(modules, this.slotPos[slot].X, this.slotPos[slot].Y)
+	this.gameSlots[offSlot].labelSlotNumber.SetText(slotNumberStr)
+	this.gameSlots[offSlot].labelSlotNumber.SetColor(font.GetColor(fontengine.COLOR_MENU_NORMAL))
+
+	lsnBounds := this.gameSlots[offSlot].labelSlotNumber.GetBounds(modules)
+	if this.textTrimBoundary > 0 && lsnBounds.X+lsnBounds.W >= this.textTrimBoundary+slotDest.X {
+		this.gameSlots[offSlot].labelSlotNumber.SetMaxWidth(this.textTrimBoundary - (lsnBounds.X - slotDest.X))
+	}
+
+	return this.gameSlots[offSlot].labelSlotNumber.Render(modules)
+}
+
 // 更新滚动条
 func (this *Load) refreshScrollBar(modules common.Modules) {
 	this.hasScrollBar = len(this.gameSlots) > this.gameSlotMax
@@ -776,6 +1163,9 @@ func (this *Load) RefreshWidgets(modules common.Modules, gameRes gameres.GameRes
 	this.buttonNew.SetPos1(modules, 0, 0)
 	this.buttonLoad.SetPos1(modules, 0, 0)
 	this.buttonDelete.SetPos1(modules, 0, 0)
+	this.buttonCopy.SetPos1(modules, 0, 0)
+	this.buttonRename.SetPos1(modules, 0, 0)
+	this.buttonRestore.SetPos1(modules, 0, 0)
 
 	// 调整头像位置
 	if this.portrait != nil {
@@ -800,7 +1190,9 @@ func (this *Load) RefreshWidgets(modules common.Modules, gameRes gameres.GameRes
 
 	// 更新滚动条
 	this.refreshScrollBar(modules)
+	this.layout.Align(modules, rect.Construct())
 	this.confirm.Align(modules)
+	this.confirmRestore.Align(modules)
 	return nil
 }
 
@@ -894,30 +1286,17 @@ func (this *Load) loadPreview(modules common.Modules, slot *Slot) error {
 
 // 读档
 func (this *Load) readGameSlots(modules common.Modules, gameRes gameres.GameRes) error {
-	settings := modules.Settings()
 	eset := modules.Eset()
 	mods := modules.Mods()
 
 	ss := gameRes.Stats()
 
-	saveRoot := settings.GetPathUser() + "saves/" + eset.Get("misc", "save_prefix").(string) + "/"
-	var saveDirs []string
-	var err error
-
-	//
-	saveDirs, err = utils.GetDirList(settings.GetPathUser()+"saves/"+eset.Get("misc", "save_prefix").(string), saveDirs)
+	// 存档目录名为从1开始的数字，已排序
+	saveDirs, err := saveload.ListSlots(this.saveRoot)
 	if err != nil {
 		return err
 	}
 
-	// 存档文件名为从1开始的数字，所以可排序
-	sort.Slice(saveDirs, func(i, j int) bool { return parsing.ToInt(saveDirs[i], 0) < parsing.ToInt(saveDirs[j], 0) })
-	for i := len(saveDirs); i > 0; i-- {
-		if parsing.ToInt(saveDirs[i-1], 0) < 1 {
-			saveDirs = tools.EraseStr(saveDirs, i-1)
-		}
-	}
-
 	// 分配内存
 	this.gameSlots = make([]*Slot, len(saveDirs))
 	if len(this.gameSlots) < this.gameSlotMax {
@@ -926,22 +1305,11 @@ func (this *Load) readGameSlots(modules common.Modules, gameRes gameres.GameRes)
 		this.visibleSlots = this.gameSlotMax
 	}
 
-	infile := fileparser.New()
-
 	// 解析存档
-	for i, dir := range saveDirs {
-		filename := saveRoot + dir + "/avatar.txt"
-
-		err := infile.Open(filename, false, mods)
-		if err != nil {
-			return err
-		}
-
-		defer infile.Close()
-
+	for i, id := range saveDirs {
 		this.gameSlots[i] = NewSlot(modules, gameRes)
 
-		this.gameSlots[i].id = (uint)(parsing.ToInt(saveDirs[i], 0))
+		this.gameSlots[i].id = (uint)(id)
 		this.gameSlots[i].stats.SetHero(true)
 		this.gameSlots[i].labelName.SetFromLabelInfo(this.namePos)             // 名字
 		this.gameSlots[i].labelLevel.SetFromLabelInfo(this.levelPos)           // 等级
@@ -949,6 +1317,17 @@ func (this *Load) readGameSlots(modules common.Modules, gameRes gameres.GameRes)
 		this.gameSlots[i].labelMap.SetFromLabelInfo(this.mapPos)               // 地图信息
 		this.gameSlots[i].labelSlotNumber.SetFromLabelInfo(this.slotNumberPos) // 存档编号
 
+		infile := fileparser.New()
+		filename := saveload.SlotPath(this.saveRoot, id) + "/" + saveload.AVATAR_FILE
+
+		err := infile.Open(filename, false, mods)
+		if err != nil {
+			// 存档损坏，可以删除或恢复备份
+			logfile.LogError("GameStateLoad: %s", err)
+			this.gameSlots[i].corrupt = true
+			continue
+		}
+
 		for infile.Next(mods) {
 			switch infile.Key() {
 			case "name":
@@ -988,7 +1367,7 @@ func (this *Load) readGameSlots(modules common.Modules, gameRes gameres.GameRes)
 				var err error
 				this.gameSlots[i].currentMap, err = this.getMapName(modules, first)
 				if err != nil {
-					return err
+					logfile.LogError("GameStateLoad: %s", err)
 				}
 
 			case "permadeath":
@@ -997,6 +1376,14 @@ func (this *Load) readGameSlots(modules common.Modules, gameRes gameres.GameRes)
 				this.gameSlots[i].timePlayed = parsing.ToUnsignedLong(infile.Val(), 0)
 			}
 		}
+		infile.Close()
+
+		// 没有名字的存档也视为损坏
+		if this.gameSlots[i].stats.GetName() == "" {
+			logfile.LogError("GameStateLoad: %s has no hero name.", filename)
+			this.gameSlots[i].corrupt = true
+			continue
+		}
 
 		this.gameSlots[i].stats.Recalc(modules, ss)
 		this.gameSlots[i].stats.SetDirection(6)
@@ -1009,8 +1396,11 @@ func (this *Load) readGameSlots(modules common.Modules, gameRes gameres.GameRes)
 	return nil
 }
 
-func (this *Load) refreshSavePaths() {
-	//TODO
+func (this *Load) refreshSavePaths(modules common.Modules) {
+	settings := modules.Settings()
+	eset := modules.Eset()
+
+	this.saveRoot = saveload.SaveRoot(settings, eset)
 }
 
 // 加载选择存档的头像，更新按钮状态、文字及滚动条
@@ -1021,7 +1411,8 @@ func (this *Load) UpdateButtons(modules common.Modules, gameRes gameres.GameRes)
 
 	err := this.loadPortrait(modules, this.selectedSlot) // 加载头像
 	if err != nil {
-		return err
+		// 头像可能来自已关闭的mod，存档本身还能加载，不显示头像即可
+		logfile.LogError("GameStateLoad: %s", err)
 	}
 
 	_, err = mods.Locate(settings, "maps/spawn.txt")
@@ -1034,8 +1425,14 @@ func (this *Load) UpdateButtons(modules common.Modules, gameRes gameres.GameRes)
 		return err
 	}
 
-	// 选择了一个的存档
-	if this.selectedSlot >= 0 && this.gameSlots[this.selectedSlot] != nil {
+	// 损坏的存档只能删除或恢复
+	if this.selectedSlot >= 0 && this.gameSlots[this.selectedSlot].corrupt {
+		this.buttonLoad.SetLabel(modules, msg.Get("Load Game"))
+		this.buttonLoad.SetEnabled(false)
+		this.buttonDelete.SetEnabled(true)
+		this.buttonCopy.SetEnabled(false)
+		this.buttonRename.SetEnabled(false)
+	} else if this.selectedSlot >= 0 && this.gameSlots[this.selectedSlot] != nil {
 		if !this.buttonLoad.GetEnabled() {
 			this.buttonLoad.SetEnabled(true)
 		}
@@ -1044,6 +1441,8 @@ func (this *Load) UpdateButtons(modules common.Modules, gameRes gameres.GameRes)
 		if !this.buttonDelete.GetEnabled() {
 			this.buttonDelete.SetEnabled(true)
 		}
+		this.buttonCopy.SetEnabled(true)
+		this.buttonRename.SetEnabled(true)
 
 		// 更改为加载
 		this.buttonLoad.SetLabel(modules, msg.Get("Load Game"))
@@ -1063,12 +1462,20 @@ func (this *Load) UpdateButtons(modules common.Modules, gameRes gameres.GameRes)
 		this.buttonLoad.SetLabel(modules, msg.Get("Choose a Slot"))
 		this.buttonLoad.SetEnabled(false)
 		this.buttonDelete.SetEnabled(false)
+		this.buttonCopy.SetEnabled(false)
+		this.buttonRename.SetEnabled(false)
 	}
 
+	// 有备份就可以恢复
+	this.buttonRestore.SetEnabled(this.selectedSlot >= 0 && saveload.HasBackup(this.saveRoot, (int)(this.gameSlots[this.selectedSlot].id)))
+
 	// 更新按钮状态
 	this.buttonNew.Refresh(modules)
 	this.buttonLoad.Refresh(modules)
 	this.buttonDelete.Refresh(modules)
+	this.buttonCopy.Refresh(modules)
+	this.buttonRename.Refresh(modules)
+	this.buttonRestore.Refresh(modules)
 
 	// 更新存档，滚动条和头像
 	this.RefreshWidgets(modules, gameRes)
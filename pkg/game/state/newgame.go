@@ -11,14 +11,17 @@ import (
 	"monster/pkg/common/define/inputstate"
 	"monster/pkg/common/define/widget/button"
 	"monster/pkg/common/define/widget/checkbox"
+	"monster/pkg/common/define/widget/input"
 	"monster/pkg/common/define/widget/listbox"
 	"monster/pkg/common/gameres"
 	"monster/pkg/common/rect"
 	"monster/pkg/filesystem/fileparser"
+	"monster/pkg/filesystem/saveload"
 	"monster/pkg/game/base"
 	"monster/pkg/utils/parsing"
 	"monster/pkg/utils/tools"
 	"sort"
+	"strings"
 )
 
 type HeroOption struct {
@@ -49,10 +52,12 @@ type NewGame struct {
 	buttonRandomize  common.WidgetButton
 	labelPortrait    common.WidgetLabel
 	labelName        common.WidgetLabel
+	inputName        common.WidgetInput
 	buttonPermadeath common.WidgetCheckBox
 	labelPermadeath  common.WidgetLabel
 	labelClassList   common.WidgetLabel
 	classList        common.WidgetListBox
+	tablist          common.WidgetTablist
 
 	portraitPos   rect.Rect // 头像显示位置
 	showClassList bool
@@ -102,8 +107,8 @@ func (this *NewGame) init(modules common.Modules, gameRes gameres.GameRes) gamer
 	this.buttonRandomize = widgetf.New("button").(common.WidgetButton).Init(modules, button.DEFAULT_FILE)
 	this.buttonRandomize.SetLabel(modules, msg.Get("Randomize"))
 
-	// TODO
-	// input name
+	this.inputName = widgetf.New("input").(common.WidgetInput).Init(modules, input.DEFAULT_FILE)
+	this.inputName.SetMaxLength(20)
 
 	this.buttonPermadeath = widgetf.New("checkbox").(common.WidgetCheckBox).Init(modules, checkbox.DEFAULT_FILE)
 	if eset.Get("death_penalty", "permadeath").(bool) {
@@ -135,9 +140,7 @@ func (this *NewGame) init(modules common.Modules, gameRes gameres.GameRes) gamer
 	this.buttonPermadeath.SetAlignment(define.ALIGN_FRAME_TOPLEFT)
 	this.buttonRandomize.SetAlignment(define.ALIGN_FRAME_TOPLEFT)
 
-	// TODO
-	// input name
-
+	this.inputName.SetAlignment(define.ALIGN_FRAME_TOPLEFT)
 	this.classList.SetAlignment(define.ALIGN_FRAME_TOPLEFT)
 
 	infile := fileparser.New()
@@ -189,7 +192,11 @@ func (this *NewGame) init(modules common.Modules, gameRes gameres.GameRes) gamer
 			a = parsing.ToAlignment(first, define.ALIGN_FRAME_TOPLEFT)
 			this.buttonRandomize.SetPosBase(x, y, a)
 		case "name_input":
-			// TODO
+			x, strVal = parsing.PopFirstInt(infile.Val(), "")
+			y, strVal = parsing.PopFirstInt(strVal, "")
+			first, strVal = parsing.PopFirstString(strVal, "")
+			a = parsing.ToAlignment(first, define.ALIGN_FRAME_TOPLEFT)
+			this.inputName.SetPosBase(x, y, a)
 		case "portrait_label":
 			this.labelPortrait.SetFromLabelInfo(parsing.PopLabelInfo(infile.Val()))
 		case "name_label":
@@ -222,6 +229,21 @@ func (this *NewGame) init(modules common.Modules, gameRes gameres.GameRes) gamer
 		}
 	}
 
+	// 设置tablist，隐藏的组件不加入
+	this.tablist = widgetf.New("tablist").(common.WidgetTablist).Init()
+	this.tablist.Add(this.buttonExit)
+	this.tablist.Add(this.buttonCreate)
+	this.tablist.Add(this.inputName)
+	this.tablist.Add(this.buttonPrev)
+	this.tablist.Add(this.buttonNext)
+	this.tablist.Add(this.buttonPermadeath)
+	if this.showRandomize {
+		this.tablist.Add(this.buttonRandomize)
+	}
+	if this.showClassList {
+		this.tablist.Add(this.classList)
+	}
+
 	hcList := eset.Get("hero_classes", "list").([]common.HeroClass)
 	for _, hc := range hcList {
 		this.classList.Append(modules, msg.Get(hc.GetName()), msg.Get(hc.GetDescription()))
@@ -315,6 +337,11 @@ func (this *NewGame) Clear(modules common.Modules, gameRes gameres.GameRes) {
 		this.labelName = nil
 	}
 
+	if this.inputName != nil {
+		this.inputName.Close()
+		this.inputName = nil
+	}
+
 	if this.buttonPermadeath != nil {
 		this.buttonPermadeath.Close()
 		this.buttonPermadeath = nil
@@ -334,6 +361,11 @@ func (this *NewGame) Clear(modules common.Modules, gameRes gameres.GameRes) {
 		this.classList.Close()
 		this.classList = nil
 	}
+
+	if this.tablist != nil {
+		this.tablist.Close()
+		this.tablist = nil
+	}
 }
 
 func (this *NewGame) Close(modules common.Modules, gameRes gameres.GameRes) {
@@ -432,7 +464,8 @@ func (this *NewGame) setHeroOption(modules common.Modules, dir int) error {
 	}
 	this.portraitImage.SetDestFromRect(this.portraitPos)
 
-	//TODO set name
+	// 默认名字为人物的名字
+	this.inputName.SetText(this.heroOptions[this.currentOption].name)
 
 	return nil
 }
@@ -499,6 +532,7 @@ func (this *NewGame) RefreshWidgets(modules common.Modules, gameRes gameres.Game
 	this.buttonNext.SetPos1(modules, 0, 0)
 	this.buttonPermadeath.SetPos1(modules, 0, 0)
 	this.buttonRandomize.SetPos1(modules, 0, 0)
+	this.inputName.SetPos1(modules, 0, 0)
 	this.classList.SetPos1(modules, 0, 0)
 
 	tmpW := (settings.GetViewW() - eset.Get("resolutions", "menu_frame_width").(int)) / 2
@@ -514,20 +548,27 @@ func (this *NewGame) RefreshWidgets(modules common.Modules, gameRes gameres.Game
 func (this *NewGame) Logic(modules common.Modules, gameRes gameres.GameRes) error {
 	inpt := modules.Inpt()
 	eset := modules.Eset()
+	settings := modules.Settings()
 
 	if inpt.GetWindowResized() {
 		this.RefreshWidgets(modules, gameRes)
 	}
 
-	// TODO
-	// input name
+	// 输入框先处理方向键
+	this.inputName.Logic(modules)
+
+	err := this.tablist.Logic(modules)
+	if err != nil {
+		return err
+	}
 
 	this.buttonPermadeath.CheckClick(modules)
 	if this.showClassList && this.classList.CheckClick(modules) {
 		this.setHeroOption(modules, OPTION_CURRENT)
 	}
 
-	this.buttonCreate.SetEnabled(true)
+	// 没有名字不能创建
+	this.buttonCreate.SetEnabled(this.getName() != "")
 	this.buttonCreate.Refresh(modules)
 
 	if inpt.GetPressing(inputstate.CANCEL) && !inpt.GetLock(inputstate.CANCEL) || this.buttonExit.CheckClick(modules) {
@@ -538,24 +579,39 @@ func (this *NewGame) Logic(modules common.Modules, gameRes gameres.GameRes) erro
 
 		this.deleteItems = false
 		this.ShowLoading(modules)
-		this.SetRequestedGameState(modules, gameRes, NewLoad(modules, gameRes))
+		load, err := NewLoad(modules, gameRes)
+		if err != nil {
+			return err
+		}
+		this.SetRequestedGameState(modules, gameRes, load)
 	}
 
 	if this.buttonCreate.CheckClick(modules) {
 		inpt.SetLockAll(true)
 		this.deleteItems = false
 		this.ShowLoading(modules)
-		play := NewPlay(modules, gameRes)
+
+		// 新游戏使用第一个空的存档序号
+		slot, err := saveload.NextFreeSlot(saveload.SaveRoot(settings, eset))
+		if err != nil {
+			return err
+		}
+		saveload.SetGameSlot(slot)
+
+		play, err := NewPlay(modules, gameRes)
+		if err != nil {
+			return err
+		}
 		play.ResetGame(modules, gameRes)
 
-		//TODO play
+		gameRes.Pc().GetStats().SetName(this.getName())
 		this.SetRequestedGameState(modules, gameRes, play)
 	}
 
 	if this.buttonNext.CheckClick(modules) {
 		this.setHeroOption(modules, OPTION_NEXT)
 	} else if this.buttonPrev.CheckClick(modules) {
-		this.setHeroOption(modules, OPTION_NEXT)
+		this.setHeroOption(modules, OPTION_PREV)
 	}
 
 	if this.showRandomize && this.buttonRandomize.CheckClick(modules) {
@@ -607,6 +663,11 @@ func (this *NewGame) Render(modules common.Modules, gameRes gameres.GameRes) err
 		}
 	}
 
+	err = this.inputName.Render(modules)
+	if err != nil {
+		return err
+	}
+
 	src := rect.Construct()
 	dest := rect.Construct()
 
@@ -665,3 +726,8 @@ func (this *NewGame) Render(modules common.Modules, gameRes gameres.GameRes) err
 
 	return nil
 }
+
+// 去掉首尾空白的名字
+func (this *NewGame) getName() string {
+	return strings.TrimSpace(this.inputName.GetText())
+}
@@ -1,14 +1,15 @@
 package state
 
 import (
-	"fmt"
 	"monster/pkg/common"
 	"monster/pkg/common/define"
 	"monster/pkg/common/gameres"
 	"monster/pkg/common/gameres/avatar"
 	"monster/pkg/common/timer"
 	"monster/pkg/filesystem/fileparser"
+	"monster/pkg/filesystem/logfile"
 	"monster/pkg/game/base"
+	"monster/pkg/subengine/loader"
 	"monster/pkg/utils"
 	"monster/pkg/utils/parsing"
 )
@@ -36,17 +37,23 @@ type Play struct {
 	npcId          int
 	titles         []RoleTitle
 	isFirstMapLoad bool
+	loader         common.Loader // 换图时的异步加载
 }
 
-func NewPlay(modules common.Modules, gameRes gameres.GameRes) *Play {
+func NewPlay(modules common.Modules, gameRes gameres.GameRes) (*Play, error) {
 	play := &Play{}
+	_ = (gameres.GameStatePlay)(play)
 
-	play.init(modules, gameRes)
+	err := play.init(modules, gameRes)
+	if err != nil {
+		play.Close(modules, gameRes)
+		return nil, err
+	}
 
-	return play
+	return play, nil
 }
 
-func (this *Play) init(modules common.Modules, gameRes gameres.GameRes) gameres.GameStatePlay {
+func (this *Play) init(modules common.Modules, gameRes gameres.GameRes) error {
 	settings := modules.Settings()
 
 	items := gameRes.Items()
@@ -55,18 +62,28 @@ func (this *Play) init(modules common.Modules, gameRes gameres.GameRes) gameres.
 	gresf := gameRes.Resf()
 
 	if items == nil {
-		items = gameRes.NewItems(modules, ss)
+		var err error
+		items, err = this.loadItems(modules, gameRes)
+		if err != nil {
+			return err
+		}
 	}
 
 	camp := gameRes.NewCamp()
 	_ = camp
-	loot := gameRes.NewLoot(modules, items)
+	loot, err := gameRes.NewLoot(modules, items)
+	if err != nil {
+		return err
+	}
 	_ = loot
-	powers := gameRes.NewPowers(modules, ss)
+	powers, err := gameRes.NewPowers(modules, ss)
+	if err != nil {
+		return err
+	}
 	mapr := gameRes.NewMapr(modules, gresf)
 	pc := gameRes.NewPc(modules, mapr, ss, powers, gresf)
 	menu := gameRes.NewMenu(modules, pc, powers, menuf)
-	_ = menu
+	camp.SetInventory(menu.Get("inv").(gameres.MenuInventory))
 	eventManager := gameRes.NewEventManager()
 	_ = eventManager
 
@@ -78,12 +95,52 @@ func (this *Play) init(modules common.Modules, gameRes gameres.GameRes) gameres.
 	this.npcId = -1
 	this.isFirstMapLoad = true
 
-	err := this.loadTitles(modules, gameRes)
+	err = this.loadTitles(modules, gameRes)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// 在加载协程里解析物品，同时解码掉落动画的图片
+func (this *Play) loadItems(modules common.Modules, gameRes gameres.GameRes) (gameres.ItemManager, error) {
+	settings := modules.Settings()
+	mods := modules.Mods()
+	render := modules.Render()
+	anim := modules.Anim()
+	ss := gameRes.Stats()
+
+	ldr := loader.New(0)
+	defer ldr.Close()
+
+	var items gameres.ItemManager
+	ldr.Add(func() error {
+		var err error
+		items, err = gameRes.NewItems(modules, ss)
+		if err != nil {
+			return err
+		}
+
+		names := map[string]bool{}
+		for _, item := range items.GetItems() {
+			for _, la := range item.LootAnimation {
+				if !names[la.Name] {
+					names[la.Name] = true
+					anim.Preload(ldr, settings, mods, render, la.Name)
+				}
+			}
+		}
+
+		return nil
+	}, nil)
+
+	err := ldr.Wait()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	return this
+	return items, nil
 }
 
 func (this *Play) Clear(modules common.Modules, gameRes gameres.GameRes) {
@@ -95,6 +152,17 @@ func (this *Play) Clear(modules common.Modules, gameRes gameres.GameRes) {
 	eventManager := gameRes.NewEventManager()
 	pc := gameRes.Pc()
 
+	// 等加载协程结束再释放
+	if this.loader != nil {
+		err := this.loader.Wait()
+		if err != nil {
+			logfile.LogError("GameStatePlay: %s", err)
+		}
+
+		this.loader.Close()
+		this.loader = nil
+	}
+
 	if camp != nil {
 		camp.Close()
 	}
@@ -152,11 +220,16 @@ func (this *Play) Logic(modules common.Modules, gameRes gameres.GameRes) error {
 		this.RefreshWidgets(modules, gameRes)
 	}
 
+	// 加载中只处理加载
+	if this.loader != nil {
+		return this.logicLoading()
+	}
+
 	// 顶层先
 	menu.Logic(modules, pc, powers)
 
 	if !this.isPaused() {
-		pc.Logic(modules, mapr, camp)
+		pc.Logic(modules, menu.MenuAct().CheckAction(modules), mapr, camp)
 	}
 
 	err := this.checkTeleport(modules, gameRes)
@@ -169,6 +242,8 @@ func (this *Play) Logic(modules common.Modules, gameRes gameres.GameRes) error {
 		return err
 	}
 
+	this.checkCutscene(modules, gameRes)
+
 	mapr.Logic(modules)
 
 	return nil
@@ -179,6 +254,10 @@ func (this *Play) Render(modules common.Modules, gameRes gameres.GameRes) error
 	mapr := gameRes.Mapr()
 	pc := gameRes.Pc()
 
+	if this.loader != nil {
+		return this.RenderLoading(modules)
+	}
+
 	if mapr.GetIsSpawnMap() {
 		return nil
 	}
@@ -187,6 +266,10 @@ func (this *Play) Render(modules common.Modules, gameRes gameres.GameRes) error
 
 	rens = pc.AddRenders(modules, rens)
 
+	for _, l := range pc.AddLights(nil) {
+		mapr.AddLight(l)
+	}
+
 	err := mapr.Render(modules, rens, rensDead)
 	if err != nil {
 		return err
@@ -216,12 +299,46 @@ func (this *Play) ResetGame(modules common.Modules, gameRes gameres.GameRes) {
 	// menu
 	menu.Get("inv").(gameres.MenuInventory).SetChangedEquipment(true)
 	menu.Get("inv").(gameres.MenuInventory).SetCurrency(0)
+	this.equipClassItems(modules, gameRes)
+
+	err := menu.MenuAct().LoadLayout(modules, pc, powers)
+	if err != nil {
+		logfile.LogError("GameStatePlay: %s", err)
+	}
 
 	// 默认传送到出生点地图
 	mapr.SetTeleportation(true)
 	mapr.SetTeleportMapName("maps/spawn.txt")
 }
 
+// 穿上职业的初始装备
+func (this *Play) equipClassItems(modules common.Modules, gameRes gameres.GameRes) {
+	eset := modules.Eset()
+
+	pc := gameRes.Pc()
+	items := gameRes.Items().GetItems()
+	inv := gameRes.Menu().Get("inv").(gameres.MenuInventory)
+
+	className := pc.GetStats().GetCharacterClass()
+	for _, hc := range eset.Get("hero_classes", "list").([]common.HeroClass) {
+		if hc.GetName() != className {
+			continue
+		}
+
+		for _, id := range hc.GetEquipment() {
+			it, ok := items[id]
+			if !ok {
+				logfile.LogError("GameStatePlay: Class '%s' has unknown equipment %d", className, id)
+				continue
+			}
+
+			inv.SetEquipped(it.Type, id)
+		}
+
+		break
+	}
+}
+
 // 加载玩家称号
 func (this *Play) loadTitles(modules common.Modules, gameRes gameres.GameRes) error {
 	mods := modules.Mods()
@@ -281,7 +398,7 @@ func (this *Play) loadTitles(modules common.Modules, gameRes gameres.GameRes) er
 			first, val = parsing.PopFirstString(val, "")
 			this.titles[len(this.titles)-1].primaryStat2 = first
 		default:
-			return fmt.Errorf("GameStatePlay: '%s' is not a valid key.\n", key)
+			return infile.Errorf("GameStatePlay: '%s' is not a valid key.", key)
 		}
 	}
 
@@ -298,8 +415,6 @@ func (this *Play) checkTeleport(modules common.Modules, gameRes gameres.GameRes)
 	gresf := gameRes.Resf()
 	pc := gameRes.Pc()
 
-	onLoadTeleport := false
-
 	if mapr.GetTeleportation() {
 
 		if mapr.GetTeleportation() {
@@ -318,33 +433,79 @@ func (this *Play) checkTeleport(modules common.Modules, gameRes gameres.GameRes)
 			teleportMapName := mapr.GetTeleportMapName()
 			mapr.SetTeleportMapName("")
 			inpt.SetLockAll(mapr.GetTeleportMapName() == "maps/spawn.txt")
-			err := this.ShowLoading(modules)
-			if err != nil {
-				return err
-			}
 
-			err = mapr.Load(modules, loot, camp, eventManager, gresf, teleportMapName)
-			if err != nil {
-				return err
-			}
+			// 加载完成后再处理地图事件
+			this.loader = loader.New(0)
+			this.SetLoader(this.loader)
+			mapr.LoadAsync(modules, this.loader, loot, camp, eventManager, gresf, teleportMapName)
+			this.loader.OnDone(func() error {
+				this.finishTeleport(modules, gameRes)
+				return nil
+			})
+
+			return nil
 		}
 
-		// 清空请求换图的状态
+		this.finishTeleport(modules, gameRes)
+		return nil
+	}
+
+	if mapr.GetTeleportMapName() == "" {
 		mapr.SetTeleportation(false)
+	}
 
-		// 处理地图加载事件
-		mapr.ExecuteOnLoadEvent(modules, eventManager, camp)
+	return nil
+}
 
-		if mapr.GetTeleportation() {
-			onLoadTeleport = true
-		}
+// 事件请求播放过场动画，播放完后回到本状态
+func (this *Play) checkCutscene(modules common.Modules, gameRes gameres.GameRes) {
+	mapr := gameRes.Mapr()
+
+	if !mapr.GetCutscene() {
+		return
 	}
 
-	if !onLoadTeleport && mapr.GetTeleportMapName() == "" {
-		mapr.SetTeleportation(false)
+	filename := mapr.GetCutsceneFile()
+	mapr.SetCutscene(false)
+	mapr.SetCutsceneFile("")
+
+	cutscene := NewCutscene(modules, gameRes, this)
+	err := cutscene.Load(modules, filename)
+	if err != nil {
+		logfile.LogError("GameStatePlay: %s", err)
+		cutscene.Close(modules, gameRes)
+		return
 	}
 
-	return nil
+	this.SetSuspended(true)
+	this.SetRequestedGameState(modules, gameRes, cutscene)
+}
+
+func (this *Play) finishTeleport(modules common.Modules, gameRes gameres.GameRes) {
+	mapr := gameRes.Mapr()
+	camp := gameRes.Camp()
+	eventManager := gameRes.EventManager()
+
+	// 清空请求换图的状态
+	mapr.SetTeleportation(false)
+
+	// 处理地图加载事件
+	mapr.ExecuteOnLoadEvent(modules, eventManager, camp)
+}
+
+// 每帧处理加载完成的任务，更新进度条
+func (this *Play) logicLoading() error {
+	done, err := this.loader.Logic()
+
+	if !done {
+		return nil
+	}
+
+	this.loader.Close()
+	this.loader = nil
+	this.SetLoader(nil)
+
+	return err
 }
 
 func (this *Play) checkEquipmentChange(modules common.Modules, gameRes gameres.GameRes) error {
@@ -358,6 +519,7 @@ func (this *Play) checkEquipmentChange(modules common.Modules, gameRes gameres.G
 
 	// 第一次肯定会触发图片加载
 	if inv.GetChangedEquipment() {
+		items := gameRes.Items().GetItems()
 		feetIndex := -1
 		_ = feetIndex
 		var imgGfx []avatar.LayerGfx
@@ -367,8 +529,12 @@ func (this *Play) checkEquipmentChange(modules common.Modules, gameRes gameres.G
 			gfx := avatar.ConstructLayerGfx()
 			gfx.Type = val
 
-			//TODO
-			// menu inv
+			// 装备的物品决定图层的动画，染色和发光
+			if id := inv.GetEquipped(val); id > 0 {
+				if it, ok := items[id]; ok {
+					gfx.SetItem(&it)
+				}
+			}
 
 			// 没有头盔时用默认头展现
 			if gfx.Gfx == "" && val == "head" {
@@ -1,10 +1,12 @@
 package state
 
 import (
+	"errors"
 	"fmt"
 	"monster/pkg/common"
 	"monster/pkg/common/color"
 	"monster/pkg/common/define"
+	"monster/pkg/common/define/inputstate"
 	"monster/pkg/common/gameres"
 	"monster/pkg/common/rect"
 	"monster/pkg/common/timer"
@@ -12,6 +14,8 @@ import (
 	"monster/pkg/filesystem/logfile"
 	"monster/pkg/utils"
 	"monster/pkg/utils/parsing"
+	"runtime"
+	"time"
 )
 
 // 入口
@@ -30,6 +34,8 @@ type Switcher struct {
 	backgroundFilename string
 	backgroundList     []string
 	done               bool
+	errorDialog        gameres.MenuConfirm // 加载出错的弹窗
+	errorFatal         bool                // 标题界面出错，关闭弹窗后退出游戏
 }
 
 func NewSwitcher(modules common.Modules) *Switcher {
@@ -75,6 +81,11 @@ func (this *Switcher) Close(modules common.Modules) {
 		this.labelFPS = nil
 	}
 
+	if this.errorDialog != nil {
+		this.errorDialog.Close()
+		this.errorDialog = nil
+	}
+
 	this.FreeBackground()
 }
 
@@ -83,9 +94,6 @@ func (this *Switcher) Logic(modules common.Modules) error {
 	inpt := modules.Inpt()
 	curs := modules.Render().Curs()
 	tooltipm := modules.Tooltipm()
-	render := modules.Render()
-	mods := modules.Mods()
-	eset := modules.Eset()
 
 	// 光标逻辑
 	err := curs.Logic(settings, inpt)
@@ -96,59 +104,204 @@ func (this *Switcher) Logic(modules common.Modules) error {
 	// 清空提示文字
 	tooltipm.Clear()
 
+	// 截图
+	if inpt.GetPressing(inputstate.SCREENSHOT) && !inpt.GetLock(inputstate.SCREENSHOT) {
+		inpt.SetLock(inputstate.SCREENSHOT, true)
+		this.screenshot(modules)
+	}
+
+	// 加载出错，等待确认后回到标题界面
+	if this.errorDialog != nil {
+		return this.logicErrorDialog(modules)
+	}
+
+	err = protect(func() error {
+		return this.logicState(modules)
+	})
+	if err != nil {
+		return this.showError(modules, err)
+	}
+
+	return nil
+}
+
+func (this *Switcher) logicState(modules common.Modules) error {
+	settings := modules.Settings()
+	inpt := modules.Inpt()
+	eset := modules.Eset()
+
 	newState := this.currentState.GetRequestedGameState()
 	if newState != nil {
+		err := this.setState(modules, newState)
+		if err != nil {
+			return err
+		}
+	}
 
-		// 当前游戏状态是否需要重新加载背景列表 或 之前重新加载过渲染系统
-		if this.currentState.GetReloadBackgrounds() || render.ReloadGraphics() {
-			err := this.LoadBackgroundList(mods)
-			if err != nil {
-				fmt.Println("here")
-				return err
-			}
+	// 处理窗口逻辑
+	if (inpt.GetWindowResized() || this.currentState.GetForceRefreshBackground()) && this.currentState.GetHasBackground() {
+
+		fmt.Println("refresh bk")
+		err := this.RefreshBackground(settings, eset) // 更新背景大小
+		if err != nil {
+			return err
 		}
 
-		fmt.Println("new state")
+		this.currentState.SetForceRefreshBackground(false)
+	}
+
+	err := this.currentState.Logic(modules, this.gameRes)
+	if err != nil {
+		return err
+	}
+
+	this.done = this.currentState.GetExitRequested()
+
+	return nil
+}
+
+// 切换到新的游戏状态
+func (this *Switcher) setState(modules common.Modules, newState gameres.GameState) error {
+	render := modules.Render()
+	mods := modules.Mods()
+
+	// 当前游戏状态是否需要重新加载背景列表 或 之前重新加载过渲染系统
+	if this.currentState.GetReloadBackgrounds() || render.ReloadGraphics() {
+		err := this.LoadBackgroundList(mods)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("new state")
+
+	// 被接管的状态由接管者负责关闭
+	if !this.currentState.GetSuspended() {
 		this.currentState.Close(modules, this.gameRes)
-		this.currentState = newState
-		this.currentState.IncrLoadCounter() // 2 ++1
+	}
+
+	// 恢复之前被接管的状态
+	if newState.GetSuspended() {
+		newState.SetSuspended(false)
+		newState.ClearRequestedGameState()
+	}
 
-		// reload fps
-		err = this.LoadFPS(modules)
+	this.currentState = newState
+	this.currentState.IncrLoadCounter() // 2 ++1
+
+	// reload fps
+	err := this.LoadFPS(modules)
+	if err != nil {
+		return err
+	}
+
+	// TODO
+	// load music
+
+	// 需要背景图片
+	if this.currentState.GetHasBackground() {
+		err = this.LoadBackgroundImage(modules)
 		if err != nil {
 			return err
 		}
+	} else {
+		this.FreeBackground()
+	}
 
-		// TODO
-		// load music
+	return nil
+}
 
-		// 需要背景图片
-		if this.currentState.GetHasBackground() {
-			err = this.LoadBackgroundImage(modules)
-			if err != nil {
-				return err
+// 游戏状态里的加载panic转成错误，运行时错误继续抛出
+func protect(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch val := r.(type) {
+			case runtime.Error:
+				panic(r)
+			case error:
+				err = val
+			case string:
+				err = errors.New(val)
+			default:
+				panic(r)
 			}
-		} else {
-			this.FreeBackground()
 		}
+	}()
+
+	return f()
+}
+
+// 显示错误弹窗，标题界面本身出错时无法返回，关闭弹窗后退出
+func (this *Switcher) showError(modules common.Modules, err error) error {
+	msg := modules.Msg()
+
+	logfile.LogError("GameSwitcher: %s", err.Error())
+
+	_, this.errorFatal = this.currentState.(*Title)
+
+	buttonMsg := msg.Get("Return to title")
+	if this.errorFatal {
+		buttonMsg = msg.Get("Exit")
 	}
 
-	// 处理窗口逻辑
-	if (inpt.GetWindowResized() || this.currentState.GetForceRefreshBackground()) && this.currentState.GetHasBackground() {
+	boxMsg := msg.Get("Error loading game data:") + "\n" + err.Error()
+	dialog, dialogErr := this.gameRes.Menuf().New("confirm").(gameres.MenuConfirm).Init(modules, buttonMsg, boxMsg)
+	if dialogErr != nil {
+		// 弹窗也无法创建，只能返回原来的错误
+		logfile.LogError("GameSwitcher: %s", dialogErr)
+		return err
+	}
 
-		fmt.Println("refresh bk")
-		err = this.RefreshBackground(settings, eset) // 更新背景大小
+	this.errorDialog = dialog
+	this.errorDialog.SetVisible(true)
+
+	return this.errorDialog.Align(modules)
+}
+
+func (this *Switcher) logicErrorDialog(modules common.Modules) error {
+	inpt := modules.Inpt()
+
+	if inpt.GetWindowResized() {
+		err := this.errorDialog.Align(modules)
 		if err != nil {
 			return err
 		}
+	}
 
-		this.currentState.SetForceRefreshBackground(false)
+	err := this.errorDialog.Logic(modules, nil, nil)
+	if err != nil {
+		return err
 	}
-	this.currentState.Logic(modules, this.gameRes)
 
-	this.done = this.currentState.GetExitRequested()
+	if !this.errorDialog.GetConfirmClicked() && this.errorDialog.GetVisible() {
+		return nil
+	}
 
-	return nil
+	this.errorDialog.Close()
+	this.errorDialog = nil
+
+	if this.errorFatal {
+		this.done = true
+		return nil
+	}
+
+	// 回到标题界面
+	return this.setState(modules, NewTitle(modules, this.gameRes))
+}
+
+// 截图保存到 PATH_USER/screenshots
+func (this *Switcher) screenshot(modules common.Modules) {
+	settings := modules.Settings()
+
+	path := settings.GetPathUser() + "screenshots"
+	err := utils.CreateDir(path)
+	if err != nil {
+		logfile.LogError("Switcher: Couldn't create screenshot directory '%s'. %s", path, err)
+		return
+	}
+
+	filename := path + "/" + time.Now().Format("20060102_150405.000") + ".png"
+	modules.Render().Screenshot(filename)
 }
 
 func (this *Switcher) ShowFPS(modules common.Modules, fps float32) error {
@@ -168,6 +321,15 @@ func (this *Switcher) ShowFPS(modules common.Modules, fps float32) error {
 			avgFPS := (fps + this.lastFPS) / 2
 			this.lastFPS = fps
 			strFPS := fmt.Sprintf("%2.0f", avgFPS) + " fps"
+			if settings.Get("dev_mode").(bool) && settings.Get("dev_hud").(bool) {
+				// 合批效果
+				drawCalls, stateChanges := modules.Render().GetRenderStats()
+				strFPS += fmt.Sprintf(", %d draws, %d states", drawCalls, stateChanges)
+
+				// 文字缓存命中
+				textHits, textMisses, glyphHits, glyphMisses := modules.Render().GetTextCacheStats()
+				strFPS += fmt.Sprintf(", text %d/%d, glyphs %d/%d", textHits, textHits+textMisses, glyphHits, glyphHits+glyphMisses)
+			}
 			pos := utils.AlignToScreenEdge(settings, eset, this.fpsCorner, this.fpsPosition)
 			this.labelFPS.SetPos1(modules, pos.X, pos.Y)
 			this.labelFPS.SetText(strFPS)
@@ -334,7 +496,20 @@ func (this *Switcher) Render(modules common.Modules) error {
 			return err
 		}
 	}
-	this.currentState.Render(modules, this.gameRes)
+
+	if this.errorDialog != nil {
+		err := this.errorDialog.Render(modules)
+		if err != nil {
+			return err
+		}
+	} else {
+		err := protect(func() error {
+			return this.currentState.Render(modules, this.gameRes)
+		})
+		if err != nil {
+			return this.showError(modules, err)
+		}
+	}
 
 	err := tooltipm.Render(settings, eset, font, render)
 	if err != nil {
@@ -15,6 +15,7 @@ import (
 	"monster/pkg/filesystem/fileparser"
 	"monster/pkg/filesystem/logfile"
 	"monster/pkg/game/base"
+	"monster/pkg/game/menu"
 	"monster/pkg/utils"
 	"monster/pkg/utils/parsing"
 )
@@ -31,9 +32,7 @@ type Title struct {
 	tablist       common.WidgetTablist
 	posLogo       point.Point // logo的位置
 	alignLogo     int
-
-	//TODO
-	// MenuMovementType
+	movementType  gameres.MenuMovementType // 首次启动选择操作方式
 }
 
 func NewTitle(modules common.Modules, gameRes gameres.GameRes) *Title {
@@ -51,6 +50,7 @@ func (this *Title) init(modules common.Modules, gameRes gameres.GameRes) gameres
 	mods := modules.Mods()
 	render := modules.Render()
 	widgetf := modules.Widgetf()
+	platform := modules.Platform()
 
 	// base
 	this.State = base.ConstructState(modules)
@@ -148,8 +148,13 @@ func (this *Title) init(modules common.Modules, gameRes gameres.GameRes) gameres
 	this.labelVersion.SetText(version.CreateVersionStringFull())
 	this.labelVersion.SetColor(font.GetColor(fontengine.COLOR_MENU_NORMAL))
 
-	// TODO
 	// 设置tablist
+	this.tablist.Add(this.buttonPlay)
+	this.tablist.Add(this.buttonCfg)
+	this.tablist.Add(this.buttonCredits)
+	if platform.GetHasExitButton() {
+		this.tablist.Add(this.buttonExit)
+	}
 
 	err = this.RefreshWidgets(modules, gameRes)
 	if err != nil {
@@ -169,7 +174,17 @@ func (this *Title) init(modules common.Modules, gameRes gameres.GameRes) gameres
 
 	render.SetBackgroundColor(color.Construct(0, 0, 0, 0))
 
-	//TODO MenuMovementType
+	if !settings.Get("move_type_dimissed").(bool) {
+		movementType, err := menu.NewMovementType(modules)
+		if err != nil {
+			// 没有弹窗也能进入游戏，之后可以在设置里选择
+			logfile.LogError("GameStateTitle: %s", err)
+		} else {
+			this.movementType = movementType
+			this.movementType.SetVisible(true)
+		}
+	}
+
 	return this
 }
 
@@ -201,6 +216,11 @@ func (this *Title) Clear(common.Modules, gameres.GameRes) {
 	if this.tablist != nil {
 		this.tablist.Close()
 	}
+
+	if this.movementType != nil {
+		this.movementType.Close()
+		this.movementType = nil
+	}
 }
 
 func (this *Title) Close(modules common.Modules, gameRes gameres.GameRes) {
@@ -239,6 +259,10 @@ func (this *Title) RefreshWidgets(modules common.Modules, gameRes gameres.GameRe
 	// 锚点为(0,0) X的偏移为视口宽，右对齐
 	this.labelVersion.SetPos1(modules, settings.GetViewW(), 0)
 
+	if this.movementType != nil {
+		this.movementType.Align(modules)
+	}
+
 	return err
 }
 
@@ -280,6 +304,13 @@ func (this *Title) Render(modules common.Modules, gameRes gameres.GameRes) error
 		return err
 	}
 
+	if this.movementType != nil {
+		err = this.movementType.Render(modules)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -298,6 +329,11 @@ func (this *Title) Logic(modules common.Modules, gameRes gameres.GameRes) error
 	// TODO
 	// snd
 
+	// 选择操作方式时屏蔽标题界面
+	if this.movementType != nil && this.movementType.GetVisible() {
+		return this.movementType.Logic(modules, nil, nil)
+	}
+
 	if inpt.GetPressing(inputstate.CANCEL) && !inpt.GetLock(inputstate.CANCEL) {
 		inpt.SetLock(inputstate.CANCEL, true)
 		this.SetExitRequested(true)
@@ -306,22 +342,33 @@ func (this *Title) Logic(modules common.Modules, gameRes gameres.GameRes) error
 	//TODO
 	// menu
 
-	//TODO
-	//this.tablist.Logic()
+	err := this.tablist.Logic(modules)
+	if err != nil {
+		return err
+	}
 
 	// 检测按钮状态变化，hover，按放等
 	if this.buttonPlay.CheckClick(modules) {
 		fmt.Println("click play")
 
 		this.ShowLoading(modules)
-		this.SetRequestedGameState(modules, gameRes, NewLoad(modules, gameRes))
+		load, err := NewLoad(modules, gameRes)
+		if err != nil {
+			return err
+		}
+		this.SetRequestedGameState(modules, gameRes, load)
 	} else if this.buttonCfg.CheckClick(modules) {
 		fmt.Println("click cfg")
 
 		this.ShowLoading(modules)
 		this.SetRequestedGameState(modules, gameRes, NewConfig(modules, gameRes))
 	} else if this.buttonCredits.CheckClick(modules) {
-		fmt.Println("click credits")
+		this.ShowLoading(modules)
+		credits, err := NewCredits(modules, gameRes)
+		if err != nil {
+			return err
+		}
+		this.SetRequestedGameState(modules, gameRes, credits)
 	} else if platform.GetHasExitButton() && this.buttonExit.CheckClick(modules) {
 		this.SetExitRequested(true)
 	}
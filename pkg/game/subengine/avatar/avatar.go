@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"monster/pkg/common"
+	"monster/pkg/common/color"
 	"monster/pkg/common/define"
 	"monster/pkg/common/define/game/mapcollision"
 	"monster/pkg/common/define/game/stats"
@@ -14,8 +15,10 @@ import (
 	"monster/pkg/common/gameres/avatar"
 	"monster/pkg/common/gameres/power"
 	"monster/pkg/common/gameres/statblock"
+	"monster/pkg/common/light"
 	"monster/pkg/common/timer"
 	"monster/pkg/filesystem/fileparser"
+	"monster/pkg/filesystem/logfile"
 	"monster/pkg/game/base"
 	"monster/pkg/utils"
 	"monster/pkg/utils/parsing"
@@ -26,6 +29,10 @@ type Avatar struct {
 
 	animsets            []common.AnimationSet
 	anims               []common.Animation
+	layers              []avatar.LayerGfx     // 每个图层的染色和发光设置
+	glowsets            []common.AnimationSet // 发光图层，和animsets一一对应
+	glowAnims           []common.Animation
+	emitters            map[string]int // 装备和效果带的粒子发射器，文件名 -> 发射器id
 	body                int16
 	transformTriggered  bool // 在释放变身技能
 	lastTransform       string
@@ -56,6 +63,9 @@ type Avatar struct {
 	usingMain2         bool
 	prevHP             int
 	teleportCameraLock bool
+
+	ss     gameres.Stats
+	powers gameres.PowerManager
 }
 
 func New(modules common.Modules, mapr gameres.MapRenderer, ss gameres.Stats, powers gameres.PowerManager, gresf gameres.Factory) *Avatar {
@@ -122,6 +132,9 @@ func (this *Avatar) init(modules common.Modules, mapr gameres.MapRenderer, ss ga
 func (this *Avatar) Init(modules common.Modules, mapr gameres.MapRenderer, ss gameres.Stats, powers gameres.PowerManager) {
 	eset := modules.Eset()
 
+	this.ss = ss
+	this.powers = powers
+
 	// 清空图片
 	this.Entity.SetSprites(nil)
 
@@ -187,6 +200,13 @@ func (this *Avatar) Clear(modules common.Modules) {
 
 	anim.DecreaseCount("animations/hero.txt")
 
+	this.clearLayers(modules)
+	anim.CleanUp()
+}
+
+func (this *Avatar) clearLayers(modules common.Modules) {
+	anim := modules.Anim()
+
 	for i, ptr := range this.animsets {
 		if ptr != nil {
 			anim.DecreaseCount(ptr.GetName())
@@ -197,7 +217,21 @@ func (this *Avatar) Clear(modules common.Modules) {
 		}
 	}
 
-	anim.CleanUp()
+	for i, ptr := range this.glowsets {
+		if ptr != nil {
+			anim.DecreaseCount(ptr.GetName())
+		}
+
+		if this.glowAnims[i] != nil {
+			this.glowAnims[i].Close()
+		}
+	}
+
+	this.animsets = nil
+	this.anims = nil
+	this.glowsets = nil
+	this.glowAnims = nil
+	this.layers = nil
 }
 
 func (this *Avatar) Close(modules common.Modules) {
@@ -221,6 +255,18 @@ func (this *Avatar) SetAnimation(name string) {
 			this.anims[i] = nil
 		}
 	}
+
+	for i := 0; i < len(this.glowsets); i++ {
+		if this.glowAnims[i] != nil {
+			this.glowAnims[i].Close()
+		}
+
+		if this.glowsets[i] != nil {
+			this.glowAnims[i] = this.glowsets[i].GetAnimation(name)
+		} else {
+			this.glowAnims[i] = nil
+		}
+	}
 }
 
 func (this *Avatar) LoadGraphics(modules common.Modules, imageGfx []avatar.LayerGfx) error {
@@ -231,44 +277,142 @@ func (this *Avatar) LoadGraphics(modules common.Modules, imageGfx []avatar.Layer
 	mresf := modules.Resf()
 
 	stats := this.Entity.GetStats()
-	for i, ptr := range this.animsets {
-		if ptr != nil {
-			anim.DecreaseCount(ptr.GetName())
+
+	// 图层的动画没有变化时只更新染色，不重新加载
+	if this.sameLayers(imageGfx) {
+		for i, val := range imageGfx {
+			this.SetLayerTint(val.Type, val.Color, val.Alpha)
+			this.layers[i].GlowColor = val.GlowColor
+			this.layers[i].Light = val.Light
 		}
-		this.anims[i].Close()
+
+		return nil
 	}
 
-	this.animsets = nil
-	this.anims = nil
+	this.clearLayers(modules)
 
 	defer anim.CleanUp()
 
+	// 没有正在播放的动画时从站立开始，否则新图层和当前动画同步
+	if this.Entity.GetActiveAnimation() == nil {
+		this.Entity.SetActiveAnimation(this.Entity.GetAnimationSet().GetAnimation("stance"))
+	}
+
+	// 加载一个图层的动画，并和身体动画同步
+	load := func(gfx string) (common.AnimationSet, common.Animation, error) {
+		if gfx == "" {
+			return nil, nil, nil
+		}
+
+		// 每个txt包含一堆动画
+		name := "animations/avatar/" + stats.GetGfxBase() + "/" + gfx + ".txt"
+		anim.IncreaseCount(name)
+		aSet, err := anim.GetAnimationSet(settings, mods, render, mresf, name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		aSet.SetParent(this.Entity.GetAnimationSet())
+		a := aSet.GetAnimation(this.Entity.GetActiveAnimation().GetName())
+		if !a.SyncTo(this.Entity.GetActiveAnimation()) {
+			return aSet, a, fmt.Errorf("Avatar: Error syncing animation in '%s' to 'animations/hero.txt'.\n", aSet.GetName())
+		}
+
+		return aSet, a, nil
+	}
+
 	for _, val := range imageGfx {
+		aSet, a, err := load(val.Gfx)
+		this.animsets = append(this.animsets, aSet)
+		this.anims = append(this.anims, a)
+		if err != nil {
+			return err
+		}
+
+		var gSet common.AnimationSet
+		var g common.Animation
 		if val.Gfx != "" {
-			// 每个txt包含一堆动画
-			name := "animations/avatar/" + stats.GetGfxBase() + "/" + val.Gfx + ".txt"
-			anim.IncreaseCount(name)
-			aSet, err := anim.GetAnimationSet(settings, mods, render, mresf, name)
-			if err != nil {
-				return err
-			}
+			gSet, g, err = load(val.Glow)
+		}
+		this.glowsets = append(this.glowsets, gSet)
+		this.glowAnims = append(this.glowAnims, g)
+		if err != nil {
+			return err
+		}
 
-			aSet.SetParent(this.Entity.GetAnimationSet())
-			this.animsets = append(this.animsets, aSet)
-			this.anims = append(this.anims, aSet.GetAnimation(this.Entity.GetActiveAnimation().GetName()))
-			this.SetAnimation("stance")
+		this.layers = append(this.layers, val)
+	}
 
-			if !this.anims[len(this.anims)-1].SyncTo(this.Entity.GetActiveAnimation()) {
-				return fmt.Errorf("Avatar: Error syncing animation in '%s' to 'animations/hero.txt'.\n", this.animsets[len(this.animsets)-1].GetName())
-			}
+	return nil
+}
 
-		} else {
-			this.animsets = append(this.animsets, nil)
-			this.anims = append(this.anims, nil)
+// 粒子跟随角色，装备卸下或效果结束后停止发射
+func (this *Avatar) updateEmitters(modules common.Modules, mapr gameres.MapRenderer) {
+	particles := mapr.GetParticles()
+	stats := this.GetStats()
+
+	wanted := map[string]bool{}
+	for _, layer := range this.layers {
+		if layer.Particles != "" {
+			wanted[layer.Particles] = true
 		}
 	}
 
-	return nil
+	for _, name := range stats.GetEffects().GetParticles() {
+		wanted[name] = true
+	}
+
+	if this.emitters == nil {
+		this.emitters = map[string]int{}
+	}
+
+	for name, id := range this.emitters {
+		if !wanted[name] {
+			particles.StopEmitter(id)
+			delete(this.emitters, name)
+		}
+	}
+
+	for name, _ := range wanted {
+		// 加载失败的不再重试，换图清除后重新添加
+		if id, ok := this.emitters[name]; ok && (id == 0 || particles.HasEmitter(id)) {
+			continue
+		}
+
+		id, err := particles.AttachEmitter(modules, name, func() (fpoint.FPoint, bool) {
+			return stats.GetPos(), true
+		})
+		if err != nil {
+			logfile.LogError("Avatar: %s", err)
+		}
+
+		this.emitters[name] = id
+	}
+}
+
+func (this *Avatar) sameLayers(imageGfx []avatar.LayerGfx) bool {
+	if len(imageGfx) != len(this.layers) {
+		return false
+	}
+
+	for i, val := range imageGfx {
+		cur := this.layers[i]
+		if cur.Type != val.Type || cur.Gfx != val.Gfx || cur.Glow != val.Glow {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 运行时修改某个图层的染色和透明度
+func (this *Avatar) SetLayerTint(layerType string, c color.Color, alpha uint8) {
+	for i, _ := range this.layers {
+		if this.layers[i].Type == layerType {
+			this.layers[i].Color = c
+			this.layers[i].Alpha = alpha
+		}
+	}
 }
 
 // 根据方向选择对应的图片
@@ -278,19 +422,36 @@ func (this *Avatar) AddRenders(modules common.Modules, r []common.Renderable) []
 	if !stats.GetTransformed() {
 		// 不是变身状态
 
+		// 图层顺序随方向变化，比如朝北时武器在身体后面
 		for i, index := range this.layerDef[stats.GetDirection()] {
-			if this.anims[index] != nil {
-				ren := this.anims[index].GetCurrentFrame(modules, (int)(stats.GetDirection()))
-				ren.SetMapPos(stats.GetPos())
-				ren.SetPrio(uint64(i) + 1)
-
-				// 颜色同最后添加的效果和透明度
-				ren.SetColorMod(stats.GetEffects().GetCurrentColor(ren.GetColorMod()))
-				ren.SetAlphaMod(stats.GetEffects().GetCurrentAlpha(ren.GetAlphaMod()))
-				if stats.GetHP() > 0 {
-					ren.SetType(renderable.TYPE_HERO)
-				}
-				r = append(r, ren)
+			if int(index) >= len(this.anims) || this.anims[index] == nil {
+				continue
+			}
+
+			layer := this.layers[index]
+
+			ren := this.anims[index].GetCurrentFrame(modules, (int)(stats.GetDirection()))
+			ren.SetMapPos(stats.GetPos())
+			ren.SetPrio(uint64(i)*2 + 1)
+
+			// 颜色同最后添加的效果和透明度，再叠加图层自己的染色
+			ren.SetColorMod(multiplyColor(stats.GetEffects().GetCurrentColor(ren.GetColorMod()), layer.Color))
+			ren.SetAlphaMod(multiplyAlpha(stats.GetEffects().GetCurrentAlpha(ren.GetAlphaMod()), layer.Alpha))
+			if stats.GetHP() > 0 {
+				ren.SetType(renderable.TYPE_HERO)
+			}
+			r = append(r, ren)
+
+			// 发光层紧跟在图层之上
+			if this.glowAnims[index] != nil {
+				glow := this.glowAnims[index].GetCurrentFrame(modules, (int)(stats.GetDirection()))
+				glow.SetMapPos(stats.GetPos())
+				glow.SetPrio(uint64(i)*2 + 2)
+				glow.SetBlendMode(renderable.BLEND_ADD)
+				glow.SetEmissive(true) // 发光不被光照图压暗
+				glow.SetColorMod(layer.GlowColor)
+				glow.SetAlphaMod(multiplyAlpha(stats.GetEffects().GetCurrentAlpha(glow.GetAlphaMod()), layer.Alpha))
+				r = append(r, glow)
 			}
 		}
 
@@ -301,6 +462,28 @@ func (this *Avatar) AddRenders(modules common.Modules, r []common.Renderable) []
 	return r
 }
 
+// 图层物品和生效中的效果带的光源，跟随角色
+func (this *Avatar) AddLights(lights []light.Light) []light.Light {
+	stats := this.Entity.GetStats()
+
+	for _, layer := range this.layers {
+		if !layer.Light.IsValid() {
+			continue
+		}
+
+		l := layer.Light
+		l.Pos = stats.GetPos()
+		lights = append(lights, l)
+	}
+
+	for _, l := range stats.GetEffects().GetLights() {
+		l.Pos = stats.GetPos()
+		lights = append(lights, l)
+	}
+
+	return lights
+}
+
 // 加载精灵图层定义
 func (this *Avatar) loadLayerDefinitions(modules common.Modules) error {
 	mods := modules.Mods()
@@ -351,9 +534,22 @@ func (this *Avatar) loadLayerDefinitions(modules common.Modules) error {
 		}
 	}
 
+	avatar.FillDefaultLayerOrder(this.layerDef, len(this.layerReferenceOrder))
+
 	return nil
 }
 
+func multiplyColor(c1, c2 color.Color) color.Color {
+	c1.R = (uint8)((int)(c1.R) * (int)(c2.R) / 255)
+	c1.G = (uint8)((int)(c1.G) * (int)(c2.G) / 255)
+	c1.B = (uint8)((int)(c1.B) * (int)(c2.B) / 255)
+	return c1
+}
+
+func multiplyAlpha(a1, a2 uint8) uint8 {
+	return (uint8)((int)(a1) * (int)(a2) / 255)
+}
+
 func (this *Avatar) pressingMove(modules common.Modules) bool {
 	settings := modules.Settings()
 	inpt := modules.Inpt()
@@ -403,7 +599,7 @@ func (this *Avatar) SetDirection(modules common.Modules, mapr gameres.MapRendere
 	}
 }
 
-func (this *Avatar) Logic(modules common.Modules, mapr gameres.MapRenderer, camp gameres.CampaignManager) {
+func (this *Avatar) Logic(modules common.Modules, actionQueue []power.ActionData, mapr gameres.MapRenderer, camp gameres.CampaignManager) {
 	settings := modules.Settings()
 	inpt := modules.Inpt()
 	eset := modules.Eset()
@@ -444,6 +640,14 @@ func (this *Avatar) Logic(modules common.Modules, mapr gameres.MapRenderer, camp
 	// 计算状态值
 	this.GetStats().Logic(modules, this, camp)
 
+	this.updateEmitters(modules, mapr)
+
+	// 技能冷却
+	for id, ptr := range this.powerCooldownTimers {
+		ptr.Tick()
+		this.powerCastTimers[id].Tick()
+	}
+
 	if this.isDroppedToLowHP(modules) {
 		// TODO
 		// log msg
@@ -453,6 +657,11 @@ func (this *Avatar) Logic(modules common.Modules, mapr gameres.MapRenderer, camp
 	// TODO
 	// sound
 
+	// 受击闪红
+	if this.GetStats().GetHP() < this.prevHP {
+		modules.Render().FlashScreen(color.Construct(255, 0, 0, 96), settings.Get("max_fps").(int)/4)
+	}
+
 	this.prevHP = this.GetStats().GetHP()
 
 	if this.GetStats().GetLevel() < eset.XPGetMaxLevel() && this.GetStats().GetXp() >= eset.XPGetLevelXP(this.GetStats().GetLevel()+1) {
@@ -612,7 +821,11 @@ func (this *Avatar) Logic(modules common.Modules, mapr gameres.MapRenderer, camp
 		}
 
 		if allowedToUsePower {
-			// TODO 技能使用
+			for _, action := range actionQueue {
+				if this.usePower(modules, mapr, action) {
+					break
+				}
+			}
 		}
 
 	}
@@ -620,6 +833,51 @@ func (this *Avatar) Logic(modules common.Modules, mapr gameres.MapRenderer, camp
 	mapr.GetCam().SetTarget(this.Entity.GetStats().GetPos())
 }
 
+// 释放动作栏请求的技能
+func (this *Avatar) usePower(modules common.Modules, mapr gameres.MapRenderer, action power.ActionData) bool {
+	settings := modules.Settings()
+	eset := modules.Eset()
+	inpt := modules.Inpt()
+
+	pow := this.powers.GetPower(action.Power)
+	if pow == nil {
+		return false
+	}
+
+	if !this.powerCooldownTimers[action.Power].IsEnd() || !this.powerCastTimers[action.Power].IsEnd() {
+		return false
+	}
+
+	stats := this.GetStats()
+
+	// 目标为鼠标位置，键盘则为面向的方向
+	var target fpoint.FPoint
+	if inpt.UsingMouse(settings) {
+		mouse := inpt.GetMouse()
+		cam := mapr.GetCam().GetPos()
+		target = utils.ScreenToMap(settings, eset, mouse.X, mouse.Y, cam.X, cam.Y)
+	} else {
+		target = utils.CalcVector(stats.GetPos(), stats.GetDirection(), pow.TargetRange)
+	}
+
+	if pow.Face {
+		stats.SetDirection(utils.CalcDirection(stats.GetPos().X, stats.GetPos().Y, target.X, target.Y))
+	}
+
+	if !this.powers.Activate(modules, this.ss, action.Power, stats, target) {
+		return false
+	}
+
+	this.currentPower = action.Power
+	this.actTarget = target
+	this.powerCooldownTimers[action.Power].SetDuration((uint)(pow.Cooldown))
+
+	// TODO
+	// 施法动画
+
+	return true
+}
+
 func (this *Avatar) isDroppedToLowHP(modules common.Modules) bool {
 	settings := modules.Settings()
 
@@ -1,6 +1,7 @@
 package campaignmanager
 
 import (
+	"math"
 	"monster/pkg/common/define"
 	"monster/pkg/common/gameres"
 	"monster/pkg/utils/tools"
@@ -15,6 +16,7 @@ type CampaignManager struct {
 	bonusXP float32
 
 	status map[define.StatusId]*StatusPair
+	inv    gameres.MenuInventory // 事件奖励的物品放入背包
 }
 
 func New() *CampaignManager {
@@ -30,6 +32,46 @@ func (this *CampaignManager) init() gameres.CampaignManager {
 }
 
 func (this *CampaignManager) Close() {
+	this.inv = nil
+}
+
+func (this *CampaignManager) SetInventory(inv gameres.MenuInventory) {
+	this.inv = inv
+}
+
+// 奖励物品
+func (this *CampaignManager) RewardItem(id define.ItemId, quantity int) {
+	if this.inv == nil {
+		return
+	}
+
+	this.inv.AddItem(id, quantity)
+}
+
+// 移除物品，数量不足时不移除
+func (this *CampaignManager) RemoveItem(id define.ItemId, quantity int) bool {
+	if this.inv == nil {
+		return false
+	}
+
+	return this.inv.RemoveItem(id, quantity)
+}
+
+func (this *CampaignManager) RewardCurrency(amount int) {
+	if this.inv == nil || amount <= 0 {
+		return
+	}
+
+	this.inv.SetCurrency(this.inv.GetCurrency() + amount)
+}
+
+// 扣钱，不足时扣到0
+func (this *CampaignManager) RemoveCurrency(amount int) {
+	if this.inv == nil || amount <= 0 {
+		return
+	}
+
+	this.inv.SetCurrency((int)(math.Max((float64)(this.inv.GetCurrency()-amount), 0)))
 }
 
 // 注册
@@ -49,6 +91,15 @@ func (this *CampaignManager) RegisterStatus(s string) define.StatusId {
 	return newId
 }
 
+// 获得状态的名字
+func (this *CampaignManager) GetStatusName(s define.StatusId) string {
+	if ptr, ok := this.status[s]; ok {
+		return ptr.second
+	}
+
+	return ""
+}
+
 func (this *CampaignManager) checkStatus(s define.StatusId) bool {
 	if ptr, ok := this.status[s]; ok && ptr.first {
 		return true
@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"math"
 	"monster/pkg/common"
+	"monster/pkg/common/define"
 	"monster/pkg/common/event"
 	"monster/pkg/common/fpoint"
 	"monster/pkg/common/gameres"
 	"monster/pkg/common/timer"
+	"monster/pkg/filesystem/logfile"
 	"monster/pkg/utils/parsing"
 	"monster/pkg/utils/tools"
 )
@@ -46,7 +48,7 @@ func (this *EventManager) LoadEvent(modules common.Modules, loot gameres.LootMan
 		case "static":
 			evnt.ActivateType = event.ACTIVATE_STATIC
 		default:
-			return fmt.Errorf("EventManager: Event activation type '%s' unknown. Defaulting to 'on_trigger'.\n", val)
+			return fmt.Errorf("EventManager: Event activation type '%s' unknown. Defaulting to 'on_trigger'.", val)
 		}
 	case "location":
 		var first int
@@ -587,8 +589,24 @@ func (this *EventManager) loadEventComponent(modules common.Modules, loot gamere
 	case "parallax_layers":
 		e.Type = event.PARALLAX_LAYERS
 		e.S = val
+	case "light":
+		// radius,r,g,b[,flicker]
+		l := parsing.ToLight(val)
+		if !l.IsValid() {
+			return fmt.Errorf("EventManager: Light radius must be greater than 0.")
+		}
+		e.Type = event.LIGHT
+		e.F = l.Radius
+		e.X = (int)(l.Color.R)
+		e.Y = (int)(l.Color.G)
+		e.Z = (int)(l.Color.B)
+		e.A = (int)(l.Flicker*100 + 0.5)
 	default:
-		fmt.Errorf("EventManager: '%s' is not a valid key.\n", key)
+		// 未知的键只提示，不影响地图加载
+		if evnt != nil {
+			evnt.Components = evnt.Components[:len(evnt.Components)-1]
+		}
+		return common.NewLoadWarning(fmt.Errorf("EventManager: '%s' is not a valid key.", key))
 	}
 
 	return nil
@@ -667,6 +685,22 @@ func (this *EventManager) executeEventInternal(modules common.Modules, mapr game
 			} else {
 				mapr.SetTeleportDestination(fpoint.Construct(float32(ec.X)+0.5, float32(ec.Y)+0.5))
 			}
+		case event.REWARD_ITEM:
+			camp.RewardItem((define.ItemId)(ec.Id), ec.X)
+		case event.REMOVE_ITEM:
+			camp.RemoveItem((define.ItemId)(ec.Id), ec.X)
+		case event.REWARD_CURRENCY:
+			camp.RewardCurrency(ec.X)
+		case event.REMOVE_CURRENCY:
+			camp.RemoveCurrency(ec.X)
+		case event.CUTSCENE:
+			mapr.SetCutscene(true)
+			mapr.SetCutsceneFile(ec.S)
+		case event.PARALLAX_LAYERS:
+			err := mapr.SetMapParallax(modules, ec.S)
+			if err != nil {
+				logfile.LogError("EventManager: %s", err)
+			}
 		}
 	}
 
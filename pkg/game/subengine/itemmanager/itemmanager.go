@@ -19,23 +19,21 @@ type ItemManager struct {
 	itemQualities []item.Quality
 }
 
-func New(modules common.Modules, stats gameres.Stats) *ItemManager {
+func New(modules common.Modules, stats gameres.Stats) (*ItemManager, error) {
 	im := &ItemManager{}
-	im.init(modules, stats)
+	err := im.init(modules, stats)
+	if err != nil {
+		return nil, err
+	}
 
-	return im
+	return im, nil
 }
 
-func (this *ItemManager) init(modules common.Modules, stats gameres.Stats) gameres.ItemManager {
+func (this *ItemManager) init(modules common.Modules, stats gameres.Stats) error {
 	this.items = map[define.ItemId]*item.Item{}
 	this.itemSets = map[define.ItemSetId]*item.Set{}
 
-	err := this.loadAll(modules, stats)
-	if err != nil {
-		panic(err)
-	}
-
-	return this
+	return this.loadAll(modules, stats)
 }
 
 func (this *ItemManager) loadAll(modules common.Modules, stats gameres.Stats) error {
@@ -60,7 +58,7 @@ func (this *ItemManager) loadAll(modules common.Modules, stats gameres.Stats) er
 	}
 
 	if len(this.items) == 0 {
-		return fmt.Errorf("ItemManager: No items were found.")
+		return common.NewLoadError("items/items.txt", 0, "", "", fmt.Errorf("ItemManager: No items were found."))
 	}
 
 	return nil
@@ -111,8 +109,11 @@ func (this *ItemManager) loadItems(modules common.Modules, stats gameres.Stats,
 
 		if id < 1 {
 			if idLine {
-				return fmt.Errorf("ItemManager: Item index out of bounds 1-%d, skipping item.\n", math.MaxInt)
+				if err := infile.Reportf("ItemManager: Item index out of bounds 1-%d, skipping item.", math.MaxInt); err != nil {
+					return err
+				}
 			}
+			continue
 		}
 
 		if idLine {
@@ -160,7 +161,9 @@ func (this *ItemManager) loadItems(modules common.Modules, stats gameres.Stats,
 			}
 
 			if dmgType == len(dtList) {
-				return fmt.Errorf("ItemManager: '%s' is not a known damage type id.\n", dmgTypeStr)
+				if err := infile.Reportf("ItemManager: '%s' is not a known damage type id.", dmgTypeStr); err != nil {
+					return err
+				}
 			} else {
 				this.items[id].DmgMin[dmgType], val = parsing.PopFirstInt(val, "")
 				if val != "" {
@@ -193,7 +196,10 @@ func (this *ItemManager) loadItems(modules common.Modules, stats gameres.Stats,
 			if ok {
 				this.items[id].ReqStat = append(this.items[id].ReqStat, reqStatIndex)
 			} else {
-				return fmt.Errorf("ItemManager: '%s' is not a valid primary stat.\n", s)
+				if err := infile.Reportf("ItemManager: '%s' is not a valid primary stat.", s); err != nil {
+					return err
+				}
+				break
 			}
 
 			reqVal, _ := parsing.PopFirstInt(val, "")
@@ -211,7 +217,10 @@ func (this *ItemManager) loadItems(modules common.Modules, stats gameres.Stats,
 			bdata := item.ConstructBonusData()
 			_, err := this.parseBonus(modules, stats, key, val, &bdata)
 			if err != nil {
-				return err
+				if err := infile.Report(err); err != nil {
+					return err
+				}
+				break
 			}
 			this.items[id].Bonus = append(this.items[id].Bonus, bdata)
 		case "bonus_power_level":
@@ -229,6 +238,21 @@ func (this *ItemManager) loadItems(modules common.Modules, stats gameres.Stats,
 
 		case "gfx":
 			this.items[id].Gfx = val
+		case "gfx_color":
+			this.items[id].GfxColor = parsing.ToRGB(val)
+		case "gfx_alpha":
+			this.items[id].GfxAlpha = (uint8)(parsing.ToInt(val, 255))
+		case "glow":
+			// gfx, r, g, b
+			this.items[id].GlowGfx, val = parsing.PopFirstString(val, "")
+			if val != "" {
+				this.items[id].GlowColor = parsing.ToRGB(val)
+			}
+		case "light":
+			// radius, r, g, b, flicker
+			this.items[id].Light = parsing.ToLight(val)
+		case "particles":
+			this.items[id].Particles = val
 		case "loot_animation":
 			if clearLootAnim {
 				this.items[id].LootAnimation = nil
@@ -245,7 +269,9 @@ func (this *ItemManager) loadItems(modules common.Modules, stats gameres.Stats,
 			if parsing.ToInt(val, 0) > 0 {
 				this.items[id].Power = (define.PowerId)(parsing.ToInt(val, 0))
 			} else {
-				return fmt.Errorf("ItemManager: Power index out of bounds 1-%d, skipping power.\n", math.MaxInt)
+				if err := infile.Reportf("ItemManager: Power index out of bounds 1-%d, skipping power.", math.MaxInt); err != nil {
+					return err
+				}
 			}
 
 		case "replace_power":
@@ -308,14 +334,18 @@ func (this *ItemManager) loadItems(modules common.Modules, stats gameres.Stats,
 			case "all":
 				this.items[id].NoStash = item.NO_STASH_ALL
 			default:
-				return fmt.Errorf("ItemManager: '%s' is not a valid value for 'no_stash'. Use 'ignore', 'private', 'shared', or 'all'.\n", temp)
+				if err := infile.Reportf("ItemManager: '%s' is not a valid value for 'no_stash'. Use 'ignore', 'private', 'shared', or 'all'.", temp); err != nil {
+					return err
+				}
 			}
 
 		case "script":
 			this.items[id].Script, _ = parsing.PopFirstString(val, "")
 
 		default:
-			return fmt.Errorf("ItemManager: '%s' is not a valid key.\n", key)
+			if err := infile.Reportf("ItemManager: '%s' is not a valid key.", key); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -385,7 +415,7 @@ func (this *ItemManager) parseBonus(modules common.Modules, ss gameres.Stats, ke
 	}
 
 	fmt.Println("-----" + bonusStr)
-	return val, fmt.Errorf("ItemManager: Unknown bonus type '%s'.\n", bonusStr)
+	return val, fmt.Errorf("ItemManager: Unknown bonus type '%s'.", bonusStr)
 }
 
 // 加载物品类型，装在哪个位置
@@ -424,7 +454,9 @@ func (this *ItemManager) loadType(modules common.Modules, filename string) error
 		case "name":
 			this.itemTypes[len(this.itemTypes)-1].Name = val
 		default:
-			return fmt.Errorf("ItemManager: '%s' is not a valid key.\n", key)
+			if err := infile.Reportf("ItemManager: '%s' is not a valid key.", key); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -473,7 +505,12 @@ func (this *ItemManager) loadSets(modules common.Modules, stats gameres.Stats, f
 		}
 
 		if id < 1 {
-			return fmt.Errorf("ItemManager: Item set index out of bounds 1-%d, skipping set.\n", math.MaxInt)
+			if idLine {
+				if err := infile.Reportf("ItemManager: Item set index out of bounds 1-%d, skipping set.", math.MaxInt); err != nil {
+					return err
+				}
+			}
+			continue
 		}
 
 		if idLine {
@@ -518,7 +555,9 @@ func (this *ItemManager) loadSets(modules common.Modules, stats gameres.Stats, f
 
 			this.itemSets[id].Bonus = append(this.itemSets[id].Bonus, bonus)
 		default:
-			return fmt.Errorf("ItemManager: '%s' is not a valid key.\n", key)
+			if err := infile.Reportf("ItemManager: '%s' is not a valid key.", key); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -566,7 +605,9 @@ func (this *ItemManager) loadQualities(modules common.Modules, filename string)
 		case "overlay_icon":
 			this.itemQualities[len(this.itemQualities)-1].OverlayIcon = parsing.ToInt(val, 0)
 		default:
-			return fmt.Errorf("ItemManager: '%s' is not a valid key.\n", key)
+			if err := infile.Reportf("ItemManager: '%s' is not a valid key.", key); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -1,7 +1,6 @@
 package lootmanager
 
 import (
-	"fmt"
 	"math"
 	"monster/pkg/common"
 	"monster/pkg/common/define"
@@ -17,29 +16,33 @@ type LootManager struct {
 	animations map[define.ItemId]([]common.Animation)
 }
 
-func New(modules common.Modules, items gameres.ItemManager) *LootManager {
+func New(modules common.Modules, items gameres.ItemManager) (*LootManager, error) {
 	lm := &LootManager{}
 
-	lm.init(modules, items)
+	err := lm.init(modules, items)
+	if err != nil {
+		lm.Close(modules)
+		return nil, err
+	}
 
-	return lm
+	return lm, nil
 }
 
-func (this *LootManager) init(modules common.Modules, items gameres.ItemManager) gameres.LootManager {
+func (this *LootManager) init(modules common.Modules, items gameres.ItemManager) error {
 	this.lootTables = map[string]([]event.Component){}
 	this.animations = map[define.ItemId]([]common.Animation){}
 
 	err := this.loadGraphics(modules, items)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	err = this.loadLootTables(modules)
-	if err != nil {
-		panic(err)
-	}
+	return this.loadLootTables(modules)
+}
 
-	return this
+// 获得全部战利品表 (文件名 -> 掉落列表)
+func (this *LootManager) GetLootTables() map[string][]event.Component {
+	return this.lootTables
 }
 
 func (this *LootManager) Close(modules common.Modules) {
@@ -144,7 +147,9 @@ func (this *LootManager) loadLootTables(modules common.Modules) error {
 					} else if parsing.ToInt(ec.S, -1) != -1 {
 						ec.Id = parsing.ToInt(ec.S, 0)
 					} else {
-						return fmt.Errorf("LootManager: Invalid item id for loot.")
+						if err := infile.Reportf("LootManager: Invalid item id for loot."); err != nil {
+							return err
+						}
 					}
 				case "chance":
 					var chance string
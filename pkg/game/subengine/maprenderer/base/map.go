@@ -102,6 +102,10 @@ type Map struct {
 	heroPosEnabled         bool
 	heroPos                fpoint.FPoint // 默认主角出生位置
 	parallaxFilename       string        // 视差图层文件定义
+	collisionGenerated     bool          // 地图文件里没有碰撞图层
+	weatherFilename        string        // 天气粒子发射器定义
+	ambientLight           color.Color   // 环境光，启用时画光照图
+	ambientLightEnabled    bool
 	backgroundColor        color.Color
 }
 
@@ -173,6 +177,9 @@ func (this *Map) Load(modules common.Modules, loot gameres.LootManager, camp gam
 
 	this.musicFilename = ""
 	this.parallaxFilename = ""
+	this.weatherFilename = ""
+	this.ambientLight = color.Construct(255, 255, 255)
+	this.ambientLightEnabled = false
 	this.backgroundColor = color.Construct(0, 0, 0, 0)
 	this.w = 1
 	this.h = 1
@@ -204,32 +211,30 @@ func (this *Map) Load(modules common.Modules, loot gameres.LootManager, camp gam
 		switch infile.GetSection() {
 		case "header":
 			err := this.loadHeader(modules, infile.Key(), infile.Val())
-			if err != nil {
-				panic("header")
+			if err := infile.Report(err); err != nil {
 				return err
 			}
 		case "layer":
 			// 图层定义
 			err := this.loadLayer(modules, infile)
-			if err != nil {
-				panic("layer")
+			if err := infile.Report(err); err != nil {
 				return err
 			}
 		case "enemy":
 			err := this.loadEnemyGroup(camp, infile.Key(), infile.Val())
-			if err != nil {
+			if err := infile.Report(err); err != nil {
 				return err
 			}
 		case "npc":
 			err := this.loadNPC(camp, infile.Key(), infile.Val())
-			if err != nil {
+			if err := infile.Report(err); err != nil {
 				return err
 			}
 
 		case "event":
 			// 地图事件
 			err := eventManager.LoadEvent(modules, loot, camp, infile.Key(), infile.Val(), &(this.events[len(this.events)-1]))
-			if err != nil {
+			if err := infile.Report(err); err != nil {
 				return err
 			}
 		}
@@ -252,6 +257,7 @@ func (this *Map) Load(modules common.Modules, loot gameres.LootManager, camp gam
 	}
 
 	// 保证一定有碰撞图层
+	this.collisionGenerated = !found
 	if !found {
 		this.layerNames = append(this.layerNames, "collision")
 		tmp := make([][]uint16, this.w)
@@ -293,6 +299,29 @@ func (this *Map) loadHeader(modules common.Modules, key, val string) error {
 		this.heroPosEnabled = true
 	case "parallax_layers":
 		this.parallaxFilename = val
+	case "weather":
+		// weather=rain|snow|fog[,自定义发射器文件]
+		var weatherType string
+		weatherType, val = parsing.PopFirstString(val, "")
+		switch weatherType {
+		case "rain", "snow", "fog":
+		default:
+			return fmt.Errorf("Map: '%s' is not a valid weather type.", weatherType)
+		}
+
+		this.weatherFilename = "particles/weather/" + weatherType + ".txt"
+		if val != "" {
+			this.weatherFilename = val
+		}
+	case "ambient_light":
+		// 亮度 0-255 或者 r,g,b
+		if strings.Contains(val, ",") {
+			this.ambientLight = parsing.ToRGB(val)
+		} else {
+			level := (uint8)(math.Min(math.Max((float64)(parsing.ToInt(val, 255)), 0), 255))
+			this.ambientLight = color.Construct(level, level, level)
+		}
+		this.ambientLightEnabled = true
 	case "background_color":
 		this.backgroundColor = parsing.ToRGBA(val)
 	case "fogofwar":
@@ -305,7 +334,7 @@ func (this *Map) loadHeader(modules common.Modules, key, val string) error {
 
 	case "orientation":
 	default:
-		return fmt.Errorf("Map: '%s' is not a valid key.\n", key)
+		return fmt.Errorf("Map: '%s' is not a valid key.", key)
 	}
 
 	return nil
@@ -323,7 +352,7 @@ func (this *Map) loadLayer(modules common.Modules, infile *fileparser.FileParser
 		this.layerNames = append(this.layerNames, infile.Val())
 	case "format":
 		if infile.Val() != "dec" {
-			return fmt.Errorf("Map: The format of a layer must be 'dec'!")
+			return infile.Errorf("Map: The format of a layer must be 'dec'!")
 		}
 	case "data":
 		for j := (uint16)(0); j < this.h; j++ {
@@ -334,7 +363,9 @@ func (this *Map) loadLayer(modules common.Modules, infile *fileparser.FileParser
 			}
 			commaCount := strings.Count(val, ",")
 			if commaCount != (int)(this.w) {
-				return fmt.Errorf("Map: A row of layer data has a width not equal to %d.\n", this.w)
+				if err := infile.Reportf("Map: A row of layer data has a width not equal to %d.", this.w); err != nil {
+					return err
+				}
 			}
 
 			var first int
@@ -344,7 +375,7 @@ func (this *Map) loadLayer(modules common.Modules, infile *fileparser.FileParser
 			}
 		}
 	default:
-		return fmt.Errorf("Map: '%s' is not a valid key.\n", infile.Key())
+		return infile.Errorf("Map: '%s' is not a valid key.", infile.Key())
 	}
 
 	return nil
@@ -505,7 +536,7 @@ func (this *Map) loadEnemyGroup(camp gameres.CampaignManager, key, val string) e
 		}
 
 	default:
-		return fmt.Errorf("Map: '%s' is not a valid key.\n", key)
+		return fmt.Errorf("Map: '%s' is not a valid key.", key)
 	}
 
 	return nil
@@ -610,7 +641,7 @@ func (this *Map) loadNPC(camp gameres.CampaignManager, key, val string) error {
 		npc.requirements = append(npc.requirements, ec)
 
 	default:
-		return fmt.Errorf("Map: '%s' is not a valid key.\n", key)
+		return fmt.Errorf("Map: '%s' is not a valid key.", key)
 	}
 
 	return nil
@@ -699,6 +730,22 @@ func (this *Map) GetHeroPos() fpoint.FPoint {
 	return this.heroPos
 }
 
+func (this *Map) GetCollisionGenerated() bool {
+	return this.collisionGenerated
+}
+
+func (this *Map) GetAmbientLight() (color.Color, bool) {
+	return this.ambientLight, this.ambientLightEnabled
+}
+
+func (this *Map) GetWeatherFilename() string {
+	return this.weatherFilename
+}
+
+func (this *Map) GetParallaxFilename() string {
+	return this.parallaxFilename
+}
+
 func (this *Map) GetFilename() string {
 	return this.filename
 }
@@ -0,0 +1,257 @@
+package maprenderer
+
+import (
+	"math"
+	"monster/pkg/common"
+	"monster/pkg/common/color"
+	"monster/pkg/common/define/enginesettings"
+	"monster/pkg/common/define/renderable"
+	"monster/pkg/common/event"
+	"monster/pkg/common/light"
+	"monster/pkg/common/rect"
+	"monster/pkg/utils"
+)
+
+const LIGHT_GFX_SIZE = 128 // 径向渐变图片的边长
+
+// 光照图，环境光为底色，光源加法叠加后整体乘到画面上
+type Lighting struct {
+	lightMap  common.Image
+	lightGfx  common.Image
+	mapLights []light.Light // 地图事件的光源
+	lights    []light.Light // 本帧添加的光源
+	frame     int
+}
+
+func newLighting() *Lighting {
+	return &Lighting{}
+}
+
+func (this *Lighting) Close() {
+	if this.lightMap != nil {
+		this.lightMap.UnRef()
+		this.lightMap = nil
+	}
+
+	if this.lightGfx != nil {
+		this.lightGfx.UnRef()
+		this.lightGfx = nil
+	}
+
+	this.mapLights = nil
+	this.lights = nil
+}
+
+// 收集地图事件里的光源，事件执行后可能被删除，所以在加载时保存
+func (this *Lighting) loadMapLights(events []event.Event) {
+	this.mapLights = nil
+
+	for i, _ := range events {
+		ec, ok := events[i].GetComponent(event.LIGHT)
+		if !ok {
+			continue
+		}
+
+		l := light.Construct()
+		l.Pos = events[i].Center
+		if l.Pos.X == -1 && l.Pos.Y == -1 {
+			l.Pos.X = (float32)(events[i].Location.X) + (float32)(events[i].Location.W)/2
+			l.Pos.Y = (float32)(events[i].Location.Y) + (float32)(events[i].Location.H)/2
+		}
+		l.Radius = ec.F
+		l.Color = color.Construct((uint8)(ec.X), (uint8)(ec.Y), (uint8)(ec.Z))
+		l.Flicker = (float32)(ec.A) / 100
+		l.Seed = i * 31
+
+		this.mapLights = append(this.mapLights, l)
+	}
+}
+
+func (this *Lighting) addLight(l light.Light) {
+	if l.IsValid() {
+		this.lights = append(this.lights, l)
+	}
+}
+
+// 光照图和画面一样大
+func (this *Lighting) prepare(modules common.Modules) error {
+	settings := modules.Settings()
+	render := modules.Render()
+
+	viewW := settings.GetViewW()
+	viewH := settings.GetViewH()
+
+	if this.lightMap != nil {
+		w, err := this.lightMap.GetWidth()
+		if err != nil {
+			return err
+		}
+		h, err := this.lightMap.GetHeight()
+		if err != nil {
+			return err
+		}
+
+		if w != viewW || h != viewH {
+			this.lightMap.UnRef()
+			this.lightMap = nil
+		}
+	}
+
+	var err error
+	if this.lightMap == nil {
+		this.lightMap, err = render.CreateImage(viewW, viewH)
+		if err != nil {
+			return err
+		}
+	}
+
+	if this.lightGfx == nil {
+		this.lightGfx, err = createLightGfx(render)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createLightGfx(render common.RenderDevice) (common.Image, error) {
+	gfx, err := render.CreateImage(LIGHT_GFX_SIZE, LIGHT_GFX_SIZE)
+	if err != nil {
+		return nil, err
+	}
+
+	err = gfx.BeginPixelBatch()
+	if err != nil {
+		gfx.UnRef()
+		return nil, err
+	}
+
+	half := (float32)(LIGHT_GFX_SIZE) / 2
+	for y := 0; y < LIGHT_GFX_SIZE; y++ {
+		for x := 0; x < LIGHT_GFX_SIZE; x++ {
+			dx := ((float32)(x) + 0.5 - half) / half
+			dy := ((float32)(y) + 0.5 - half) / half
+			v := (uint8)(light.Falloff((float32)(math.Sqrt((float64)(dx*dx+dy*dy)))) * 255)
+
+			err = gfx.DrawPixel(x, y, color.Construct(v, v, v, 255))
+			if err != nil {
+				gfx.UnRef()
+				return nil, err
+			}
+		}
+	}
+
+	err = gfx.EndPixelBatch()
+	if err != nil {
+		gfx.UnRef()
+		return nil, err
+	}
+
+	return gfx, nil
+}
+
+// 光源半径对应的屏幕半轴，等距地图是椭圆
+func lightScreenRadius(eset common.EngineSettings, radius float32) (int, int) {
+	tileSize := eset.Get("tileset", "tile_size").([]int)
+
+	if eset.Get("tileset", "orientation").(int) == enginesettings.TILESET_ISOMETRIC {
+		return (int)(radius * (float32)(tileSize[0]) / math.Sqrt2), (int)(radius * (float32)(tileSize[1]) / math.Sqrt2)
+	}
+
+	return (int)(radius * (float32)(tileSize[0])), (int)(radius * (float32)(tileSize[1]))
+}
+
+// 画光照图并乘到画面上，再补画自发光的对象
+func (this *MapRenderer) renderLighting(modules common.Modules, emissive []common.Renderable) error {
+	settings := modules.Settings()
+	eset := modules.Eset()
+	render := modules.Render()
+	mresf := modules.Resf()
+
+	defer func() {
+		this.lighting.lights = this.lighting.lights[:0]
+		this.lighting.frame++
+	}()
+
+	ambient, ok := this.Map.GetAmbientLight()
+	if !ok {
+		return nil
+	}
+
+	err := this.lighting.prepare(modules)
+	if err != nil {
+		return err
+	}
+
+	err = this.lighting.lightMap.FillWithColor(color.Construct(ambient.R, ambient.G, ambient.B, 255))
+	if err != nil {
+		return err
+	}
+
+	err = render.BeginTarget(this.lighting.lightMap)
+	if err != nil {
+		return err
+	}
+
+	viewW := settings.GetViewW()
+	viewH := settings.GetViewH()
+
+	for _, lights := range [][]light.Light{this.lighting.mapLights, this.lighting.lights} {
+		for i, _ := range lights {
+			l := &(lights[i])
+
+			halfW, halfH := lightScreenRadius(eset, l.Radius)
+			if halfW <= 0 || halfH <= 0 {
+				continue
+			}
+
+			p := utils.MapToScreen(settings, eset, l.Pos.X, l.Pos.Y, this.cam.shake.X, this.cam.shake.Y)
+			dest := rect.Construct(p.X-halfW, p.Y-halfH, halfW*2, halfH*2)
+
+			// 屏幕外的跳过
+			if dest.X+dest.W < 0 || dest.Y+dest.H < 0 || dest.X > viewW || dest.Y > viewH {
+				continue
+			}
+
+			ren := mresf.New("renderable").(common.Renderable)
+			ren.SetImage(this.lighting.lightGfx)
+			ren.SetSrc(rect.Construct(0, 0, LIGHT_GFX_SIZE, LIGHT_GFX_SIZE))
+			ren.SetBlendMode(renderable.BLEND_ADD)
+			ren.SetColorMod(l.ColorAt(this.lighting.frame))
+			ren.SetAlphaMod(255)
+
+			err = render.Render1(ren, dest)
+			if err != nil {
+				render.EndTarget()
+				return err
+			}
+		}
+	}
+
+	err = render.EndTarget()
+	if err != nil {
+		return err
+	}
+
+	ren := mresf.New("renderable").(common.Renderable)
+	ren.SetImage(this.lighting.lightMap)
+	ren.SetSrc(rect.Construct(0, 0, viewW, viewH))
+	ren.SetBlendMode(renderable.BLEND_MULTIPLY)
+	ren.SetColorMod(color.Construct(255, 255, 255))
+	ren.SetAlphaMod(255)
+
+	err = render.Render1(ren, rect.Construct(0, 0, viewW, viewH))
+	if err != nil {
+		return err
+	}
+
+	for i, _ := range emissive {
+		err := this.drawRenderable(modules, emissive, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
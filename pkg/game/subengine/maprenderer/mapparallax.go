@@ -1,9 +1,11 @@
 package maprenderer
 
 import (
+	"math"
 	"monster/pkg/common"
 	"monster/pkg/common/fpoint"
 	"monster/pkg/filesystem/fileparser"
+	"monster/pkg/utils"
 	"monster/pkg/utils/parsing"
 )
 
@@ -13,20 +15,24 @@ type MapParallaxLayer struct {
 	speed       float32
 	fixedSpeed  fpoint.FPoint
 	fixedOffset fpoint.FPoint
-	mapLayer    string
+	mapLayer    string // 锚定的地图图层，空表示在所有地图图层之前
+	before      bool   // 在锚定图层之前绘制
+	tileX       bool   // 水平平铺
+	tileY       bool   // 垂直平铺
 }
 
 func constructMapParallaxLayer() MapParallaxLayer {
 	return MapParallaxLayer{
 		fixedSpeed:  fpoint.Construct(),
 		fixedOffset: fpoint.Construct(),
+		tileX:       true,
+		tileY:       true,
 	}
 }
 
 type MapParallax struct {
 	layers          []MapParallaxLayer
 	mapCenter       fpoint.FPoint
-	currentLayer    int
 	loaded          bool
 	currentFilename string
 }
@@ -54,6 +60,7 @@ func (this *MapParallax) clear() {
 
 	this.layers = nil
 	this.loaded = false
+	this.currentFilename = ""
 }
 
 func (this *MapParallax) Load(modules common.Modules, filename string) error {
@@ -62,12 +69,22 @@ func (this *MapParallax) Load(modules common.Modules, filename string) error {
 	settings := modules.Settings()
 
 	maxFPS := settings.Get("max_fps").(int)
-	this.currentFilename = filename
+
+	// 同一个文件不重复加载
+	if this.loaded && this.currentFilename == filename {
+		return nil
+	}
 
 	if this.loaded {
 		this.clear()
 	}
 
+	// 关闭视差图层时只记下文件名，打开后在Render里加载
+	this.currentFilename = filename
+	if filename == "" || !settings.Get("parallax_layers").(bool) {
+		return nil
+	}
+
 	infile := fileparser.New()
 
 	err := infile.Open(filename, true, mods)
@@ -103,15 +120,33 @@ func (this *MapParallax) Load(modules common.Modules, filename string) error {
 			}
 			graphics.UnRef()
 		case "speed":
-			this.layers[len(this.layers)-1].speed = (settings.LOGIC_FPS() * parsing.ToFloat(val, 0) / (float32)(maxFPS))
+			// 相对地图的额外移动比例，0 和地图一起移动，-1 固定在屏幕上
+			this.layers[len(this.layers)-1].speed = parsing.ToFloat(val, 0)
 		case "fixed_speed":
 			first, val = parsing.PopFirstString(val, "")
 			this.layers[len(this.layers)-1].fixedSpeed.X = (settings.LOGIC_FPS() * parsing.ToFloat(first, 0) / (float32)(maxFPS))
 			first, val = parsing.PopFirstString(val, "")
 			this.layers[len(this.layers)-1].fixedSpeed.Y = (settings.LOGIC_FPS() * parsing.ToFloat(first, 0) / (float32)(maxFPS))
-
+		case "fixed_offset":
+			first, val = parsing.PopFirstString(val, "")
+			this.layers[len(this.layers)-1].fixedOffset.X = parsing.ToFloat(first, 0)
+			first, val = parsing.PopFirstString(val, "")
+			this.layers[len(this.layers)-1].fixedOffset.Y = parsing.ToFloat(first, 0)
+		case "tile":
+			first, val = parsing.PopFirstString(val, "")
+			this.layers[len(this.layers)-1].tileX = parsing.ToBool(first)
+			first, val = parsing.PopFirstString(val, "")
+			if first != "" {
+				this.layers[len(this.layers)-1].tileY = parsing.ToBool(first)
+			} else {
+				this.layers[len(this.layers)-1].tileY = this.layers[len(this.layers)-1].tileX
+			}
 		case "map_layer":
-			this.layers[len(this.layers)-1].mapLayer = val
+			// map_layer=名称[,before|after]
+			first, val = parsing.PopFirstString(val, "")
+			this.layers[len(this.layers)-1].mapLayer = first
+			first, val = parsing.PopFirstString(val, "")
+			this.layers[len(this.layers)-1].before = (first == "before")
 		}
 	}
 
@@ -125,7 +160,103 @@ func (this *MapParallax) SetMapCenter(x, y int) {
 	this.mapCenter.Y = float32(y) + 0.5
 }
 
-func (this *MapParallax) Render(cam fpoint.FPoint, mapLayer string) error {
-	// TODO
+// 绘制锚定在mapLayer上的视差图层，mapLayer为空时绘制未锚定的图层
+func (this *MapParallax) Render(modules common.Modules, cam fpoint.FPoint, mapLayer string, before bool) error {
+	settings := modules.Settings()
+	eset := modules.Eset()
+	render := modules.Render()
+
+	if !settings.Get("parallax_layers").(bool) {
+		return nil
+	}
+
+	if !this.loaded {
+		if this.currentFilename == "" {
+			return nil
+		}
+
+		err := this.Load(modules, this.currentFilename)
+		if err != nil {
+			this.clear()
+			return err
+		}
+	}
+
+	for i, _ := range this.layers {
+		layer := &(this.layers[i])
+
+		if layer.sprite == nil || layer.mapLayer != mapLayer {
+			continue
+		}
+
+		if mapLayer != "" && layer.before != before {
+			continue
+		}
+
+		width, err := layer.sprite.GetGraphicsWidth()
+		if err != nil {
+			return err
+		}
+		height, err := layer.sprite.GetGraphicsHeight()
+		if err != nil {
+			return err
+		}
+
+		if width <= 0 || height <= 0 {
+			continue
+		}
+
+		// 固定速度滚动，单位是屏幕像素，平铺方向上按图片大小回绕
+		layer.fixedOffset.X += layer.fixedSpeed.X
+		layer.fixedOffset.Y += layer.fixedSpeed.Y
+
+		if layer.tileX {
+			layer.fixedOffset.X = wrapOffset(layer.fixedOffset.X, (float32)(width))
+		}
+		if layer.tileY {
+			layer.fixedOffset.Y = wrapOffset(layer.fixedOffset.Y, (float32)(height))
+		}
+
+		dx := this.mapCenter.X - cam.X
+		dy := this.mapCenter.Y - cam.Y
+
+		center := utils.MapToScreen(settings, eset, this.mapCenter.X+dx*layer.speed, this.mapCenter.Y+dy*layer.speed, cam.X, cam.Y)
+		center.X += (int)(layer.fixedOffset.X) - width/2
+		center.Y += (int)(layer.fixedOffset.Y) - height/2
+
+		startX, endX := tileRange(center.X, width, settings.GetViewW(), layer.tileX)
+		startY, endY := tileRange(center.Y, height, settings.GetViewH(), layer.tileY)
+
+		for x := startX; x < endX; x += width {
+			for y := startY; y < endY; y += height {
+				layer.sprite.SetDest(x, y)
+				err := render.Render(layer.sprite)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
 	return nil
 }
+
+func wrapOffset(offset, size float32) float32 {
+	if offset > size || offset < -size {
+		offset = (float32)(math.Mod((float64)(offset), (float64)(size)))
+	}
+
+	return offset
+}
+
+// 一个方向上的绘制范围，不平铺时只画一张
+func tileRange(pos, size, viewSize int, tile bool) (int, int) {
+	if !tile {
+		return pos, pos + 1
+	}
+
+	// 第一张图片的起点落在 (-size, 0]
+	start := pos - (int)(math.Ceil((float64)(pos)/(float64)(size)))*size
+
+	return start, viewSize
+}
@@ -8,10 +8,12 @@ import (
 	"monster/pkg/common/event"
 	"monster/pkg/common/fpoint"
 	"monster/pkg/common/gameres"
+	"monster/pkg/common/light"
 	"monster/pkg/common/point"
 	"monster/pkg/common/rect"
 	"monster/pkg/common/tooltipdata"
 	"monster/pkg/game/subengine/maprenderer/base"
+	"monster/pkg/game/subengine/particlemanager"
 	"monster/pkg/utils"
 	"sort"
 )
@@ -23,6 +25,8 @@ type MapRenderer struct {
 	tipPos             point.Point
 	tset               *TileSet
 	mapParallax        *MapParallax
+	particles          gameres.ParticleManager
+	lighting           *Lighting
 	entityHiddenNormal common.Sprite
 	entityHiddenEnemy  common.Sprite
 	cam                *Camera
@@ -33,6 +37,8 @@ type MapRenderer struct {
 	teleportation       bool // 传送
 	teleportDestination fpoint.FPoint
 	teleportMapName     string
+	cutscene            bool // 播放过场动画
+	cutsceneFile        string
 	indexObjectLayer    uint // 层级关系：背景、对象、碰撞，碰撞被删除，故先背景后对象
 	isSpawnMap          bool // 初始地图为maps/spawn.txt，里面包含要跳转的实际地图，所以不需要一开始就渲染
 }
@@ -57,8 +63,10 @@ func (this *MapRenderer) init(modules common.Modules, resf common.Factory) gamer
 	this.tip = widgetf.New("tooltip").(common.WidgetTooltip).Init(modules)
 	this.tipBuf = tooltipdata.Construct()
 	this.tipPos = point.Construct()
-	this.tset = newTileSet()
+	this.tset = NewTileSet()
 	this.mapParallax = newMapParallax()
+	this.particles = particlemanager.New()
+	this.lighting = newLighting()
 	this.cam = newCamera(modules)
 	this.collider = resf.New("mapcollision").(gameres.MapCollision).Init()
 
@@ -109,6 +117,16 @@ func (this *MapRenderer) Clear() {
 		this.mapParallax.Close()
 		this.mapParallax = nil
 	}
+
+	if this.particles != nil {
+		this.particles.Close()
+		this.particles = nil
+	}
+
+	if this.lighting != nil {
+		this.lighting.Close()
+		this.lighting = nil
+	}
 }
 
 func (this *MapRenderer) Close() {
@@ -118,25 +136,43 @@ func (this *MapRenderer) Close() {
 func (this *MapRenderer) Render(modules common.Modules, r []common.Renderable, rDead []common.Renderable) error {
 	eset := modules.Eset()
 
-	err := this.mapParallax.Render(this.cam.shake, "")
+	err := this.mapParallax.Render(modules, this.cam.shake, "", false)
 	if err != nil {
 		return err
 	}
 
+	// 粒子和其他对象一起排序
+	r = this.particles.AddRenders(modules, r)
+
 	if eset.Get("tileset", "orientation").(int) == enginesettings.TILESET_ORTHOGONAL {
-		// TODO
+		r = this.calculatePriosOrtho(r)
+		rDead = this.calculatePriosOrtho(rDead)
+		sort.Slice(r, func(i, j int) bool { return r[i].GetPrio() < r[j].GetPrio() })
+		sort.Slice(rDead, func(i, j int) bool { return rDead[i].GetPrio() < rDead[j].GetPrio() })
+		err := this.renderOrtho(modules, r, rDead)
+		if err != nil {
+			return err
+		}
 	} else {
 		r = this.calculatePriosIso(r)
 		rDead = this.calculatePriosIso(rDead)
 		sort.Slice(r, func(i, j int) bool { return r[i].GetPrio() < r[j].GetPrio() })
-		sort.Slice(rDead, func(i, j int) bool { return r[i].GetPrio() < r[j].GetPrio() })
+		sort.Slice(rDead, func(i, j int) bool { return rDead[i].GetPrio() < rDead[j].GetPrio() })
 		err := this.renderIso(modules, r, rDead)
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	// 自发光的对象不受光照影响
+	var emissive []common.Renderable
+	for _, ptr := range r {
+		if ptr.IsEmissive() {
+			emissive = append(emissive, ptr)
+		}
+	}
+
+	return this.renderLighting(modules, emissive)
 }
 
 func (this *MapRenderer) calculatePriosIso(r []common.Renderable) []common.Renderable {
@@ -159,18 +195,27 @@ func (this *MapRenderer) renderIso(modules common.Modules, r []common.Renderable
 
 	// 背景层
 	for index < (int)(this.indexObjectLayer) {
-		err := this.renderIsoLayer(modules, this.GetLayer(index), this.tset)
+		err := this.mapParallax.Render(modules, this.cam.shake, this.GetLayerName(index), true)
 		if err != nil {
 			return err
 		}
-		err = this.mapParallax.Render(this.cam.shake, this.GetLayerName(index))
+		err = this.renderIsoLayer(modules, this.GetLayer(index), this.tset)
+		if err != nil {
+			return err
+		}
+		err = this.mapParallax.Render(modules, this.cam.shake, this.GetLayerName(index), false)
 		if err != nil {
 			return err
 		}
 		index++
 	}
 
-	err := this.renderIsoBackObjects(modules, rDead)
+	err := this.mapParallax.Render(modules, this.cam.shake, this.GetLayerName(index), true)
+	if err != nil {
+		return err
+	}
+
+	err = this.renderIsoBackObjects(modules, rDead)
 	if err != nil {
 		return err
 	}
@@ -180,12 +225,21 @@ func (this *MapRenderer) renderIso(modules common.Modules, r []common.Renderable
 		return err
 	}
 
+	err = this.mapParallax.Render(modules, this.cam.shake, this.GetLayerName(index), false)
+	if err != nil {
+		return err
+	}
+
 	// 对象层
 	index++
 
 	// 战争迷雾
 	layers := this.Map.GetLayers()
 	for index < len(layers) {
+		err := this.mapParallax.Render(modules, this.cam.shake, this.GetLayerName(index), true)
+		if err != nil {
+			return err
+		}
 
 		if this.GetLayerName(index) != "fow_dark" && this.GetLayerName(index) != "fow_fog" {
 			err := this.renderIsoLayer(modules, this.GetLayer(index), this.tset)
@@ -194,7 +248,7 @@ func (this *MapRenderer) renderIso(modules common.Modules, r []common.Renderable
 			}
 		}
 
-		err := this.mapParallax.Render(this.cam.shake, this.GetLayerName(index))
+		err = this.mapParallax.Render(modules, this.cam.shake, this.GetLayerName(index), false)
 		if err != nil {
 			return err
 		}
@@ -309,6 +363,22 @@ func (this *MapRenderer) GetTeleportMapName() string {
 	return this.teleportMapName
 }
 
+func (this *MapRenderer) SetCutscene(val bool) {
+	this.cutscene = val
+}
+
+func (this *MapRenderer) GetCutscene() bool {
+	return this.cutscene
+}
+
+func (this *MapRenderer) SetCutsceneFile(val string) {
+	this.cutsceneFile = val
+}
+
+func (this *MapRenderer) GetCutsceneFile() string {
+	return this.cutsceneFile
+}
+
 func (this *MapRenderer) SetTeleportDestination(val fpoint.FPoint) {
 	this.teleportDestination = val
 }
@@ -318,9 +388,55 @@ func (this *MapRenderer) GetTeleportDestination() fpoint.FPoint {
 }
 
 func (this *MapRenderer) Load(modules common.Modules, loot gameres.LootManager, camp gameres.CampaignManager, event gameres.EventManager, gresf gameres.Factory, fname string) error {
+	tsetData, err := this.loadData(modules, loot, camp, event, gresf, fname)
+	if err != nil {
+		return err
+	}
+
+	return this.loadGraphics(modules, tsetData)
+}
 
+// 异步加载，地图和瓷砖文件的解析及图片解码在加载协程里，精灵在主线程里创建
+func (this *MapRenderer) LoadAsync(modules common.Modules, ldr common.Loader, loot gameres.LootManager, camp gameres.CampaignManager, event gameres.EventManager, gresf gameres.Factory, fname string) {
+	settings := modules.Settings()
+	mods := modules.Mods()
 	render := modules.Render()
 
+	var tsetData *tileSetData
+
+	ldr.Add(func() error {
+		var err error
+		tsetData, err = this.loadData(modules, loot, camp, event, gresf, fname)
+		if err != nil {
+			return err
+		}
+
+		if tsetData == nil {
+			return nil
+		}
+
+		// 每张瓷砖图片一个解码任务
+		for _, filename := range tsetData.imageFilenames {
+			if filename == "" {
+				continue
+			}
+
+			filename := filename
+			ldr.Add(func() error {
+				return render.PreloadImage(settings, mods, filename)
+			}, nil)
+		}
+
+		return nil
+	}, nil)
+
+	ldr.OnDone(func() error {
+		return this.loadGraphics(modules, tsetData)
+	})
+}
+
+// 不涉及渲染的部分，瓷砖文件没有变化时返回nil
+func (this *MapRenderer) loadData(modules common.Modules, loot gameres.LootManager, camp gameres.CampaignManager, event gameres.EventManager, gresf gameres.Factory, fname string) (*tileSetData, error) {
 	// TODO
 	// reset all
 
@@ -333,21 +449,43 @@ func (this *MapRenderer) Load(modules common.Modules, loot gameres.LootManager,
 	// 加载图层定义，怪物定义和地图触发事件等
 	err := this.Map.Load(modules, loot, camp, event, gresf, fname)
 	if err != nil {
-		panic(err)
-		return err
+		return nil, err
 	}
 
 	// TODO
 	// load music
 
+	this.lighting.loadMapLights(this.Map.GetEvents())
+
+	// 解析瓷砖，瓷砖文件没有变化时沿用当前的
+	var tsetData *tileSetData
+	tileCollision := this.tset.collision
+
+	if this.tset.currentFilename != this.Map.GetTileSet() {
+		tsetData, err = parseTileSet(modules, this.Map.GetTileSet())
+		if err != nil {
+			return nil, err
+		}
+		tileCollision = tsetData.collision
+	}
+
 	layers := this.Map.GetLayers()
 
+	// 地图没有碰撞图层时按瓷砖的默认碰撞值生成
+	if this.Map.GetCollisionGenerated() {
+		for i, _ := range layers {
+			if this.Map.GetLayerName(i) == "collision" {
+				generateCollision(&this.Map, layers[i], tileCollision)
+			}
+		}
+	}
+
 	// 拷贝并移除碰撞图层
 	for i, layer := range layers {
 		if this.Map.GetLayerName(i) == "collision" {
 			width := (uint16)(len(layer))
 			if width == 0 {
-				return fmt.Errorf("MapRenderer: Map width is 0. Can't set collision layer.")
+				return nil, fmt.Errorf("MapRenderer: Map width is 0. Can't set collision layer.")
 			}
 			height := (uint16)(len(layer[0]))
 			this.collider.SetMap(layer, width, height) // 拷贝
@@ -363,21 +501,88 @@ func (this *MapRenderer) Load(modules common.Modules, loot gameres.LootManager,
 	}
 
 	// TODO enemy group
+
+	return tsetData, nil
+}
+
+// 用各图层瓷砖的默认碰撞值填充碰撞图层，后面的图层覆盖前面的
+func generateCollision(m *base.Map, collision [][]uint16, tileCollision map[uint16]uint16) {
+	if len(tileCollision) == 0 {
+		return
+	}
+
+	layers := m.GetLayers()
+	for index, layer := range layers {
+		if m.GetLayerName(index) == "collision" {
+			continue
+		}
+
+		for i, _ := range layer {
+			for j, tile := range layer[i] {
+				if tile == 0 {
+					continue
+				}
+
+				if val, ok := tileCollision[tile]; ok {
+					collision[i][j] = val
+				}
+			}
+		}
+	}
+}
+
+func (this *MapRenderer) loadGraphics(modules common.Modules, tsetData *tileSetData) error {
+	render := modules.Render()
+
 	// 加载瓷砖
-	this.tset.Load(modules, this.Map.GetTileSet())
+	if tsetData != nil {
+		err := this.tset.apply(modules, tsetData)
+		if err != nil {
+			return err
+		}
+	}
 
 	// TODO
 	// fog of war
-	// parallax map
+
+	// 视差图层
+	err := this.SetMapParallax(modules, this.Map.GetParallaxFilename())
+	if err != nil {
+		return err
+	}
+
+	// 换图时清除粒子，天气跟随摄像头
+	this.particles.Clear()
+	if this.Map.GetWeatherFilename() != "" {
+		_, err := this.particles.AttachEmitter(modules, this.Map.GetWeatherFilename(), func() (fpoint.FPoint, bool) {
+			return this.cam.pos, true
+		})
+		if err != nil {
+			return err
+		}
+	}
 
 	render.SetBackgroundColor(this.Map.GetBackgroundColor())
 
 	return nil
 }
 
+// 切换视差图层文件，文件名为空时清除
+func (this *MapRenderer) SetMapParallax(modules common.Modules, filename string) error {
+	err := this.mapParallax.Load(modules, filename)
+	if err != nil {
+		return err
+	}
+
+	this.mapParallax.SetMapCenter((int)(this.Map.GetW())/2, (int)(this.Map.GetH())/2)
+
+	return nil
+}
+
 func (this *MapRenderer) Logic(modules common.Modules) {
 	this.tset.Logic()
 	this.cam.Logic(modules)
+	this.particles.Logic()
 }
 
 func (this *MapRenderer) ExecuteOnLoadEvent(modules common.Modules, eventManager gameres.EventManager, camp gameres.CampaignManager) {
@@ -752,6 +957,15 @@ func (this *MapRenderer) GetCollider() gameres.MapCollision {
 	return this.collider
 }
 
+// 本帧的动态光源，比如角色、危险区域，渲染后清空
+func (this *MapRenderer) AddLight(l light.Light) {
+	this.lighting.addLight(l)
+}
+
+func (this *MapRenderer) GetParticles() gameres.ParticleManager {
+	return this.particles
+}
+
 func (this *MapRenderer) GetCam() gameres.MapCamera {
 	return this.cam
 }
@@ -0,0 +1,145 @@
+package maprenderer
+
+import (
+	"math"
+	"monster/pkg/common"
+	"monster/pkg/common/point"
+	"monster/pkg/utils"
+)
+
+// 正交地图，先按行再按列排序
+func (this *MapRenderer) calculatePriosOrtho(r []common.Renderable) []common.Renderable {
+	for _, ptr := range r {
+		tileX := math.Floor((float64)(ptr.GetMapPos().X))
+		tileY := math.Floor((float64)(ptr.GetMapPos().Y))
+
+		commay := (int)((ptr.GetMapPos().Y - (float32)(tileY)) * (1 << 10))
+		oldPrio := ptr.GetPrio()
+		oldPrio += ((uint64)(tileY) << 37) + ((uint64)(tileX) << 20) + ((uint64)(commay) << 8)
+		ptr.SetPrio(oldPrio)
+	}
+
+	return r
+}
+
+func (this *MapRenderer) renderOrtho(modules common.Modules, r []common.Renderable, rDead []common.Renderable) error {
+	index := 0
+
+	// 背景层
+	for index < (int)(this.indexObjectLayer) {
+		err := this.mapParallax.Render(modules, this.cam.shake, this.GetLayerName(index), true)
+		if err != nil {
+			return err
+		}
+		err = this.renderOrthoLayer(modules, this.GetLayer(index), this.tset)
+		if err != nil {
+			return err
+		}
+		err = this.mapParallax.Render(modules, this.cam.shake, this.GetLayerName(index), false)
+		if err != nil {
+			return err
+		}
+		index++
+	}
+
+	err := this.mapParallax.Render(modules, this.cam.shake, this.GetLayerName(index), true)
+	if err != nil {
+		return err
+	}
+
+	// 对象层，先画尸体等死亡对象
+	for i, _ := range rDead {
+		err := this.drawRenderable(modules, rDead, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	layers := this.Map.GetLayers()
+	if index < len(layers) {
+		err := this.renderOrthoLayer(modules, this.GetLayer(index), this.tset)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, _ := range r {
+		err := this.drawRenderable(modules, r, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = this.mapParallax.Render(modules, this.cam.shake, this.GetLayerName(index), false)
+	if err != nil {
+		return err
+	}
+
+	index++
+
+	for index < len(layers) {
+		err := this.mapParallax.Render(modules, this.cam.shake, this.GetLayerName(index), true)
+		if err != nil {
+			return err
+		}
+
+		if this.GetLayerName(index) != "fow_dark" && this.GetLayerName(index) != "fow_fog" {
+			err := this.renderOrthoLayer(modules, this.GetLayer(index), this.tset)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = this.mapParallax.Render(modules, this.cam.shake, this.GetLayerName(index), false)
+		if err != nil {
+			return err
+		}
+
+		index++
+	}
+
+	return nil
+}
+
+func (this *MapRenderer) renderOrthoLayer(modules common.Modules, layerData [][]uint16, tileSet *TileSet) error {
+	eset := modules.Eset()
+	settings := modules.Settings()
+	render := modules.Render()
+
+	upperLeft := utils.ScreenToMap(settings, eset, 0, 0, this.cam.shake.X, this.cam.shake.Y)
+	tileSize := eset.Get("tileset", "tile_size").([]int)
+
+	// 可见范围，向外多扩展最大瓷砖尺寸
+	startI := (int)(math.Max((float64)(math.Floor((float64)(upperLeft.X)))-(float64)(tileSet.maxSizeX)+1, 0))
+	startJ := (int)(math.Max((float64)(math.Floor((float64)(upperLeft.Y)))-(float64)(tileSet.maxSizeY), 0))
+	endI := (int)(math.Min((float64)(startI+settings.GetViewW()/tileSize[0]+2*tileSet.maxSizeX), (float64)(this.GetW())))
+	endJ := (int)(math.Min((float64)(startJ+settings.GetViewH()/tileSize[1]+2*tileSet.maxSizeY), (float64)(this.GetH())))
+
+	dest := point.Construct()
+
+	for j := startJ; j < endJ; j++ {
+		p := utils.MapToScreen(settings, eset, (float32)(startI), (float32)(j), this.cam.shake.X, this.cam.shake.Y)
+		p = this.centerTile(eset, p)
+
+		for i := startI; i < endI; i++ {
+			currentTile := layerData[i][j]
+
+			if currentTile != 0 && (int)(currentTile) < len(tileSet.tiles) && tileSet.tiles[currentTile].tile != nil {
+				tile := tileSet.tiles[currentTile]
+
+				dest.X = p.X - tile.offset.X
+				dest.Y = p.Y - tile.offset.Y
+
+				tile.tile.SetDestFromPoint(dest)
+				err := render.Render(tile.tile)
+				if err != nil {
+					return err
+				}
+			}
+
+			p.X += tileSize[0]
+		}
+	}
+
+	return nil
+}
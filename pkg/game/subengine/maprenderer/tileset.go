@@ -1,7 +1,6 @@
 package maprenderer
 
 import (
-	"fmt"
 	"math"
 	"monster/pkg/common"
 	"monster/pkg/common/point"
@@ -39,12 +38,13 @@ type TileSet struct {
 	currentFilename string
 	sprites         []common.Sprite
 	anim            []TileAnim
-	tiles           []TileDef // 瓷砖的定义
-	maxSizeX        int       // 比例：多少个eset定义大小的瓷砖
+	tiles           []TileDef         // 瓷砖的定义
+	collision       map[uint16]uint16 // 瓷砖默认的碰撞值
+	maxSizeX        int               // 比例：多少个eset定义大小的瓷砖
 	maxSizeY        int
 }
 
-func newTileSet() *TileSet {
+func NewTileSet() *TileSet {
 	ts := &TileSet{}
 	ts.init()
 
@@ -72,6 +72,8 @@ func (this *TileSet) Reset() {
 
 	this.tiles = nil
 	this.anim = nil
+	this.collision = nil
+	this.currentFilename = ""
 
 	this.maxSizeX = 0
 	this.maxSizeY = 0
@@ -93,7 +95,7 @@ func (this *TileSet) loadGraphics(modules common.Modules, filenames []string) er
 			continue
 		}
 
-		graphics, err := render.LoadImage(settings, mods, filename)
+		graphics, err := render.LoadAtlasImage(settings, mods, filename)
 		if err != nil {
 			return err
 		}
@@ -111,54 +113,58 @@ func (this *TileSet) loadGraphics(modules common.Modules, filenames []string) er
 	return nil
 }
 
-func (this *TileSet) Load(modules common.Modules, filename string) error {
+// 瓷砖文件的解析结果，不涉及渲染，可以在加载协程里生成
+type tileSetData struct {
+	filename       string
+	imageFilenames []string // 瓷砖图片文件
+	tileImages     []int    // 瓷砖定义对应的图片文件
+	tileClips      []rect.Rect
+	tileOffsets    []point.Point
+	anim           []TileAnim
+	collision      map[uint16]uint16
+}
+
+func parseTileSet(modules common.Modules, filename string) (*tileSetData, error) {
 	mods := modules.Mods()
-	eset := modules.Eset()
+	settings := modules.Settings()
 
-	if this.currentFilename == filename {
-		return nil
-	}
+	maxFPS := settings.Get("max_fps").(int)
 
-	this.Reset()
 	infile := fileparser.New()
 
 	err := infile.Open(filename, true, mods)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer infile.Close()
 
-	var imageFilenames []string // 瓷砖图片文件
-	var tileImages []int        // 瓷砖定义对应的图片文件
-	var tileClips []rect.Rect
-	var tileOffsets []point.Point
+	data := &tileSetData{filename: filename, collision: map[uint16]uint16{}}
+
 	var index int
 	for infile.Next(mods) {
 		key := infile.Key()
 		val := infile.Val()
 
 		if (infile.IsNewSection() && infile.GetSection() == "tileset") ||
-			(len(this.sprites) == 0 && infile.GetSection() == "") {
-			imageFilenames = append(imageFilenames, "")
-			this.sprites = append(this.sprites, nil)
+			(len(data.imageFilenames) == 0 && infile.GetSection() == "") {
+			data.imageFilenames = append(data.imageFilenames, "")
 		}
 
 		switch key {
 		case "img":
 			// 对应的图片
-			imageFilenames[len(imageFilenames)-1] = val
+			data.imageFilenames[len(data.imageFilenames)-1] = val
 		case "tile":
 			// 瓷砖的定义
 			index, val = parsing.PopFirstInt(val, "")
 
-			if index >= len(this.tiles) {
-				add := index + 1 - len(this.tiles)
+			if index >= len(data.tileImages) {
+				add := index + 1 - len(data.tileImages)
 
 				for i := 0; i < add; i++ {
-					this.tiles = append(this.tiles, constructTileDef())
-					tileImages = append(tileImages, 0)
-					tileClips = append(tileClips, rect.Construct())
-					tileOffsets = append(tileOffsets, point.Construct())
+					data.tileImages = append(data.tileImages, 0)
+					data.tileClips = append(data.tileClips, rect.Construct())
+					data.tileOffsets = append(data.tileOffsets, point.Construct())
 				}
 			}
 
@@ -172,38 +178,85 @@ func (this *TileSet) Load(modules common.Modules, filename string) error {
 			offset.X, val = parsing.PopFirstInt(val, "")
 			offset.Y, val = parsing.PopFirstInt(val, "")
 
-			tileImages[index] = len(imageFilenames) - 1
-			tileClips[index] = clip
-			tileOffsets[index] = offset
+			data.tileImages[index] = len(data.imageFilenames) - 1
+			data.tileClips[index] = clip
+			data.tileOffsets[index] = offset
 		case "animation":
 			var frame uint16
 			index, val = parsing.PopFirstInt(val, "")
 
-			if index >= len(this.anim) {
-				add := index + 1 - len(this.anim)
+			if index >= len(data.anim) {
+				add := index + 1 - len(data.anim)
 				for i := 0; i < add; i++ {
-					this.anim = append(this.anim, constructTileAnim())
+					data.anim = append(data.anim, constructTileAnim())
 				}
 			}
+			// 重新定义时覆盖之前的帧
+			data.anim[index] = constructTileAnim()
+
 			var repeatVal, strDuration string
 			repeatVal, val = parsing.PopFirstString(val, "")
 			for repeatVal != "" {
-				this.anim[index].frames++
-				this.anim[index].pos = append(this.anim[index].pos, point.Construct())
-				this.anim[index].frameDuration = append(this.anim[index].frameDuration, 0)
-				this.anim[index].pos[frame].X = parsing.ToInt(repeatVal, 0)
-				this.anim[index].pos[frame].Y, val = parsing.PopFirstInt(val, "")
+				data.anim[index].frames++
+				data.anim[index].pos = append(data.anim[index].pos, point.Construct())
+				data.anim[index].frameDuration = append(data.anim[index].frameDuration, 0)
+				data.anim[index].pos[frame].X = parsing.ToInt(repeatVal, 0)
+				data.anim[index].pos[frame].Y, val = parsing.PopFirstInt(val, "")
 				strDuration, val = parsing.PopFirstString(val, "")
-				this.anim[index].frameDuration[frame] = (uint16)(parsing.ToDuration(strDuration, 0))
+				data.anim[index].frameDuration[frame] = (uint16)(parsing.ToDuration(strDuration, maxFPS))
 				frame++
 				repeatVal, val = parsing.PopFirstString(val, "")
 			}
+		case "collision":
+			// collision=瓷砖序号,碰撞值
+			index, val = parsing.PopFirstInt(val, "")
+			var collision int
+			collision, val = parsing.PopFirstInt(val, "")
+			if index <= 0 || index > math.MaxUint16 {
+				if err := infile.Reportf("TileSet: Tile index %d is out of range.", index); err != nil {
+					return nil, err
+				}
+				break
+			}
+			data.collision[(uint16)(index)] = (uint16)(collision)
 		default:
-			return fmt.Errorf("TileSet: '%s' is not a valid key.\n", key)
+			if err := infile.Reportf("TileSet: '%s' is not a valid key.", key); err != nil {
+				return nil, err
+			}
 		}
 	}
 
-	err = this.loadGraphics(modules, imageFilenames)
+	return data, nil
+}
+
+func (this *TileSet) Load(modules common.Modules, filename string) error {
+	if this.currentFilename == filename {
+		return nil
+	}
+
+	data, err := parseTileSet(modules, filename)
+	if err != nil {
+		return err
+	}
+
+	return this.apply(modules, data)
+}
+
+// 主线程里创建精灵，预先解码的图片在这里上传
+func (this *TileSet) apply(modules common.Modules, data *tileSetData) error {
+	eset := modules.Eset()
+
+	this.Reset()
+
+	this.sprites = make([]common.Sprite, len(data.imageFilenames))
+	this.anim = data.anim
+	this.collision = data.collision
+	this.tiles = make([]TileDef, len(data.tileImages))
+	for i, _ := range this.tiles {
+		this.tiles[i] = constructTileDef()
+	}
+
+	err := this.loadGraphics(modules, data.imageFilenames)
 	if err != nil {
 		return err
 	}
@@ -214,7 +267,7 @@ func (this *TileSet) Load(modules common.Modules, filename string) error {
 	// 每个瓷砖的定义
 	for i, _ := range this.tiles {
 		// 瓷砖定义对应的精灵
-		ptr := this.sprites[tileImages[i]]
+		ptr := this.sprites[data.tileImages[i]]
 		if ptr == nil {
 			continue
 		}
@@ -225,14 +278,29 @@ func (this *TileSet) Load(modules common.Modules, filename string) error {
 			return err
 		}
 
-		this.tiles[i].tile.SetClipFromRect(tileClips[i])
-		this.tiles[i].offset = tileOffsets[i]
+		this.tiles[i].tile.SetClipFromRect(data.tileClips[i])
+		this.tiles[i].offset = data.tileOffsets[i]
 
 		this.maxSizeX = (int)(math.Max(float64(this.maxSizeX), float64(this.tiles[i].tile.GetClip().W/tileW)+1))
 		this.maxSizeY = (int)(math.Max(float64(this.maxSizeY), float64(this.tiles[i].tile.GetClip().H/tileH)+1))
 	}
 
-	this.currentFilename = filename
+	// 动画瓷砖从第一帧开始
+	for i, _ := range this.anim {
+		this.anim[i].currentFrame = 0
+		this.anim[i].duration = 0
+
+		if this.anim[i].frames == 0 || i >= len(this.tiles) || this.tiles[i].tile == nil {
+			continue
+		}
+
+		clip := this.tiles[i].tile.GetClip()
+		clip.X = this.anim[i].pos[0].X
+		clip.Y = this.anim[i].pos[0].Y
+		this.tiles[i].tile.SetClipFromRect(clip)
+	}
+
+	this.currentFilename = data.filename
 
 	return nil
 }
@@ -245,21 +313,22 @@ func (this *TileSet) Logic() {
 	for i, _ := range this.anim {
 		an := &(this.anim[i])
 
-		if an.frames == 0 {
+		if an.frames == 0 || i >= len(this.tiles) || this.tiles[i].tile == nil {
 			// 静态
 			continue
 		}
 
-		if this.tiles[i].tile != nil && an.duration >= an.frameDuration[an.currentFrame] {
+		an.duration++
+
+		if an.duration >= an.frameDuration[an.currentFrame] {
 			// play下一帧
+			an.duration = 0
+			an.currentFrame = (an.currentFrame + 1) % an.frames // 反复
+
 			clip := this.tiles[i].tile.GetClip()
 			clip.X = an.pos[an.currentFrame].X
 			clip.Y = an.pos[an.currentFrame].Y
 			this.tiles[i].tile.SetClipFromRect(clip)
-			an.duration = 0
-			an.currentFrame = (an.currentFrame + 1) % an.frames // 反复
 		}
-
-		an.duration++
 	}
 }
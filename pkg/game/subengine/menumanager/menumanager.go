@@ -5,10 +5,15 @@ import (
 	"monster/pkg/common/define/game/menu/statbar"
 	"monster/pkg/common/define/game/stats"
 	"monster/pkg/common/gameres"
+	"monster/pkg/filesystem/fileparser"
+	"monster/pkg/filesystem/logfile"
+	"monster/pkg/utils"
+	"monster/pkg/utils/parsing"
 )
 
 type MenuManager struct {
-	menus map[string]gameres.Menu
+	menus   map[string]gameres.Menu
+	layouts []string // menus/layouts.txt里加载的菜单
 }
 
 func New(modules common.Modules, pc gameres.Avatar, powers gameres.PowerManager, menuf gameres.Factory) *MenuManager {
@@ -27,10 +32,91 @@ func (this *MenuManager) init(modules common.Modules, pc gameres.Avatar, powers
 	this.menus["xp"] = menuf.New("statbar").(gameres.MenuStatBar).Init(modules, statbar.TYPE_XP)
 	this.menus["exit"] = menuf.New("exit").(gameres.MenuExit).Init(modules, pc)
 	this.menus["act"] = menuf.New("actionbar").(gameres.MenuActionBar).Init(modules, powers)
+	this.menus["act"].(gameres.MenuActionBar).SetInventory(this.menus["inv"].(gameres.MenuInventory))
+
+	err := this.loadLayouts(modules, menuf)
+	if err != nil {
+		logfile.LogError("MenuManager: %s", err)
+	}
 
 	return this
 }
 
+/*
+ * mod添加的菜单，完全由菜单文件描述
+ *
+ * [menu]
+ * id=quests
+ * file=menus/quests.txt
+ * visible=false
+ */
+func (this *MenuManager) loadLayouts(modules common.Modules, menuf gameres.Factory) error {
+	mods := modules.Mods()
+
+	infile := fileparser.New()
+	err := infile.Open("menus/layouts.txt", true, mods)
+	if err != nil {
+		if utils.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer infile.Close()
+
+	type layoutDef struct {
+		id      string
+		file    string
+		visible bool
+	}
+
+	var defs []*layoutDef
+	for infile.Next(mods) {
+		if infile.IsNewSection() && infile.GetSection() == "menu" {
+			defs = append(defs, &layoutDef{})
+		}
+
+		if len(defs) == 0 {
+			continue
+		}
+
+		def := defs[len(defs)-1]
+		switch infile.Key() {
+		case "id":
+			def.id = infile.Val()
+		case "file":
+			def.file = infile.Val()
+		case "visible":
+			def.visible = parsing.ToBool(infile.Val())
+		default:
+			logfile.LogError("MenuManager: %s", infile.Errorf("'%s' is not a valid key.", infile.Key()))
+		}
+	}
+
+	for _, def := range defs {
+		if def.id == "" || def.file == "" {
+			logfile.LogError("MenuManager: layout menu needs both id and file.")
+			continue
+		}
+
+		if _, ok := this.menus[def.id]; ok {
+			logfile.LogError("MenuManager: menu '%s' already exists.", def.id)
+			continue
+		}
+
+		ptr, err := menuf.New("layout").(gameres.MenuLayout).Init(modules, def.file)
+		if err != nil {
+			logfile.LogError("MenuManager: %s", err)
+			continue
+		}
+
+		ptr.SetVisible(def.visible)
+		this.menus[def.id] = ptr
+		this.layouts = append(this.layouts, def.id)
+	}
+
+	return nil
+}
+
 func (this *MenuManager) Close() {
 	for _, ptr := range this.menus {
 		ptr.Close()
@@ -53,7 +139,6 @@ func (this *MenuManager) Render(modules common.Modules) error {
 	for _, ptr := range this.menus {
 		err := ptr.Render(modules)
 		if err != nil {
-			panic(err)
 			return err
 		}
 	}
@@ -79,6 +164,20 @@ func (this *MenuManager) Logic(modules common.Modules, pc gameres.Avatar, powers
 	}
 
 	this.menus["act"].Logic(modules, pc, powers)
+	this.menus["inv"].Logic(modules, pc, powers)
+
+	for _, id := range this.layouts {
+		err := this.menus[id].Logic(modules, pc, powers)
+		if err != nil {
+			logfile.LogError("MenuManager: %s", err)
+		}
+	}
+
+	// 技能消耗的道具从背包扣除
+	for _, id := range powers.GetUsedItems() {
+		this.menus["inv"].(gameres.MenuInventory).RemoveItem(id, 1)
+	}
+	powers.ClearUsedItems()
 }
 
 func (this *MenuManager) MenuAct() gameres.MenuActionBar {
@@ -0,0 +1,374 @@
+package particlemanager
+
+import (
+	"fmt"
+	"monster/pkg/common"
+	"monster/pkg/common/define/renderable"
+	"monster/pkg/common/fpoint"
+	"monster/pkg/common/gameres"
+	"monster/pkg/common/point"
+	"monster/pkg/common/rect"
+	"monster/pkg/filesystem/fileparser"
+	"monster/pkg/subengine/particle"
+	"monster/pkg/utils/parsing"
+)
+
+// 发射器文件的定义和对应的图片
+type emitterDef struct {
+	def      particle.Def
+	image    common.Image
+	frameW   int
+	frameH   int
+	offset   point.Point
+	emissive bool // 不受光照图影响，默认加法混合的发射器自发光
+}
+
+type emitterInst struct {
+	id      int
+	def     *emitterDef
+	emitter *particle.Emitter
+	follow  func() (fpoint.FPoint, bool) // 跟随的目标，返回false表示目标已消失
+}
+
+type ParticleManager struct {
+	defs     map[string]*emitterDef // 按文件名缓存
+	emitters []*emitterInst
+	nextId   int
+	pool     []common.Renderable // 每帧复用，上一帧的绘制列表已经用完
+}
+
+func New() *ParticleManager {
+	pm := &ParticleManager{}
+	pm.init()
+
+	return pm
+}
+
+func (this *ParticleManager) init() gameres.ParticleManager {
+	this.defs = map[string]*emitterDef{}
+	this.nextId = 1
+
+	return this
+}
+
+// 移除全部发射器，定义保留到Close
+func (this *ParticleManager) Clear() {
+	this.emitters = nil
+	this.pool = nil
+}
+
+func (this *ParticleManager) Close() {
+	this.Clear()
+
+	for _, def := range this.defs {
+		if def.image != nil {
+			def.image.UnRef()
+		}
+	}
+
+	this.defs = map[string]*emitterDef{}
+}
+
+func (this *ParticleManager) loadDef(modules common.Modules, filename string) (*emitterDef, error) {
+	if def, ok := this.defs[filename]; ok {
+		return def, nil
+	}
+
+	settings := modules.Settings()
+	mods := modules.Mods()
+	render := modules.Render()
+
+	maxFPS := (float32)(settings.Get("max_fps").(int))
+
+	infile := fileparser.New()
+
+	err := infile.Open(filename, true, mods)
+	if err != nil {
+		return nil, err
+	}
+	defer infile.Close()
+
+	def := &emitterDef{def: particle.ConstructDef()}
+	hasOffset := false
+	hasEmissive := false
+
+	// 出错时释放已加载的图片
+	loaded := false
+	defer func() {
+		if !loaded && def.image != nil {
+			def.image.UnRef()
+		}
+	}()
+
+	for infile.Next(mods) {
+		key := infile.Key()
+		val := infile.Val()
+
+		switch key {
+		case "image":
+			if def.image != nil {
+				def.image.UnRef()
+			}
+
+			def.image, err = render.LoadAtlasImage(settings, mods, val)
+			if err != nil {
+				return nil, err
+			}
+		case "frame_size":
+			def.frameW, val = parsing.PopFirstInt(val, "")
+			def.frameH, val = parsing.PopFirstInt(val, "")
+		case "frames":
+			def.def.Frames = parsing.ToInt(val, 1)
+			if def.def.Frames < 1 {
+				def.def.Frames = 1
+			}
+		case "frame_duration":
+			def.def.FrameDuration = parsing.ToDuration(val, (int)(maxFPS))
+		case "loop":
+			def.def.Loop = parsing.ToBool(val)
+		case "render_offset":
+			def.offset.X, val = parsing.PopFirstInt(val, "")
+			def.offset.Y, val = parsing.PopFirstInt(val, "")
+			hasOffset = true
+		case "spawn_rate":
+			// 每秒发射数
+			def.def.SpawnRate = parsing.ToFloat(val, 0) / maxFPS
+		case "spawn_area":
+			def.def.SpawnArea.X, val = popFirstFloat(val)
+			def.def.SpawnArea.Y, val = popFirstFloat(val)
+		case "max_particles":
+			def.def.MaxParticles = parsing.ToInt(val, 0)
+		case "lifetime":
+			var strMin, strMax string
+			strMin, val = parsing.PopFirstString(val, "")
+			strMax, val = parsing.PopFirstString(val, "")
+			if strMax == "" {
+				strMax = strMin
+			}
+			def.def.Lifetime.Min = (float32)(parsing.ToDuration(strMin, (int)(maxFPS)))
+			def.def.Lifetime.Max = (float32)(parsing.ToDuration(strMax, (int)(maxFPS)))
+		case "velocity":
+			// 每秒移动的地图单位 min_x,max_x,min_y,max_y
+			var v float32
+			v, val = popFirstFloat(val)
+			def.def.VelocityX.Min = v / maxFPS
+			v, val = popFirstFloat(val)
+			def.def.VelocityX.Max = v / maxFPS
+			v, val = popFirstFloat(val)
+			def.def.VelocityY.Min = v / maxFPS
+			v, val = popFirstFloat(val)
+			def.def.VelocityY.Max = v / maxFPS
+		case "gravity":
+			var v float32
+			v, val = popFirstFloat(val)
+			def.def.Gravity.X = v / maxFPS / maxFPS
+			v, val = popFirstFloat(val)
+			def.def.Gravity.Y = v / maxFPS / maxFPS
+		case "color":
+			// 开始和结束的颜色 r,g,b,r,g,b
+			def.def.ColorStart.R, val = popFirstUint8(val)
+			def.def.ColorStart.G, val = popFirstUint8(val)
+			def.def.ColorStart.B, val = popFirstUint8(val)
+			def.def.ColorEnd = def.def.ColorStart
+			if val != "" {
+				def.def.ColorEnd.R, val = popFirstUint8(val)
+				def.def.ColorEnd.G, val = popFirstUint8(val)
+				def.def.ColorEnd.B, val = popFirstUint8(val)
+			}
+		case "alpha":
+			def.def.AlphaStart, val = popFirstUint8(val)
+			def.def.AlphaEnd = def.def.AlphaStart
+			if val != "" {
+				def.def.AlphaEnd, val = popFirstUint8(val)
+			}
+		case "blend_mode":
+			switch val {
+			case "normal":
+				def.def.BlendMode = renderable.BLEND_NORMAL
+			case "add":
+				def.def.BlendMode = renderable.BLEND_ADD
+			default:
+				if err := infile.Reportf("ParticleManager: '%s' is not a valid blend mode.", val); err != nil {
+					return nil, err
+				}
+			}
+		case "emissive":
+			def.emissive = parsing.ToBool(val)
+			hasEmissive = true
+		default:
+			if err := infile.Reportf("ParticleManager: '%s' is not a valid key.", key); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if def.image == nil {
+		return nil, fmt.Errorf("ParticleManager: No image defined in '%s'.", filename)
+	}
+
+	// 没有指定帧大小时整张图片为一帧
+	if def.frameW <= 0 || def.frameH <= 0 {
+		def.frameW, err = def.image.GetWidth()
+		if err != nil {
+			return nil, err
+		}
+		def.frameH, err = def.image.GetHeight()
+		if err != nil {
+			return nil, err
+		}
+		if def.def.Frames > 1 {
+			def.frameW /= def.def.Frames
+		}
+	}
+
+	if !hasEmissive {
+		def.emissive = def.def.BlendMode == renderable.BLEND_ADD
+	}
+
+	// 默认以帧的中心作为粒子位置
+	if !hasOffset {
+		def.offset = point.Construct(def.frameW/2, def.frameH/2)
+	}
+
+	this.defs[filename] = def
+	loaded = true
+
+	return def, nil
+}
+
+func (this *ParticleManager) add(modules common.Modules, filename string, pos fpoint.FPoint, follow func() (fpoint.FPoint, bool)) (int, error) {
+	def, err := this.loadDef(modules, filename)
+	if err != nil {
+		return 0, err
+	}
+
+	inst := &emitterInst{
+		id:      this.nextId,
+		def:     def,
+		emitter: particle.NewEmitter(&def.def, (int64)(this.nextId)),
+		follow:  follow,
+	}
+	inst.emitter.SetPos(pos)
+
+	this.nextId++
+	this.emitters = append(this.emitters, inst)
+
+	return inst.id, nil
+}
+
+// 在地图的固定位置添加发射器
+func (this *ParticleManager) AddEmitter(modules common.Modules, filename string, pos fpoint.FPoint) (int, error) {
+	return this.add(modules, filename, pos, nil)
+}
+
+// 发射器跟随实体、危险区域或摄像头，目标消失后停止发射
+func (this *ParticleManager) AttachEmitter(modules common.Modules, filename string, follow func() (fpoint.FPoint, bool)) (int, error) {
+	pos, _ := follow()
+	return this.add(modules, filename, pos, follow)
+}
+
+// 停止发射，已有的粒子自然消失
+func (this *ParticleManager) StopEmitter(id int) {
+	for _, inst := range this.emitters {
+		if inst.id == id {
+			inst.emitter.Stop()
+		}
+	}
+}
+
+// 立即移除发射器和它的粒子
+func (this *ParticleManager) RemoveEmitter(id int) {
+	for i, inst := range this.emitters {
+		if inst.id == id {
+			this.emitters = append(this.emitters[:i], this.emitters[i+1:]...)
+			return
+		}
+	}
+}
+
+// 发射器还在，换图时会被全部清除
+func (this *ParticleManager) HasEmitter(id int) bool {
+	for _, inst := range this.emitters {
+		if inst.id == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (this *ParticleManager) Logic() {
+	alive := this.emitters[:0]
+
+	for _, inst := range this.emitters {
+		if inst.follow != nil {
+			pos, ok := inst.follow()
+			if ok {
+				inst.emitter.SetPos(pos)
+			} else {
+				inst.follow = nil
+				inst.emitter.Stop()
+			}
+		}
+
+		inst.emitter.Logic()
+
+		if !inst.emitter.IsDone() {
+			alive = append(alive, inst)
+		}
+	}
+
+	this.emitters = alive
+}
+
+// 每个粒子一个Renderable，由地图渲染器统一排序
+func (this *ParticleManager) AddRenders(modules common.Modules, r []common.Renderable) []common.Renderable {
+	mresf := modules.Resf()
+
+	used := 0
+	for _, inst := range this.emitters {
+		def := inst.def
+		particles := inst.emitter.GetParticles()
+
+		for i, _ := range particles {
+			p := &(particles[i])
+
+			c, alpha := inst.emitter.ColorOf(p)
+
+			if used == len(this.pool) {
+				this.pool = append(this.pool, mresf.New("renderable").(common.Renderable))
+			}
+			ren := this.pool[used]
+			used++
+
+			ren.SetPrio(0)
+			ren.SetImage(def.image)
+			ren.SetSrc(rect.Construct(inst.emitter.FrameOf(p)*def.frameW, 0, def.frameW, def.frameH))
+			ren.SetOffset(def.offset)
+			ren.SetMapPos(p.Pos)
+			ren.SetBlendMode(def.def.BlendMode)
+			ren.SetColorMod(c)
+			ren.SetAlphaMod(alpha)
+			ren.SetEmissive(def.emissive)
+			r = append(r, ren)
+		}
+	}
+
+	return r
+}
+
+func popFirstFloat(s string) (float32, string) {
+	first, s := parsing.PopFirstString(s, "")
+	return parsing.ToFloat(first, 0), s
+}
+
+func popFirstUint8(s string) (uint8, string) {
+	first, s := parsing.PopFirstInt(s, "")
+	if first < 0 {
+		first = 0
+	} else if first > 255 {
+		first = 255
+	}
+
+	return (uint8)(first), s
+}
@@ -32,29 +32,28 @@ type PowerManager struct {
 	usedEquippedItems []define.ItemId // 技能已经消耗掉的已经装备的道具
 }
 
-func New(modules common.Modules, ss gameres.Stats) *PowerManager {
+func New(modules common.Modules, ss gameres.Stats) (*PowerManager, error) {
 	pm := &PowerManager{}
 
-	pm.init(modules, ss)
+	err := pm.init(modules, ss)
+	if err != nil {
+		pm.Close()
+		return nil, err
+	}
 
-	return pm
+	return pm, nil
 }
 
-func (this *PowerManager) init(modules common.Modules, ss gameres.Stats) gameres.PowerManager {
+func (this *PowerManager) init(modules common.Modules, ss gameres.Stats) error {
 	this.powerAnimations = map[define.PowerId]common.Animation{}
 	this.powers = map[define.PowerId]*power.Power{}
 
 	err := this.loadEffects(modules, ss)
 	if err != nil {
-		panic(err)
-	}
-
-	err = this.loadPowers(modules, ss)
-	if err != nil {
-		panic(err)
+		return err
 	}
 
-	return this
+	return this.loadPowers(modules, ss)
 }
 
 func (this *PowerManager) clear() {
@@ -117,9 +116,17 @@ func (this *PowerManager) loadEffects(modules common.Modules, ss gameres.Stats)
 			anim.IncreaseCount(val)
 			a, err := anim.GetAnimationSet(settings, mods, render, mresf, val)
 			if err != nil {
-				return err
+				if err := infile.Report(err); err != nil {
+					return err
+				}
+				break
 			}
 			this.effectAnimations[len(this.effectAnimations)-1] = a.GetAnimation("")
+		case "particles":
+			ptr.Particles = val
+		case "light":
+			// radius, r, g, b, flicker
+			ptr.Light = parsing.ToLight(val)
 		case "can_stack":
 			ptr.CanStack = parsing.ToBool(val)
 		case "max_stacks":
@@ -135,7 +142,9 @@ func (this *PowerManager) loadEffects(modules common.Modules, ss gameres.Stats)
 		case "attack_speed_anim":
 			ptr.AttackSpeedAnim = val
 		default:
-			return fmt.Errorf("PowerManager: '%s' is not a valid key.\n", key)
+			if err := infile.Reportf("PowerManager: '%s' is not a valid key.", key); err != nil {
+				return err
+			}
 		}
 
 	}
@@ -177,13 +186,19 @@ func (this *PowerManager) loadPowers(modules common.Modules, ss gameres.Stats) e
 			this.powers[inputId] = power.New(modules)
 			clearPostEffects = true
 			this.powers[inputId].IsEmpty = false
+
+			if inputId < 1 {
+				if err := infile.Reportf("PowerManager: Power index out of bounds 1-%d, skipping power.", math.MaxInt); err != nil {
+					return err
+				}
+			}
 			continue
 		} else {
 			idLine = false
 		}
 
 		if inputId < 1 {
-			return fmt.Errorf("PowerManager: Power index out of bounds 1-%d, skipping power.\n", math.MaxInt)
+			continue
 		}
 
 		if idLine {
@@ -207,7 +222,9 @@ func (this *PowerManager) loadPowers(modules common.Modules, ss gameres.Stats) e
 			} else if val == "block" {
 				this.powers[inputId].Type = power.TYPE_BLOCK
 			} else {
-				return fmt.Errorf("PowerManager: Unknown type '%s'\n", val)
+				if err := infile.Reportf("PowerManager: Unknown type '%s'", val); err != nil {
+					return err
+				}
 			}
 		case "name":
 			this.powers[inputId].Name = msg.Get(val)
@@ -237,7 +254,9 @@ func (this *PowerManager) loadPowers(modules common.Modules, ss gameres.Stats) e
 			} else if val == "enemy" {
 				this.powers[inputId].SourceType = power.SOURCE_TYPE_ENEMY
 			} else {
-				return fmt.Errorf("PowerManager: Unknown source_type '%s'\n", val)
+				if err := infile.Reportf("PowerManager: Unknown source_type '%s'", val); err != nil {
+					return err
+				}
 			}
 		case "beacon":
 			this.powers[inputId].Beacon = parsing.ToBool(val)
@@ -258,7 +277,9 @@ func (this *PowerManager) loadPowers(modules common.Modules, ss gameres.Stats) e
 			} else if val == "on_death" {
 				this.powers[inputId].PassiveTrigger = power.TRIGGER_DEATH
 			} else {
-				return fmt.Errorf("PowerManager: Unknown passive trigger '%s'\n", val)
+				if err := infile.Reportf("PowerManager: Unknown passive trigger '%s'", val); err != nil {
+					return err
+				}
 			}
 		case "meta_power":
 			this.powers[inputId].MetaPower = parsing.ToBool(val)
@@ -342,7 +363,9 @@ func (this *PowerManager) loadPowers(modules common.Modules, ss gameres.Stats) e
 				this.powers[inputId].RequiresMaxHPMP.HPState = power.HPMPSTATE_IGNORE
 				this.powers[inputId].RequiresMaxHPMP.HP = -1
 			} else {
-				return fmt.Errorf("PowerManager: '%s' is not a valid hp/mp state. Use 'percent', 'not_percent', or 'ignore'.\n", stateHP)
+				if err := infile.Reportf("PowerManager: '%s' is not a valid hp/mp state. Use 'percent', 'not_percent', or 'ignore'.", stateHP); err != nil {
+					return err
+				}
 			}
 
 			if stateMP == "percent" {
@@ -353,7 +376,9 @@ func (this *PowerManager) loadPowers(modules common.Modules, ss gameres.Stats) e
 				this.powers[inputId].RequiresMaxHPMP.MPState = power.HPMPSTATE_IGNORE
 				this.powers[inputId].RequiresMaxHPMP.MP = -1
 			} else {
-				return fmt.Errorf("PowerManager: '%s' is not a valid hp/mp state. Use 'percent', 'not_percent', or 'ignore'.\n", stateMP)
+				if err := infile.Reportf("PowerManager: '%s' is not a valid hp/mp state. Use 'percent', 'not_percent', or 'ignore'.", stateMP); err != nil {
+					return err
+				}
 			}
 
 			if mode == "any" {
@@ -375,7 +400,9 @@ func (this *PowerManager) loadPowers(modules common.Modules, ss gameres.Stats) e
 				this.powers[inputId].RequiresMaxHPMP.HPState = power.HPMPSTATE_IGNORE
 				this.powers[inputId].RequiresMaxHPMP.HP = -1
 			} else {
-				return fmt.Errorf("PowerManager: Please specify 'any' or 'all'.\n")
+				if err := infile.Reportf("PowerManager: Please specify 'any' or 'all'."); err != nil {
+					return err
+				}
 			}
 
 		case "animation":
@@ -389,7 +416,10 @@ func (this *PowerManager) loadPowers(modules common.Modules, ss gameres.Stats) e
 				anim.IncreaseCount(this.powers[inputId].AnimationName)
 				aset, err := anim.GetAnimationSet(settings, mods, render, mresf, val)
 				if err != nil {
-					return err
+					if err := infile.Report(err); err != nil {
+						return err
+					}
+					break
 				}
 				this.powerAnimations[inputId] = aset.GetAnimation("")
 			}
@@ -446,7 +476,9 @@ func (this *PowerManager) loadPowers(modules common.Modules, ss gameres.Stats) e
 			} else if val == "melee" {
 				this.powers[inputId].StartingPos = power.STARTING_POS_MELEE
 			} else {
-				return fmt.Errorf("PowerManager: Unknown starting_pos '%s'\n", val)
+				if err := infile.Reportf("PowerManager: Unknown starting_pos '%s'", val); err != nil {
+					return err
+				}
 			}
 
 		case "relative_pos":
@@ -469,7 +501,9 @@ func (this *PowerManager) loadPowers(modules common.Modules, ss gameres.Stats) e
 			} else if val == "intangible" {
 				this.powers[inputId].MovementType = mapcollision.MOVE_INTANGIBLE
 			} else {
-				return fmt.Errorf("PowerManager: Unknown movement_type '%s'\n", val)
+				if err := infile.Reportf("PowerManager: Unknown movement_type '%s'", val); err != nil {
+					return err
+				}
 			}
 		case "trait_armor_penetration":
 			this.powers[inputId].TraitArmorPenetration = parsing.ToBool(val)
@@ -529,7 +563,9 @@ func (this *PowerManager) loadPowers(modules common.Modules, ss gameres.Stats) e
 			pe := power.ConstructPostEffect()
 			pe.Id, val = parsing.PopFirstString(val, "")
 			if !this.isValidEffect(modules, ss, pe.Id) {
-				return fmt.Errorf("PowerManager: Unknown effect '%s'\n", pe.Id)
+				if err := infile.Reportf("PowerManager: Unknown effect '%s'", pe.Id); err != nil {
+					return err
+				}
 			} else {
 				if key == "post_effect_src" {
 					pe.TargetSrc = true
@@ -589,7 +625,9 @@ func (this *PowerManager) loadPowers(modules common.Modules, ss gameres.Stats) e
 			} else if mode == "unlimited" {
 				this.powers[inputId].SpawnLimitMode = power.SPAWN_LIMIT_MODE_UNLIMITED
 			} else {
-				return fmt.Errorf("PowerManager: Unknown spawn_limit_mode '%s'\n", mode)
+				if err := infile.Reportf("PowerManager: Unknown spawn_limit_mode '%s'", mode); err != nil {
+					return err
+				}
 			}
 
 			if this.powers[inputId].SpawnLimitMode != power.SPAWN_LIMIT_MODE_UNLIMITED {
@@ -601,7 +639,9 @@ func (this *PowerManager) loadPowers(modules common.Modules, ss gameres.Stats) e
 					if primStatIndex, ok := eset.PrimaryStatsGetIndexById(stat); ok {
 						this.powers[inputId].SpawnLimitStat = primStatIndex
 					} else {
-						return fmt.Errorf("PowerManager: '%s' is not a valid primary stat.\n", stat)
+						if err := infile.Reportf("PowerManager: '%s' is not a valid primary stat.", stat); err != nil {
+							return err
+						}
 					}
 				}
 			}
@@ -618,7 +658,9 @@ func (this *PowerManager) loadPowers(modules common.Modules, ss gameres.Stats) e
 			} else if mode == "level" {
 				this.powers[inputId].SpawnLevelMode = power.SPAWN_LEVEL_MODE_LEVEL
 			} else {
-				return fmt.Errorf("PowerManager: Unknown spawn_level_mode '%s'\n", mode)
+				if err := infile.Reportf("PowerManager: Unknown spawn_level_mode '%s'", mode); err != nil {
+					return err
+				}
 			}
 
 			if this.powers[inputId].SpawnLevelMode != power.SPAWN_LEVEL_MODE_DEFAULT {
@@ -634,7 +676,9 @@ func (this *PowerManager) loadPowers(modules common.Modules, ss gameres.Stats) e
 					if primStatIndex, ok := eset.PrimaryStatsGetIndexById(stat); ok {
 						this.powers[inputId].SpawnLevelStat = primStatIndex
 					} else {
-						return fmt.Errorf("PowerManager: '%s' is not a valid primary stat.\n", stat)
+						if err := infile.Reportf("PowerManager: '%s' is not a valid primary stat.", stat); err != nil {
+							return err
+						}
 					}
 				}
 			}
@@ -660,7 +704,9 @@ func (this *PowerManager) loadPowers(modules common.Modules, ss gameres.Stats) e
 			} else if mode == "absolute" {
 				this.powers[inputId].ModAccuracyMode = power.STAT_MODIFIER_MODE_ABSOLUTE
 			} else {
-				return fmt.Errorf("PowerManager: Unknown stat_modifier_mode '%s'\n", mode)
+				if err := infile.Reportf("PowerManager: Unknown stat_modifier_mode '%s'", mode); err != nil {
+					return err
+				}
 			}
 
 			this.powers[inputId].ModAccuracyValue, val = parsing.PopFirstInt(val, "")
@@ -676,7 +722,9 @@ func (this *PowerManager) loadPowers(modules common.Modules, ss gameres.Stats) e
 			} else if mode == "absolute" {
 				this.powers[inputId].ModDamageMode = power.STAT_MODIFIER_MODE_ABSOLUTE
 			} else {
-				return fmt.Errorf("PowerManager: Unknown stat_modifier_mode '%s'\n", mode)
+				if err := infile.Reportf("PowerManager: Unknown stat_modifier_mode '%s'", mode); err != nil {
+					return err
+				}
 			}
 
 			this.powers[inputId].ModDamageValueMin, val = parsing.PopFirstInt(val, "")
@@ -692,7 +740,9 @@ func (this *PowerManager) loadPowers(modules common.Modules, ss gameres.Stats) e
 			} else if mode == "absolute" {
 				this.powers[inputId].ModCritMode = power.STAT_MODIFIER_MODE_ABSOLUTE
 			} else {
-				return fmt.Errorf("PowerManager: Unknown stat_modifier_mode '%s'\n", mode)
+				if err := infile.Reportf("PowerManager: Unknown stat_modifier_mode '%s'", mode); err != nil {
+					return err
+				}
 			}
 
 			this.powers[inputId].ModCritValue, val = parsing.PopFirstInt(val, "")
@@ -714,7 +764,9 @@ func (this *PowerManager) loadPowers(modules common.Modules, ss gameres.Stats) e
 			} else if trigger == "on_wall" {
 				this.powers[inputId].ScriptTrigger = power.SCRIPT_TRIGGER_WALL
 			} else {
-				return fmt.Errorf("PowerManager: Unknown script trigger '%s'\n", trigger)
+				if err := infile.Reportf("PowerManager: Unknown script trigger '%s'", trigger); err != nil {
+					return err
+				}
 			}
 
 			this.powers[inputId].Script, val = parsing.PopFirstString(val, "")
@@ -754,7 +806,9 @@ func (this *PowerManager) loadPowers(modules common.Modules, ss gameres.Stats) e
 			}
 
 		default:
-			return fmt.Errorf("PowerManager: '%s' is not a valid key\n", key)
+			if err := infile.Reportf("PowerManager: '%s' is not a valid key", key); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -945,7 +999,7 @@ func (this *PowerManager) Buff(modules common.Modules, ss gameres.Stats, powerIn
 		if !this.powers[powerIndex].Passive {
 
 			if tools.PercentChance(this.powers[powerIndex].PostPowerChance) {
-				this.activate(modules, ss, this.powers[powerIndex].PostPower, srcStats, srcStats.GetPos())
+				this.Activate(modules, ss, this.powers[powerIndex].PostPower, srcStats, srcStats.GetPos())
 			}
 		}
 	}
@@ -1054,8 +1108,22 @@ func (this *PowerManager) block(modules common.Modules, ss gameres.Stats, powerI
 	return true
 }
 
+// 固定位置的技能
+func (this *PowerManager) fixed(modules common.Modules, ss gameres.Stats, powerIndex define.PowerId, srcStats gameres.StatBlock, target fpoint.FPoint) bool {
+	if this.powers[powerIndex].UseHazard {
+		// TODO
+		// 生成伤害区域
+	}
+
+	this.Buff(modules, ss, powerIndex, srcStats, target)
+
+	// 技能消耗
+	this.payPowerCost(powerIndex, srcStats)
+	return true
+}
+
 // 激活对应的技能或者功能
-func (this *PowerManager) activate(modules common.Modules, ss gameres.Stats, powerIndex define.PowerId, srcStats gameres.StatBlock, target fpoint.FPoint) bool {
+func (this *PowerManager) Activate(modules common.Modules, ss gameres.Stats, powerIndex define.PowerId, srcStats gameres.StatBlock, target fpoint.FPoint) bool {
 	if this.powers[powerIndex].IsEmpty {
 		return false
 	}
@@ -1105,7 +1173,7 @@ func (this *PowerManager) activate(modules common.Modules, ss gameres.Stats, pow
 	switch this.powers[powerIndex].Type {
 	case power.TYPE_FIXED:
 		// 固定
-		// TODO
+		return this.fixed(modules, ss, powerIndex, srcStats, newTarget)
 	case power.TYPE_MISSILE:
 		// 飞弹
 		// TODO
@@ -1238,6 +1306,16 @@ func (this *PowerManager) GetPower(powerId define.PowerId) *power.Power {
 	return this.powers[powerId]
 }
 
+// 技能消耗掉的仓库道具，由背包扣除
+func (this *PowerManager) GetUsedItems() []define.ItemId {
+	return this.usedItems
+}
+
+func (this *PowerManager) ClearUsedItems() {
+	this.usedItems = nil
+	this.usedEquippedItems = nil
+}
+
 func (this *PowerManager) GetPowers() map[define.PowerId]*power.Power {
 	return this.powers
 }
@@ -26,7 +26,7 @@ func (this *Media) Close() {
 
 // 记载图片，标记为key，key为空字符串代表默认
 func (this *Media) LoadImage(settings common.Settings, mods common.ModManager, render common.RenderDevice, path, key string) error {
-	loadedImg, err := render.LoadImage(settings, mods, path)
+	loadedImg, err := render.LoadAtlasImage(settings, mods, path)
 	if err != nil {
 		return err
 	}
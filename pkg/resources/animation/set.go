@@ -1,7 +1,6 @@
 package animation
 
 import (
-	"errors"
 	"fmt"
 	"monster/pkg/common"
 	"monster/pkg/common/color"
@@ -32,7 +31,7 @@ func NewSet(settings common.Settings, mods common.ModManager, render common.Rend
 }
 */
 
-func (this *Set) Init(settings common.Settings, mods common.ModManager, render common.RenderDevice, name string) common.AnimationSet {
+func (this *Set) Init(settings common.Settings, mods common.ModManager, render common.RenderDevice, name string) (common.AnimationSet, error) {
 	this.name = name
 	this.sprite = NewMedia()
 	defaultAnim := NewAnimation("default",
@@ -48,10 +47,11 @@ func (this *Set) Init(settings common.Settings, mods common.ModManager, render c
 	// 加载name对应的配置，加载配置文件里指定的图片
 	err := this.load(settings, mods, render)
 	if err != nil {
-		panic(err)
+		this.Close()
+		return nil, err
 	}
 
-	return this
+	return this, nil
 }
 
 func (this *Set) Close() {
@@ -156,7 +156,7 @@ func (this *Set) load(settings common.Settings, mods common.ModManager, render c
 				imgFilename, strVal := parsing.PopFirstString(infile.Val(), "")
 				imgId, strVal := parsing.PopFirstString(strVal, "")
 				err := this.sprite.LoadImage(settings, mods, render, imgFilename, imgId)
-				if err != nil {
+				if err := infile.Report(err); err != nil {
 					return err
 				}
 			case "render_size":
@@ -183,7 +183,9 @@ func (this *Set) load(settings common.Settings, mods common.ModManager, render c
 			case "color_mod":
 				colorMod = parsing.ToRGB(infile.Val())
 			default:
-				return errors.New(fmt.Sprintf("AnimationSet: '%s' is not a valid key.\n", infile.Key()))
+				if err := infile.Reportf("AnimationSet: '%s' is not a valid key.", infile.Key()); err != nil {
+					return err
+				}
 			}
 		} else {
 			// 一个方向或动作
@@ -265,7 +267,9 @@ func (this *Set) load(settings common.Settings, mods common.ModManager, render c
 				}
 
 			default:
-				return errors.New(fmt.Sprintf("AnimationSet: '%s' is not a valid key.\n", infile.Key()))
+				if err := infile.Reportf("AnimationSet: '%s' is not a valid key.", infile.Key()); err != nil {
+					return err
+				}
 			}
 
 		}
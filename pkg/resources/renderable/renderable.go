@@ -19,6 +19,7 @@ type Renderable struct {
 	colorMod  color.Color
 	alphaMod  uint8
 	type1     uint8
+	emissive  bool // 自发光，不受光照图影响
 }
 
 func Init() common.Renderable {
@@ -102,3 +103,11 @@ func (this *Renderable) SetType(type1 uint8) {
 func (this *Renderable) GetType() uint8 {
 	return this.type1
 }
+
+func (this *Renderable) SetEmissive(emissive bool) {
+	this.emissive = emissive
+}
+
+func (this *Renderable) IsEmissive() bool {
+	return this.emissive
+}
@@ -3,6 +3,8 @@ package animationmanager
 import (
 	"fmt"
 	"monster/pkg/common"
+	"monster/pkg/filesystem/fileparser"
+	"monster/pkg/utils/parsing"
 )
 
 type AnimationManager struct {
@@ -30,8 +32,12 @@ func (this *AnimationManager) GetAnimationSet(settings common.Settings, mods com
 
 	if index >= 0 {
 		if this.sets[index] == nil {
-			this.sets[index] = resf.New("animationset").(common.AnimationSet).Init(settings, mods, render, filename)
+			set, err := resf.New("animationset").(common.AnimationSet).Init(settings, mods, render, filename)
+			if err != nil {
+				return nil, err
+			}
 
+			this.sets[index] = set
 		}
 
 		return this.sets[index], nil
@@ -40,6 +46,32 @@ func (this *AnimationManager) GetAnimationSet(settings common.Settings, mods com
 	return nil, fmt.Errorf("AnimationManager::getAnimationSet(): %s not found\n", filename)
 }
 
+// 解析动画文件并解码其中的图片，之后GetAnimationSet只需上传纹理
+// 不创建动画集，可以在工作协程里调用
+func (this *AnimationManager) Preload(ldr common.Loader, settings common.Settings, mods common.ModManager, render common.RenderDevice, filename string) {
+	ldr.Add(func() error {
+		infile := fileparser.New()
+		err := infile.Open(filename, true, mods)
+		if err != nil {
+			return err
+		}
+		defer infile.Close()
+
+		for infile.Next(mods) {
+			if infile.GetSection() != "" || infile.Key() != "image" {
+				continue
+			}
+
+			imgFilename, _ := parsing.PopFirstString(infile.Val(), "")
+			ldr.Add(func() error {
+				return render.PreloadImage(settings, mods, imgFilename)
+			}, nil)
+		}
+
+		return nil
+	}, nil)
+}
+
 func (this *AnimationManager) IncreaseCount(name string) {
 	index := -1
 	for i, val := range this.names {
@@ -0,0 +1,321 @@
+package base
+
+import (
+	"unicode"
+)
+
+/*
+ * 简化的双向文字处理
+ * 阿拉伯文按前后字母替换为连写形式，再把单行文字从逻辑顺序重排为显示顺序
+ * 不支持显式的方向控制符
+ */
+
+const (
+	bidiL  = iota // 从左到右
+	bidiR         // 从右到左
+	bidiEN        // 数字
+	bidiN         // 中性，跟随两侧
+)
+
+func bidiClass(r rune) int {
+	switch {
+	case (r >= 0x0590 && r <= 0x08ff) || (r >= 0xfb1d && r <= 0xfdff) || (r >= 0xfe70 && r <= 0xfefc):
+		// 希伯来文、阿拉伯文及其表现形式
+		if unicode.IsDigit(r) {
+			return bidiEN
+		}
+		return bidiR
+	case unicode.IsDigit(r):
+		return bidiEN
+	case unicode.IsLetter(r):
+		return bidiL
+	}
+
+	return bidiN
+}
+
+func hasRTL(runes []rune) bool {
+	for _, r := range runes {
+		if bidiClass(r) == bidiR {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 从右到左时镜像的括号
+var bidiMirror = map[rune]rune{
+	'(': ')', ')': '(',
+	'[': ']', ']': '[',
+	'{': '}', '}': '{',
+	'<': '>', '>': '<',
+	'«': '»', '»': '«',
+}
+
+// 单行文字的显示顺序
+func VisualOrder(text string) string {
+	runes := []rune(text)
+	if !hasRTL(runes) {
+		return text
+	}
+
+	classes := make([]int, len(runes))
+	base := -1 // 段落方向取第一个强方向字符
+	for i, r := range runes {
+		classes[i] = bidiClass(r)
+		if base == -1 && (classes[i] == bidiL || classes[i] == bidiR) {
+			base = classes[i]
+		}
+	}
+
+	if base == -1 {
+		base = bidiL
+	}
+
+	// 前面是从左到右的数字按从左到右处理
+	last := base
+	for i, c := range classes {
+		switch c {
+		case bidiL, bidiR:
+			last = c
+		case bidiEN:
+			if last == bidiL {
+				classes[i] = bidiL
+			}
+		}
+	}
+
+	// 中性字符两侧方向相同则跟随，否则取段落方向
+	for i := 0; i < len(classes); {
+		if classes[i] != bidiN {
+			i++
+			continue
+		}
+
+		end := i
+		for end < len(classes) && classes[end] == bidiN {
+			end++
+		}
+
+		before := base
+		if i > 0 {
+			before = strongOf(classes[i-1])
+		}
+
+		after := base
+		if end < len(classes) {
+			after = strongOf(classes[end])
+		}
+
+		dir := base
+		if before == after {
+			dir = before
+		}
+
+		for ; i < end; i++ {
+			classes[i] = dir
+		}
+	}
+
+	levels := make([]int, len(runes))
+	maxLevel := 0
+	for i, c := range classes {
+		switch {
+		case base == bidiL && c == bidiR:
+			levels[i] = 1
+		case base == bidiL && c == bidiEN:
+			levels[i] = 2
+		case base == bidiR && c == bidiR:
+			levels[i] = 1
+		case base == bidiR:
+			levels[i] = 2
+		}
+
+		if levels[i] > maxLevel {
+			maxLevel = levels[i]
+		}
+	}
+
+	// 从最高层级到最低的奇数层级，依次反转连续的片段
+	for level := maxLevel; level >= 1; level-- {
+		for i := 0; i < len(runes); {
+			if levels[i] < level {
+				i++
+				continue
+			}
+
+			end := i
+			for end < len(runes) && levels[end] >= level {
+				end++
+			}
+
+			reverseRunes(runes[i:end])
+			reverseInts(levels[i:end])
+			i = end
+		}
+	}
+
+	for i, r := range runes {
+		if levels[i]%2 == 1 {
+			if m, ok := bidiMirror[r]; ok {
+				runes[i] = m
+			}
+		}
+	}
+
+	return string(runes)
+}
+
+// 数字在中性字符的判断里算作从右到左
+func strongOf(c int) int {
+	if c == bidiEN {
+		return bidiR
+	}
+	return c
+}
+
+func reverseRunes(runes []rune) {
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+}
+
+func reverseInts(vals []int) {
+	for i, j := 0, len(vals)-1; i < j; i, j = i+1, j-1 {
+		vals[i], vals[j] = vals[j], vals[i]
+	}
+}
+
+// 阿拉伯字母的四种形式：独立、词尾、词首、词中，只能与右侧连写的字母没有词首和词中
+type arabicForms struct {
+	isolated rune
+	final    rune
+	initial  rune
+	medial   rune
+}
+
+var arabicTable = map[rune]arabicForms{
+	0x0621: {0xfe80, 0, 0, 0},
+	0x0622: {0xfe81, 0xfe82, 0, 0},
+	0x0623: {0xfe83, 0xfe84, 0, 0},
+	0x0624: {0xfe85, 0xfe86, 0, 0},
+	0x0625: {0xfe87, 0xfe88, 0, 0},
+	0x0626: {0xfe89, 0xfe8a, 0xfe8b, 0xfe8c},
+	0x0627: {0xfe8d, 0xfe8e, 0, 0},
+	0x0628: {0xfe8f, 0xfe90, 0xfe91, 0xfe92},
+	0x0629: {0xfe93, 0xfe94, 0, 0},
+	0x062a: {0xfe95, 0xfe96, 0xfe97, 0xfe98},
+	0x062b: {0xfe99, 0xfe9a, 0xfe9b, 0xfe9c},
+	0x062c: {0xfe9d, 0xfe9e, 0xfe9f, 0xfea0},
+	0x062d: {0xfea1, 0xfea2, 0xfea3, 0xfea4},
+	0x062e: {0xfea5, 0xfea6, 0xfea7, 0xfea8},
+	0x062f: {0xfea9, 0xfeaa, 0, 0},
+	0x0630: {0xfeab, 0xfeac, 0, 0},
+	0x0631: {0xfead, 0xfeae, 0, 0},
+	0x0632: {0xfeaf, 0xfeb0, 0, 0},
+	0x0633: {0xfeb1, 0xfeb2, 0xfeb3, 0xfeb4},
+	0x0634: {0xfeb5, 0xfeb6, 0xfeb7, 0xfeb8},
+	0x0635: {0xfeb9, 0xfeba, 0xfebb, 0xfebc},
+	0x0636: {0xfebd, 0xfebe, 0xfebf, 0xfec0},
+	0x0637: {0xfec1, 0xfec2, 0xfec3, 0xfec4},
+	0x0638: {0xfec5, 0xfec6, 0xfec7, 0xfec8},
+	0x0639: {0xfec9, 0xfeca, 0xfecb, 0xfecc},
+	0x063a: {0xfecd, 0xfece, 0xfecf, 0xfed0},
+	0x0640: {0x0640, 0x0640, 0x0640, 0x0640},
+	0x0641: {0xfed1, 0xfed2, 0xfed3, 0xfed4},
+	0x0642: {0xfed5, 0xfed6, 0xfed7, 0xfed8},
+	0x0643: {0xfed9, 0xfeda, 0xfedb, 0xfedc},
+	0x0644: {0xfedd, 0xfede, 0xfedf, 0xfee0},
+	0x0645: {0xfee1, 0xfee2, 0xfee3, 0xfee4},
+	0x0646: {0xfee5, 0xfee6, 0xfee7, 0xfee8},
+	0x0647: {0xfee9, 0xfeea, 0xfeeb, 0xfeec},
+	0x0648: {0xfeed, 0xfeee, 0, 0},
+	0x0649: {0xfeef, 0xfef0, 0, 0},
+	0x064a: {0xfef1, 0xfef2, 0xfef3, 0xfef4},
+}
+
+// 拉姆和艾利夫的连字：独立、词尾
+var arabicLamAlef = map[rune][2]rune{
+	0x0622: {0xfef5, 0xfef6},
+	0x0623: {0xfef7, 0xfef8},
+	0x0625: {0xfef9, 0xfefa},
+	0x0627: {0xfefb, 0xfefc},
+}
+
+// 不影响连写的符号
+func isArabicTransparent(r rune) bool {
+	return (r >= 0x064b && r <= 0x065f) || r == 0x0670
+}
+
+// 跳过符号后的前一个或后一个字母
+func arabicNeighbor(runes []rune, i, step int) (arabicForms, bool) {
+	for i += step; i >= 0 && i < len(runes); i += step {
+		if isArabicTransparent(runes[i]) {
+			continue
+		}
+
+		forms, ok := arabicTable[runes[i]]
+		return forms, ok
+	}
+
+	return arabicForms{}, false
+}
+
+// 阿拉伯文替换为连写形式，按逻辑顺序处理
+func ShapeArabic(text string) string {
+	runes := []rune(text)
+
+	found := false
+	for _, r := range runes {
+		if _, ok := arabicTable[r]; ok {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return text
+	}
+
+	out := make([]rune, 0, len(runes))
+	for i := 0; i < len(runes); i++ {
+		forms, ok := arabicTable[runes[i]]
+		if !ok {
+			out = append(out, runes[i])
+			continue
+		}
+
+		prev, prevOk := arabicNeighbor(runes, i, -1)
+		joinPrev := prevOk && prev.medial != 0
+
+		// 拉姆后面紧跟艾利夫
+		if runes[i] == 0x0644 && i+1 < len(runes) {
+			if lig, ok := arabicLamAlef[runes[i+1]]; ok {
+				if joinPrev {
+					out = append(out, lig[1])
+				} else {
+					out = append(out, lig[0])
+				}
+				i++
+				continue
+			}
+		}
+
+		_, nextOk := arabicNeighbor(runes, i, 1)
+		joinNext := nextOk && forms.medial != 0
+
+		switch {
+		case joinPrev && joinNext:
+			out = append(out, forms.medial)
+		case joinPrev:
+			out = append(out, forms.final)
+		case joinNext:
+			out = append(out, forms.initial)
+		default:
+			out = append(out, forms.isolated)
+		}
+	}
+
+	return string(out)
+}
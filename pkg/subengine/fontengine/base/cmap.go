@@ -0,0 +1,138 @@
+package base
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// 字体文件里的字符映射，用来判断字体是否包含某个字符
+type Cmap struct {
+	ranges []cmapRange // 按start排序
+}
+
+type cmapRange struct {
+	start rune
+	end   rune
+}
+
+func (this *Cmap) HasRune(r rune) bool {
+	i := sort.Search(len(this.ranges), func(i int) bool {
+		return this.ranges[i].end >= r
+	})
+
+	return i < len(this.ranges) && this.ranges[i].start <= r
+}
+
+func (this *Cmap) IsEmpty() bool {
+	return len(this.ranges) == 0
+}
+
+// 读取ttf/otf的cmap表，ttc只读取第一个字体
+func ParseCmap(data []byte) (Cmap, error) {
+	var cmap Cmap
+
+	u16 := func(off int) int {
+		if off < 0 || off+2 > len(data) {
+			return -1
+		}
+		return int(binary.BigEndian.Uint16(data[off:]))
+	}
+
+	u32 := func(off int) int {
+		if off < 0 || off+4 > len(data) {
+			return -1
+		}
+		return int(binary.BigEndian.Uint32(data[off:]))
+	}
+
+	fontOffset := 0
+	if len(data) >= 16 && string(data[:4]) == "ttcf" {
+		fontOffset = u32(12)
+	}
+
+	numTables := u16(fontOffset + 4)
+	if numTables <= 0 {
+		return cmap, fmt.Errorf("FontEngine: invalid font file.")
+	}
+
+	cmapOffset := -1
+	for i := 0; i < numTables; i++ {
+		record := fontOffset + 12 + i*16
+		if record+16 > len(data) {
+			break
+		}
+
+		if string(data[record:record+4]) == "cmap" {
+			cmapOffset = u32(record + 8)
+			break
+		}
+	}
+
+	if cmapOffset == -1 {
+		return cmap, fmt.Errorf("FontEngine: font has no cmap table.")
+	}
+
+	// 优先使用完整的unicode映射(格式12)，否则使用基本平面(格式4)
+	format4 := -1
+	format12 := -1
+	numSubtables := u16(cmapOffset + 2)
+	for i := 0; i < numSubtables; i++ {
+		record := cmapOffset + 4 + i*8
+		platform := u16(record)
+		encoding := u16(record + 2)
+		offset := cmapOffset + u32(record+4)
+
+		switch u16(offset) {
+		case 4:
+			if platform == 0 || (platform == 3 && encoding == 1) {
+				format4 = offset
+			}
+		case 12:
+			if platform == 0 || (platform == 3 && encoding == 10) {
+				format12 = offset
+			}
+		}
+	}
+
+	switch {
+	case format12 != -1:
+		numGroups := u32(format12 + 12)
+		for i := 0; i < numGroups; i++ {
+			group := format12 + 16 + i*12
+			start := u32(group)
+			end := u32(group + 4)
+			if start == -1 || end == -1 {
+				return cmap, fmt.Errorf("FontEngine: invalid cmap table.")
+			}
+
+			cmap.ranges = append(cmap.ranges, cmapRange{start: rune(start), end: rune(end)})
+		}
+	case format4 != -1:
+		segCount := u16(format4+6) / 2
+		endCodes := format4 + 14
+		startCodes := endCodes + segCount*2 + 2
+		for i := 0; i < segCount; i++ {
+			start := u16(startCodes + i*2)
+			end := u16(endCodes + i*2)
+			if start == -1 || end == -1 {
+				return cmap, fmt.Errorf("FontEngine: invalid cmap table.")
+			}
+
+			// 最后一段0xffff只是结束标记
+			if start == 0xffff {
+				continue
+			}
+
+			cmap.ranges = append(cmap.ranges, cmapRange{start: rune(start), end: rune(end)})
+		}
+	default:
+		return cmap, fmt.Errorf("FontEngine: no unicode cmap subtable.")
+	}
+
+	sort.Slice(cmap.ranges, func(i, j int) bool {
+		return cmap.ranges[i].start < cmap.ranges[j].start
+	})
+
+	return cmap, nil
+}
@@ -9,7 +9,6 @@ import (
 	"monster/pkg/filesystem/fileparser"
 	"monster/pkg/filesystem/logfile"
 	"monster/pkg/utils/parsing"
-	"strings"
 )
 
 const (
@@ -24,6 +23,7 @@ var (
 type FontEngine struct {
 	fontColors map[int]color.Color
 	cursorY    int
+	source     common.FontMarkupSource // 富文本的图标和按键
 }
 
 func ConstructFontEngine(mods common.ModManager) FontEngine {
@@ -117,110 +117,19 @@ func (this *FontEngine) GetColor(key int) color.Color {
 
 // 计算多行的宽和高
 func (this *FontEngine) CalcSize(impl common.FontEngine, textWithNewlines string, width int) point.Point {
-	newline := "\n"
-
-	text := textWithNewlines
-	checkNewline := strings.Index(text, newline)
-
-	// 换行则递归
-	if checkNewline != -1 {
-		p1 := this.CalcSize(impl, textWithNewlines[0:checkNewline], width)
-		p2 := this.CalcSize(impl, textWithNewlines[checkNewline+1:], width)
-		p3 := point.Construct()
-
-		// 取最宽
-		if p1.X > p2.X {
-			p3.X = p1.X
-		} else {
-			p3.X = p2.X
-		}
-
-		p3.Y = p1.Y + p2.Y // height
-		return p3
+	size := point.Construct()
+	if textWithNewlines == "" {
+		return size
 	}
 
-	height := 0
-	maxWidth := 0
-	space := ([]byte(" "))[0]
-	fulltext := text + " "
-	nextWord := ""
-	cursor := 0
-	builder := "" // 上下2次字符串拼接，直到能大于宽度被处理
-	builderPrev := ""
-	longToken := ""
-
-	// 弹出从cursor到下个空格前的字符串
-	nextWord, cursor = getNextToken(fulltext, cursor, space)
-
-	for {
-		if cursor == -1 {
-			// 处理完
-			break
-		}
-
-		builder += nextWord
-
-		if impl.CalcWidth(builder) > width {
-			// 超过像素宽度
-
-			if builderPrev != "" {
-				height += impl.GetLineHeight()
-				if impl.CalcWidth(builderPrev) > maxWidth {
-					maxWidth = impl.CalcWidth(builderPrev)
-				}
-			}
-
-			builder = ""                                                      // 清理单行
-			longToken, nextWord = this.popTokenByWidth(impl, nextWord, width) // 返回的nextword小于行像素宽度
-
-			for {
-				if longToken == "" {
-					break
-				}
-
-				if impl.CalcWidth(nextWord) > maxWidth {
-					maxWidth = impl.CalcWidth(nextWord) // 计算最宽的行
-				}
-
-				height += impl.GetLineHeight() // 总行数+1
-				nextWord = longToken
-				longToken, nextWord = this.popTokenByWidth(impl, nextWord, width) // 返回的nextword小于行像素宽度
-				if longToken == nextWord {
-					// 无法小于指定像素宽度的字符串,跳出
-					break
-				}
-			}
-
-			builder += nextWord + " "
-			builderPrev = builder
-
-		} else {
-			// 未超过指定像素宽度的字符串，等待累加
-			builder += " "
-			builderPrev = builder
+	for _, line := range this.wrapLines(impl, textWithNewlines, width) {
+		if w := impl.CalcWidth(line); w > size.X {
+			size.X = w // 取最宽
 		}
 
-		// 下一个空格前的字符串
-		nextWord, cursor = getNextToken(fulltext, cursor, space)
-	}
-
-	// 处理剩余的或" "字符
-	builder = strings.TrimSpace(builder)
-	if builder != "" {
-		height += impl.GetLineHeight()
-	}
-
-	if impl.CalcWidth(builder) > maxWidth {
-		maxWidth = impl.CalcWidth(builder)
-	}
-
-	if textWithNewlines == " " {
-		height += impl.GetLineHeight()
+		size.Y += impl.GetLineHeight()
 	}
 
-	size := point.Construct()
-	size.X = maxWidth
-	size.Y = height
 	return size
 }
 
@@ -259,62 +168,16 @@ func (this *FontEngine) Render(impl common.FontEngine, renderDevice common.Rende
 		return nil
 	}
 
-	fulltext := text + " " // 补上一个空格
-	nextWord := ""
-	cursor := 0
 	this.cursorY = y
-	space := ([]byte(" "))[0]
-	builder := ""
-	builderPrev := ""
-	longToken := ""
-
-	// 获取标尺到下个空格前的字符串
-	nextWord, cursor = getNextToken(fulltext, cursor, space)
-	for {
-		if cursor == -1 {
-			// 处理完
-			break
-		}
-
-		builder += nextWord
-		if impl.CalcWidth(builder) > width {
-
-			if builderPrev != "" {
-				impl.RenderInternal(renderDevice, builderPrev, x, this.cursorY, justify, target, color)
-				this.cursorY += impl.GetLineHeight()
-			}
-
-			builder = ""
-			longToken, nextWord = this.popTokenByWidth(impl, nextWord, width) //nextWord为小于行像素宽度的字符串
-
-			for {
-				if longToken == "" {
-					break
-				}
-
-				impl.RenderInternal(renderDevice, nextWord, x, this.cursorY, justify, target, color)
-				this.cursorY += impl.GetLineHeight()
-				nextWord = longToken
-				longToken, nextWord = this.popTokenByWidth(impl, nextWord, width)
-				if longToken == nextWord { // < width
-					break
-				}
-			}
-
-			builder += nextWord + " "
-			builderPrev = builder
-		} else {
-			builder += " "
-			builderPrev = builder
+	for _, line := range this.wrapLines(impl, text, width) {
+		err := impl.RenderInternal(renderDevice, line, x, this.cursorY, justify, target, color)
+		if err != nil {
+			return err
 		}
 
-		// 获取标尺到下个空格前的字符串
-		nextWord, cursor = getNextToken(fulltext, cursor, space)
+		this.cursorY += impl.GetLineHeight()
 	}
 
-	impl.RenderInternal(renderDevice, builder, x, this.cursorY, justify, target, color)
-	this.cursorY += impl.GetLineHeight()
-
 	return nil
 }
 
@@ -334,25 +197,6 @@ func (this *FontEngine) RenderShadowed(impl common.FontEngine, renderDevice comm
 	return nil
 }
 
-// 截取strVal的cursor位置开始到第一个separator位置的字符串
-func getNextToken(strVal string, cursor int, separator byte) (string, int) {
-	if cursor >= len(strVal) {
-		return "", -1
-	}
-
-	var bytesSeparator []byte
-	bytesSeparator = append(bytesSeparator, separator)
-
-	strVal = strVal[cursor:]
-	seppos := strings.Index(strVal, string(bytesSeparator))
-	if seppos == -1 {
-		return "", -1
-	}
-
-	outs := strVal[:seppos]
-	return outs, cursor + seppos + 1
-}
-
 /*
  * Fits a string, "text", to a pixel "width".
  * The original string is mutated to fit within the width.
@@ -0,0 +1,123 @@
+package base
+
+import (
+	"monster/pkg/common"
+	"strings"
+	"unicode"
+)
+
+/*
+ * 简化的Unicode换行规则
+ * 空格后可以换行，中日韩文字之间可以换行
+ * 避头尾：闭合标点不能在行首，开始标点不能在行尾
+ */
+
+// 不能出现在行首
+const noBreakBefore = "!),.:;?]}¢°’”‰′″℃、。々〉》」』】〕〗〙〟ゝゞーァィゥェォッャュョヮヵヶぁぃぅぇぉっゃゅょゎ・！％），．：；？］｝～｡｣､･ｰ"
+
+// 不能出现在行尾
+const noBreakAfter = "([{£¥‘“〈《「『【〔〖〘〝（［｛｢￡￥"
+
+func isBreakSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '　'
+}
+
+// 中日韩文字，每个字之间都可以换行
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r) ||
+		(r >= 0x3000 && r <= 0x303f) || // 中日韩标点
+		(r >= 0xff00 && r <= 0xffef) // 全角
+}
+
+// prev和next之间是否可以换行
+func canBreakBetween(prev, next rune) bool {
+	if prev == 0 || isBreakSpace(next) {
+		return false
+	}
+
+	if isBreakSpace(prev) {
+		return true
+	}
+
+	if strings.ContainsRune(noBreakBefore, next) || strings.ContainsRune(noBreakAfter, prev) {
+		return false
+	}
+
+	return isCJK(prev) || isCJK(next)
+}
+
+// 按换行机会拆成不可再分的片段，片段末尾保留空格
+func splitLineSegments(text string) []string {
+	var segments []string
+
+	start := 0
+	prev := rune(0)
+	for pos, r := range text {
+		if canBreakBetween(prev, r) {
+			segments = append(segments, text[start:pos])
+			start = pos
+		}
+		prev = r
+	}
+
+	if start < len(text) {
+		segments = append(segments, text[start:])
+	}
+
+	return segments
+}
+
+func trimBreakSpace(text string) string {
+	return strings.TrimRightFunc(text, isBreakSpace)
+}
+
+// 把一段没有换行符的文字按像素宽度分行
+func (this *FontEngine) wrapLine(impl common.FontEngine, text string, width int) []string {
+	var lines []string
+
+	line := ""
+	for _, segment := range splitLineSegments(text) {
+		if line != "" && impl.CalcWidth(trimBreakSpace(line+segment)) <= width {
+			line += segment
+			continue
+		}
+
+		if line != "" {
+			lines = append(lines, trimBreakSpace(line))
+		}
+
+		line = segment
+
+		// 单个片段超过宽度，按字符拆开
+		for impl.CalcWidth(trimBreakSpace(line)) > width {
+			remain, fit := this.popTokenByWidth(impl, line, width)
+			if remain == fit {
+				break
+			}
+
+			lines = append(lines, fit)
+			line = remain
+		}
+	}
+
+	return append(lines, trimBreakSpace(line))
+}
+
+// 按换行符和像素宽度分行，width为0只按换行符分行
+func (this *FontEngine) wrapLines(impl common.FontEngine, text string, width int) []string {
+	var lines []string
+
+	for _, paragraph := range strings.Split(text, "\n") {
+		if width == 0 {
+			lines = append(lines, paragraph)
+			continue
+		}
+
+		lines = append(lines, this.wrapLine(impl, paragraph, width)...)
+	}
+
+	return lines
+}
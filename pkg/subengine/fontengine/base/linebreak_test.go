@@ -0,0 +1,91 @@
+package base
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitLineSegments(t *testing.T) {
+	r := require.New(t)
+
+	r.Equal([]string{"hello ", "world"}, splitLineSegments("hello world"))
+	r.Equal([]string{"中", "文", "字", "符。", "测", "试"}, splitLineSegments("中文字符。测试"))
+	r.Equal([]string{"「中", "文」"}, splitLineSegments("「中文」"))
+	r.Equal([]string{"a ", "中", "b"}, splitLineSegments("a 中b"))
+	r.Nil(splitLineSegments(""))
+}
+
+func TestVisualOrder(t *testing.T) {
+	r := require.New(t)
+
+	r.Equal("abc", VisualOrder("abc"))
+
+	// 从左到右的段落里的希伯来文
+	r.Equal("a גבא b", VisualOrder("a אבג b"))
+
+	// 从右到左的段落，数字保持顺序，括号镜像
+	r.Equal("12 בא", VisualOrder("אב 12"))
+	r.Equal("(בא)", VisualOrder("(אב)"))
+}
+
+func TestShapeArabic(t *testing.T) {
+	r := require.New(t)
+
+	r.Equal("abc", ShapeArabic("abc"))
+
+	// 拜 + 阿利夫：词首 + 词尾
+	r.Equal("ﺑﺎ", ShapeArabic("با"))
+
+	// 单独的字母
+	r.Equal("ﺏ", ShapeArabic("ب"))
+
+	// 拜 拜 拜：词首 词中 词尾
+	r.Equal("ﺑﺒﺐ", ShapeArabic("ببب"))
+
+	// 拉姆+阿利夫连字
+	r.Equal("ﻻ", ShapeArabic("لا"))
+	r.Equal("ﺑﻼ", ShapeArabic("بلا"))
+}
+
+// 只有格式4 cmap表的字体
+func testFontData(segments [][2]uint16) []byte {
+	segments = append(segments, [2]uint16{0xffff, 0xffff})
+	segCount := len(segments)
+
+	sub := make([]byte, 16+segCount*8)
+	binary.BigEndian.PutUint16(sub[0:], 4)
+	binary.BigEndian.PutUint16(sub[6:], uint16(segCount*2))
+	for i, seg := range segments {
+		binary.BigEndian.PutUint16(sub[14+i*2:], seg[1])
+		binary.BigEndian.PutUint16(sub[16+segCount*2+i*2:], seg[0])
+	}
+
+	data := make([]byte, 12+16+4+8)
+	binary.BigEndian.PutUint16(data[4:], 1)
+	copy(data[12:], "cmap")
+	binary.BigEndian.PutUint32(data[20:], 28)
+
+	// cmap头和一个子表记录
+	binary.BigEndian.PutUint16(data[30:], 1)
+	binary.BigEndian.PutUint16(data[32:], 3)
+	binary.BigEndian.PutUint16(data[34:], 1)
+	binary.BigEndian.PutUint32(data[36:], 12)
+
+	return append(data, sub...)
+}
+
+func TestParseCmap(t *testing.T) {
+	r := require.New(t)
+
+	cmap, err := ParseCmap(testFontData([][2]uint16{{0x20, 0x7e}, {0x4e00, 0x9fff}}))
+	r.Nil(err)
+	r.True(cmap.HasRune('a'))
+	r.True(cmap.HasRune('中'))
+	r.False(cmap.HasRune(0x0628))
+	r.False(cmap.HasRune(0xffff))
+
+	_, err = ParseCmap([]byte{0, 1})
+	r.NotNil(err)
+}
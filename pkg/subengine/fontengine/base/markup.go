@@ -0,0 +1,543 @@
+package base
+
+import (
+	"monster/pkg/common"
+	"monster/pkg/common/color"
+	"monster/pkg/common/define/fontengine"
+	"monster/pkg/common/point"
+	"monster/pkg/utils/parsing"
+	"strconv"
+	"strings"
+)
+
+/*
+ * 富文本标记
+ * [color=item_bonus]...[/color] 或 [color=255,0,0]...[/color] 改变颜色，可嵌套
+ * [b]...[/b] [i]...[/i] 切换到font_bold、font_italic、font_bold_italic，字体不存在时保持原字体
+ * [icon=12] 插入IconManager的图标
+ * [key=accept] 替换为按键绑定的名称
+ * [[ 表示字符[，无法识别的标记按原文显示
+ */
+
+// 一段相同样式的内容
+type markupRun struct {
+	text    string
+	font    string
+	color   color.Color
+	icon    int // -1 表示文字
+	newline bool
+}
+
+func (this *markupRun) sameStyle(other *markupRun) bool {
+	return this.icon == -1 && other.icon == -1 && this.font == other.font && this.color == other.color
+}
+
+type markupParser struct {
+	colors  map[int]color.Color
+	hasFont func(string) bool
+	getKey  func(string) string // 返回空表示不支持按键占位
+	icons   bool                // 是否能显示图标
+}
+
+// 解析成多段样式
+func (this *markupParser) parse(text, font string, baseColor color.Color) []markupRun {
+	var runs []markupRun
+	var colors []color.Color
+	bold := 0
+	italic := 0
+	builder := strings.Builder{}
+
+	currentColor := func() color.Color {
+		if len(colors) == 0 {
+			return baseColor
+		}
+
+		c := colors[len(colors)-1]
+		c.A = baseColor.A
+		return c
+	}
+
+	currentFont := func() string {
+		if bold > 0 && italic > 0 && this.hasFont("font_bold_italic") {
+			return "font_bold_italic"
+		} else if bold > 0 && this.hasFont("font_bold") {
+			return "font_bold"
+		} else if italic > 0 && this.hasFont("font_italic") {
+			return "font_italic"
+		}
+
+		return font
+	}
+
+	flush := func() {
+		if builder.Len() == 0 {
+			return
+		}
+
+		runs = append(runs, markupRun{text: builder.String(), font: currentFont(), color: currentColor(), icon: -1})
+		builder.Reset()
+	}
+
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\n':
+			flush()
+			runs = append(runs, markupRun{icon: -1, newline: true})
+			continue
+		case '[':
+		default:
+			builder.WriteByte(text[i])
+			continue
+		}
+
+		// [[
+		if i+1 < len(text) && text[i+1] == '[' {
+			builder.WriteByte('[')
+			i++
+			continue
+		}
+
+		end := strings.IndexByte(text[i:], ']')
+		if end == -1 {
+			builder.WriteByte('[')
+			continue
+		}
+
+		tag := text[i+1 : i+end]
+		name, val := tag, ""
+		if pos := strings.IndexByte(tag, '='); pos != -1 {
+			name, val = tag[:pos], tag[pos+1:]
+		}
+
+		handled := true
+		switch name {
+		case "color":
+			c, ok := this.parseColor(val)
+			if ok {
+				flush()
+				colors = append(colors, c)
+			} else {
+				handled = false
+			}
+		case "/color":
+			flush()
+			if len(colors) > 0 {
+				colors = colors[:len(colors)-1]
+			}
+		case "b":
+			flush()
+			bold++
+		case "/b":
+			flush()
+			if bold > 0 {
+				bold--
+			}
+		case "i":
+			flush()
+			italic++
+		case "/i":
+			flush()
+			if italic > 0 {
+				italic--
+			}
+		case "icon":
+			id, err := strconv.Atoi(val)
+			if err != nil {
+				handled = false
+			} else if this.icons {
+				flush()
+				runs = append(runs, markupRun{icon: id})
+			}
+		case "key":
+			str := ""
+			if this.getKey != nil {
+				str = this.getKey(val)
+			}
+
+			if str == "" {
+				handled = false
+			} else {
+				builder.WriteString(str)
+			}
+		default:
+			handled = false
+		}
+
+		if !handled {
+			builder.WriteByte('[')
+			continue
+		}
+
+		i += end
+	}
+
+	flush()
+	return runs
+}
+
+// 颜色名或r,g,b
+func (this *markupParser) parseColor(val string) (color.Color, bool) {
+	if key := stringToFontColor(val); key != -1 {
+		if c, ok := this.colors[key]; ok {
+			return c, true
+		}
+	}
+
+	if strings.Count(val, ",") == 2 {
+		return parsing.ToRGB(val), true
+	}
+
+	return color.Color{}, false
+}
+
+// 排版后的一段
+type markupPiece struct {
+	markupRun
+	x int
+	w int
+}
+
+type markupLine struct {
+	pieces []markupPiece
+	width  int
+	height int
+}
+
+// 标记感知的自动换行
+type markupLayout struct {
+	width   int // 0表示不换行
+	measure func(run *markupRun, text string) int
+	lines   []markupLine
+}
+
+func (this *markupLayout) current() *markupLine {
+	return &this.lines[len(this.lines)-1]
+}
+
+// 去掉行尾空格后换行
+func (this *markupLayout) newLine() {
+	this.trimLine()
+	this.lines = append(this.lines, markupLine{})
+}
+
+func (this *markupLayout) trimLine() {
+	line := this.current()
+	if len(line.pieces) == 0 {
+		return
+	}
+
+	last := &line.pieces[len(line.pieces)-1]
+	if last.icon != -1 {
+		return
+	}
+
+	last.text = trimBreakSpace(last.text)
+	if last.text == "" {
+		line.pieces = line.pieces[:len(line.pieces)-1]
+		line.width = last.x
+		return
+	}
+
+	last.w = this.measure(&last.markupRun, last.text)
+	line.width = last.x + last.w
+}
+
+// 追加到当前行，与前一段样式相同则合并
+func (this *markupLayout) add(run *markupRun, text string) {
+	line := this.current()
+
+	if len(line.pieces) > 0 {
+		last := &line.pieces[len(line.pieces)-1]
+		if last.sameStyle(run) {
+			last.text += text
+			last.w = this.measure(&last.markupRun, last.text)
+			line.width = last.x + last.w
+			return
+		}
+	}
+
+	piece := markupPiece{markupRun: *run, x: line.width}
+	piece.text = text
+	piece.w = this.measure(run, text)
+	line.pieces = append(line.pieces, piece)
+	line.width = piece.x + piece.w
+}
+
+// 放不下一行的单词，按字符拆开
+func (this *markupLayout) addLong(word []markupRun) {
+	for i, _ := range word {
+		run := &word[i]
+
+		if run.icon != -1 {
+			if len(this.current().pieces) > 0 && this.current().width+this.measure(run, "") > this.width {
+				this.newLine()
+			}
+			this.add(run, "")
+			continue
+		}
+
+		for _, r := range run.text {
+			if len(this.current().pieces) > 0 && !isBreakSpace(r) && this.current().width+this.measure(run, string(r)) > this.width {
+				this.newLine()
+			}
+			this.add(run, string(r))
+		}
+	}
+}
+
+// 单词末尾的空格不计入是否换行的判断
+func (this *markupLayout) addWord(word []markupRun) {
+	if len(word) == 0 {
+		return
+	}
+
+	w := 0
+	for i, _ := range word {
+		text := word[i].text
+		if i == len(word)-1 {
+			text = trimBreakSpace(text)
+		}
+		w += this.measure(&word[i], text)
+	}
+
+	if this.width > 0 && len(this.current().pieces) > 0 && this.current().width+w > this.width {
+		this.newLine()
+	}
+
+	if this.width > 0 && w > this.width {
+		this.addLong(word)
+		return
+	}
+
+	for i, _ := range word {
+		this.add(&word[i], word[i].text)
+	}
+}
+
+// 图标在换行判断里的字符
+const markupObject = '\ufffc'
+
+// 按换行机会分词后排版，图标和相邻的文字算作同一个词
+func layoutMarkup(runs []markupRun, width int, measure func(run *markupRun, text string) int) []markupLine {
+	layout := markupLayout{
+		width:   width,
+		measure: measure,
+		lines:   []markupLine{{}},
+	}
+
+	var word []markupRun
+	prev := rune(0)
+	for _, run := range runs {
+		if run.newline {
+			layout.addWord(word)
+			word = nil
+			layout.newLine()
+			prev = 0
+			continue
+		}
+
+		if run.icon != -1 {
+			if canBreakBetween(prev, markupObject) {
+				layout.addWord(word)
+				word = nil
+			}
+
+			word = append(word, run)
+			prev = markupObject
+			continue
+		}
+
+		start := 0
+		for pos, r := range run.text {
+			if canBreakBetween(prev, r) {
+				if pos > start {
+					part := run
+					part.text = run.text[start:pos]
+					word = append(word, part)
+				}
+
+				layout.addWord(word)
+				word = nil
+				start = pos
+			}
+			prev = r
+		}
+
+		if start < len(run.text) {
+			part := run
+			part.text = run.text[start:]
+			word = append(word, part)
+		}
+	}
+	layout.addWord(word)
+	layout.trimLine()
+
+	return layout.lines
+}
+func (this *FontEngine) SetMarkupSource(source common.FontMarkupSource) {
+	this.source = source
+}
+
+// 按键名称替换为绑定的按键
+func (this *FontEngine) getKeyString(name string) string {
+	if this.source == nil || this.source.Inpt() == nil {
+		return ""
+	}
+
+	inpt := this.source.Inpt()
+	binding := inpt.GetBindingFromName(name)
+	if binding == -1 {
+		return ""
+	}
+
+	return inpt.GetBindingString(this.source.Msg(), binding, false)
+}
+
+func (this *FontEngine) hasIcons() bool {
+	return this.source != nil && this.source.Icons() != nil && this.source.Eset() != nil
+}
+
+func (this *FontEngine) getIconSize() int {
+	if !this.hasIcons() {
+		return 0
+	}
+
+	return this.source.Eset().Get("resolutions", "icon_size").(int)
+}
+
+// 解析并排版，结束后恢复当前字体
+func (this *FontEngine) layoutMarkup(impl common.FontEngine, text string, width int, baseColor color.Color) []markupLine {
+	font := impl.GetFont()
+	defer impl.SetFont(font)
+
+	parser := markupParser{
+		colors:  this.fontColors,
+		hasFont: impl.HasFont,
+		getKey:  this.getKeyString,
+		icons:   this.hasIcons(),
+	}
+
+	iconSize := this.getIconSize()
+	measure := func(run *markupRun, text string) int {
+		if run.icon != -1 {
+			return iconSize
+		}
+
+		impl.SetFont(run.font)
+		return impl.CalcWidth(text)
+	}
+
+	lines := layoutMarkup(parser.parse(text, font, baseColor), width, measure)
+
+	// 行高取字体和图标中最高的
+	for i, _ := range lines {
+		impl.SetFont(font)
+		lines[i].height = impl.GetLineHeight()
+
+		for _, piece := range lines[i].pieces {
+			h := iconSize
+			if piece.icon == -1 {
+				impl.SetFont(piece.font)
+				h = impl.GetLineHeight()
+			}
+
+			if h > lines[i].height {
+				lines[i].height = h
+			}
+		}
+	}
+
+	return lines
+}
+
+// 计算富文本的宽和高
+func (this *FontEngine) CalcMarkupSize(impl common.FontEngine, text string, width int) point.Point {
+	size := point.Construct()
+	if text == "" {
+		return size
+	}
+
+	for _, line := range this.layoutMarkup(impl, text, width, white) {
+		if line.width > size.X {
+			size.X = line.width
+		}
+		size.Y += line.height
+	}
+
+	return size
+}
+
+// 绘制富文本，width为0表示不换行
+func (this *FontEngine) RenderMarkup(impl common.FontEngine, renderDevice common.RenderDevice, text string, x, y, justify int, target common.Image, width int, color color.Color) error {
+	return this.renderMarkup(impl, renderDevice, text, x, y, justify, target, width, color, false)
+}
+
+// 先绘制阴影，图标只绘制一次
+func (this *FontEngine) RenderMarkupShadowed(impl common.FontEngine, renderDevice common.RenderDevice, text string, x, y, justify int, target common.Image, width int, color color.Color) error {
+	err := this.renderMarkup(impl, renderDevice, text, x+1, y+1, justify, target, width, color, true)
+	if err != nil {
+		return err
+	}
+
+	return this.renderMarkup(impl, renderDevice, text, x, y, justify, target, width, color, false)
+}
+
+func (this *FontEngine) renderMarkup(impl common.FontEngine, renderDevice common.RenderDevice, text string, x, y, justify int, target common.Image, width int, baseColor color.Color, shadow bool) error {
+	lines := this.layoutMarkup(impl, text, width, baseColor)
+	iconSize := this.getIconSize()
+
+	font := impl.GetFont()
+	defer impl.SetFont(font)
+
+	this.cursorY = y
+	for _, line := range lines {
+		left := x
+		switch justify {
+		case fontengine.JUSTIFY_RIGHT:
+			left = x - line.width
+		case fontengine.JUSTIFY_CENTER:
+			left = x - line.width/2
+		}
+
+		for _, piece := range line.pieces {
+			if piece.icon != -1 {
+				if shadow {
+					continue
+				}
+
+				err := this.renderIcon(renderDevice, target, piece.icon, left+piece.x, this.cursorY+(line.height-iconSize)/2)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			c := piece.color
+			if shadow {
+				c = this.GetColor(fontengine.COLOR_BLACK)
+				c.A = baseColor.A
+			}
+
+			impl.SetFont(piece.font)
+			err := impl.RenderInternal(renderDevice, piece.text, left+piece.x, this.cursorY+(line.height-impl.GetLineHeight())/2, fontengine.JUSTIFY_LEFT, target, c)
+			if err != nil {
+				return err
+			}
+		}
+
+		this.cursorY += line.height
+	}
+
+	return nil
+}
+
+func (this *FontEngine) renderIcon(renderDevice common.RenderDevice, target common.Image, iconId, x, y int) error {
+	icons := this.source.Icons()
+
+	icons.SetIcon(this.source.Eset(), iconId, point.Construct(x, y))
+	if target != nil {
+		return icons.RenderToImage(renderDevice, target)
+	}
+
+	return icons.Render(renderDevice)
+}
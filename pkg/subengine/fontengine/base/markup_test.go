@@ -0,0 +1,135 @@
+package base
+
+import (
+	"monster/pkg/common/color"
+	"monster/pkg/common/define/fontengine"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestParser() markupParser {
+	return markupParser{
+		colors: map[int]color.Color{
+			fontengine.COLOR_ITEM_BONUS: color.Construct(0, 255, 0),
+		},
+		hasFont: func(font string) bool {
+			return font == "font_bold"
+		},
+		getKey: func(name string) string {
+			if name == "accept" {
+				return "Enter"
+			}
+			return ""
+		},
+		icons: true,
+	}
+}
+
+// 每个字符1像素，图标2像素
+func testMeasure(run *markupRun, text string) int {
+	if run.icon != -1 {
+		return 2
+	}
+	return len([]rune(text))
+}
+
+func TestParseMarkup(t *testing.T) {
+	r := require.New(t)
+	p := newTestParser()
+
+	runs := p.parse("a [color=item_bonus]b[/color] [b]c[/b][i]d[/i]", "font_regular", white)
+	r.Len(runs, 5)
+	r.Equal("a ", runs[0].text)
+	r.Equal(white, runs[0].color)
+	r.Equal("b", runs[1].text)
+	r.Equal(color.Construct(0, 255, 0), runs[1].color)
+	r.Equal(" ", runs[2].text)
+	r.Equal("c", runs[3].text)
+	r.Equal("font_bold", runs[3].font)
+
+	// 没有斜体字体，保持原字体
+	r.Equal("d", runs[4].text)
+	r.Equal("font_regular", runs[4].font)
+
+	runs = p.parse("[color=1,2,3]x[/color]", "font_regular", color.Construct(255, 255, 255, 100))
+	r.Equal(color.Construct(1, 2, 3, 100), runs[0].color)
+
+	runs = p.parse("press [key=accept] [icon=3]", "font_regular", white)
+	r.Len(runs, 2)
+	r.Equal("press Enter ", runs[0].text)
+	r.Equal(3, runs[1].icon)
+}
+
+func TestParseMarkupLiteral(t *testing.T) {
+	r := require.New(t)
+	p := newTestParser()
+
+	runs := p.parse("[[b] [foo] [key=nothing] [color=bad] [", "font_regular", white)
+	r.Len(runs, 1)
+	r.Equal("[b] [foo] [key=nothing] [color=bad] [", runs[0].text)
+
+	runs = p.parse("a\nb", "font_regular", white)
+	r.Len(runs, 3)
+	r.True(runs[1].newline)
+}
+
+func TestLayoutMarkup(t *testing.T) {
+	r := require.New(t)
+	p := newTestParser()
+
+	// 不换行
+	lines := layoutMarkup(p.parse("aa [b]bb[/b] cc", "font_regular", white), 0, testMeasure)
+	r.Len(lines, 1)
+	r.Equal(8, lines[0].width)
+	r.Len(lines[0].pieces, 3)
+	r.Equal("aa ", lines[0].pieces[0].text)
+	r.Equal(3, lines[0].pieces[1].x)
+	r.Equal("bb", lines[0].pieces[1].text)
+	r.Equal(" cc", lines[0].pieces[2].text)
+
+	// 按宽度换行，标记不计入宽度
+	lines = layoutMarkup(p.parse("aa [color=item_bonus]bb[/color] cc", "font_regular", white), 5, testMeasure)
+	r.Len(lines, 2)
+	r.Equal(5, lines[0].width)
+	r.Equal("cc", lines[1].pieces[0].text)
+
+	// 过长的单词按字符拆开
+	lines = layoutMarkup(p.parse("abcdefg", "font_regular", white), 3, testMeasure)
+	r.Len(lines, 3)
+	r.Equal("abc", lines[0].pieces[0].text)
+	r.Equal("g", lines[2].pieces[0].text)
+
+	// 图标和相邻文字算作一个词
+	lines = layoutMarkup(p.parse("aaa [icon=1]bb", "font_regular", white), 5, testMeasure)
+	r.Len(lines, 2)
+	r.Equal(1, lines[1].pieces[0].icon)
+	r.Equal(2, lines[1].pieces[1].x)
+
+	// 中日韩文字之间换行，句号不在行首
+	lines = layoutMarkup(p.parse("中文字符。测试", "font_regular", white), 4, testMeasure)
+	r.Len(lines, 2)
+	r.Equal("中文字", lines[0].pieces[0].text)
+	r.Equal("符。测试", lines[1].pieces[0].text)
+
+	lines = layoutMarkup(p.parse("a\n\nb", "font_regular", white), 0, testMeasure)
+	r.Len(lines, 3)
+	r.Len(lines[1].pieces, 0)
+
+	// 行首和连续的空格保留，只去掉换行处的行尾空格
+	lines = layoutMarkup(p.parse("  a   b", "font_regular", white), 0, testMeasure)
+	r.Len(lines, 1)
+	r.Equal("  a   b", lines[0].pieces[0].text)
+	r.Equal(7, lines[0].width)
+
+	lines = layoutMarkup(p.parse("a  [b]b[/b]\n   c", "font_regular", white), 0, testMeasure)
+	r.Len(lines, 2)
+	r.Equal("a  ", lines[0].pieces[0].text)
+	r.Equal(3, lines[0].pieces[1].x)
+	r.Equal("   c", lines[1].pieces[0].text)
+
+	lines = layoutMarkup(p.parse("aaaa   bbbb", "font_regular", white), 5, testMeasure)
+	r.Len(lines, 2)
+	r.Equal("aaaa", lines[0].pieces[0].text)
+	r.Equal("bbbb", lines[1].pieces[0].text)
+}
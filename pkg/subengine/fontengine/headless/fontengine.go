@@ -0,0 +1,113 @@
+package headless
+
+import (
+	"monster/pkg/common"
+	"monster/pkg/common/color"
+	"monster/pkg/common/point"
+	"monster/pkg/common/rect"
+	"monster/pkg/filesystem/logfile"
+	"monster/pkg/subengine/fontengine/base"
+	"unicode/utf8"
+)
+
+const (
+	glyphWidth = 8
+	fontHeight = 16
+)
+
+// 不加载字体文件的字体引擎，每个字符宽度固定，不输出文字
+// 用于离线检查mod数据 (modlint)，不依赖SDL_ttf
+type FontEngine struct {
+	base.FontEngine
+	font string
+}
+
+func NewFontEngine(settings common.Settings, mods common.ModManager) *FontEngine {
+	fe := &FontEngine{}
+	_ = (common.FontEngine)(fe)
+
+	logfile.LogInfo("Using Font Engine: HeadlessFontEngine")
+
+	fe.FontEngine = base.ConstructFontEngine(mods)
+
+	return fe
+}
+
+func (this *FontEngine) SetFont(font string) {
+	this.font = font
+}
+
+func (this *FontEngine) GetFont() string {
+	return this.font
+}
+
+func (this *FontEngine) HasFont(font string) bool {
+	return true
+}
+
+func (this *FontEngine) Render(renderDevice common.RenderDevice, text string, x, y, justify int, target common.Image, width int, color color.Color) error {
+	return nil
+}
+
+func (this *FontEngine) Position(text string, x, y, justify int) rect.Rect {
+	return this.FontEngine.Position(this, text, x, y, justify)
+}
+
+func (this *FontEngine) CalcSize(textWithNewlines string, width int) point.Point {
+	return this.FontEngine.CalcSize(this, textWithNewlines, width)
+}
+
+func (this *FontEngine) RenderShadowed(renderDevice common.RenderDevice, text string, x, y, justify int, target common.Image, width int, color color.Color) error {
+	return nil
+}
+
+func (this *FontEngine) CalcMarkupSize(text string, width int) point.Point {
+	return this.FontEngine.CalcMarkupSize(this, text, width)
+}
+
+func (this *FontEngine) RenderMarkup(renderDevice common.RenderDevice, text string, x, y, justify int, target common.Image, width int, color color.Color) error {
+	return nil
+}
+
+func (this *FontEngine) RenderMarkupShadowed(renderDevice common.RenderDevice, text string, x, y, justify int, target common.Image, width int, color color.Color) error {
+	return nil
+}
+
+func (this *FontEngine) CalcWidth(text string) int {
+	return utf8.RuneCountInString(text) * glyphWidth
+}
+
+func (this *FontEngine) GetLineHeight() int {
+	return fontHeight
+}
+
+func (this *FontEngine) GetFontHeight() int {
+	return fontHeight
+}
+
+func (this *FontEngine) RenderInternal(renderDevice common.RenderDevice, text string, x, y, justify int, target common.Image, color color.Color) error {
+	return nil
+}
+
+// 按固定字宽截断
+func (this *FontEngine) TrimTextToWidth(text string, width int, useEllipsis bool, leftPos int) string {
+	if width >= this.CalcWidth(text) {
+		return text
+	}
+
+	runes := ([]rune)(text)
+	if useEllipsis {
+		n := (width - this.CalcWidth("...")) / glyphWidth
+		if n < 0 {
+			n = 0
+		}
+		return (string)(runes[:n]) + "..."
+	}
+
+	n := width / glyphWidth
+	if leftPos+n > len(runes) {
+		return (string)(runes[len(runes)-n:])
+	}
+
+	return (string)(runes[leftPos : leftPos+n])
+}
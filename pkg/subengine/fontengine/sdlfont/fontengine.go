@@ -2,7 +2,6 @@ package sdlfont
 
 import (
 	"fmt"
-	"monster/pkg/allocs"
 	"monster/pkg/common"
 	"monster/pkg/common/color"
 	"monster/pkg/common/point"
@@ -53,7 +52,7 @@ func NewFontEngine(settings common.Settings, mods common.ModManager) *FontEngine
 			fe.fontStyles = append(fe.fontStyles, ConstructFontStyle())
 		} else if infile.GetSection() == "font_fallback" {
 			if infile.IsNewSection() {
-				logfile.LogError("FontEngine: Support for 'font_fallback' has been removed.")
+				logfile.LogError("FontEngine: Support for 'font_fallback' has been removed, use 'fallback' in a 'font' section.")
 			}
 
 			continue
@@ -73,32 +72,25 @@ func NewFontEngine(settings common.Settings, mods common.ModManager) *FontEngine
 			lang := ""
 			lang, strVal = parsing.PopFirstString(strVal, "")
 			if (lang == "default" && ptrStyle.Path == "") || lang == settings.Get("language").(string) {
-				blend := ""
-				ptrStyle.Path, strVal = parsing.PopFirstString(strVal, "")
-				ptrStyle.PtSize, strVal = parsing.PopFirstInt(strVal, "")
-				blend, strVal = parsing.PopFirstString(strVal, "")
-				ptrStyle.Blend = parsing.ToBool(blend)
-				loc, err := mods.Locate(settings, "fonts/"+ptrStyle.Path)
+				err := ptrStyle.Load(settings, mods, strVal)
 				if err != nil {
 					panic(err)
 				}
-
-				if ptrStyle.ttfont != nil {
-					//ptrStyle.ttfont.Close()
-					allocs.Delete(ptrStyle.ttfont)
-					ptrStyle.ttfont = nil
-				}
-
-				//ptrStyle.ttfont, err = ttf.OpenFont(loc, ptrStyle.PtSize)
-				ptrStyle.ttfont, err = allocs.TtfOpenFont(loc, ptrStyle.PtSize)
+			}
+		case "fallback":
+			// 语言,文件,字号,blend 缺字时按顺序使用
+			strVal := infile.Val()
+			lang := ""
+			lang, strVal = parsing.PopFirstString(strVal, "")
+			if lang == "default" || lang == settings.Get("language").(string) {
+				fallback := ConstructFontStyle()
+				err := fallback.Load(settings, mods, strVal)
 				if err != nil {
-					logfile.LogError("FontEngine: TTF_OpenFont: %s", ttf.GetError())
-					panic(err)
+					logfile.LogError("FontEngine: %s", err)
+					continue
 				}
 
-				lineSkip := ptrStyle.ttfont.LineSkip()
-				ptrStyle.LineHeight = lineSkip
-				ptrStyle.FontHeight = lineSkip
+				ptrStyle.fallbacks = append(ptrStyle.fallbacks, fallback)
 			}
 		}
 	}
@@ -116,10 +108,8 @@ func (this *FontEngine) Close() {
 
 	fmt.Println(len(this.fontStyles))
 
-	for _, fontStyle := range this.fontStyles {
-		//fmt.Println("close fs")
-		//fontStyle.ttfont.Close()
-		allocs.Delete(fontStyle.ttfont)
+	for i, _ := range this.fontStyles {
+		this.fontStyles[i].Close()
 	}
 
 	ttf.Quit()
@@ -147,6 +137,24 @@ func (this *FontEngine) SetFont(font string) {
 
 }
 
+func (this *FontEngine) GetFont() string {
+	if this.activeFont == nil {
+		return ""
+	}
+
+	return this.activeFont.Name
+}
+
+func (this *FontEngine) HasFont(font string) bool {
+	for _, fontStyle := range this.fontStyles {
+		if fontStyle.ttfont != nil && fontStyle.Name == font {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (this *FontEngine) IsActiveFontValid() bool {
 	return this.activeFont != nil && this.activeFont.ttfont != nil
 }
@@ -167,17 +175,78 @@ func (this *FontEngine) RenderShadowed(renderDevice common.RenderDevice, text st
 	return this.FontEngine.RenderShadowed(this, renderDevice, text, x, y, justify, target, width, color)
 }
 
+func (this *FontEngine) CalcMarkupSize(text string, width int) point.Point {
+	return this.FontEngine.CalcMarkupSize(this, text, width)
+}
+
+func (this *FontEngine) RenderMarkup(renderDevice common.RenderDevice, text string, x, y, justify int, target common.Image, width int, color color.Color) error {
+	return this.FontEngine.RenderMarkup(this, renderDevice, text, x, y, justify, target, width, color)
+}
+
+func (this *FontEngine) RenderMarkupShadowed(renderDevice common.RenderDevice, text string, x, y, justify int, target common.Image, width int, color color.Color) error {
+	return this.FontEngine.RenderMarkupShadowed(this, renderDevice, text, x, y, justify, target, width, color)
+}
+
+// 使用同一个字体的一段文字
+type fontRun struct {
+	style *FontStyle
+	text  string
+}
+
+// 按字体是否包含字符拆分，当前字体缺字时使用后备字体
+func (this *FontEngine) splitFontRuns(text string) []fontRun {
+	if len(this.activeFont.fallbacks) == 0 {
+		return []fontRun{{style: this.activeFont, text: text}}
+	}
+
+	var runs []fontRun
+	start := 0
+	current := this.activeFont
+	for pos, r := range text {
+		style := this.activeFont
+
+		// 空格和已有字体能显示的字符不拆开
+		if r == ' ' || current.HasRune(r) {
+			style = current
+		} else if !this.activeFont.HasRune(r) {
+			for i, _ := range this.activeFont.fallbacks {
+				if this.activeFont.fallbacks[i].HasRune(r) {
+					style = &(this.activeFont.fallbacks[i])
+					break
+				}
+			}
+		}
+
+		if style != current {
+			if pos > start {
+				runs = append(runs, fontRun{style: current, text: text[start:pos]})
+			}
+			start = pos
+			current = style
+		}
+	}
+
+	return append(runs, fontRun{style: current, text: text[start:]})
+}
+
 // 计算字符串的像素宽度
 func (this *FontEngine) CalcWidth(text string) int {
 	if !this.IsActiveFontValid() {
 		return 1
 	}
 
-	w, _, err := this.activeFont.ttfont.SizeUTF8(text)
-	if err != nil {
-		panic(err)
+	text = base.ShapeArabic(text)
+
+	width := 0
+	for _, run := range this.splitFontRuns(text) {
+		w, _, err := run.style.ttfont.SizeUTF8(run.text)
+		if err != nil {
+			panic(err)
+		}
+		width += w
 	}
-	return w
+
+	return width
 }
 
 func (this *FontEngine) GetLineHeight() int {
@@ -202,49 +271,66 @@ func (this *FontEngine) RenderInternal(renderDevice common.RenderDevice, text st
 		return nil
 	}
 
+	// 连写并按显示顺序排列
+	text = base.VisualOrder(base.ShapeArabic(text))
+
 	// 计算字符串渲染的起始位置
 	destRect := this.Position(text, x, y, justify)
 
+	for _, run := range this.splitFontRuns(text) {
+		w, err := this.renderRun(renderDevice, run, destRect, target, color)
+		if err != nil {
+			return err
+		}
+
+		destRect.X += w
+	}
+
+	return nil
+}
+
+// 渲染一段文字，返回宽度
+func (this *FontEngine) renderRun(renderDevice common.RenderDevice, run fontRun, destRect rect.Rect, target common.Image, color color.Color) (int, error) {
 	// 以字符串创建图片
-	graphics, err := renderDevice.RenderTextToImage(this.activeFont, text, color, this.activeFont.Blend)
+	graphics, err := renderDevice.RenderTextToImage(run.style, run.text, color, run.style.Blend)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer graphics.UnRef()
 
+	var clip rect.Rect
+	clip.W, err = graphics.GetWidth()
+	if err != nil {
+		return 0, err
+	}
+	clip.H, err = graphics.GetHeight()
+	if err != nil {
+		return 0, err
+	}
+
 	// 把上面的图片渲染到目标图片上
 	if target != nil {
-		var clip rect.Rect
-		clip.W, err = graphics.GetWidth()
-		if err != nil {
-			return err
-		}
-		clip.H, err = graphics.GetHeight()
-		if err != nil {
-			return err
-		}
-
 		// 把字符串图片渲染到target，以图片大小作为源
-		destRect, err = renderDevice.RenderToImage(graphics, clip, target, destRect)
+		_, err = renderDevice.RenderToImage(graphics, clip, target, destRect)
 		if err != nil {
-			return err
+			return 0, err
 		}
 
 	} else {
 		tempSprite, err := graphics.CreateSprite()
 		if err != nil {
-			return err
+			return 0, err
 		}
 		defer tempSprite.Close()
 
 		tempSprite.SetDestFromRect(destRect)
 		err = renderDevice.Render(tempSprite)
 		if err != nil {
-			return err
+			return 0, err
 		}
 	}
 
-	return nil
+	return clip.W, nil
 }
 
 // leftPos作为 utf-8的字符位置, 0为全部
@@ -1,14 +1,25 @@
 package sdlfont
 
 import (
+	"monster/pkg/allocs"
+	"monster/pkg/common"
+	"monster/pkg/filesystem/logfile"
 	"monster/pkg/subengine/fontengine/base"
+	"monster/pkg/utils/parsing"
 
 	"github.com/veandco/go-sdl2/ttf"
 )
 
+// 打开过的字体数，用来分配id
+var fontCount uint64
+
 type FontStyle struct {
 	base.FontStyle
-	ttfont *ttf.Font
+	ttfont    *ttf.Font
+	id        uint64      // 每次打开字体都不同，渲染缓存用它区分字体
+	data      []byte      // 字体文件内容 ttfont从这里读取
+	cmap      base.Cmap   // 字体包含的字符，为空表示未知
+	fallbacks []FontStyle // 缺字时依次尝试的字体
 }
 
 func ConstructFontStyle() FontStyle {
@@ -21,3 +32,74 @@ func ConstructFontStyle() FontStyle {
 func (this *FontStyle) Ttfont() interface{} {
 	return this.ttfont
 }
+
+// 释放的字体地址可能被新字体重用，缓存不能按指针区分
+func (this *FontStyle) GetId() uint64 {
+	return this.id
+}
+
+// 读取 文件,字号,blend
+func (this *FontStyle) Load(settings common.Settings, mods common.ModManager, strVal string) error {
+	blend := ""
+	this.Path, strVal = parsing.PopFirstString(strVal, "")
+	this.PtSize, strVal = parsing.PopFirstInt(strVal, "")
+	blend, strVal = parsing.PopFirstString(strVal, "")
+	this.Blend = parsing.ToBool(blend)
+	loc, err := mods.Locate(settings, "fonts/"+this.Path)
+	if err != nil {
+		return err
+	}
+
+	if this.ttfont != nil {
+		//this.ttfont.Close()
+		allocs.Delete(this.ttfont)
+		this.ttfont = nil
+	}
+
+	this.data, err = mods.ReadFile(loc)
+	if err != nil {
+		return err
+	}
+
+	//this.ttfont, err = ttf.OpenFont(loc, this.PtSize)
+	this.ttfont, err = allocs.TtfOpenFontRW(this.data, this.PtSize)
+	if err != nil {
+		logfile.LogError("FontEngine: TTF_OpenFont: %s", ttf.GetError())
+		return err
+	}
+
+	// 字形缓存按advance拼接文字，go-sdl2没有提供字距对的接口，
+	// 关闭字距调整让CalcWidth、换行和光标位置与拼出的图片宽度一致
+	this.ttfont.SetKerning(false)
+
+	fontCount++
+	this.id = fontCount
+
+	lineSkip := this.ttfont.LineSkip()
+	this.LineHeight = lineSkip
+	this.FontHeight = lineSkip
+
+	this.cmap, err = base.ParseCmap(this.data)
+	if err != nil {
+		logfile.LogError("%s (%s)", err, this.Path)
+	}
+
+	return nil
+}
+
+func (this *FontStyle) Close() {
+	for i, _ := range this.fallbacks {
+		this.fallbacks[i].Close()
+	}
+	this.fallbacks = nil
+
+	if this.ttfont != nil {
+		allocs.Delete(this.ttfont)
+		this.ttfont = nil
+	}
+}
+
+// 是否包含字符，不知道时当作包含
+func (this *FontStyle) HasRune(r rune) bool {
+	return this.cmap.IsEmpty() || this.cmap.HasRune(r)
+}
@@ -107,7 +107,7 @@ func (this *IconManager) loadIconSet(settings common.Settings, eset common.Engin
 		return iset, false, nil
 	}
 
-	graphics, err := render.LoadImage(settings, mods, filename)
+	graphics, err := render.LoadAtlasImage(settings, mods, filename)
 	if err != nil {
 		return iset, false, err
 	}
@@ -27,6 +27,9 @@ type InputState struct {
 	done            bool
 	mouse           point.Point
 	inKeys          string
+	textEdit        []int  // 本帧的编辑命令
+	composition     string // 输入法正在组合的文字
+	compositionPos  int
 	lastKey         int
 	lastButton      int
 	scrollUp        bool
@@ -58,7 +61,7 @@ func ConstructInputState() InputState {
 		fileVersionMin: version.Construct(1, 9, 20),
 	}
 
-	for i := 0; i < 31; i++ {
+	for i := 0; i < inputstate.KEY_COUNT; i++ {
 		inpt.binding[i] = 0
 		inpt.pressing[i] = false
 		inpt.unPress[i] = false
@@ -256,6 +259,8 @@ func (this *InputState) LoadKeyBindings(impl common.InputState, plat common.Plat
 			cursor = inputstate.ACTIONBAR_USE
 		case "developer_menu":
 			cursor = inputstate.DEVELOPER_MENU
+		case "screenshot":
+			cursor = inputstate.SCREENSHOT
 		}
 
 		if cursor != -1 {
@@ -278,11 +283,12 @@ func (this *InputState) Handle() {
 		return
 	}
 
-	if len(this.binding) != 31 {
-		panic("binding is not 31")
+	if len(this.binding) != inputstate.KEY_COUNT {
+		panic("binding count is not KEY_COUNT")
 	}
 
 	this.inKeys = ""
+	this.textEdit = this.textEdit[:0]
 
 	for key := 0; key < len(this.binding); key++ {
 		if this.unPress[key] == true {
@@ -359,6 +365,7 @@ func (this *InputState) SaveKeyBindings(settings common.Settings, eset common.En
 	f.WriteString("actionbar_forward=" + strconv.Itoa(this.binding[inputstate.ACTIONBAR_FORWARD]) + "\n")
 	f.WriteString("actionbar_use=" + strconv.Itoa(this.binding[inputstate.ACTIONBAR_USE]) + "\n")
 	f.WriteString("developer_menu=" + strconv.Itoa(this.binding[inputstate.DEVELOPER_MENU]) + "\n")
+	f.WriteString("screenshot=" + strconv.Itoa(this.binding[inputstate.SCREENSHOT]) + "\n")
 
 	return nil
 }
@@ -444,6 +451,7 @@ func (this *InputState) SetKeybindNames(msg common.MessageEngine) {
 	this.bindingName[inputstate.ACTIONBAR_FORWARD] = msg.Get("ActionBar Right")
 	this.bindingName[inputstate.ACTIONBAR_USE] = msg.Get("ActionBar Use")
 	this.bindingName[inputstate.DEVELOPER_MENU] = msg.Get("Developer Menu")
+	this.bindingName[inputstate.SCREENSHOT] = msg.Get("Screenshot")
 
 	this.mouseButton[0] = msg.Get("Left Mouse")
 	this.mouseButton[1] = msg.Get("Middle Mouse")
@@ -478,12 +486,7 @@ func (this *InputState) ScaleMouse(settings common.Settings, x, y uint) point.Po
 		return point.Construct((int)(x), (int)(y))
 	}
 
-	scaledMouse := point.Construct()
-	offsetY := (int)((((float32)(settings.Get("resolution_h").(int)) - (float32)(settings.GetViewH())/settings.GetViewScaling()) / 2) * settings.GetViewScaling())
-	scaledMouse.X = (int)(float32(x) * settings.GetViewScaling())
-	scaledMouse.Y = (int)((float32)(y)*settings.GetViewScaling()) - offsetY
-
-	return scaledMouse
+	return utils.WindowToScreen(settings, (int)(x), (int)(y))
 }
 
 // 窗口大小发生变化
@@ -553,6 +556,24 @@ func (this *InputState) GetInKeys() string {
 	return this.inKeys
 }
 
+func (this *InputState) AddTextEdit(cmd int) {
+	this.textEdit = append(this.textEdit, cmd)
+}
+
+func (this *InputState) GetTextEdit() []int {
+	return this.textEdit
+}
+
+func (this *InputState) SetComposition(text string, cursor int) {
+	this.composition = text
+	this.compositionPos = cursor
+}
+
+// 输入法组合中的文字和光标位置(rune)
+func (this *InputState) GetComposition() (string, int) {
+	return this.composition, this.compositionPos
+}
+
 func (this *InputState) GetMouse() point.Point {
 	return this.mouse
 }
@@ -625,6 +646,78 @@ func (this *InputState) GetMouseButton(key int) string {
 	return this.mouseButton[key]
 }
 
+// 按键名称转换成按键，用于文本标记的 [key=]
+func (this *InputState) GetBindingFromName(name string) int {
+	switch name {
+	case "cancel":
+		return inputstate.CANCEL
+	case "accept":
+		return inputstate.ACCEPT
+	case "up":
+		return inputstate.UP
+	case "down":
+		return inputstate.DOWN
+	case "left":
+		return inputstate.LEFT
+	case "right":
+		return inputstate.RIGHT
+	case "bar1":
+		return inputstate.BAR_1
+	case "bar2":
+		return inputstate.BAR_2
+	case "bar3":
+		return inputstate.BAR_3
+	case "bar4":
+		return inputstate.BAR_4
+	case "bar5":
+		return inputstate.BAR_5
+	case "bar6":
+		return inputstate.BAR_6
+	case "bar7":
+		return inputstate.BAR_7
+	case "bar8":
+		return inputstate.BAR_8
+	case "bar9":
+		return inputstate.BAR_9
+	case "bar0":
+		return inputstate.BAR_0
+	case "main1":
+		return inputstate.MAIN1
+	case "main2":
+		return inputstate.MAIN2
+	case "character":
+		return inputstate.CHARACTER
+	case "inventory":
+		return inputstate.INVENTORY
+	case "powers":
+		return inputstate.POWERS
+	case "log":
+		return inputstate.LOG
+	case "ctrl":
+		return inputstate.CTRL
+	case "shift":
+		return inputstate.SHIFT
+	case "alt":
+		return inputstate.ALT
+	case "delete":
+		return inputstate.DEL
+	case "actionbar":
+		return inputstate.ACTIONBAR
+	case "actionbar_back":
+		return inputstate.ACTIONBAR_BACK
+	case "actionbar_forward":
+		return inputstate.ACTIONBAR_FORWARD
+	case "actionbar_use":
+		return inputstate.ACTIONBAR_USE
+	case "developer_menu":
+		return inputstate.DEVELOPER_MENU
+	case "screenshot":
+		return inputstate.SCREENSHOT
+	}
+
+	return -1
+}
+
 func (this *InputState) GetBindingName(key int) string {
 	return this.bindingName[key]
 }
@@ -6,6 +6,7 @@ import (
 	"monster/pkg/common"
 	"monster/pkg/common/define/inputstate"
 	input "monster/pkg/common/define/inputstate"
+	"monster/pkg/common/rect"
 	"monster/pkg/common/timer"
 	"monster/pkg/subengine/inputstate/base"
 
@@ -101,6 +102,7 @@ func (this *InputState) DefaultQwertyKeyBindings(platform common.Platform) {
 	this.SetBinding(input.ACTIONBAR_USE, sdl.K_n)
 
 	this.SetBinding(input.DEVELOPER_MENU, sdl.K_F5)
+	this.SetBinding(input.SCREENSHOT, sdl.K_F12)
 
 	// 转化 SDL_Keycode 到 SDL_Scancode
 	keyCount := this.GetKeyCount()
@@ -168,6 +170,10 @@ func (this *InputState) Handle(modules common.Modules) error {
 		case sdl.TEXTINPUT:
 			event := rawEvent.(*sdl.TextInputEvent)
 			this.SetInKeys(this.GetInKeys() + event.GetText())
+			this.SetComposition("", 0) // 组合完成
+		case sdl.TEXTEDITING:
+			event := rawEvent.(*sdl.TextEditingEvent)
+			this.SetComposition(event.GetText(), (int)(event.Start))
 		case sdl.MOUSEMOTION:
 			event := rawEvent.(*sdl.MouseMotionEvent)
 			this.SetMouse(this.ScaleMouse(settings, (uint)(event.X), (uint)(event.Y)))
@@ -223,7 +229,25 @@ func (this *InputState) Handle(modules common.Modules) error {
 			}
 
 		case sdl.KEYDOWN:
+			event := rawEvent.(*sdl.KeyboardEvent)
+			if this.textInput {
+				this.handleTextEditKey(event.Keysym)
+
+				// 输入文字时可打印的按键不触发绑定
+				if isPrintableKey(event.Keysym.Sym) {
+					break
+				}
+			}
+
+			if key, ok := this.GetCode2Binding((int)(event.Keysym.Scancode)); ok {
+				this.SetPressing(key, true)
+				this.SetUnPress(key, false)
+			}
 		case sdl.KEYUP:
+			event := rawEvent.(*sdl.KeyboardEvent)
+			if key, ok := this.GetCode2Binding((int)(event.Keysym.Scancode)); ok {
+				this.SetUnPress(key, true)
+			}
 		case sdl.QUIT:
 			this.SetDone(true)
 			keyCount := this.GetKeyCount()
@@ -480,7 +504,7 @@ func (this *InputState) UsingMouse(settings common.Settings) bool {
 }
 
 func (this *InputState) StartTextInput() {
-	if this.textInput {
+	if !this.textInput {
 		sdl.StartTextInput()
 		this.textInput = true
 	}
@@ -490,7 +514,104 @@ func (this *InputState) StopTextInput() {
 	if this.textInput {
 		sdl.StopTextInput()
 		this.textInput = false
+		this.SetComposition("", 0)
+	}
+}
+
+// 输入法候选框的位置，view坐标转换到窗口坐标
+func (this *InputState) SetTextInputRect(settings common.Settings, r rect.Rect) {
+	offset := settings.GetViewOffset()
+	scaling := settings.GetViewScaling()
+	if scaling <= 0 {
+		scaling = 1
 	}
+
+	sdl.SetTextInputRect(&sdl.Rect{
+		X: (int32)((float32)(r.X)/scaling) + (int32)(offset.X),
+		Y: (int32)((float32)(r.Y)/scaling) + (int32)(offset.Y),
+		W: (int32)((float32)(r.W) / scaling),
+		H: (int32)((float32)(r.H) / scaling),
+	})
+}
+
+func (this *InputState) GetClipboardText() string {
+	text, err := sdl.GetClipboardText()
+	if err != nil {
+		return ""
+	}
+
+	return text
+}
+
+func (this *InputState) SetClipboardText(text string) {
+	sdl.SetClipboardText(text)
+}
+
+// 已连接的手柄数量
+func (this *InputState) GetJoystickCount() int {
+	n := sdl.NumJoysticks()
+	if n < 0 {
+		return 0
+	}
+
+	return n
+}
+
+// 编辑按键转换成命令
+func (this *InputState) handleTextEditKey(keysym sdl.Keysym) {
+	ctrl := keysym.Mod&sdl.KMOD_CTRL != 0
+	shift := keysym.Mod&sdl.KMOD_SHIFT != 0
+
+	switch keysym.Sym {
+	case sdl.K_LEFT:
+		if shift {
+			this.AddTextEdit(input.TEXTEDIT_SELECT_LEFT)
+		} else {
+			this.AddTextEdit(input.TEXTEDIT_LEFT)
+		}
+	case sdl.K_RIGHT:
+		if shift {
+			this.AddTextEdit(input.TEXTEDIT_SELECT_RIGHT)
+		} else {
+			this.AddTextEdit(input.TEXTEDIT_RIGHT)
+		}
+	case sdl.K_HOME:
+		if shift {
+			this.AddTextEdit(input.TEXTEDIT_SELECT_HOME)
+		} else {
+			this.AddTextEdit(input.TEXTEDIT_HOME)
+		}
+	case sdl.K_END:
+		if shift {
+			this.AddTextEdit(input.TEXTEDIT_SELECT_END)
+		} else {
+			this.AddTextEdit(input.TEXTEDIT_END)
+		}
+	case sdl.K_BACKSPACE:
+		this.AddTextEdit(input.TEXTEDIT_BACKSPACE)
+	case sdl.K_DELETE:
+		this.AddTextEdit(input.TEXTEDIT_DELETE)
+	case sdl.K_a:
+		if ctrl {
+			this.AddTextEdit(input.TEXTEDIT_SELECT_ALL)
+		}
+	case sdl.K_c:
+		if ctrl {
+			this.AddTextEdit(input.TEXTEDIT_COPY)
+		}
+	case sdl.K_x:
+		if ctrl {
+			this.AddTextEdit(input.TEXTEDIT_CUT)
+		}
+	case sdl.K_v:
+		if ctrl {
+			this.AddTextEdit(input.TEXTEDIT_PASTE)
+		}
+	}
+}
+
+func isPrintableKey(sym sdl.Keycode) bool {
+	return sym >= sdl.K_SPACE && sym < sdl.K_DELETE
 }
 
 func (this *InputState) SetKeybind(msg common.MessageEngine, key, bindingButton int) string {
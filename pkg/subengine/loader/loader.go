@@ -0,0 +1,201 @@
+// 异步加载
+//
+// 工作协程里解析数据文件和解码图片，主线程里只做纹理上传等SDL相关的操作。
+// 任务的Work在工作协程执行，Finish和OnDone在主线程的Logic里执行。
+package loader
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+type task struct {
+	work   func() error
+	finish func() error
+	err    error
+}
+
+type Loader struct {
+	jobs     chan *task
+	finished chan *task
+	wg       sync.WaitGroup // 投递中的任务
+	workers  sync.WaitGroup
+	counter  int32 // 未完成的任务数
+	total    int32 // 总任务数
+	done     int32 // 已完成的任务数
+	onDone   []func() error
+	err      error
+	closed   bool
+}
+
+func New(workers int) *Loader {
+	l := &Loader{}
+	l.init(workers)
+
+	return l
+}
+
+func (this *Loader) init(workers int) *Loader {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	this.jobs = make(chan *task)
+	this.finished = make(chan *task, 64)
+
+	this.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go this.worker()
+	}
+
+	return this
+}
+
+func (this *Loader) worker() {
+	defer this.workers.Done()
+
+	for t := range this.jobs {
+		this.run(t)
+		this.finished <- t
+	}
+}
+
+// 任务里的panic转成错误，交给主线程处理，不让整个进程崩溃
+func (this *Loader) run(t *task) {
+	defer func() {
+		if r := recover(); r != nil {
+			if err, ok := r.(error); ok {
+				t.err = fmt.Errorf("Loader: task panicked: %w", err)
+			} else {
+				t.err = fmt.Errorf("Loader: task panicked: %v", r)
+			}
+		}
+	}()
+
+	if t.work != nil {
+		t.err = t.work()
+	}
+}
+
+// 添加任务，可以在工作协程里调用
+func (this *Loader) Add(work, finish func() error) {
+	atomic.AddInt32(&this.counter, 1)
+	atomic.AddInt32(&this.total, 1)
+
+	t := &task{work: work, finish: finish}
+
+	this.wg.Add(1)
+	go func() {
+		defer this.wg.Done()
+		this.jobs <- t
+	}()
+}
+
+// 所有任务完成后在主线程执行，按添加的顺序
+func (this *Loader) OnDone(f func() error) {
+	this.onDone = append(this.onDone, f)
+}
+
+// 主线程每帧调用，返回是否全部完成
+func (this *Loader) Logic() (bool, error) {
+	for {
+		select {
+		case t := <-this.finished:
+			this.finish(t)
+			continue
+		default:
+		}
+
+		break
+	}
+
+	if this.GetCounter() > 0 {
+		return false, nil
+	}
+
+	return true, this.runOnDone()
+}
+
+// 主线程处理完成的任务
+func (this *Loader) finish(t *task) {
+	if t.err == nil && t.finish != nil {
+		t.err = t.finish()
+	}
+
+	if t.err != nil && this.err == nil {
+		this.err = t.err
+	}
+
+	atomic.AddInt32(&this.done, 1)
+	atomic.AddInt32(&this.counter, -1)
+}
+
+func (this *Loader) runOnDone() error {
+	if this.err != nil {
+		return this.err
+	}
+
+	for len(this.onDone) > 0 {
+		f := this.onDone[0]
+		this.onDone = this.onDone[1:]
+
+		err := f()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 阻塞到全部完成，在主线程调用
+func (this *Loader) Wait() error {
+	for this.GetCounter() > 0 {
+		this.finish(<-this.finished)
+	}
+
+	return this.runOnDone()
+}
+
+// 未完成的任务数
+func (this *Loader) GetCounter() int {
+	return int(atomic.LoadInt32(&this.counter))
+}
+
+// 添加过的任务总数，包括已完成的
+func (this *Loader) GetTotal() int {
+	return int(atomic.LoadInt32(&this.total))
+}
+
+// 0~1
+func (this *Loader) GetProgress() float32 {
+	total := atomic.LoadInt32(&this.total)
+	if total == 0 {
+		return 1
+	}
+
+	return float32(atomic.LoadInt32(&this.done)) / float32(total)
+}
+
+// 关闭工作协程，未完成的任务结果直接丢弃
+func (this *Loader) Close() {
+	if this.closed {
+		return
+	}
+
+	this.closed = true
+
+	go func() {
+		this.wg.Wait()
+		close(this.jobs)
+		this.workers.Wait()
+		close(this.finished)
+	}()
+
+	go func() {
+		for range this.finished {
+		}
+	}()
+}
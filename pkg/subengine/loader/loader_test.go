@@ -0,0 +1,98 @@
+package loader
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_loader(t *testing.T) {
+	r := require.New(t)
+
+	l := New(4)
+	defer l.Close()
+
+	var worked int32
+	finished := 0
+	order := []string{}
+
+	l.Add(func() error {
+		atomic.AddInt32(&worked, 1)
+
+		// 工作协程里追加任务
+		for i := 0; i < 10; i++ {
+			l.Add(func() error {
+				atomic.AddInt32(&worked, 1)
+				return nil
+			}, func() error {
+				finished++
+				return nil
+			})
+		}
+
+		return nil
+	}, func() error {
+		finished++
+		return nil
+	})
+
+	l.OnDone(func() error {
+		order = append(order, "done")
+		return nil
+	})
+
+	r.NoError(l.Wait())
+	r.Equal(int32(11), atomic.LoadInt32(&worked))
+	r.Equal(11, finished)
+	r.Equal([]string{"done"}, order)
+	r.Equal(0, l.GetCounter())
+	r.Equal(float32(1), l.GetProgress())
+}
+
+func Test_loader_error(t *testing.T) {
+	r := require.New(t)
+
+	l := New(2)
+	defer l.Close()
+
+	called := false
+	l.Add(func() error {
+		return errors.New("bad")
+	}, func() error {
+		called = true
+		return nil
+	})
+
+	l.OnDone(func() error {
+		called = true
+		return nil
+	})
+
+	r.EqualError(l.Wait(), "bad")
+	r.False(called)
+}
+
+func Test_loader_panic(t *testing.T) {
+	r := require.New(t)
+
+	l := New(2)
+	defer l.Close()
+
+	l.Add(func() error {
+		var m map[string]int
+		m["a"] = 1
+		return nil
+	}, nil)
+
+	l.Add(func() error {
+		panic("bad data")
+	}, nil)
+
+	err := l.Wait()
+	r.Error(err)
+	r.Contains(err.Error(), "Loader: task panicked")
+	r.Equal(0, l.GetCounter())
+	r.Equal(2, l.GetTotal())
+}
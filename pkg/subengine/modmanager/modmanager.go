@@ -9,10 +9,14 @@ import (
 	"monster/pkg/common/define/modmanager"
 	"monster/pkg/config/version"
 	"monster/pkg/filesystem/logfile"
+	"monster/pkg/filesystem/vfs"
 	"monster/pkg/utils"
 	"monster/pkg/utils/parsing"
 	"monster/pkg/utils/tools"
 	"os"
+	"path"
+	"strings"
+	"sync"
 )
 
 // ==================== mod ===================
@@ -112,14 +116,22 @@ type ModManager struct {
 	modDirs     []string     // dirs in modPath/mods/  mods文件里的全部目录
 	modList     []common.Mod // 已经加载的mod
 	cmdLineMods []string
+	dependsErrs map[string][]string // mod名 -> 最近一次ApplyDepends的失败原因
+	vfs         *vfs.VFS
+	modFS       map[string]*vfs.ModFS // modPath/mods/name -> 文件系统, 不存在为nil
+	locMu       sync.RWMutex          // 加载协程里会并发Locate
+	fsMu        sync.Mutex
 }
 
 func New(platform common.Platform, settings common.Settings, cmdLineMods []string) *ModManager {
 	mm := &ModManager{
-		locCache: map[string]string{},
-		modPaths: []string{},
-		modDirs:  []string{},
-		modList:  []common.Mod{},
+		locCache:    map[string]string{},
+		modPaths:    []string{},
+		modDirs:     []string{},
+		modList:     []common.Mod{},
+		dependsErrs: map[string][]string{},
+		vfs:         vfs.New(),
+		modFS:       map[string]*vfs.ModFS{},
 	}
 
 	mm.init(platform, settings, cmdLineMods)
@@ -136,11 +148,11 @@ func (this *ModManager) init(platform common.Platform, settings common.Settings,
 	this.setPaths(platform, settings)
 
 	modDirsOther := []string{}
-	modDirsOther, err = utils.GetDirList(settings.GetPathData()+"mods", modDirsOther)
+	modDirsOther, err = getModDirs(settings.GetPathData()+"mods", modDirsOther)
 	if err != nil {
 		panic(err)
 	}
-	modDirsOther, err = utils.GetDirList(settings.GetPathUser()+"mods", modDirsOther)
+	modDirsOther, err = getModDirs(settings.GetPathUser()+"mods", modDirsOther)
 	if err != nil {
 		panic(err)
 	}
@@ -176,33 +188,103 @@ func (this *ModManager) init(platform common.Platform, settings common.Settings,
 	return this
 }
 
+// mods目录下的全部mod: 目录和zip包
+func getModDirs(dir string, dirs []string) ([]string, error) {
+	dirs, err := utils.GetDirList(dir, dirs)
+	if err != nil {
+		return nil, err
+	}
+
+	zips, err := utils.GetFileList(os.DirFS(dir), ".", vfs.ZIP_EXT, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range zips {
+		dirs = append(dirs, strings.TrimSuffix(name, vfs.ZIP_EXT))
+	}
+
+	return dirs, nil
+}
+
+// 获取某个路径下mod的文件系统，同名时目录优先于zip包，不存在返回nil
+func (this *ModManager) getModFS(modPath, name string) (*vfs.ModFS, error) {
+	this.fsMu.Lock()
+	defer this.fsMu.Unlock()
+
+	root := modPath + "mods/" + name
+	if m, ok := this.modFS[root]; ok {
+		return m, nil
+	}
+
+	var m *vfs.ModFS
+
+	isDir, err := utils.IsDirectory(root)
+	if err != nil {
+		return nil, err
+	}
+
+	if isDir {
+		m = vfs.OpenDir(root)
+	} else {
+		isZip, err := utils.FileExists(root + vfs.ZIP_EXT)
+		if err != nil {
+			return nil, err
+		}
+
+		if isZip {
+			m, err = vfs.OpenZip(root + vfs.ZIP_EXT)
+			if err != nil {
+				logfile.LogError("ModManager: Could not open \"%s\". %s", root+vfs.ZIP_EXT, err)
+				return nil, err
+			}
+		}
+	}
+
+	if m != nil {
+		this.vfs.Mount(m)
+	}
+
+	this.modFS[root] = m
+	return m, nil
+}
+
 // 列出已加载mod下的全部指定文件 (mod里结构为目录和txt文件)
-func (this *ModManager) List(path string) ([]string, error) {
+func (this *ModManager) List(filename string) ([]string, error) {
 	var ret []string
 
+	filename = path.Clean(filename)
+
 	// [default, ...]
 	for _, mod := range this.modList {
 
 		// modpaths: [CustomPathData, PathUser, PathData]
 		// 反向遍历 从  PathData 开始找到对应的文件
 		for j := len(this.modPaths); j > 0; j-- {
-			testPath := this.modPaths[j-1] + "mods/" + mod.GetName() + "/" + path
+			m, err := this.getModFS(this.modPaths[j-1], mod.GetName())
+			if err != nil {
+				return nil, err
+			}
+
+			if m == nil {
+				continue
+			}
 
-			if isExist, err := utils.PathExists(testPath); err != nil {
+			if isDir, err := m.IsDirectory(filename); err != nil {
 				return nil, err
-			} else {
-				if isExist {
-					if isDir, err := utils.IsDirectory(testPath); err != nil {
-						return nil, err
-					} else if isDir {
-						ret, err = utils.GetFileList(testPath, "txt", ret)
-						if err != nil {
-							return nil, err
-						}
-					} else {
-						ret = append(ret, testPath)
-					}
+			} else if isDir {
+				files, err := utils.GetFileList(m, filename, "txt", nil)
+				if err != nil {
+					return nil, err
 				}
+
+				for _, file := range files {
+					ret = append(ret, m.Path(file))
+				}
+			} else if isFile, err := m.FileExists(filename); err != nil {
+				return nil, err
+			} else if isFile {
+				ret = append(ret, m.Path(filename))
 			}
 		}
 	}
@@ -244,8 +326,16 @@ func (this *ModManager) LoadMod(name string) (common.Mod, error) {
 
 	for _, modPath := range this.modPaths {
 
-		path := modPath + "mods/" + name + "/settings.txt"
-		f, err := os.Open(path)
+		m, err := this.getModFS(modPath, name)
+		if err != nil {
+			return mod, err
+		}
+
+		if m == nil {
+			continue
+		}
+
+		f, err := m.Open("settings.txt")
 		if err != nil && utils.IsNotExist(err) {
 			continue
 		} else if err != nil {
@@ -426,17 +516,32 @@ func (this *ModManager) loadModList(settings common.Settings) error {
 
 // 返回mod范围内的某个文件，不存在则返回不存在错误
 func (this *ModManager) Locate(settings common.Settings, filename string) (string, error) {
-	if loc, ok := this.locCache[filename]; ok {
+	this.locMu.RLock()
+	loc, ok := this.locCache[filename]
+	this.locMu.RUnlock()
+	if ok {
 		return loc, nil
 	}
 
+	name := path.Clean(filename)
 	for i := len(this.modList); i > 0; i-- {
-		for _, path := range this.modPaths {
-			testPath := path + "mods/" + this.modList[i-1].GetName() + "/" + filename
-			if ok, err := utils.FileExists(testPath); err != nil {
+		for _, modPath := range this.modPaths {
+			m, err := this.getModFS(modPath, this.modList[i-1].GetName())
+			if err != nil {
+				return "", err
+			}
+
+			if m == nil {
+				continue
+			}
+
+			if ok, err := m.FileExists(name); err != nil {
 				return "", err
 			} else if ok {
+				testPath := m.Path(name)
+				this.locMu.Lock()
 				this.locCache[filename] = testPath
+				this.locMu.Unlock()
 				return testPath, nil
 			}
 		}
@@ -452,8 +557,42 @@ func (this *ModManager) Locate(settings common.Settings, filename string) (strin
 	return "", fs.ErrNotExist
 }
 
+// 打开Locate或List返回的文件，zip包内的文件也一样
+func (this *ModManager) Open(filename string) (fs.File, error) {
+	return this.vfs.Open(filename)
+}
+
+func (this *ModManager) ReadFile(filename string) ([]byte, error) {
+	return this.vfs.ReadFile(filename)
+}
+
+// 关闭打开的zip包
+func (this *ModManager) Close() {
+	this.vfs.Close()
+	this.modFS = map[string]*vfs.ModFS{}
+	this.locCache = map[string]string{}
+}
+
 // 加载mod的依赖mod
 func (this *ModManager) ApplyDepends() error {
+	this.dependsErrs = map[string][]string{}
+
+	return this.applyDepends()
+}
+
+// 记录mod依赖的失败原因
+func (this *ModManager) dependsError(name, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	logfile.LogError("%s", msg)
+	this.dependsErrs[name] = append(this.dependsErrs[name], msg)
+}
+
+// 获得mod最近一次依赖检查的失败原因
+func (this *ModManager) GetDependsErrors(name string) []string {
+	return this.dependsErrs[name]
+}
+
+func (this *ModManager) applyDepends() error {
 	var newMods []common.Mod
 	newModsMap := map[string]struct{}{}
 	var game string
@@ -466,13 +605,13 @@ func (this *ModManager) ApplyDepends() error {
 	for _, mod := range this.modList {
 		// skip the mod if the game doesn't match
 		if game != modmanager.FALLBACK_GAME && mod.GetGame() != modmanager.FALLBACK_GAME && mod.GetGame() != game && mod.GetName() != modmanager.FALLBACK_MOD {
-			logfile.LogError("ModManager: Tried to enable \"%s\", but failed. Game does not match \"%s\".", mod.GetName(), game)
+			this.dependsError(mod.GetName(), "ModManager: Tried to enable \"%s\", but failed. Game does not match \"%s\".", mod.GetName(), game)
 			continue
 		}
 
 		// skip the mod if it's incompatible with this engine version
 		if version.Compare(mod.GetEngineMinVersion(), version.ENGINE) > 0 || version.Compare(version.ENGINE, mod.GetEngineMaxVersion()) > 0 {
-			logfile.LogError("ModManager: Tried to enable \"%s\", but failed. Not compatible with engine version %s.", mod.GetName(), version.ENGINE.GetString())
+			this.dependsError(mod.GetName(), "ModManager: Tried to enable \"%s\", but failed. Not compatible with engine version %s.", mod.GetName(), version.ENGINE.GetString())
 			continue
 		}
 
@@ -499,14 +638,14 @@ func (this *ModManager) ApplyDepends() error {
 					}
 
 					if game != modmanager.FALLBACK_GAME && newDepend.GetGame() != modmanager.FALLBACK_GAME && newDepend.GetGame() != game {
-						logfile.LogError("ModManager: Tried to enable dependency \"%s\" for \"%s\", but failed. Game does not match \"%s\".", newDepend.GetName(), mod.GetName(), game)
+						this.dependsError(mod.GetName(), "ModManager: Tried to enable dependency \"%s\" for \"%s\", but failed. Game does not match \"%s\".", newDepend.GetName(), mod.GetName(), game)
 						dependsMet = false
 					} else if version.Compare(newDepend.GetEngineMinVersion(), version.ENGINE) > 0 || version.Compare(version.ENGINE, newDepend.GetEngineMaxVersion()) > 0 {
-						logfile.LogError("ModManager: Tried to enable dependency \"%s\" for \"%s\", but failed. Not compatible with engine version %s.", newDepend.GetName(), mod.GetName(), version.ENGINE.GetString())
+						this.dependsError(mod.GetName(), "ModManager: Tried to enable dependency \"%s\" for \"%s\", but failed. Not compatible with engine version %s.", newDepend.GetName(), mod.GetName(), version.ENGINE.GetString())
 
 						dependsMet = false
 					} else if version.Compare(newDepend.GetVersion(), mod.GetDependsMin()[index]) < 0 || version.Compare(newDepend.GetVersion(), mod.GetDependsMax()[index]) > 0 {
-						logfile.LogError("ModManager: Tried to enable dependency \"%s\" for \"%s\", but failed. Version \"%s\" is required, but only version \"%s\" is available.", newDepend.GetName(), mod.GetName(), version.CreateVersionReqString(mod.GetDependsMin()[index], mod.GetDependsMax()[index]), newDepend.GetVersion().GetString())
+						this.dependsError(mod.GetName(), "ModManager: Tried to enable dependency \"%s\" for \"%s\", but failed. Version \"%s\" is required, but only version \"%s\" is available.", newDepend.GetName(), mod.GetName(), version.CreateVersionReqString(mod.GetDependsMin()[index], mod.GetDependsMax()[index]), newDepend.GetVersion().GetString())
 						dependsMet = false
 					} else if _, ok := newModsMap[newDepend.GetName()]; !ok {
 						logfile.LogError("ModManager: Mod \"%s\" requires the \"%s\" mod. Enabling \"%s\" now.", mod.GetName(), dep, dep)
@@ -517,7 +656,7 @@ func (this *ModManager) ApplyDepends() error {
 					}
 
 				} else {
-					logfile.LogError("ModManager: Could not find mod \"%s\", which is required by mod \"%s\". Disabling \"%s\" now.", dep, mod.GetName(), mod.GetName())
+					this.dependsError(mod.GetName(), "ModManager: Could not find mod \"%s\", which is required by mod \"%s\". Disabling \"%s\" now.", dep, mod.GetName(), mod.GetName())
 					dependsMet = false
 				}
 			}
@@ -538,7 +677,7 @@ func (this *ModManager) ApplyDepends() error {
 
 	this.modList = newMods
 	if !finished {
-		if err := this.ApplyDepends(); err != nil {
+		if err := this.applyDepends(); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,210 @@
+// 粒子模拟，只负责数值计算，不涉及渲染
+package particle
+
+import (
+	"math/rand"
+	"monster/pkg/common/color"
+	"monster/pkg/common/define/renderable"
+	"monster/pkg/common/fpoint"
+)
+
+// 数值区间
+type Range struct {
+	Min float32
+	Max float32
+}
+
+func (this Range) Rand(rnd *rand.Rand) float32 {
+	if this.Max <= this.Min {
+		return this.Min
+	}
+
+	return this.Min + rnd.Float32()*(this.Max-this.Min)
+}
+
+// 发射器定义，时间单位为帧，距离单位为地图坐标
+type Def struct {
+	SpawnRate     float32       // 每帧发射的粒子数，可以是小数
+	SpawnArea     fpoint.FPoint // 以发射器为中心的发射范围，宽高
+	MaxParticles  int           // 0 不限制
+	Lifetime      Range
+	VelocityX     Range
+	VelocityY     Range
+	Gravity       fpoint.FPoint // 每帧的速度增量
+	ColorStart    color.Color
+	ColorEnd      color.Color
+	AlphaStart    uint8
+	AlphaEnd      uint8
+	BlendMode     uint8
+	Frames        int // 动画帧数
+	FrameDuration int // 每帧持续时间
+	Loop          bool
+}
+
+func ConstructDef() Def {
+	return Def{
+		Lifetime:      Range{Min: 1, Max: 1},
+		ColorStart:    color.Construct(255, 255, 255),
+		ColorEnd:      color.Construct(255, 255, 255),
+		AlphaStart:    255,
+		AlphaEnd:      255,
+		BlendMode:     renderable.BLEND_NORMAL,
+		Frames:        1,
+		FrameDuration: 1,
+		Loop:          true,
+	}
+}
+
+type Particle struct {
+	Pos      fpoint.FPoint
+	Vel      fpoint.FPoint
+	Age      int
+	Lifetime int
+}
+
+// 生命周期的进度，[0, 1]
+func (this *Particle) Progress() float32 {
+	if this.Lifetime <= 0 {
+		return 1
+	}
+
+	p := (float32)(this.Age) / (float32)(this.Lifetime)
+	if p > 1 {
+		p = 1
+	}
+
+	return p
+}
+
+type Emitter struct {
+	def       *Def
+	pos       fpoint.FPoint
+	particles []Particle
+	spawnAcc  float32 // 累积的待发射数
+	stopped   bool
+	rnd       *rand.Rand
+}
+
+func NewEmitter(def *Def, seed int64) *Emitter {
+	return &Emitter{
+		def: def,
+		pos: fpoint.Construct(),
+		rnd: rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (this *Emitter) GetDef() *Def {
+	return this.def
+}
+
+func (this *Emitter) SetPos(pos fpoint.FPoint) {
+	this.pos = pos
+}
+
+func (this *Emitter) GetPos() fpoint.FPoint {
+	return this.pos
+}
+
+// 停止发射，已有的粒子继续到生命结束
+func (this *Emitter) Stop() {
+	this.stopped = true
+}
+
+// 停止发射并且所有粒子都已消失
+func (this *Emitter) IsDone() bool {
+	return this.stopped && len(this.particles) == 0
+}
+
+func (this *Emitter) GetParticles() []Particle {
+	return this.particles
+}
+
+func (this *Emitter) Logic() {
+	// 更新已有的粒子，移除到期的
+	alive := this.particles[:0]
+	for _, p := range this.particles {
+		p.Age++
+		if p.Age >= p.Lifetime {
+			continue
+		}
+
+		p.Vel.X += this.def.Gravity.X
+		p.Vel.Y += this.def.Gravity.Y
+		p.Pos.X += p.Vel.X
+		p.Pos.Y += p.Vel.Y
+		alive = append(alive, p)
+	}
+	this.particles = alive
+
+	if this.stopped {
+		return
+	}
+
+	this.spawnAcc += this.def.SpawnRate
+	for this.spawnAcc >= 1 {
+		this.spawnAcc--
+
+		if this.def.MaxParticles > 0 && len(this.particles) >= this.def.MaxParticles {
+			this.spawnAcc = 0
+			break
+		}
+
+		this.particles = append(this.particles, this.spawn())
+	}
+}
+
+func (this *Emitter) spawn() Particle {
+	p := Particle{}
+
+	p.Pos.X = this.pos.X + (this.rnd.Float32()-0.5)*this.def.SpawnArea.X
+	p.Pos.Y = this.pos.Y + (this.rnd.Float32()-0.5)*this.def.SpawnArea.Y
+	p.Vel.X = this.def.VelocityX.Rand(this.rnd)
+	p.Vel.Y = this.def.VelocityY.Rand(this.rnd)
+
+	p.Lifetime = (int)(this.def.Lifetime.Rand(this.rnd) + 0.5)
+	if p.Lifetime < 1 {
+		p.Lifetime = 1
+	}
+
+	return p
+}
+
+// 粒子当前的颜色和透明度，按生命周期线性插值
+func (this *Emitter) ColorOf(p *Particle) (color.Color, uint8) {
+	t := p.Progress()
+
+	c := color.Construct(
+		lerp(this.def.ColorStart.R, this.def.ColorEnd.R, t),
+		lerp(this.def.ColorStart.G, this.def.ColorEnd.G, t),
+		lerp(this.def.ColorStart.B, this.def.ColorEnd.B, t),
+	)
+
+	return c, lerp(this.def.AlphaStart, this.def.AlphaEnd, t)
+}
+
+// 粒子当前的动画帧
+func (this *Emitter) FrameOf(p *Particle) int {
+	if this.def.Frames <= 1 {
+		return 0
+	}
+
+	duration := this.def.FrameDuration
+	if duration < 1 {
+		duration = 1
+	}
+
+	frame := p.Age / duration
+	if this.def.Loop {
+		return frame % this.def.Frames
+	}
+
+	if frame >= this.def.Frames {
+		frame = this.def.Frames - 1
+	}
+
+	return frame
+}
+
+func lerp(a, b uint8, t float32) uint8 {
+	return (uint8)((float32)(a) + ((float32)(b)-(float32)(a))*t + 0.5)
+}
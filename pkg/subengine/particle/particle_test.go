@@ -0,0 +1,103 @@
+package particle
+
+import (
+	"monster/pkg/common/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmitterSpawnRate(t *testing.T) {
+	r := require.New(t)
+
+	def := ConstructDef()
+	def.SpawnRate = 0.5
+	def.Lifetime = Range{Min: 100, Max: 100}
+
+	e := NewEmitter(&def, 1)
+	for i := 0; i < 10; i++ {
+		e.Logic()
+	}
+
+	r.Len(e.GetParticles(), 5)
+}
+
+func TestEmitterMaxParticles(t *testing.T) {
+	r := require.New(t)
+
+	def := ConstructDef()
+	def.SpawnRate = 3
+	def.MaxParticles = 4
+	def.Lifetime = Range{Min: 100, Max: 100}
+
+	e := NewEmitter(&def, 1)
+	e.Logic()
+	e.Logic()
+
+	r.Len(e.GetParticles(), 4)
+}
+
+func TestEmitterLifetimeAndStop(t *testing.T) {
+	r := require.New(t)
+
+	def := ConstructDef()
+	def.SpawnRate = 1
+	def.Lifetime = Range{Min: 2, Max: 2}
+
+	e := NewEmitter(&def, 1)
+	e.Logic()
+	e.Stop()
+	r.False(e.IsDone())
+
+	e.Logic()
+	r.Len(e.GetParticles(), 1)
+
+	e.Logic()
+	r.True(e.IsDone())
+}
+
+func TestEmitterGravity(t *testing.T) {
+	r := require.New(t)
+
+	def := ConstructDef()
+	def.SpawnRate = 1
+	def.MaxParticles = 1
+	def.Lifetime = Range{Min: 10, Max: 10}
+	def.VelocityX = Range{Min: 1, Max: 1}
+	def.Gravity.Y = 0.5
+
+	e := NewEmitter(&def, 1)
+	e.Logic()
+	e.Logic()
+	e.Logic()
+
+	p := e.GetParticles()[0]
+	r.InDelta(2, p.Pos.X, 0.0001)
+	r.InDelta(1.5, p.Pos.Y, 0.0001)
+}
+
+func TestEmitterColorAndFrame(t *testing.T) {
+	r := require.New(t)
+
+	def := ConstructDef()
+	def.ColorStart = color.Construct(0, 0, 0)
+	def.ColorEnd = color.Construct(200, 100, 50)
+	def.AlphaStart = 255
+	def.AlphaEnd = 0
+	def.Frames = 3
+	def.FrameDuration = 2
+
+	e := NewEmitter(&def, 1)
+	p := Particle{Age: 5, Lifetime: 10}
+
+	c, a := e.ColorOf(&p)
+	r.Equal(color.Construct(100, 50, 25), c)
+	r.Equal(uint8(128), a)
+	r.Equal(2, e.FrameOf(&p))
+
+	p.Age = 6
+	r.Equal(0, e.FrameOf(&p))
+
+	def.Loop = false
+	r.Equal(2, e.FrameOf(&p))
+}
@@ -0,0 +1,153 @@
+// 纹理图集的矩形装箱
+//
+// 天际线(skyline)算法，每次放在最低的位置，适合加载时一次性装入大量小图。
+package atlas
+
+// 天际线的一段
+type segment struct {
+	x, y, w int
+}
+
+type Packer struct {
+	w, h    int
+	padding int // 相邻图片之间的间隔，避免采样时串色
+	sky     []segment
+	used    int // 已用面积(不含间隔)
+}
+
+func NewPacker(w, h, padding int) *Packer {
+	p := &Packer{}
+	p.init(w, h, padding)
+
+	return p
+}
+
+func (this *Packer) init(w, h, padding int) *Packer {
+	this.w = w
+	this.h = h
+	this.padding = padding
+	this.sky = []segment{{x: 0, y: 0, w: w}}
+	this.used = 0
+
+	return this
+}
+
+// 放入一个矩形，返回左上角位置
+func (this *Packer) Insert(w, h int) (int, int, bool) {
+	if w <= 0 || h <= 0 {
+		return 0, 0, false
+	}
+
+	pw := w + this.padding
+	ph := h + this.padding
+
+	bestIndex := -1
+	bestX, bestY, bestW := 0, 0, 0
+	for i := range this.sky {
+		y, ok := this.fit(i, pw, ph)
+		if !ok {
+			continue
+		}
+
+		// 最低的优先，同样高度选更窄的段
+		if bestIndex == -1 || y < bestY || (y == bestY && this.sky[i].w < bestW) {
+			bestIndex = i
+			bestX = this.sky[i].x
+			bestY = y
+			bestW = this.sky[i].w
+		}
+	}
+
+	if bestIndex == -1 {
+		return 0, 0, false
+	}
+
+	this.addSegment(bestIndex, segment{x: bestX, y: bestY + ph, w: pw})
+	this.used += w * h
+
+	return bestX, bestY, true
+}
+
+// 从第index段开始能否放下，返回放置的y
+func (this *Packer) fit(index, w, h int) (int, bool) {
+	x := this.sky[index].x
+	if x+w > this.w {
+		return 0, false
+	}
+
+	y := this.sky[index].y
+	left := w
+	for i := index; left > 0; i++ {
+		if i >= len(this.sky) {
+			return 0, false
+		}
+
+		if this.sky[i].y > y {
+			y = this.sky[i].y
+		}
+
+		if y+h > this.h {
+			return 0, false
+		}
+
+		left -= this.sky[i].w
+	}
+
+	return y, true
+}
+
+func (this *Packer) addSegment(index int, seg segment) {
+	this.sky = append(this.sky, segment{})
+	copy(this.sky[index+1:], this.sky[index:])
+	this.sky[index] = seg
+
+	// 被新段盖住的部分裁掉
+	for i := index + 1; i < len(this.sky); i++ {
+		prev := this.sky[i-1]
+		if this.sky[i].x >= prev.x+prev.w {
+			break
+		}
+
+		shrink := prev.x + prev.w - this.sky[i].x
+		this.sky[i].x += shrink
+		this.sky[i].w -= shrink
+
+		if this.sky[i].w > 0 {
+			break
+		}
+
+		this.sky = append(this.sky[:i], this.sky[i+1:]...)
+		i--
+	}
+
+	// 合并同高度的相邻段
+	for i := 0; i < len(this.sky)-1; i++ {
+		if this.sky[i].y == this.sky[i+1].y {
+			this.sky[i].w += this.sky[i+1].w
+			this.sky = append(this.sky[:i+1], this.sky[i+2:]...)
+			i--
+		}
+	}
+}
+
+func (this *Packer) GetW() int {
+	return this.w
+}
+
+func (this *Packer) GetH() int {
+	return this.h
+}
+
+// 已用面积
+func (this *Packer) GetUsed() int {
+	return this.used
+}
+
+// 占用率 0~1
+func (this *Packer) GetOccupancy() float32 {
+	if this.w == 0 || this.h == 0 {
+		return 0
+	}
+
+	return float32(this.used) / float32(this.w*this.h)
+}
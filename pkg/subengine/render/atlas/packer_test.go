@@ -0,0 +1,61 @@
+package atlas
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type box struct {
+	x, y, w, h int
+}
+
+func Test_packer(t *testing.T) {
+	r := require.New(t)
+
+	p := NewPacker(256, 256, 1)
+
+	var boxes []box
+	sizes := [][2]int{{64, 32}, {32, 64}, {100, 20}, {16, 16}, {128, 128}, {50, 70}, {30, 30}}
+	for _, s := range sizes {
+		x, y, ok := p.Insert(s[0], s[1])
+		r.True(ok)
+		r.True(x >= 0 && y >= 0 && x+s[0] <= 256 && y+s[1] <= 256)
+		boxes = append(boxes, box{x, y, s[0], s[1]})
+	}
+
+	// 互不重叠
+	for i := range boxes {
+		for j := i + 1; j < len(boxes); j++ {
+			a, b := boxes[i], boxes[j]
+			overlap := a.x < b.x+b.w && b.x < a.x+a.w && a.y < b.y+b.h && b.y < a.y+a.h
+			r.False(overlap, "%v %v", a, b)
+		}
+	}
+
+	used := 0
+	for _, s := range sizes {
+		used += s[0] * s[1]
+	}
+	r.Equal(used, p.GetUsed())
+	r.InDelta(float32(used)/float32(256*256), p.GetOccupancy(), 0.0001)
+
+	// 放不下
+	_, _, ok := p.Insert(300, 10)
+	r.False(ok)
+}
+
+func Test_packer_full(t *testing.T) {
+	r := require.New(t)
+
+	p := NewPacker(64, 64, 0)
+	for i := 0; i < 16; i++ {
+		_, _, ok := p.Insert(16, 16)
+		r.True(ok)
+	}
+
+	r.Equal(float32(1), p.GetOccupancy())
+
+	_, _, ok := p.Insert(1, 1)
+	r.False(ok)
+}
@@ -21,6 +21,12 @@ type RenderDevice struct {
 	cache                 map[string]common.Image
 	MClip                 rect.Rect
 	MDest                 rect.Rect
+
+	// 绘制统计，每帧提交时结算
+	drawCalls        int
+	stateChanges     int
+	lastDrawCalls    int
+	lastStateChanges int
 }
 
 func ConstructRenderDevice() RenderDevice {
@@ -156,6 +162,32 @@ func (this *RenderDevice) LocalToGlobal(r common.Sprite) bool {
 }
 
 // 之前是否已经重置过渲染系统，之后并重置状态
+func (this *RenderDevice) AddDrawCalls(n int) {
+	this.drawCalls += n
+}
+
+func (this *RenderDevice) AddStateChanges(n int) {
+	this.stateChanges += n
+}
+
+// 结算一帧的统计
+func (this *RenderDevice) EndFrameStats() {
+	this.lastDrawCalls = this.drawCalls
+	this.lastStateChanges = this.stateChanges
+	this.drawCalls = 0
+	this.stateChanges = 0
+}
+
+// 上一帧的绘制调用和状态切换次数
+func (this *RenderDevice) GetRenderStats() (int, int) {
+	return this.lastDrawCalls, this.lastStateChanges
+}
+
+// 没有文字缓存的设备
+func (this *RenderDevice) GetTextCacheStats() (int, int, int, int) {
+	return 0, 0, 0, 0
+}
+
 func (this *RenderDevice) ReloadGraphics() bool {
 	if this.IsReloadGraphics {
 		this.IsReloadGraphics = false
@@ -185,17 +217,6 @@ func (this *RenderDevice) WindowResizeInternal(impl common.RenderDevice, setting
 	s.Set("resolution_w", w)
 	s.Set("resolution_h", h)
 
-	tmpScreenH := 0
-
-	if s.Get("dpi_scaling").(bool) && this.ddpi > 0 && eset.Get("resolutions", "virtual_dpi").(float32) > 0 {
-		// 配置了启用dpi缩放
-		tmpScreenH = (int)((float32)(s.Get("resolution_h").(int)) * (eset.Get("resolutions", "virtual_dpi").(float32) / this.ddpi))
-	} else {
-		tmpScreenH = s.Get("resolution_h").(int)
-	}
-
-	s.SetViewH(tmpScreenH) // 配置视口高 默认等于分辨率
-
 	maxRenderSize := 0
 	tmp := eset.Get("resolutions", "virtual_height").([]int)
 	if s.Get("max_render_size").(int) == 0 {
@@ -208,6 +229,26 @@ func (this *RenderDevice) WindowResizeInternal(impl common.RenderDevice, setting
 		maxRenderSize = s.Get("max_render_size").(int)
 	}
 
+	tmpScreenH := 0
+
+	if s.Get("virtual_resolution").(bool) && maxRenderSize > 0 {
+		// 固定的内部分辨率，和窗口大小无关
+		tmpScreenH = maxRenderSize
+	} else if s.Get("dpi_scaling").(bool) && this.ddpi > 0 && eset.Get("resolutions", "virtual_dpi").(float32) > 0 {
+		// 配置了启用dpi缩放
+		tmpScreenH = (int)((float32)(s.Get("resolution_h").(int)) * (eset.Get("resolutions", "virtual_dpi").(float32) / this.ddpi))
+	} else {
+		tmpScreenH = s.Get("resolution_h").(int)
+	}
+
+	// 界面缩放，内部分辨率越小界面越大
+	uiScale := s.Get("ui_scale").(float32)
+	if uiScale > 0 && uiScale != 1 {
+		tmpScreenH = (int)((float32)(tmpScreenH) / uiScale)
+	}
+
+	s.SetViewH(tmpScreenH) // 配置视口高 默认等于分辨率
+
 	// 处理窗口高度(tmpScreenH)不在要求的范围内的情况
 	// scale virtual height when outside of VIRTUAL_HEIGHTS range
 	if len(tmp) != 0 {
@@ -232,6 +273,12 @@ func (this *RenderDevice) WindowResizeInternal(impl common.RenderDevice, setting
 
 	s.SetViewWHalf(s.GetViewW() / 2)
 
+	// 画面按比例放进窗口，居中，剩下的是黑边
+	offset := point.Construct()
+	offset.X = (int)(((float32)(s.Get("resolution_w").(int)) - (float32)(s.GetViewW())/s.GetViewScaling()) / 2)
+	offset.Y = (int)(((float32)(s.Get("resolution_h").(int)) - (float32)(s.GetViewH())/s.GetViewScaling()) / 2)
+	s.SetViewOffset(offset)
+
 	if s.GetViewW() != oldViewW || s.GetViewH() != oldViewH {
 		logfile.LogInfo("RenderDevice: Internal render size is %dx%d", s.GetViewW(), s.GetViewH())
 	}
@@ -0,0 +1,90 @@
+package batch
+
+// 把一帧的绘制命令按纹理和混合状态分组
+// 只有不和中间批次重叠时才会合并到前面的批次，保证画家顺序
+
+const DEFAULT_LOOKBACK = 16 // 向前查找可合并批次的数量
+
+type Command struct {
+	Key        int // 纹理和混合状态
+	X, Y, W, H int // 目标区域
+}
+
+type Batch struct {
+	Key    int
+	Items  []int // 命令下标，按提交顺序
+	x0, y0 int
+	x1, y1 int
+}
+
+func (this *Batch) overlap(cmd Command) bool {
+	return cmd.X < this.x1 && cmd.X+cmd.W > this.x0 && cmd.Y < this.y1 && cmd.Y+cmd.H > this.y0
+}
+
+func (this *Batch) add(index int, cmd Command) {
+	if len(this.Items) == 0 {
+		this.x0, this.y0 = cmd.X, cmd.Y
+		this.x1, this.y1 = cmd.X+cmd.W, cmd.Y+cmd.H
+	} else {
+		if cmd.X < this.x0 {
+			this.x0 = cmd.X
+		}
+		if cmd.Y < this.y0 {
+			this.y0 = cmd.Y
+		}
+		if cmd.X+cmd.W > this.x1 {
+			this.x1 = cmd.X + cmd.W
+		}
+		if cmd.Y+cmd.H > this.y1 {
+			this.y1 = cmd.Y + cmd.H
+		}
+	}
+
+	this.Items = append(this.Items, index)
+}
+
+// lookback <= 0 使用默认值
+func Build(cmds []Command, lookback int) []Batch {
+	if lookback <= 0 {
+		lookback = DEFAULT_LOOKBACK
+	}
+
+	var batches []Batch
+
+	for i, cmd := range cmds {
+		target := -1
+
+		for j := len(batches) - 1; j >= 0 && j >= len(batches)-lookback; j-- {
+			if batches[j].Key == cmd.Key {
+				target = j
+				break
+			}
+
+			// 被后面的批次挡住，不能提前绘制
+			if batches[j].overlap(cmd) {
+				break
+			}
+		}
+
+		if target == -1 {
+			batches = append(batches, Batch{Key: cmd.Key})
+			target = len(batches) - 1
+		}
+
+		batches[target].add(i, cmd)
+	}
+
+	return batches
+}
+
+// 相邻批次之间的状态切换次数
+func CountStateChanges(batches []Batch) int {
+	changes := 0
+	for i := 1; i < len(batches); i++ {
+		if batches[i].Key != batches[i-1].Key {
+			changes++
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,36 @@
+package batch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_batch(t *testing.T) {
+	r := require.New(t)
+
+	// 两种纹理交替，互不重叠，合并成两批
+	cmds := []Command{
+		{Key: 1, X: 0, Y: 0, W: 10, H: 10},
+		{Key: 2, X: 20, Y: 0, W: 10, H: 10},
+		{Key: 1, X: 40, Y: 0, W: 10, H: 10},
+		{Key: 2, X: 60, Y: 0, W: 10, H: 10},
+	}
+
+	batches := Build(cmds, 0)
+	r.Len(batches, 2)
+	r.Equal([]int{0, 2}, batches[0].Items)
+	r.Equal([]int{1, 3}, batches[1].Items)
+	r.Equal(1, CountStateChanges(batches))
+
+	// 第三个命令被第二个挡住，必须保持顺序
+	cmds = []Command{
+		{Key: 1, X: 0, Y: 0, W: 10, H: 10},
+		{Key: 2, X: 5, Y: 5, W: 10, H: 10},
+		{Key: 1, X: 8, Y: 8, W: 10, H: 10},
+	}
+
+	batches = Build(cmds, 0)
+	r.Len(batches, 3)
+	r.Equal(2, CountStateChanges(batches))
+}
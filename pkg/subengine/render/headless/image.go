@@ -0,0 +1,89 @@
+package headless
+
+import (
+	"monster/pkg/common"
+	"monster/pkg/common/color"
+	"monster/pkg/subengine/render/base"
+)
+
+// 只记录尺寸的图片，不持有任何sdl资源
+type Image struct {
+	base.Image
+	w int
+	h int
+}
+
+// 不允许外部使用
+func newImage(device common.RenderDevice, filename string, w, h int) *Image {
+	ptr := &Image{}
+	ptr.init(device, filename, w, h)
+	return ptr
+}
+
+func (this *Image) init(device common.RenderDevice, filename string, w, h int) common.Image {
+	// 先base初始化
+	this.Image = base.ConstructImage(device, filename)
+
+	// 后子类初始化
+	this.w = w
+	this.h = h
+
+	return this
+}
+
+func (this *Image) Clear() {
+}
+
+func (this *Image) Close() {
+	this.Image.Close(this)
+}
+
+func (this *Image) GetWidth() (int, error) {
+	return this.w, nil
+}
+
+func (this *Image) GetHeight() (int, error) {
+	return this.h, nil
+}
+
+func (this *Image) CreateSprite() (common.Sprite, error) {
+	return this.Image.CreateSprite(this)
+}
+
+func (this *Image) UnRef() {
+	this.Image.UnRef(this)
+}
+
+func (this *Image) FillWithColor(color color.Color) error {
+	return nil
+}
+
+func (this *Image) DrawPixel(x int, y int, color color.Color) error {
+	return nil
+}
+
+func (this *Image) DrawLine(x0, y0, x1, y1 int, color color.Color) error {
+	return nil
+}
+
+func (this *Image) BeginPixelBatch() error {
+	return nil
+}
+
+func (this *Image) EndPixelBatch() error {
+	return nil
+}
+
+func (this *Image) Resize(width, height int) (common.Image, error) {
+	scaled := newImage(this.GetDevice(), this.GetFilename(), width, height) // +1
+
+	this.UnRef() //清理老的
+	return scaled, nil
+}
+
+func (this *Image) Surface() interface{} {
+	return nil
+}
+
+func (this *Image) SetSurface(s interface{}) {
+}
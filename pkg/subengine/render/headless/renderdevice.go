@@ -0,0 +1,252 @@
+package headless
+
+import (
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"monster/pkg/common"
+	"monster/pkg/common/color"
+	"monster/pkg/common/fpoint"
+	"monster/pkg/common/point"
+	"monster/pkg/common/rect"
+	"monster/pkg/filesystem/logfile"
+	"monster/pkg/subengine/render/base"
+	"monster/pkg/utils"
+	"sort"
+)
+
+// 不创建窗口的渲染设备，只解析图片头获得尺寸
+// 用于离线检查mod数据 (modlint)
+type RenderDevice struct {
+	base.RenderDevice
+	windowW         int
+	windowH         int
+	missingImages   map[string]struct{} // 找不到或无法解析的图片
+	backgroundColor color.Color
+}
+
+func NewRenderDevice(settings common.Settings, eset common.EngineSettings) *RenderDevice {
+	impl := ConstructRenderDevice(settings, eset)
+	ptr := &impl
+	_ = (common.RenderDevice)(ptr)
+
+	return ptr
+}
+
+func ConstructRenderDevice(settings common.Settings, eset common.EngineSettings) RenderDevice {
+	impl := RenderDevice{
+		missingImages: map[string]struct{}{},
+	}
+
+	// base
+	impl.RenderDevice = base.ConstructRenderDevice()
+
+	logfile.LogInfo("Using Render Device: HeadlessRenderDevice")
+
+	// self
+	impl.windowW = settings.Get("resolution_w").(int)
+	impl.windowH = settings.Get("resolution_h").(int)
+	impl.MinScreen.X = eset.Get("resolutions", "required_width").(int)
+	impl.MinScreen.Y = eset.Get("resolutions", "required_height").(int)
+
+	return impl
+}
+
+func (this *RenderDevice) CreateContext(settings common.Settings, eset common.EngineSettings, msg common.MessageEngine, mods common.ModManager) error {
+	return this.RenderDevice.CreateContext(this, settings, eset, msg, mods)
+}
+
+func (this *RenderDevice) CreateContextInternal(settings common.Settings, eset common.EngineSettings, msg common.MessageEngine, mods common.ModManager) error {
+	this.IsInitialized = true
+	return this.WindowResize(settings, eset)
+}
+
+func (this *RenderDevice) CreateContextError() {
+	logfile.LogError("HeadlessRenderDevice: createContext() failed")
+}
+
+func (this *RenderDevice) DestroyContext() {
+	this.RenderDevice.CacheRemoveAll()
+	this.IsReloadGraphics = true
+}
+
+func (this *RenderDevice) Clear() {
+	this.DestroyContext()
+}
+
+func (this *RenderDevice) Close() {
+	this.RenderDevice.Close(this)
+}
+
+func (this *RenderDevice) WindowResize(settings common.Settings, eset common.EngineSettings) error {
+	err := this.RenderDevice.WindowResizeInternal(this, settings, eset)
+	if err != nil {
+		return err
+	}
+
+	settings.UpdateScreenVars(eset)
+	return nil
+}
+
+func (this *RenderDevice) UpdateTitleBar(settings common.Settings, eset common.EngineSettings, msg common.MessageEngine, mods common.ModManager) error {
+	return nil
+}
+
+func (this *RenderDevice) SetGamma(g float32) error {
+	return nil
+}
+
+func (this *RenderDevice) ResetGamma() error {
+	return nil
+}
+
+func (this *RenderDevice) BeginTarget(image common.Image) error {
+	return nil
+}
+
+func (this *RenderDevice) EndTarget() error {
+	return nil
+}
+
+// 无渲染模式没有画面
+func (this *RenderDevice) Screenshot(filename string) {
+}
+
+func (this *RenderDevice) SetColorblind(enable bool) {
+}
+
+func (this *RenderDevice) FlashScreen(c color.Color, frames int) {
+}
+
+func (this *RenderDevice) GetWindowSize() (int, int) {
+	return this.windowW, this.windowH
+}
+
+func (this *RenderDevice) BlankScreen() error {
+	return nil
+}
+
+func (this *RenderDevice) CommitFrame(inpt common.InputState) error {
+	return nil
+}
+
+func (this *RenderDevice) Render(r common.Sprite) error {
+	return nil
+}
+
+func (this *RenderDevice) Render1(r common.Renderable, dest rect.Rect) error {
+	return nil
+}
+
+func (this *RenderDevice) RenderToImage(srcImage common.Image, src rect.Rect, destImage common.Image, dest rect.Rect) (rect.Rect, error) {
+	dest.W = src.W
+	dest.H = src.H
+	return dest, nil
+}
+
+func (this *RenderDevice) RenderTextToImage(fontStyle common.FontStyle, text string, color color.Color, blended bool) (common.Image, error) {
+	return newImage(this, "", 0, 0), nil
+}
+
+func (this *RenderDevice) DrawRectangle(p0, p1 point.Point, color color.Color) error {
+	return nil
+}
+
+func (this *RenderDevice) DrawLine(x0, y0, x1, y1 int, color color.Color) error {
+	return nil
+}
+
+func (this *RenderDevice) DrawFilledRect(r rect.Rect, color color.Color) error {
+	return nil
+}
+
+func (this *RenderDevice) DrawFilledTriangles(points []fpoint.FPoint, color color.Color) error {
+	return nil
+}
+
+func (this *RenderDevice) FillRect() error {
+	return nil
+}
+
+func (this *RenderDevice) CreateImage(width, height int) (common.Image, error) {
+	return newImage(this, "", width, height), nil
+}
+
+// 只解析图片头，找不到的图片记录下来并返回1x1的占位图片，让加载继续
+func (this *RenderDevice) LoadImage(settings common.Settings, mods common.ModManager, filename string) (common.Image, error) {
+	image, ok := this.CacheLookup(filename) // +1
+	if ok {
+		return image, nil
+	}
+
+	w, h := 1, 1
+	loc, err := mods.Locate(settings, filename)
+	if err != nil && !utils.IsNotExist(err) {
+		return nil, err
+	}
+
+	if err == nil {
+		w, h, err = decodeSize(mods, loc)
+	}
+
+	if err != nil {
+		this.missingImages[filename] = struct{}{}
+		logfile.LogError("HeadlessRenderDevice: Couldn't load image: '%s'.", filename)
+	}
+
+	image = newImage(this, filename, w, h) // +1
+	this.CacheStore(filename, image)
+	return image, nil
+}
+
+// 无渲染模式只解析图片头，不需要预先解码
+func (this *RenderDevice) PreloadImage(settings common.Settings, mods common.ModManager, filename string) error {
+	return nil
+}
+
+// 无渲染模式没有图集
+func (this *RenderDevice) LoadAtlasImage(settings common.Settings, mods common.ModManager, filename string) (common.Image, error) {
+	return this.LoadImage(settings, mods, filename)
+}
+
+// 加载失败的图片列表
+func (this *RenderDevice) GetMissingImages() []string {
+	var list []string
+	for name, _ := range this.missingImages {
+		list = append(list, name)
+	}
+
+	sort.Strings(list)
+	return list
+}
+
+func (this *RenderDevice) SetBackgroundColor(color color.Color) {
+	this.backgroundColor = color
+}
+
+func (this *RenderDevice) GetBackgroundColor() color.Color {
+	return this.backgroundColor
+}
+
+func (this *RenderDevice) GetRefreshRate() int {
+	return 0
+}
+
+func (this *RenderDevice) Curs() common.CursorManager {
+	return nil
+}
+
+func decodeSize(mods common.ModManager, loc string) (int, int, error) {
+	f, err := mods.Open(loc)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	conf, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return conf.Width, conf.Height, nil
+}
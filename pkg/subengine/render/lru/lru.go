@@ -0,0 +1,95 @@
+// 最近最少使用的缓存
+//
+// 容量满时淘汰最久没有使用的条目，淘汰时回调onEvict释放资源，并统计命中次数。
+package lru
+
+import "container/list"
+
+type entry struct {
+	key   interface{}
+	value interface{}
+}
+
+type Cache struct {
+	capacity int
+	items    map[interface{}]*list.Element
+	order    *list.List // 前面是最近使用的
+	onEvict  func(key, value interface{})
+	hits     int
+	misses   int
+}
+
+func New(capacity int, onEvict func(key, value interface{})) *Cache {
+	c := &Cache{}
+	c.init(capacity, onEvict)
+
+	return c
+}
+
+func (this *Cache) init(capacity int, onEvict func(key, value interface{})) *Cache {
+	this.capacity = capacity
+	this.items = map[interface{}]*list.Element{}
+	this.order = list.New()
+	this.onEvict = onEvict
+
+	return this
+}
+
+// 查找并标记为最近使用
+func (this *Cache) Get(key interface{}) (interface{}, bool) {
+	elem, ok := this.items[key]
+	if !ok {
+		this.misses++
+		return nil, false
+	}
+
+	this.hits++
+	this.order.MoveToFront(elem)
+	return elem.Value.(*entry).value, true
+}
+
+// 已存在则替换旧值
+func (this *Cache) Put(key, value interface{}) {
+	if elem, ok := this.items[key]; ok {
+		old := elem.Value.(*entry)
+		if this.onEvict != nil && old.value != value {
+			this.onEvict(old.key, old.value)
+		}
+
+		old.value = value
+		this.order.MoveToFront(elem)
+		return
+	}
+
+	this.items[key] = this.order.PushFront(&entry{key: key, value: value})
+
+	for this.capacity > 0 && this.order.Len() > this.capacity {
+		this.remove(this.order.Back())
+	}
+}
+
+func (this *Cache) remove(elem *list.Element) {
+	e := elem.Value.(*entry)
+	this.order.Remove(elem)
+	delete(this.items, e.key)
+
+	if this.onEvict != nil {
+		this.onEvict(e.key, e.value)
+	}
+}
+
+// 清空所有条目，统计保留
+func (this *Cache) Clear() {
+	for this.order.Len() > 0 {
+		this.remove(this.order.Back())
+	}
+}
+
+func (this *Cache) Len() int {
+	return this.order.Len()
+}
+
+// 累计的命中和未命中次数
+func (this *Cache) GetStats() (int, int) {
+	return this.hits, this.misses
+}
@@ -0,0 +1,45 @@
+package lru
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	r := require.New(t)
+
+	var evicted []interface{}
+	c := New(2, func(key, value interface{}) {
+		evicted = append(evicted, key)
+	})
+
+	c.Put("a", 1)
+	c.Put("b", 2)
+
+	// a最近使用，淘汰b
+	val, ok := c.Get("a")
+	r.True(ok)
+	r.Equal(1, val)
+
+	c.Put("c", 3)
+	r.Equal([]interface{}{"b"}, evicted)
+	r.Equal(2, c.Len())
+
+	_, ok = c.Get("b")
+	r.False(ok)
+
+	hits, misses := c.GetStats()
+	r.Equal(1, hits)
+	r.Equal(1, misses)
+
+	// 替换旧值时释放
+	c.Put("a", 4)
+	r.Equal([]interface{}{"b", "a"}, evicted)
+	val, _ = c.Get("a")
+	r.Equal(4, val)
+
+	c.Clear()
+	r.Equal(0, c.Len())
+	r.Len(evicted, 4)
+}
@@ -0,0 +1,141 @@
+package postfx
+
+import (
+	"image"
+	"image/png"
+	"math"
+	"os"
+)
+
+// 最终画面的后处理，像素格式为RGBA32
+
+// 红绿色盲(deuteranopia)的颜色矫正
+// 由 I + E*(I-S) 得到，S是色盲模拟矩阵，E把丢失的红绿差异转移到亮度和蓝色
+// 每行和为1，灰色保持不变
+var colorblindMatrix = [9]float32{
+	1, 0, 0,
+	-0.4375, 1.4375, 0,
+	0.2625, -0.5625, 1.3,
+}
+
+type Filter struct {
+	gamma      float32
+	lut        [256]uint8
+	colorblind bool
+	mix        [9][256]int32 // 矩阵每一项乘上0-255，定点数，放大256倍
+}
+
+func New() *Filter {
+	f := &Filter{}
+	f.SetGamma(1)
+
+	for i, v := range colorblindMatrix {
+		for c := 0; c < 256; c++ {
+			f.mix[i][c] = int32(v * float32(c) * 256)
+		}
+	}
+
+	return f
+}
+
+// 0.5最暗，2.0最亮，1不处理
+func (this *Filter) SetGamma(g float32) {
+	if g <= 0 {
+		g = 1
+	}
+
+	this.gamma = g
+	for i := 0; i < 256; i++ {
+		v := math.Pow(float64(i)/255, 1/float64(g))*255 + 0.5
+		if v > 255 {
+			v = 255
+		}
+		this.lut[i] = uint8(v)
+	}
+}
+
+func (this *Filter) GetGamma() float32 {
+	return this.gamma
+}
+
+func (this *Filter) SetColorblind(enable bool) {
+	this.colorblind = enable
+}
+
+func (this *Filter) IsActive() bool {
+	return this.gamma != 1 || this.colorblind
+}
+
+// 色盲矫正要混合通道，只能读回画面逐像素处理
+func (this *Filter) NeedsReadback() bool {
+	return this.colorblind
+}
+
+// 伽马用 x + s*x*(1-x) 近似，显卡用混合模式就能画出来，不用读回画面
+// s>0调亮，s<0调暗，两端的0和1不变，中间的0.5与真实伽马曲线一致
+func (this *Filter) GetGammaBlend() float32 {
+	s := 4 * (float32(math.Pow(0.5, 1/float64(this.gamma))) - 0.5)
+	if s > 1 {
+		s = 1
+	} else if s < -1 {
+		s = -1
+	}
+
+	return s
+}
+
+func (this *Filter) Apply(pixels []byte) {
+	if !this.IsActive() {
+		return
+	}
+
+	m := &this.mix
+	for i := 0; i+3 < len(pixels); i += 4 {
+		r, g, b := pixels[i], pixels[i+1], pixels[i+2]
+
+		if this.colorblind {
+			r, g, b = clamp(m[0][r]+m[1][g]+m[2][b]),
+				clamp(m[3][r]+m[4][g]+m[5][b]),
+				clamp(m[6][r]+m[7][g]+m[8][b])
+		}
+
+		pixels[i] = this.lut[r]
+		pixels[i+1] = this.lut[g]
+		pixels[i+2] = this.lut[b]
+	}
+}
+
+func clamp(v int32) uint8 {
+	v = (v + 128) >> 8
+	if v <= 0 {
+		return 0
+	}
+
+	if v >= 255 {
+		return 255
+	}
+
+	return uint8(v)
+}
+
+// 保存截图
+func SavePNG(filename string, pixels []byte, w, h int) error {
+	img := &image.NRGBA{
+		Pix:    pixels,
+		Stride: w * 4,
+		Rect:   image.Rect(0, 0, w, h),
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = png.Encode(f, img)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,59 @@
+package postfx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_filter(t *testing.T) {
+	r := require.New(t)
+
+	f := New()
+	r.False(f.IsActive())
+
+	pixels := []byte{10, 128, 250, 77}
+	f.Apply(pixels)
+	r.Equal([]byte{10, 128, 250, 77}, pixels)
+
+	// 调亮，透明度不变
+	f.SetGamma(2)
+	r.True(f.IsActive())
+	f.Apply(pixels)
+	r.True(pixels[1] > 128)
+	r.Equal(byte(77), pixels[3])
+
+	// 灰色不受色盲矫正影响
+	f.SetGamma(1)
+	f.SetColorblind(true)
+	gray := []byte{100, 100, 100, 255}
+	f.Apply(gray)
+	r.Equal([]byte{100, 100, 100, 255}, gray)
+
+	// 红绿区分开
+	red := []byte{200, 60, 0, 255}
+	green := []byte{60, 200, 0, 255}
+	f.Apply(red)
+	f.Apply(green)
+	r.NotEqual(red[2], green[2])
+}
+
+func Test_gammaBlend(t *testing.T) {
+	r := require.New(t)
+
+	f := New()
+	r.Equal(float32(0), f.GetGammaBlend())
+	r.False(f.NeedsReadback())
+
+	// 0.5处与真实伽马一致
+	f.SetGamma(2)
+	s := f.GetGammaBlend()
+	r.True(s > 0)
+	r.InDelta(f.lut[128], (0.5+s*0.25)*255, 2)
+
+	f.SetGamma(0.5)
+	r.True(f.GetGammaBlend() < 0)
+
+	f.SetColorblind(true)
+	r.True(f.NeedsReadback())
+}
@@ -0,0 +1,237 @@
+package sdlhardware
+
+import (
+	"fmt"
+	"monster/pkg/allocs"
+	"monster/pkg/common"
+	"monster/pkg/common/rect"
+	"monster/pkg/filesystem/logfile"
+	"monster/pkg/subengine/render/atlas"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+const (
+	ATLAS_MAX_SIZE = 2048 // 图集纹理的最大边长
+	ATLAS_PADDING  = 1    // 图片之间留空，避免过滤时串色
+)
+
+// 一张图集纹理，多张小图共享
+type atlasPage struct {
+	id      string
+	texture *sdl.Texture
+	packer  *atlas.Packer
+	refs    int // 引用该页的图片数量
+}
+
+func (this *atlasPage) report() {
+	allocs.SetAtlas(this.id, this.packer.GetUsed(), this.packer.GetW()*this.packer.GetH())
+}
+
+func (this *atlasPage) free() {
+	if this.texture != nil {
+		allocs.Delete(this.texture)
+		this.texture = nil
+	}
+
+	allocs.DeleteAtlas(this.id)
+}
+
+// 图集页大小，受限于显卡支持的最大纹理
+func (this *RenderDevice) getAtlasSize() int {
+	if this.atlasSize > 0 {
+		return this.atlasSize
+	}
+
+	this.atlasSize = ATLAS_MAX_SIZE
+	info, err := this.renderer.GetInfo()
+	if err == nil {
+		if info.MaxTextureWidth > 0 && int(info.MaxTextureWidth) < this.atlasSize {
+			this.atlasSize = int(info.MaxTextureWidth)
+		}
+
+		if info.MaxTextureHeight > 0 && int(info.MaxTextureHeight) < this.atlasSize {
+			this.atlasSize = int(info.MaxTextureHeight)
+		}
+	}
+
+	return this.atlasSize
+}
+
+func (this *RenderDevice) newAtlasPage() (*atlasPage, error) {
+	size := this.getAtlasSize()
+
+	texture, err := allocs.SdlCreateTexture(this.renderer, sdl.PIXELFORMAT_ABGR8888, sdl.TEXTUREACCESS_STATIC, int32(size), int32(size))
+	if err != nil {
+		return nil, err
+	}
+
+	err = texture.SetBlendMode(sdl.BLENDMODE_BLEND)
+	if err != nil {
+		allocs.Delete(texture)
+		return nil, err
+	}
+
+	this.atlasCount++
+	page := &atlasPage{
+		id:      fmt.Sprintf("page%d", this.atlasCount),
+		texture: texture,
+		packer:  atlas.NewPacker(size, size, ATLAS_PADDING),
+	}
+
+	this.atlasPages = append(this.atlasPages, page)
+	page.report()
+	return page, nil
+}
+
+// 把图片放进图集，放不下返回nil
+func (this *RenderDevice) packSurface(surface *sdl.Surface) (*atlasPage, rect.Rect, error) {
+	w, h := int(surface.W), int(surface.H)
+	size := this.getAtlasSize()
+
+	// 大图单独成纹理
+	if w <= 0 || h <= 0 || w > size/2 || h > size/2 {
+		return nil, rect.Rect{}, nil
+	}
+
+	var page *atlasPage
+	var x, y int
+	for _, ptr := range this.atlasPages {
+		if px, py, ok := ptr.packer.Insert(w, h); ok {
+			page, x, y = ptr, px, py
+			break
+		}
+	}
+
+	if page == nil {
+		ptr, err := this.newAtlasPage()
+		if err != nil {
+			return nil, rect.Rect{}, err
+		}
+
+		px, py, ok := ptr.packer.Insert(w, h)
+		if !ok {
+			return nil, rect.Rect{}, nil
+		}
+
+		page, x, y = ptr, px, py
+	}
+
+	conv, err := surface.ConvertFormat(sdl.PIXELFORMAT_ABGR8888, 0)
+	if err != nil {
+		return nil, rect.Rect{}, err
+	}
+	defer conv.Free()
+
+	region := rect.Construct(x, y, w, h)
+	dst := sdl.Rect{X: int32(x), Y: int32(y), W: int32(w), H: int32(h)}
+
+	if conv.MustLock() {
+		conv.Lock()
+	}
+	err = page.texture.Update(&dst, conv.Pixels(), int(conv.Pitch))
+	if conv.MustLock() {
+		conv.Unlock()
+	}
+
+	if err != nil {
+		return nil, rect.Rect{}, err
+	}
+
+	page.refs++
+	page.report()
+	return page, region, nil
+}
+
+func (this *RenderDevice) releaseAtlasPage(page *atlasPage) {
+	page.refs--
+	if page.refs > 0 {
+		return
+	}
+
+	page.free()
+	for i, ptr := range this.atlasPages {
+		if ptr == page {
+			this.atlasPages = append(this.atlasPages[:i], this.atlasPages[i+1:]...)
+			break
+		}
+	}
+}
+
+func (this *RenderDevice) clearAtlas() {
+	for _, page := range this.atlasPages {
+		page.free()
+	}
+
+	this.atlasPages = nil
+	this.atlasSize = 0
+}
+
+// 和LoadImage一样，但小图会被打包进共享的图集纹理
+// 图集图片只读，src矩形在渲染时自动偏移
+func (this *RenderDevice) LoadAtlasImage(settings common.Settings, mods common.ModManager, filename string) (common.Image, error) {
+	image, ok := this.CacheLookup(filename) // +1
+	if ok {
+		if preSurface := this.takePreloaded(filename); preSurface != nil {
+			allocs.Delete(preSurface)
+		}
+		return image, nil
+	}
+
+	surface := this.takePreloaded(filename)
+	if surface == nil {
+		loc, err := mods.Locate(settings, filename)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := mods.ReadFile(loc)
+		if err != nil {
+			logfile.LogError("SDLHardwareRenderDevice: Couldn't load image: '%s'. %s", filename, err)
+			return nil, err
+		}
+
+		surface, err = allocs.ImgLoadRW(data)
+		if err != nil {
+			logfile.LogError("SDLHardwareRenderDevice: Couldn't load image: '%s'. %s", filename, err)
+			return nil, err
+		}
+	}
+	defer allocs.Delete(surface)
+
+	img := newImage(this, filename, this.renderer) // +1
+	defer img.UnRef()                              // -1
+
+	page, region, err := this.packSurface(surface)
+	if err != nil {
+		logfile.LogError("SDLHardwareRenderDevice: Couldn't pack image into atlas: '%s'. %s", filename, err)
+	}
+
+	if page != nil {
+		img.atlas = page
+		img.region = region
+		img.SetSurface(page.texture)
+	} else {
+		texture, err := allocs.SdlCreateTextureFromSurface(this.renderer, surface)
+		if err != nil {
+			logfile.LogError("SDLHardwareRenderDevice: Couldn't load image: '%s'. %s", filename, err)
+			return nil, err
+		}
+
+		img.SetSurface(texture)
+	}
+
+	img.Ref() // +1
+	this.CacheStore(filename, img)
+	return img, nil
+}
+
+// 图集图片在共享纹理里的偏移
+func atlasOffset(img common.Image) (int32, int32) {
+	ptr, ok := img.(*Image)
+	if !ok || ptr.atlas == nil {
+		return 0, 0
+	}
+
+	return int32(ptr.region.X), int32(ptr.region.Y)
+}
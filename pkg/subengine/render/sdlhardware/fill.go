@@ -0,0 +1,123 @@
+package sdlhardware
+
+import (
+	"math"
+	"monster/pkg/common/color"
+	"monster/pkg/common/fpoint"
+	"monster/pkg/common/rect"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// 半透明填充，绘制时使用混合模式，之后恢复原来的模式
+func (this *RenderDevice) withBlendFill(c color.Color, draw func() error) error {
+	err := this.flushBatch()
+	if err != nil {
+		return err
+	}
+
+	var prev sdl.BlendMode
+	err = this.renderer.GetDrawBlendMode(&prev)
+	if err != nil {
+		return err
+	}
+
+	err = this.renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	if err != nil {
+		return err
+	}
+
+	err = this.renderer.SetDrawColor(c.R, c.G, c.B, c.A)
+	if err == nil {
+		err = draw()
+		this.AddDrawCalls(1)
+	}
+
+	if blendErr := this.renderer.SetDrawBlendMode(prev); err == nil {
+		err = blendErr
+	}
+
+	return err
+}
+
+func (this *RenderDevice) DrawFilledRect(r rect.Rect, c color.Color) error {
+	if r.W <= 0 || r.H <= 0 {
+		return nil
+	}
+
+	return this.withBlendFill(c, func() error {
+		return this.renderer.FillRect(&sdl.Rect{X: int32(r.X), Y: int32(r.Y), W: int32(r.W), H: int32(r.H)})
+	})
+}
+
+// 每三个点一个三角形，相邻三角形共用的边不会重复混合
+func (this *RenderDevice) DrawFilledTriangles(points []fpoint.FPoint, c color.Color) error {
+	count := len(points) / 3 * 3
+	if count == 0 {
+		return nil
+	}
+
+	return this.withBlendFill(c, func() error {
+		if !this.geometryDisabled {
+			this.vertices = this.vertices[:0]
+			this.indices = this.indices[:0]
+
+			for i := 0; i < count; i++ {
+				this.vertices = append(this.vertices, vertex{points[i].X, points[i].Y, c.R, c.G, c.B, c.A, 0, 0})
+				this.indices = append(this.indices, int32(i))
+			}
+
+			// 没有纹理时使用绘制的混合模式
+			if renderGeometry(this.renderer, nil, this.vertices, this.indices) == nil {
+				return nil
+			}
+
+			// 旧版本sdl，退回逐行填充
+			this.geometryDisabled = true
+		}
+
+		var spans []sdl.Rect
+		for i := 0; i < count; i += 3 {
+			spans = appendTriangleSpans(spans, points[i], points[i+1], points[i+2])
+		}
+
+		if len(spans) == 0 {
+			return nil
+		}
+
+		return this.renderer.FillRects(spans)
+	})
+}
+
+// 三角形覆盖的每行像素，以像素中心判断，区间左闭右开
+func appendTriangleSpans(spans []sdl.Rect, p0, p1, p2 fpoint.FPoint) []sdl.Rect {
+	edges := [3][2]fpoint.FPoint{{p0, p1}, {p1, p2}, {p2, p0}}
+
+	minY := math.Min(float64(p0.Y), math.Min(float64(p1.Y), float64(p2.Y)))
+	maxY := math.Max(float64(p0.Y), math.Max(float64(p1.Y), float64(p2.Y)))
+
+	for y := int(math.Ceil(minY - 0.5)); float64(y)+0.5 < maxY; y++ {
+		cy := float64(y) + 0.5
+		left, right := math.Inf(1), math.Inf(-1)
+
+		for _, e := range edges {
+			ay, by := float64(e[0].Y), float64(e[1].Y)
+			if (cy < ay) == (cy < by) {
+				continue
+			}
+
+			ax, bx := float64(e[0].X), float64(e[1].X)
+			x := ax + (cy-ay)*(bx-ax)/(by-ay)
+			left = math.Min(left, x)
+			right = math.Max(right, x)
+		}
+
+		x0 := int(math.Ceil(left - 0.5))
+		x1 := int(math.Ceil(right - 0.5))
+		if x1 > x0 {
+			spans = append(spans, sdl.Rect{X: int32(x0), Y: int32(y), W: int32(x1 - x0), H: 1})
+		}
+	}
+
+	return spans
+}
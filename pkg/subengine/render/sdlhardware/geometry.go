@@ -0,0 +1,68 @@
+package sdlhardware
+
+/*
+#cgo windows LDFLAGS: -lSDL2
+#cgo linux freebsd darwin openbsd pkg-config: sdl2
+
+#if defined(_WIN32)
+	#include <SDL2/SDL.h>
+#else
+	#include <SDL.h>
+#endif
+
+#if SDL_VERSION_ATLEAST(2,0,18)
+static int renderGeometry(SDL_Renderer *renderer, SDL_Texture *texture, const void *vertices, int numVertices, const int *indices, int numIndices)
+{
+	return SDL_RenderGeometry(renderer, texture, (const SDL_Vertex *)vertices, numVertices, indices, numIndices);
+}
+#else
+static int renderGeometry(SDL_Renderer *renderer, SDL_Texture *texture, const void *vertices, int numVertices, const int *indices, int numIndices)
+{
+	return SDL_SetError("SDL_RenderGeometry is not supported before SDL 2.0.18");
+}
+#endif
+*/
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+var (
+	Err_render_geometry_failed = errors.New("render geometry failed")
+)
+
+// 和SDL_Vertex内存布局一致
+type vertex struct {
+	x, y       float32
+	r, g, b, a uint8
+	u, v       float32
+}
+
+// go-sdl2 v0.4.10 没有封装SDL_RenderGeometry
+func renderGeometry(renderer *sdl.Renderer, texture *sdl.Texture, vertices []vertex, indices []int32) error {
+	if len(vertices) == 0 || len(indices) == 0 {
+		return nil
+	}
+
+	ret := C.renderGeometry(
+		(*C.SDL_Renderer)(unsafe.Pointer(renderer)),
+		(*C.SDL_Texture)(unsafe.Pointer(texture)),
+		unsafe.Pointer(&vertices[0]),
+		C.int(len(vertices)),
+		(*C.int)(unsafe.Pointer(&indices[0])),
+		C.int(len(indices)),
+	)
+
+	if ret != 0 {
+		if err := sdl.GetError(); err != nil {
+			return err
+		}
+		return Err_render_geometry_failed
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"monster/pkg/allocs"
 	"monster/pkg/common"
 	"monster/pkg/common/color"
+	"monster/pkg/common/rect"
 	"monster/pkg/subengine/render/base"
 
 	"github.com/veandco/go-sdl2/sdl"
@@ -12,6 +13,7 @@ import (
 
 var (
 	Err_bad_args_in_sdlhardwareimage_resize = errors.New("bad args in sdl hardware image resize")
+	Err_atlas_image_read_only               = errors.New("atlas image is read only")
 )
 
 // 和sdl捆绑，重建sdl时需要销毁
@@ -20,6 +22,8 @@ type Image struct {
 	renderer          *sdl.Renderer // 外部指针，不负责销毁
 	surface           *sdl.Texture  // 代表该图片本身
 	pixelBatchSurface *sdl.Surface
+	atlas             *atlasPage // 非空时surface是共享的图集纹理
+	region            rect.Rect  // 在图集里的位置
 }
 
 // 不允许外部使用
@@ -44,6 +48,15 @@ func (this *Image) init(device common.RenderDevice, filename string, renderer *s
 func (this *Image) Clear() {
 	this.renderer = nil
 
+	if this.atlas != nil {
+		// 图集纹理共享，由设备负责销毁
+		if device, ok := this.GetDevice().(*RenderDevice); ok {
+			device.releaseAtlasPage(this.atlas)
+		}
+		this.atlas = nil
+		this.surface = nil
+	}
+
 	if this.surface != nil {
 		//this.surface.Destroy()
 		allocs.Delete(this.surface)
@@ -59,7 +72,21 @@ func (this *Image) Close() {
 	this.Image.Close(this)
 }
 
+// 绘制到自身前提交设备队列里对自身的引用
+func (this *Image) flushDevice() error {
+	device, ok := this.GetDevice().(*RenderDevice)
+	if !ok {
+		return nil
+	}
+
+	return device.flushTexture(this.surface)
+}
+
 func (this *Image) GetWidth() (int, error) {
+	if this.atlas != nil {
+		return this.region.W, nil
+	}
+
 	_, _, w, _, err := this.surface.Query()
 	if err != nil {
 		return 0, nil
@@ -69,6 +96,10 @@ func (this *Image) GetWidth() (int, error) {
 }
 
 func (this *Image) GetHeight() (int, error) {
+	if this.atlas != nil {
+		return this.region.H, nil
+	}
+
 	_, _, _, h, err := this.surface.Query()
 	if err != nil {
 		return 0, err
@@ -90,7 +121,16 @@ func (this *Image) FillWithColor(color color.Color) error {
 		return nil
 	}
 
-	err := this.renderer.SetRenderTarget(this.surface)
+	if this.atlas != nil {
+		return Err_atlas_image_read_only
+	}
+
+	err := this.flushDevice()
+	if err != nil {
+		return err
+	}
+
+	err = this.renderer.SetRenderTarget(this.surface)
 	if err != nil {
 		return err
 	}
@@ -123,6 +163,10 @@ func (this *Image) DrawPixel(x int, y int, color color.Color) error {
 		return nil
 	}
 
+	if this.atlas != nil {
+		return Err_atlas_image_read_only
+	}
+
 	w, err := this.GetWidth()
 	if err != nil {
 		return err
@@ -152,7 +196,12 @@ func (this *Image) DrawPixel(x int, y int, color color.Color) error {
 			this.pixelBatchSurface.Unlock()
 		}
 	} else {
-		err := this.renderer.SetRenderTarget(this.surface)
+		err := this.flushDevice()
+		if err != nil {
+			return err
+		}
+
+		err = this.renderer.SetRenderTarget(this.surface)
 		if err != nil {
 			return err
 		}
@@ -183,7 +232,16 @@ func (this *Image) DrawPixel(x int, y int, color color.Color) error {
 }
 
 func (this *Image) DrawLine(x0, y0, x1, y1 int, color color.Color) error {
-	err := this.renderer.SetRenderTarget(this.surface)
+	if this.atlas != nil {
+		return Err_atlas_image_read_only
+	}
+
+	err := this.flushDevice()
+	if err != nil {
+		return err
+	}
+
+	err = this.renderer.SetRenderTarget(this.surface)
 	if err != nil {
 		return err
 	}
@@ -216,6 +274,10 @@ func (this *Image) BeginPixelBatch() error {
 		return nil
 	}
 
+	if this.atlas != nil {
+		return Err_atlas_image_read_only
+	}
+
 	if this.pixelBatchSurface != nil {
 		//this.pixelBatchSurface.Free()
 		allocs.Delete(this.pixelBatchSurface)
@@ -260,6 +322,11 @@ func (this *Image) EndPixelBatch() error {
 		return nil
 	}
 
+	err := this.flushDevice()
+	if err != nil {
+		return err
+	}
+
 	pixelBatchTexture, err := this.renderer.CreateTextureFromSurface(this.pixelBatchSurface)
 	if err != nil {
 		return err
@@ -311,7 +378,12 @@ func (this *Image) Resize(width, height int) (common.Image, error) {
 		return nil, err
 	}
 
-	err = this.renderer.CopyEx(this.surface, nil, nil, 0, nil, sdl.FLIP_NONE)
+	var src *sdl.Rect
+	if this.atlas != nil {
+		src = &sdl.Rect{X: int32(this.region.X), Y: int32(this.region.Y), W: int32(this.region.W), H: int32(this.region.H)}
+	}
+
+	err = this.renderer.CopyEx(this.surface, src, nil, 0, nil, sdl.FLIP_NONE)
 	if err != nil {
 		return nil, err
 	}
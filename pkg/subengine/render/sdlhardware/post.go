@@ -0,0 +1,302 @@
+package sdlhardware
+
+import (
+	"errors"
+	"monster/pkg/allocs"
+	"monster/pkg/common"
+	"monster/pkg/common/color"
+	"monster/pkg/filesystem/logfile"
+	"monster/pkg/subengine/render/postfx"
+	"unsafe"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+var (
+	Err_render_target_stack_empty = errors.New("render target stack is empty")
+	Err_bad_render_target         = errors.New("bad render target")
+)
+
+// 当前的绘制目标
+func (this *RenderDevice) currentTarget() *sdl.Texture {
+	if len(this.targets) > 0 {
+		return this.targets[len(this.targets)-1]
+	}
+
+	return this.texture
+}
+
+// 之后的绘制都画到image上，直到EndTarget
+func (this *RenderDevice) BeginTarget(image common.Image) error {
+	ptr, ok := image.(*Image)
+	if !ok || ptr.surface == nil {
+		return Err_bad_render_target
+	}
+
+	if ptr.atlas != nil {
+		return Err_atlas_image_read_only
+	}
+
+	err := this.flushBatch()
+	if err != nil {
+		return err
+	}
+
+	err = this.renderer.SetRenderTarget(ptr.surface)
+	if err != nil {
+		return err
+	}
+
+	this.targets = append(this.targets, ptr.surface)
+	return nil
+}
+
+func (this *RenderDevice) EndTarget() error {
+	if len(this.targets) == 0 {
+		return Err_render_target_stack_empty
+	}
+
+	err := this.flushBatch()
+	if err != nil {
+		return err
+	}
+
+	this.targets = this.targets[:len(this.targets)-1]
+	return this.renderer.SetRenderTarget(this.currentTarget())
+}
+
+// 在下一次CommitFrame时保存画面
+func (this *RenderDevice) Screenshot(filename string) {
+	this.screenshot = filename
+}
+
+func (this *RenderDevice) SetColorblind(enable bool) {
+	this.post.SetColorblind(enable)
+}
+
+// 受击等效果的全屏闪烁，frames帧内淡出
+func (this *RenderDevice) FlashScreen(c color.Color, frames int) {
+	if frames <= 0 {
+		return
+	}
+
+	this.flashColor = c
+	this.flashFrames = frames
+	this.flashTotal = frames
+}
+
+func (this *RenderDevice) renderFlash() error {
+	if this.flashFrames <= 0 {
+		return nil
+	}
+
+	alpha := int(this.flashColor.A) * this.flashFrames / this.flashTotal
+	this.flashFrames--
+
+	err := this.renderer.SetRenderTarget(this.texture)
+	if err != nil {
+		return err
+	}
+
+	err = this.renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	if err != nil {
+		return err
+	}
+
+	err = this.renderer.SetDrawColor(this.flashColor.R, this.flashColor.G, this.flashColor.B, uint8(alpha))
+	if err != nil {
+		return err
+	}
+
+	err = this.renderer.FillRect(nil)
+	if err != nil {
+		return err
+	}
+
+	return this.renderer.SetDrawBlendMode(sdl.BLENDMODE_NONE)
+}
+
+// 读回整帧
+func (this *RenderDevice) readFrame() ([]byte, int, int, error) {
+	_, _, w, h, err := this.texture.Query()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	size := int(w) * int(h) * 4
+	if cap(this.framePixels) < size {
+		this.framePixels = make([]byte, size)
+	}
+	this.framePixels = this.framePixels[:size]
+
+	err = this.renderer.SetRenderTarget(this.texture)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	err = this.renderer.ReadPixels(nil, sdl.PIXELFORMAT_RGBA32, unsafe.Pointer(&this.framePixels[0]), int(w)*4)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	return this.framePixels, int(w), int(h), nil
+}
+
+// 返回最终要显示的纹理
+func (this *RenderDevice) postProcess() (*sdl.Texture, error) {
+	err := this.renderFlash()
+	if err != nil {
+		return nil, err
+	}
+
+	if this.screenshot != "" {
+		err = this.saveScreenshot()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !this.post.IsActive() {
+		return this.texture, nil
+	}
+
+	if !this.post.NeedsReadback() && !this.gammaBlendFailed {
+		frame, err := this.blendGamma()
+		if err == nil {
+			return frame, nil
+		}
+
+		// 渲染器不支持自定义混合模式，改用读回
+		logfile.LogError("SDLHardwareRenderDevice: Custom blend mode not supported, gamma falls back to software. %s", err)
+		this.gammaBlendFailed = true
+	}
+
+	pixels, w, h, err := this.readFrame()
+	if err != nil {
+		return nil, err
+	}
+
+	this.post.Apply(pixels)
+
+	if this.postTexture == nil {
+		this.postTexture, err = allocs.SdlCreateTexture(this.renderer, sdl.PIXELFORMAT_RGBA32, sdl.TEXTUREACCESS_STREAMING, int32(w), int32(h))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = this.postTexture.Update(nil, pixels, w*4)
+	if err != nil {
+		return nil, err
+	}
+
+	return this.postTexture, nil
+}
+
+// 在显卡上调整伽马：先复制一份画面，再用 dst ± src*(1-dst) 把画面叠上去
+func (this *RenderDevice) blendGamma() (*sdl.Texture, error) {
+	_, _, w, h, err := this.texture.Query()
+	if err != nil {
+		return nil, err
+	}
+
+	if this.postTarget == nil {
+		this.postTarget, err = allocs.SdlCreateTexture(this.renderer, sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_TARGET, w, h)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s := this.post.GetGammaBlend()
+	op := sdl.BLENDOPERATION_ADD
+	if s < 0 {
+		op = sdl.BLENDOPERATION_REV_SUBTRACT
+		s = -s
+	}
+
+	mode := sdl.ComposeCustomBlendMode(sdl.BLENDFACTOR_ONE_MINUS_DST_COLOR, sdl.BLENDFACTOR_ONE, op, sdl.BLENDFACTOR_ZERO, sdl.BLENDFACTOR_ONE, sdl.BLENDOPERATION_ADD)
+
+	oldMode, err := this.texture.GetBlendMode()
+	if err != nil {
+		return nil, err
+	}
+
+	err = this.renderer.SetRenderTarget(this.postTarget)
+	if err != nil {
+		return nil, err
+	}
+
+	err = this.texture.SetBlendMode(sdl.BLENDMODE_NONE)
+	if err != nil {
+		return nil, err
+	}
+
+	err = this.renderer.Copy(this.texture, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// 不支持时这里返回错误
+	err = this.texture.SetBlendMode(mode)
+	if err != nil {
+		this.texture.SetBlendMode(oldMode)
+		return nil, err
+	}
+
+	mod := uint8(s*255 + 0.5)
+	err = this.texture.SetColorMod(mod, mod, mod)
+	if err == nil {
+		err = this.renderer.Copy(this.texture, nil, nil)
+	}
+
+	this.texture.SetColorMod(255, 255, 255)
+	this.texture.SetBlendMode(oldMode)
+	if err != nil {
+		return nil, err
+	}
+
+	return this.postTarget, nil
+}
+
+// 截图只读回这一帧，编码和写文件放到协程里，不卡住画面
+func (this *RenderDevice) saveScreenshot() error {
+	filename := this.screenshot
+	this.screenshot = ""
+
+	pixels, w, h, err := this.readFrame()
+	if err != nil {
+		return err
+	}
+
+	// 截图不透明
+	tmp := make([]byte, len(pixels))
+	copy(tmp, pixels)
+	this.post.Apply(tmp)
+	for i := 3; i < len(tmp); i += 4 {
+		tmp[i] = 255
+	}
+
+	go func() {
+		err := postfx.SavePNG(filename, tmp, w, h)
+		if err != nil {
+			logfile.LogError("SDLHardwareRenderDevice: Couldn't save screenshot '%s'. %s", filename, err)
+			return
+		}
+
+		logfile.LogInfo("SDLHardwareRenderDevice: Saved screenshot to '%s'", filename)
+	}()
+
+	return nil
+}
+
+func (this *RenderDevice) clearPostTexture() {
+	if this.postTexture != nil {
+		allocs.Delete(this.postTexture)
+		this.postTexture = nil
+	}
+
+	if this.postTarget != nil {
+		allocs.Delete(this.postTarget)
+		this.postTarget = nil
+	}
+}
@@ -10,11 +10,13 @@ import (
 	"monster/pkg/filesystem/logfile"
 	"monster/pkg/subengine/cursormanager"
 	"monster/pkg/subengine/render/base"
+	"monster/pkg/subengine/render/lru"
+	"monster/pkg/subengine/render/postfx"
 	"monster/pkg/utils"
+	"sync"
 
 	"github.com/veandco/go-sdl2/img"
 	"github.com/veandco/go-sdl2/sdl"
-	"github.com/veandco/go-sdl2/ttf"
 )
 
 type RenderDevice struct {
@@ -24,11 +26,39 @@ type RenderDevice struct {
 	titlebarIcon    *sdl.Surface
 	title           string
 	backgroundColor color.Color
-	gammaR          *[256]uint16
-	gammaG          *[256]uint16
-	gammaB          *[256]uint16
 	curs            common.CursorManager
 	texture         *sdl.Texture
+
+	preloadMu sync.Mutex
+	preloaded map[string]*sdl.Surface // 加载协程里解码好的图片，等待上传
+
+	atlasPages []*atlasPage // 动画、瓦片、图标打包用的图集
+	atlasSize  int
+	atlasCount int
+
+	textCache   *lru.Cache // 整串文字的纹理
+	glyphCache  *lru.Cache // 字形在字形图集里的位置
+	glyphPage   *atlasPage // 字形图集，放满后清空
+	glyphResets int        // 字形图集清空的次数
+
+	queue            []drawCmd // 本帧排队的绘制
+	vertices         []vertex
+	indices          []int32
+	geometryDisabled bool // sdl不支持RenderGeometry
+
+	targets     []*sdl.Texture // BeginTarget压栈的离屏目标
+	post        *postfx.Filter // 伽马和色盲矫正
+	postTexture *sdl.Texture   // 读回处理后的画面
+	postTarget  *sdl.Texture   // 显卡上调整伽马后的画面
+	framePixels []byte
+	screenshot  string // 等待保存的截图路径
+	flashColor  color.Color
+	flashFrames int
+	flashTotal  int
+
+	gammaBlendFailed bool // 不支持自定义混合模式
+
+	viewport sdl.Rect // 画面在窗口里的位置，输出像素
 }
 
 func NewRenderDevice(settings common.Settings, eset common.EngineSettings) *RenderDevice {
@@ -42,13 +72,13 @@ func NewRenderDevice(settings common.Settings, eset common.EngineSettings) *Rend
 func ConstructRenderDevice(settings common.Settings, eset common.EngineSettings) RenderDevice {
 	impl := RenderDevice{
 		backgroundColor: color.Construct(0, 0, 0),
-		gammaR:          &[256]uint16{},
-		gammaG:          &[256]uint16{},
-		gammaB:          &[256]uint16{},
+		preloaded:       map[string]*sdl.Surface{},
+		post:            postfx.New(),
 	}
 
 	// base
 	impl.RenderDevice = base.ConstructRenderDevice()
+	impl.textCache, impl.glyphCache = newTextCaches()
 
 	strDriver, err := sdl.GetCurrentVideoDriver()
 	if err != nil {
@@ -177,14 +207,7 @@ func (this *RenderDevice) CreateContextInternal(settings common.Settings, eset c
 		this.window.SetPosition(sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED)
 
 		if !this.IsInitialized {
-
-			// save the system gamma levels if we just created the window
-			this.gammaR, this.gammaG, this.gammaB, err = this.window.GetGammaRamp()
-			if err != nil {
-				return err
-			}
 			logfile.LogInfo("RenderDevice: Window size is %dx%d", s.Get("resolution_w").(int), s.Get("resolution_h").(int))
-
 		}
 
 		this.Fullscreen = s.Get("fullscreen").(bool)
@@ -234,13 +257,22 @@ func (this *RenderDevice) CreateContextInternal(settings common.Settings, eset c
 		s.Set("gamma", float32(1))
 	}
 
+	this.SetColorblind(s.Get("colorblind").(bool))
+
 	return nil
 }
 
 func (this *RenderDevice) DestroyContext() {
 	this.ResetGamma()
 
+	this.discardBatch()
+	this.targets = nil
+	this.clearPostTexture()
+	this.gammaBlendFailed = false // 换了渲染器重新检查
 	this.RenderDevice.CacheRemoveAll()
+	this.clearPreloaded()
+	this.clearTextCache()
+	this.clearAtlas()
 	this.IsReloadGraphics = true // 设置已经重置过渲染系统
 
 	//TODO
@@ -294,7 +326,10 @@ func (this *RenderDevice) CreateContextError() {
 // 修改分辨率和视口，重建素材, 更新配置
 func (this *RenderDevice) WindowResize(settings common.Settings, eset common.EngineSettings) error {
 	this.RenderDevice.WindowResizeInternal(this, settings, eset)
-	this.renderer.SetLogicalSize((int32)(settings.GetViewW()), (int32)(settings.GetViewH()))
+	this.updateViewport(settings)
+	this.discardBatch()
+	this.targets = nil
+	this.clearPostTexture()
 	if this.texture != nil {
 		//this.texture.Destroy()
 		allocs.Delete(this.texture)
@@ -316,6 +351,29 @@ func (this *RenderDevice) WindowResize(settings common.Settings, eset common.Eng
 	return nil
 }
 
+// 黑边由我们自己处理，不用sdl的逻辑大小，鼠标坐标在InputState里换算
+func (this *RenderDevice) updateViewport(settings common.Settings) {
+	winW, winH := this.GetWindowSize()
+	if winW <= 0 || winH <= 0 {
+		return
+	}
+
+	outW, outH, err := this.renderer.GetOutputSize()
+	if err != nil {
+		outW, outH = int32(winW), int32(winH)
+	}
+
+	// 高分屏上输出像素比窗口坐标多
+	ratioX := float32(outW) / float32(winW)
+	ratioY := float32(outH) / float32(winH)
+	offset := settings.GetViewOffset()
+
+	this.viewport.X = int32(float32(offset.X) * ratioX)
+	this.viewport.Y = int32(float32(offset.Y) * ratioY)
+	this.viewport.W = outW - 2*this.viewport.X
+	this.viewport.H = outH - 2*this.viewport.Y
+}
+
 func (this *RenderDevice) UpdateTitleBar(settings common.Settings, eset common.EngineSettings, msg common.MessageEngine, mods common.ModManager) error {
 	if this.title != "" {
 		this.title = ""
@@ -339,8 +397,13 @@ func (this *RenderDevice) UpdateTitleBar(settings common.Settings, eset common.E
 		return err
 	}
 
+	data, err := mods.ReadFile(loc)
+	if err != nil {
+		return err
+	}
+
 	//this.titlebarIcon, err = img.Load(loc)
-	this.titlebarIcon, err = allocs.ImgLoad(loc)
+	this.titlebarIcon, err = allocs.ImgLoadRW(data)
 	if err != nil {
 		return err
 	}
@@ -357,14 +420,15 @@ func (this *RenderDevice) UpdateTitleBar(settings common.Settings, eset common.E
 
 }
 
+// 伽马在后处理里实现，不依赖窗口的伽马表
 func (this *RenderDevice) SetGamma(g float32) error {
-	ramp := [256]uint16{}
-	sdl.CalculateGammaRamp(g, &ramp)
-	return this.window.SetGammaRamp(&ramp, &ramp, &ramp)
+	this.post.SetGamma(g)
+	return nil
 }
 
 func (this *RenderDevice) ResetGamma() error {
-	return this.window.SetGammaRamp(this.gammaR, this.gammaG, this.gammaB)
+	this.post.SetGamma(1)
+	return nil
 }
 
 func (this *RenderDevice) GetWindowSize() (int, int) {
@@ -372,13 +436,17 @@ func (this *RenderDevice) GetWindowSize() (int, int) {
 	return int(w), int(h)
 }
 
+// dest没有指定大小时按原图大小绘制
 func (this *RenderDevice) Render1(r common.Renderable, dest rect.Rect) error {
-	dest.W = r.GetSrc().W
-	dest.H = r.GetSrc().H
+	if dest.W <= 0 || dest.H <= 0 {
+		dest.W = r.GetSrc().W
+		dest.H = r.GetSrc().H
+	}
 
 	var src, _dest sdl.Rect
-	src.X = (int32)(r.GetSrc().X)
-	src.Y = (int32)(r.GetSrc().Y)
+	offsetX, offsetY := atlasOffset(r.GetImage())
+	src.X = (int32)(r.GetSrc().X) + offsetX
+	src.Y = (int32)(r.GetSrc().Y) + offsetY
 	src.W = (int32)(r.GetSrc().W)
 	src.H = (int32)(r.GetSrc().H)
 
@@ -387,41 +455,16 @@ func (this *RenderDevice) Render1(r common.Renderable, dest rect.Rect) error {
 	_dest.W = (int32)(dest.W)
 	_dest.H = (int32)(dest.H)
 
-	var err error
-
-	err = this.renderer.SetRenderTarget(this.texture)
-	if err != nil {
-		return err
-	}
-
-	surface := r.GetImage().Surface().(*sdl.Texture)
-	if r.GetBlendMode() == renderable.BLEND_ADD {
-		err = surface.SetBlendMode(sdl.BLENDMODE_ADD)
-		if err != nil {
-			return err
-		}
-	} else {
-		err = surface.SetBlendMode(sdl.BLENDMODE_BLEND)
-		if err != nil {
-			return err
-		}
-	}
-
-	err = surface.SetColorMod(r.GetColorMod().R, r.GetColorMod().G, r.GetColorMod().B)
-	if err != nil {
-		return err
-	}
-
-	err = surface.SetAlphaMod(r.GetAlphaMod())
-	if err != nil {
-		return err
-	}
-
-	err = this.renderer.Copy(surface, &src, &_dest)
-	if err != nil {
-		return err
+	blend := sdl.BLENDMODE_BLEND
+	switch r.GetBlendMode() {
+	case renderable.BLEND_ADD:
+		blend = sdl.BLENDMODE_ADD
+	case renderable.BLEND_MULTIPLY:
+		blend = sdl.BLENDMODE_MOD
 	}
 
+	colorMod := r.GetColorMod()
+	this.queueDraw(r.GetImage(), blend, src, _dest, sdl.Color{colorMod.R, colorMod.G, colorMod.B, r.GetAlphaMod()})
 	return nil
 }
 
@@ -446,8 +489,9 @@ func (this *RenderDevice) Render(r common.Sprite) error {
 	this.MDest.H = this.MClip.H
 
 	var src, dest sdl.Rect
-	src.X = (int32)(this.MClip.X)
-	src.Y = (int32)(this.MClip.Y)
+	offsetX, offsetY := atlasOffset(r.GetGraphics())
+	src.X = (int32)(this.MClip.X) + offsetX
+	src.Y = (int32)(this.MClip.Y) + offsetY
 	src.W = (int32)(this.MClip.W)
 	src.H = (int32)(this.MClip.H)
 
@@ -456,29 +500,8 @@ func (this *RenderDevice) Render(r common.Sprite) error {
 	dest.W = (int32)(this.MDest.W)
 	dest.H = (int32)(this.MDest.H)
 
-	surface := r.GetGraphics().Surface().(*sdl.Texture)
-	err := surface.SetColorMod(r.ColorMod().R, r.ColorMod().G, r.ColorMod().B)
-	if err != nil {
-		return err
-	}
-
-	err = surface.SetAlphaMod(r.AlphaMod())
-	if err != nil {
-		return err
-	}
-
-	err = this.renderer.SetRenderTarget(this.texture)
-	if err != nil {
-		return err
-	}
-
-	// src 和 dest为空就是全部，然后就是拉伸来填充
-	// 文档： the texture will be stretched to fill the given rectangle
-	err = this.renderer.Copy(surface, &src, &dest)
-	if err != nil {
-		return err
-	}
-
+	colorMod := r.ColorMod()
+	this.queueDraw(r.GetGraphics(), sdl.BLENDMODE_BLEND, src, dest, sdl.Color{colorMod.R, colorMod.G, colorMod.B, r.AlphaMod()})
 	return nil
 }
 
@@ -489,8 +512,14 @@ func (this *RenderDevice) RenderToImage(srcImage common.Image, src rect.Rect, de
 		return dest, nil
 	}
 
+	// 目标图片可能已经在队列里
+	err := this.flushBatch()
+	if err != nil {
+		return dest, err
+	}
+
 	destSurface := destImage.Surface().(*sdl.Texture)
-	err := this.renderer.SetRenderTarget(destSurface)
+	err = this.renderer.SetRenderTarget(destSurface)
 	if err != nil {
 		return dest, err
 	}
@@ -499,8 +528,9 @@ func (this *RenderDevice) RenderToImage(srcImage common.Image, src rect.Rect, de
 	dest.H = src.H
 
 	var _src, _dest sdl.Rect
-	_src.X = (int32)(src.X)
-	_src.Y = (int32)(src.Y)
+	offsetX, offsetY := atlasOffset(srcImage)
+	_src.X = (int32)(src.X) + offsetX
+	_src.Y = (int32)(src.Y) + offsetY
 	_src.W = (int32)(src.W)
 	_src.H = (int32)(src.H)
 
@@ -531,47 +561,13 @@ func (this *RenderDevice) RenderToImage(srcImage common.Image, src rect.Rect, de
 	return dest, nil
 }
 
-// 以字符串创建图片
-func (this *RenderDevice) RenderTextToImage(fontStyle common.FontStyle, text string, color color.Color, blended bool) (common.Image, error) {
-	image := newImage(this, "", this.renderer) // +1
-	defer image.UnRef()                        // -1
-
-	var cleanup *sdl.Surface
-	var err error
-
-	_color := sdl.Color{color.R, color.G, color.B, color.A}
-
-	if blended {
-		//cleanup, err = fontStyle.Ttfont().(*ttf.Font).RenderUTF8Blended(text, _color)
-		cleanup, err = allocs.FontRenderUTF8Blended(fontStyle.Ttfont().(*ttf.Font), text, _color)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		//cleanup, err = fontStyle.Ttfont().(*ttf.Font).RenderUTF8Solid(text, _color)
-		cleanup, err = allocs.FontRenderUTF8Solid(fontStyle.Ttfont().(*ttf.Font), text, _color)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	defer allocs.Delete(cleanup)
-	//defer cleanup.Free()
-
-	//surface, err := this.renderer.CreateTextureFromSurface(cleanup)
-	surface, err := allocs.SdlCreateTextureFromSurface(this.renderer, cleanup)
+func (this *RenderDevice) DrawPixel(x, y int, color color.Color) error {
+	err := this.flushBatch()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	image.SetSurface(surface)
-	image.Ref() // +1
-
-	return image, nil
-}
-
-func (this *RenderDevice) DrawPixel(x, y int, color color.Color) error {
-	err := this.renderer.SetDrawColor(color.R, color.G, color.B, color.A)
+	err = this.renderer.SetDrawColor(color.R, color.G, color.B, color.A)
 	if err != nil {
 		return err
 	}
@@ -585,25 +581,25 @@ func (this *RenderDevice) DrawPixel(x, y int, color color.Color) error {
 }
 
 func (this *RenderDevice) DrawLine(x0, y0, x1, y1 int, color color.Color) error {
-	err := this.renderer.SetDrawColor(color.R, color.G, color.B, color.A)
+	err := this.flushBatch()
 	if err != nil {
 		return err
 	}
 
-	err = this.renderer.DrawLine(int32(x0), int32(y0), int32(x1), int32(y1))
+	err = this.renderer.SetDrawColor(color.R, color.G, color.B, color.A)
 	if err != nil {
 		return err
 	}
-	return nil
-}
 
-func (this *RenderDevice) DrawRectangle(p0, p1 point.Point, color color.Color) error {
-	err := this.renderer.SetDrawColor(color.R, color.G, color.B, color.A)
+	err = this.renderer.DrawLine(int32(x0), int32(y0), int32(x1), int32(y1))
 	if err != nil {
 		return err
 	}
+	return nil
+}
 
-	err = this.DrawLine(p0.X, p0.Y, p1.X, p0.Y, color)
+func (this *RenderDevice) DrawRectangle(p0, p1 point.Point, color color.Color) error {
+	err := this.DrawLine(p0.X, p0.Y, p1.X, p0.Y, color)
 	if err != nil {
 		return err
 	}
@@ -625,6 +621,8 @@ func (this *RenderDevice) DrawRectangle(p0, p1 point.Point, color color.Color) e
 }
 
 func (this *RenderDevice) BlankScreen() error {
+	this.discardBatch()
+
 	err := this.renderer.SetRenderTarget(nil)
 	if err != nil {
 		return err
@@ -659,12 +657,28 @@ func (this *RenderDevice) BlankScreen() error {
 }
 
 func (this *RenderDevice) CommitFrame(inpt common.InputState) error {
-	err := this.renderer.SetRenderTarget(nil)
+	err := this.flushBatch()
+	if err != nil {
+		return err
+	}
+	this.EndFrameStats()
+
+	if len(this.targets) > 0 {
+		logfile.LogError("SDLHardwareRenderDevice: %d render target(s) not ended before CommitFrame", len(this.targets))
+		this.targets = nil
+	}
+
+	frame, err := this.postProcess()
+	if err != nil {
+		return err
+	}
+
+	err = this.renderer.SetRenderTarget(nil)
 	if err != nil {
 		return err
 	}
 
-	err = this.renderer.Copy(this.texture, nil, nil)
+	err = this.renderer.Copy(frame, nil, &this.viewport)
 	if err != nil {
 		return err
 	}
@@ -723,19 +737,45 @@ func (this *RenderDevice) CreateImage(width, height int) (common.Image, error) {
 func (this *RenderDevice) LoadImage(settings common.Settings, mods common.ModManager, filename string) (common.Image, error) {
 	image, ok := this.CacheLookup(filename) // +1
 	if ok {
+		// 已缓存，预解码的不再需要
+		if preSurface := this.takePreloaded(filename); preSurface != nil {
+			allocs.Delete(preSurface)
+		}
 		return image, nil
 	}
 
 	image = newImage(this, filename, this.renderer) // +1
 	defer image.UnRef()                             // -1
 
+	// 已经在加载协程里解码，只需要上传
+	if preSurface := this.takePreloaded(filename); preSurface != nil {
+		defer allocs.Delete(preSurface)
+
+		surface, err := allocs.SdlCreateTextureFromSurface(this.renderer, preSurface)
+		if err != nil {
+			logfile.LogError("SDLHardwareRenderDevice: Couldn't load image: '%s'. %s", filename, err)
+			return nil, err
+		}
+
+		image.Ref() // +1
+		image.SetSurface(surface)
+		this.CacheStore(filename, image)
+		return image, nil
+	}
+
 	loc, err := mods.Locate(settings, filename)
 	if err != nil {
 		return nil, err
 	}
 
+	data, err := mods.ReadFile(loc)
+	if err != nil {
+		logfile.LogError("SDLHardwareRenderDevice: Couldn't load image: '%s'. %s", filename, err)
+		return nil, err
+	}
+
 	//surface, err := img.LoadTexture(this.renderer, loc)
-	surface, err := allocs.ImgLoadTexture(this.renderer, loc)
+	surface, err := allocs.ImgLoadTextureRW(this.renderer, data)
 	if err != nil {
 		logfile.LogError("SDLHardwareRenderDevice: Couldn't load image: '%s'. %s", filename, img.GetError())
 		logfile.LogErrorDialog("SDLHardwareRenderDevice: Couldn't load image: '%s'.\n%s", filename, img.GetError())
@@ -748,11 +788,78 @@ func (this *RenderDevice) LoadImage(settings common.Settings, mods common.ModMan
 	return image, nil
 }
 
+// 在加载协程里解码图片，纹理在主线程LoadImage时上传
+func (this *RenderDevice) PreloadImage(settings common.Settings, mods common.ModManager, filename string) error {
+	this.preloadMu.Lock()
+	_, ok := this.preloaded[filename]
+	this.preloadMu.Unlock()
+	if ok {
+		return nil
+	}
+
+	loc, err := mods.Locate(settings, filename)
+	if err != nil {
+		return err
+	}
+
+	data, err := mods.ReadFile(loc)
+	if err != nil {
+		logfile.LogError("SDLHardwareRenderDevice: Couldn't load image: '%s'. %s", filename, err)
+		return err
+	}
+
+	surface, err := allocs.ImgLoadRW(data)
+	if err != nil {
+		logfile.LogError("SDLHardwareRenderDevice: Couldn't load image: '%s'. %s", filename, err)
+		return err
+	}
+
+	this.preloadMu.Lock()
+	defer this.preloadMu.Unlock()
+
+	if _, ok := this.preloaded[filename]; ok {
+		// 别的协程已经解码
+		allocs.Delete(surface)
+		return nil
+	}
+
+	this.preloaded[filename] = surface
+	return nil
+}
+
+func (this *RenderDevice) takePreloaded(filename string) *sdl.Surface {
+	this.preloadMu.Lock()
+	defer this.preloadMu.Unlock()
+
+	surface, ok := this.preloaded[filename]
+	if !ok {
+		return nil
+	}
+
+	delete(this.preloaded, filename)
+	return surface
+}
+
+func (this *RenderDevice) clearPreloaded() {
+	this.preloadMu.Lock()
+	defer this.preloadMu.Unlock()
+
+	for _, ptr := range this.preloaded {
+		allocs.Delete(ptr)
+	}
+
+	this.preloaded = map[string]*sdl.Surface{}
+}
+
 func (this *RenderDevice) SetBackgroundColor(color color.Color) {
 	this.backgroundColor = color
 	this.backgroundColor.A = 255
 }
 
+func (this *RenderDevice) GetBackgroundColor() color.Color {
+	return this.backgroundColor
+}
+
 func (this *RenderDevice) SetFullscreen(settings common.Settings, eset common.EngineSettings, platform common.Platform, enable bool) {
 	if !this.DestructiveFullscreen {
 		if enable {
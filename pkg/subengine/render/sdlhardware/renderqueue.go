@@ -0,0 +1,199 @@
+package sdlhardware
+
+import (
+	"monster/pkg/common"
+	"monster/pkg/filesystem/logfile"
+	"monster/pkg/subengine/render/batch"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// 一次排队的绘制
+type drawCmd struct {
+	image   common.Image // 持有引用，提交后释放
+	texture *sdl.Texture
+	blend   sdl.BlendMode
+	src     sdl.Rect
+	dest    sdl.Rect
+	color   sdl.Color
+}
+
+type batchKey struct {
+	texture *sdl.Texture
+	blend   sdl.BlendMode
+}
+
+// 排队，提交时按纹理和混合状态合批
+func (this *RenderDevice) queueDraw(image common.Image, blend sdl.BlendMode, src, dest sdl.Rect, color sdl.Color) {
+	if src.W <= 0 || src.H <= 0 || dest.W <= 0 || dest.H <= 0 {
+		return
+	}
+
+	image.Ref()
+	this.queue = append(this.queue, drawCmd{
+		image:   image,
+		texture: image.Surface().(*sdl.Texture),
+		blend:   blend,
+		src:     src,
+		dest:    dest,
+		color:   color,
+	})
+}
+
+// 丢弃未提交的绘制
+func (this *RenderDevice) discardBatch() {
+	for i := range this.queue {
+		this.queue[i].image.UnRef()
+		this.queue[i].image = nil
+	}
+
+	this.queue = this.queue[:0]
+}
+
+// 把排队的绘制提交到屏幕纹理
+func (this *RenderDevice) flushBatch() error {
+	if len(this.queue) == 0 {
+		return nil
+	}
+	defer this.discardBatch()
+
+	keys := map[batchKey]int{}
+	cmds := make([]batch.Command, len(this.queue))
+	for i, ptr := range this.queue {
+		k := batchKey{ptr.texture, ptr.blend}
+		id, ok := keys[k]
+		if !ok {
+			id = len(keys)
+			keys[k] = id
+		}
+
+		cmds[i] = batch.Command{
+			Key: id,
+			X:   int(ptr.dest.X),
+			Y:   int(ptr.dest.Y),
+			W:   int(ptr.dest.W),
+			H:   int(ptr.dest.H),
+		}
+	}
+
+	batches := batch.Build(cmds, 0)
+
+	err := this.renderer.SetRenderTarget(this.currentTarget())
+	if err != nil {
+		return err
+	}
+
+	this.AddStateChanges(batch.CountStateChanges(batches) + 1)
+
+	for _, b := range batches {
+		first := this.queue[b.Items[0]]
+
+		err = first.texture.SetBlendMode(first.blend)
+		if err != nil {
+			return err
+		}
+
+		if !this.geometryDisabled {
+			err = this.renderBatchGeometry(first.texture, b.Items)
+			if err == nil {
+				this.AddDrawCalls(1)
+				continue
+			}
+
+			// 旧版本sdl，退回逐个绘制
+			logfile.LogError("SDLHardwareRenderDevice: %s, falling back to RenderCopy", err)
+			this.geometryDisabled = true
+		}
+
+		err = this.renderBatchCopy(b.Items)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (this *RenderDevice) renderBatchGeometry(texture *sdl.Texture, items []int) error {
+	_, _, tw, th, err := texture.Query()
+	if err != nil {
+		return err
+	}
+
+	if tw <= 0 || th <= 0 {
+		return nil
+	}
+
+	// 颜色由顶点决定，纹理本身不调制
+	err = texture.SetColorMod(255, 255, 255)
+	if err != nil {
+		return err
+	}
+
+	err = texture.SetAlphaMod(255)
+	if err != nil {
+		return err
+	}
+
+	fw, fh := float32(tw), float32(th)
+
+	this.vertices = this.vertices[:0]
+	this.indices = this.indices[:0]
+
+	for _, index := range items {
+		ptr := &this.queue[index]
+
+		x0, y0 := float32(ptr.dest.X), float32(ptr.dest.Y)
+		x1, y1 := float32(ptr.dest.X+ptr.dest.W), float32(ptr.dest.Y+ptr.dest.H)
+		u0, v0 := float32(ptr.src.X)/fw, float32(ptr.src.Y)/fh
+		u1, v1 := float32(ptr.src.X+ptr.src.W)/fw, float32(ptr.src.Y+ptr.src.H)/fh
+		c := ptr.color
+
+		base := int32(len(this.vertices))
+		this.vertices = append(this.vertices,
+			vertex{x0, y0, c.R, c.G, c.B, c.A, u0, v0},
+			vertex{x1, y0, c.R, c.G, c.B, c.A, u1, v0},
+			vertex{x0, y1, c.R, c.G, c.B, c.A, u0, v1},
+			vertex{x1, y1, c.R, c.G, c.B, c.A, u1, v1},
+		)
+		this.indices = append(this.indices, base, base+1, base+2, base+2, base+1, base+3)
+	}
+
+	return renderGeometry(this.renderer, texture, this.vertices, this.indices)
+}
+
+func (this *RenderDevice) renderBatchCopy(items []int) error {
+	for _, index := range items {
+		ptr := &this.queue[index]
+
+		err := ptr.texture.SetColorMod(ptr.color.R, ptr.color.G, ptr.color.B)
+		if err != nil {
+			return err
+		}
+
+		err = ptr.texture.SetAlphaMod(ptr.color.A)
+		if err != nil {
+			return err
+		}
+
+		err = this.renderer.Copy(ptr.texture, &ptr.src, &ptr.dest)
+		if err != nil {
+			return err
+		}
+
+		this.AddDrawCalls(1)
+	}
+
+	return nil
+}
+
+// 修改纹理前，先提交还在排队的旧内容
+func (this *RenderDevice) flushTexture(texture *sdl.Texture) error {
+	for i := range this.queue {
+		if this.queue[i].texture == texture {
+			return this.flushBatch()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,385 @@
+package sdlhardware
+
+import (
+	"monster/pkg/allocs"
+	"monster/pkg/common"
+	"monster/pkg/common/color"
+	"monster/pkg/common/rect"
+	"monster/pkg/subengine/render/atlas"
+	"monster/pkg/subengine/render/lru"
+	"unicode"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/veandco/go-sdl2/ttf"
+)
+
+const (
+	TEXT_CACHE_SIZE  = 256  // 缓存的整串文字纹理
+	GLYPH_CACHE_SIZE = 4096 // 缓存的字形
+	GLYPH_ATLAS_SIZE = 1024 // 字形图集的边长，放满后整页清空重来
+)
+
+// 字形按白色渲染，绘制时用颜色调制上色，所以不区分颜色
+// 字体用id区分，重新加载的字体即使地址相同也不会命中旧的缓存
+type glyphKey struct {
+	font    uint64
+	ch      rune
+	blended bool
+}
+
+type glyph struct {
+	region  rect.Rect // 在字形图集里的位置，空白字符为空
+	minX    int
+	maxY    int
+	advance int
+}
+
+type textKey struct {
+	font    uint64
+	text    string
+	color   color.Color
+	blended bool
+}
+
+func newTextCaches() (*lru.Cache, *lru.Cache) {
+	textCache := lru.New(TEXT_CACHE_SIZE, func(key, value interface{}) {
+		value.(*Image).UnRef()
+	})
+
+	// 字形只记录图集里的位置，淘汰时不用释放
+	glyphCache := lru.New(GLYPH_CACHE_SIZE, nil)
+
+	return textCache, glyphCache
+}
+
+// 图集释放前调用
+func (this *RenderDevice) clearTextCache() {
+	this.textCache.Clear()
+	this.glyphCache.Clear()
+
+	if this.glyphPage != nil {
+		this.glyphPage.free()
+		this.glyphPage = nil
+	}
+}
+
+// 累计的整串文字和字形缓存命中次数
+func (this *RenderDevice) GetTextCacheStats() (int, int, int, int) {
+	textHits, textMisses := this.textCache.GetStats()
+	glyphHits, glyphMisses := this.glyphCache.GetStats()
+
+	return textHits, textMisses, glyphHits, glyphMisses
+}
+
+func (this *RenderDevice) getGlyphAtlasSize() int {
+	size := this.getAtlasSize()
+	if size > GLYPH_ATLAS_SIZE {
+		size = GLYPH_ATLAS_SIZE
+	}
+
+	return size
+}
+
+// 字形单独使用一页图集，不和动画图片混在一起
+func (this *RenderDevice) getGlyphPage() (*atlasPage, error) {
+	if this.glyphPage != nil {
+		return this.glyphPage, nil
+	}
+
+	size := this.getGlyphAtlasSize()
+	texture, err := allocs.SdlCreateTexture(this.renderer, sdl.PIXELFORMAT_ABGR8888, sdl.TEXTUREACCESS_STATIC, int32(size), int32(size))
+	if err != nil {
+		return nil, err
+	}
+
+	err = texture.SetBlendMode(sdl.BLENDMODE_BLEND)
+	if err != nil {
+		allocs.Delete(texture)
+		return nil, err
+	}
+
+	this.glyphPage = &atlasPage{
+		id:      "glyphs",
+		texture: texture,
+		packer:  atlas.NewPacker(size, size, ATLAS_PADDING),
+	}
+
+	this.glyphPage.report()
+	return this.glyphPage, nil
+}
+
+// 图集放满，清空所有字形，纹理留着重用
+func (this *RenderDevice) resetGlyphPage() {
+	size := this.getGlyphAtlasSize()
+
+	this.glyphCache.Clear()
+	this.glyphPage.packer = atlas.NewPacker(size, size, ATLAS_PADDING)
+	this.glyphPage.report()
+	this.glyphResets++
+}
+
+// 把字形放进字形图集，放满时先清空
+func (this *RenderDevice) packGlyph(surface *sdl.Surface) (rect.Rect, bool, error) {
+	w, h := int(surface.W), int(surface.H)
+
+	page, err := this.getGlyphPage()
+	if err != nil {
+		return rect.Rect{}, false, err
+	}
+
+	x, y, ok := page.packer.Insert(w, h)
+	if !ok {
+		this.resetGlyphPage()
+
+		// 空的图集都放不下
+		x, y, ok = page.packer.Insert(w, h)
+		if !ok {
+			return rect.Rect{}, false, nil
+		}
+	}
+
+	conv, err := surface.ConvertFormat(sdl.PIXELFORMAT_ABGR8888, 0)
+	if err != nil {
+		return rect.Rect{}, false, err
+	}
+	defer conv.Free()
+
+	dst := sdl.Rect{X: int32(x), Y: int32(y), W: int32(w), H: int32(h)}
+
+	if conv.MustLock() {
+		conv.Lock()
+	}
+	err = page.texture.Update(&dst, conv.Pixels(), int(conv.Pitch))
+	if conv.MustLock() {
+		conv.Unlock()
+	}
+
+	if err != nil {
+		return rect.Rect{}, false, err
+	}
+
+	page.report()
+	return rect.Construct(x, y, w, h), true, nil
+}
+
+// 单个字形，返回false表示无法缓存，需要整串渲染
+func (this *RenderDevice) getGlyph(fontId uint64, font *ttf.Font, ch rune, blended bool) (*glyph, bool, error) {
+	// SDL_ttf的字形接口只支持基本多文种平面
+	if ch > 0xFFFF {
+		return nil, false, nil
+	}
+
+	key := glyphKey{font: fontId, ch: ch, blended: blended}
+	if val, ok := this.glyphCache.Get(key); ok {
+		return val.(*glyph), true, nil
+	}
+
+	g := &glyph{}
+	metrics, err := font.GlyphMetrics(ch)
+	if err != nil {
+		return nil, false, err
+	}
+
+	g.minX = metrics.MinX
+	g.maxY = metrics.MaxY
+	g.advance = metrics.Advance
+
+	if metrics.MaxX > metrics.MinX && !unicode.IsSpace(ch) {
+		var surface *sdl.Surface
+		white := sdl.Color{255, 255, 255, 255}
+
+		if blended {
+			surface, err = allocs.FontRenderGlyphBlended(font, ch, white)
+		} else {
+			surface, err = allocs.FontRenderGlyphSolid(font, ch, white)
+		}
+
+		if err != nil {
+			return nil, false, err
+		}
+		defer allocs.Delete(surface)
+
+		region, ok, err := this.packGlyph(surface)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+
+		g.region = region
+	}
+
+	this.glyphCache.Put(key, g)
+	return g, true, nil
+}
+
+// 整串文字需要的字形，途中图集被清空则重新获取
+func (this *RenderDevice) getGlyphs(fontId uint64, font *ttf.Font, text string, blended bool) ([]*glyph, bool, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		var glyphs []*glyph
+		resets := this.glyphResets
+
+		for _, ch := range text {
+			g, ok, err := this.getGlyph(fontId, font, ch, blended)
+			if err != nil || !ok {
+				return nil, false, err
+			}
+
+			glyphs = append(glyphs, g)
+		}
+
+		if resets == this.glyphResets {
+			return glyphs, true, nil
+		}
+	}
+
+	// 一串文字就放满了图集
+	return nil, false, nil
+}
+
+// 用缓存的字形拼出整串文字，字形超出advance的部分留出空间
+func (this *RenderDevice) renderTextFromGlyphs(font *ttf.Font, glyphs []*glyph, color color.Color) (common.Image, error) {
+	left, right := 0, 0
+	x := 0
+	for _, g := range glyphs {
+		if g.region.W > 0 {
+			if x+g.minX < left {
+				left = x + g.minX
+			}
+			if x+g.minX+g.region.W > right {
+				right = x + g.minX + g.region.W
+			}
+		}
+
+		x += g.advance
+		if x > right {
+			right = x
+		}
+	}
+
+	width := right - left
+	if width <= 0 {
+		width = 1
+	}
+
+	image, err := this.CreateImage(width, font.Height()) // +1 调用者持有
+	if err != nil {
+		return nil, err
+	}
+
+	err = this.drawGlyphs(image.(*Image), font, glyphs, -left, color)
+	if err != nil {
+		image.UnRef()
+		return nil, err
+	}
+
+	return image, nil
+}
+
+func (this *RenderDevice) drawGlyphs(image *Image, font *ttf.Font, glyphs []*glyph, x int, color color.Color) error {
+	err := this.flushBatch()
+	if err != nil {
+		return err
+	}
+
+	texture := this.glyphPage.texture
+	err = texture.SetColorMod(color.R, color.G, color.B)
+	if err != nil {
+		return err
+	}
+
+	err = texture.SetAlphaMod(color.A)
+	if err != nil {
+		return err
+	}
+
+	err = this.renderer.SetRenderTarget(image.surface)
+	if err != nil {
+		return err
+	}
+
+	ascent := font.Ascent()
+	for _, g := range glyphs {
+		if g.region.W > 0 {
+			src := sdl.Rect{X: int32(g.region.X), Y: int32(g.region.Y), W: int32(g.region.W), H: int32(g.region.H)}
+			dest := sdl.Rect{X: int32(x + g.minX), Y: int32(ascent - g.maxY), W: src.W, H: src.H}
+
+			err = this.renderer.Copy(texture, &src, &dest)
+			if err != nil {
+				break
+			}
+		}
+
+		x += g.advance
+	}
+
+	if targetErr := this.renderer.SetRenderTarget(this.currentTarget()); err == nil {
+		err = targetErr
+	}
+
+	return err
+}
+
+// 无法用字形拼接时，整串交给SDL_ttf渲染
+func (this *RenderDevice) renderTextDirect(font *ttf.Font, text string, color color.Color, blended bool) (common.Image, error) {
+	image := newImage(this, "", this.renderer) // +1
+	defer image.UnRef()                        // -1
+
+	var cleanup *sdl.Surface
+	var err error
+
+	_color := sdl.Color{color.R, color.G, color.B, color.A}
+
+	if blended {
+		cleanup, err = allocs.FontRenderUTF8Blended(font, text, _color)
+	} else {
+		cleanup, err = allocs.FontRenderUTF8Solid(font, text, _color)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer allocs.Delete(cleanup)
+
+	surface, err := allocs.SdlCreateTextureFromSurface(this.renderer, cleanup)
+	if err != nil {
+		return nil, err
+	}
+
+	image.SetSurface(surface)
+	image.Ref() // +1 调用者持有
+
+	return image, nil
+}
+
+// 以字符串创建图片，相同的文字、字体和颜色直接使用缓存
+func (this *RenderDevice) RenderTextToImage(fontStyle common.FontStyle, text string, color color.Color, blended bool) (common.Image, error) {
+	font := fontStyle.Ttfont().(*ttf.Font)
+
+	fontId := fontStyle.GetId()
+
+	key := textKey{font: fontId, text: text, color: color, blended: blended}
+	if val, ok := this.textCache.Get(key); ok {
+		image := val.(*Image)
+		image.Ref() // +1 调用者持有
+		return image, nil
+	}
+
+	var image common.Image
+	glyphs, ok, err := this.getGlyphs(fontId, font, text, blended)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		image, err = this.renderTextFromGlyphs(font, glyphs, color) // +1 调用者持有
+	} else {
+		image, err = this.renderTextDirect(font, text, color, blended) // +1 调用者持有
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	image.Ref() // +1 缓存持有
+	this.textCache.Put(key, image.(*Image))
+	return image, nil
+}
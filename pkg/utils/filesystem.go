@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io/fs"
 	"os"
+	"path"
 	"strings"
 )
 
@@ -72,17 +73,17 @@ func PathExists(path string) (bool, error) {
 	return true, nil
 }
 
-// 获取指定扩展名的全部文件
-func GetFileList(dir, ext string, files []string) ([]string, error) {
-	fs, err := os.ReadDir(dir)
+// 获取指定扩展名的全部文件 dir为fsys内的相对路径
+func GetFileList(fsys fs.FS, dir, ext string, files []string) ([]string, error) {
+	fs1, err := fs.ReadDir(fsys, dir)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, f := range fs {
+	for _, f := range fs1 {
 		if !f.IsDir() {
 			if strings.HasSuffix(f.Name(), ext) {
-				files = append(files, dir+"/"+f.Name())
+				files = append(files, path.Join(dir, f.Name()))
 			}
 		}
 	}
@@ -9,6 +9,7 @@ import (
 	"monster/pkg/common/define/fontengine"
 	"monster/pkg/common/item"
 	"monster/pkg/common/labelinfo"
+	"monster/pkg/common/light"
 	"monster/pkg/common/point"
 	"monster/pkg/common/rect"
 	"reflect"
@@ -312,6 +313,27 @@ func ToRGB(strVal string) color.Color {
 	return c
 }
 
+// radius,r,g,b[,flicker]，flicker为百分比
+func ToLight(strVal string) light.Light {
+	l := light.Construct()
+
+	var first string
+	first, strVal = PopFirstString(strVal, "")
+	l.Radius = ToFloat(first, 0)
+
+	if strVal != "" {
+		l.Color = ToRGB(strVal)
+		for i := 0; i < 3; i++ {
+			_, strVal = PopFirstString(strVal, "")
+		}
+	}
+
+	first, strVal = PopFirstString(strVal, "")
+	l.Flicker = (float32)(math.Min(math.Max((float64)(ToFloat(first, 0))/100, 0), 1))
+
+	return l
+}
+
 func ToPoint(strVal string) point.Point {
 	p := point.Construct()
 
@@ -1,6 +1,7 @@
 package parsing
 
 import (
+	"monster/pkg/common/color"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -123,3 +124,17 @@ func Test_ToDuration(t *testing.T) {
 	r.Equal(4, ToDuration("60 ms", 60))
 	r.Equal(1, ToDuration("17ms", 60))
 }
+
+func Test_ToLight(t *testing.T) {
+	r := require.New(t)
+
+	l := ToLight("3.5,255,128,0,25")
+	r.Equal(float32(3.5), l.Radius)
+	r.Equal(color.Construct(255, 128, 0), l.Color)
+	r.Equal(float32(0.25), l.Flicker)
+
+	l = ToLight("2")
+	r.Equal(float32(2), l.Radius)
+	r.Equal(color.Construct(255, 255, 255), l.Color)
+	r.Equal(float32(0), l.Flicker)
+}
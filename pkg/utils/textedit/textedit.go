@@ -0,0 +1,219 @@
+// 单行文本编辑，按rune操作，光标和选区都是rune下标
+package textedit
+
+type Buffer struct {
+	text   []rune
+	cursor int
+	anchor int // 选区的另一端，等于cursor表示没有选区
+	maxLen int // 最大rune数，0 不限制
+}
+
+func Construct() Buffer {
+	return Buffer{}
+}
+
+func (this *Buffer) SetMaxLength(n int) {
+	this.maxLen = n
+	if n > 0 && len(this.text) > n {
+		this.text = this.text[:n]
+		this.clamp()
+	}
+}
+
+func (this *Buffer) GetMaxLength() int {
+	return this.maxLen
+}
+
+func (this *Buffer) SetText(s string) {
+	this.text = []rune(s)
+	if this.maxLen > 0 && len(this.text) > this.maxLen {
+		this.text = this.text[:this.maxLen]
+	}
+
+	this.cursor = len(this.text)
+	this.anchor = this.cursor
+}
+
+func (this *Buffer) GetText() string {
+	return string(this.text)
+}
+
+func (this *Buffer) Len() int {
+	return len(this.text)
+}
+
+func (this *Buffer) GetCursor() int {
+	return this.cursor
+}
+
+// 光标之前的文本，用于计算光标的像素位置
+func (this *Buffer) TextBefore(pos int) string {
+	if pos < 0 {
+		pos = 0
+	}
+	if pos > len(this.text) {
+		pos = len(this.text)
+	}
+
+	return string(this.text[:pos])
+}
+
+func (this *Buffer) HasSelection() bool {
+	return this.cursor != this.anchor
+}
+
+// 选区 [start, end)
+func (this *Buffer) GetSelection() (int, int) {
+	if this.anchor < this.cursor {
+		return this.anchor, this.cursor
+	}
+
+	return this.cursor, this.anchor
+}
+
+func (this *Buffer) GetSelectedText() string {
+	start, end := this.GetSelection()
+	return string(this.text[start:end])
+}
+
+func (this *Buffer) SelectAll() {
+	this.anchor = 0
+	this.cursor = len(this.text)
+}
+
+// 插入文本，替换选区，超出最大长度的部分丢弃，返回是否有变化
+func (this *Buffer) Insert(s string) bool {
+	changed := this.deleteSelection()
+
+	ins := []rune(s)
+	if this.maxLen > 0 {
+		room := this.maxLen - len(this.text)
+		if room <= 0 {
+			return changed
+		}
+		if len(ins) > room {
+			ins = ins[:room]
+		}
+	}
+
+	if len(ins) == 0 {
+		return changed
+	}
+
+	text := make([]rune, 0, len(this.text)+len(ins))
+	text = append(text, this.text[:this.cursor]...)
+	text = append(text, ins...)
+	text = append(text, this.text[this.cursor:]...)
+
+	this.text = text
+	this.cursor += len(ins)
+	this.anchor = this.cursor
+
+	return true
+}
+
+func (this *Buffer) Backspace() bool {
+	if this.deleteSelection() {
+		return true
+	}
+
+	if this.cursor == 0 {
+		return false
+	}
+
+	this.text = append(this.text[:this.cursor-1], this.text[this.cursor:]...)
+	this.cursor--
+	this.anchor = this.cursor
+
+	return true
+}
+
+func (this *Buffer) Delete() bool {
+	if this.deleteSelection() {
+		return true
+	}
+
+	if this.cursor >= len(this.text) {
+		return false
+	}
+
+	this.text = append(this.text[:this.cursor], this.text[this.cursor+1:]...)
+
+	return true
+}
+
+// 剪切选区，返回被剪切的文本
+func (this *Buffer) Cut() string {
+	s := this.GetSelectedText()
+	this.deleteSelection()
+
+	return s
+}
+
+// 移动光标，selecting为true时扩展选区
+func (this *Buffer) MoveLeft(selecting bool) {
+	if !selecting && this.HasSelection() {
+		start, _ := this.GetSelection()
+		this.moveTo(start, false)
+		return
+	}
+
+	this.moveTo(this.cursor-1, selecting)
+}
+
+func (this *Buffer) MoveRight(selecting bool) {
+	if !selecting && this.HasSelection() {
+		_, end := this.GetSelection()
+		this.moveTo(end, false)
+		return
+	}
+
+	this.moveTo(this.cursor+1, selecting)
+}
+
+func (this *Buffer) Home(selecting bool) {
+	this.moveTo(0, selecting)
+}
+
+func (this *Buffer) End(selecting bool) {
+	this.moveTo(len(this.text), selecting)
+}
+
+// 移动光标到指定位置，超出范围时截断
+func (this *Buffer) SetCursor(pos int, selecting bool) {
+	this.moveTo(pos, selecting)
+}
+
+func (this *Buffer) moveTo(pos int, selecting bool) {
+	this.cursor = pos
+	this.clamp()
+
+	if !selecting {
+		this.anchor = this.cursor
+	}
+}
+
+func (this *Buffer) deleteSelection() bool {
+	if !this.HasSelection() {
+		return false
+	}
+
+	start, end := this.GetSelection()
+	this.text = append(this.text[:start], this.text[end:]...)
+	this.cursor = start
+	this.anchor = start
+
+	return true
+}
+
+func (this *Buffer) clamp() {
+	if this.cursor < 0 {
+		this.cursor = 0
+	}
+	if this.cursor > len(this.text) {
+		this.cursor = len(this.text)
+	}
+	if this.anchor > len(this.text) {
+		this.anchor = len(this.text)
+	}
+}
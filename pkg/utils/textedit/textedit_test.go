@@ -0,0 +1,94 @@
+package textedit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertAndDelete(t *testing.T) {
+	r := require.New(t)
+
+	b := Construct()
+	r.True(b.Insert("勇者abc"))
+	r.Equal("勇者abc", b.GetText())
+	r.Equal(5, b.GetCursor())
+
+	r.True(b.Backspace())
+	r.Equal("勇者ab", b.GetText())
+
+	b.Home(false)
+	r.True(b.Delete())
+	r.Equal("者ab", b.GetText())
+
+	b.MoveRight(false)
+	r.True(b.Backspace())
+	r.Equal("ab", b.GetText())
+	r.False(b.Backspace())
+}
+
+func TestSelection(t *testing.T) {
+	r := require.New(t)
+
+	b := Construct()
+	b.SetText("hello 世界")
+	b.MoveLeft(true)
+	b.MoveLeft(true)
+
+	start, end := b.GetSelection()
+	r.Equal(6, start)
+	r.Equal(8, end)
+	r.Equal("世界", b.GetSelectedText())
+
+	r.True(b.Insert("go"))
+	r.Equal("hello go", b.GetText())
+	r.False(b.HasSelection())
+
+	b.SelectAll()
+	r.Equal("hello go", b.Cut())
+	r.Equal("", b.GetText())
+
+	// 没有选区时左移退到选区开始
+	b.SetText("abcd")
+	b.Home(false)
+	b.MoveRight(true)
+	b.MoveRight(true)
+	b.MoveLeft(false)
+	r.Equal(0, b.GetCursor())
+	r.False(b.HasSelection())
+}
+
+func TestMaxLength(t *testing.T) {
+	r := require.New(t)
+
+	b := Construct()
+	b.SetMaxLength(4)
+	b.Insert("名字太长了")
+	r.Equal("名字太长", b.GetText())
+	r.False(b.Insert("x"))
+
+	b.SetText("abcdef")
+	r.Equal("abcd", b.GetText())
+
+	b.SetMaxLength(2)
+	r.Equal("ab", b.GetText())
+	r.Equal(2, b.GetCursor())
+}
+
+func TestSetCursor(t *testing.T) {
+	r := require.New(t)
+
+	b := Construct()
+	b.SetText("abcdef")
+	b.SetCursor(3, false)
+	r.Equal(3, b.GetCursor())
+	r.False(b.HasSelection())
+
+	b.SetCursor(5, true)
+	start, end := b.GetSelection()
+	r.Equal(3, start)
+	r.Equal(5, end)
+
+	b.SetCursor(99, false)
+	r.Equal(6, b.GetCursor())
+}
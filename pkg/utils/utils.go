@@ -264,7 +264,32 @@ func GetTimeString(time int) string {
 	return ss
 }
 
+// 窗口坐标转换到内部渲染分辨率的坐标，去掉黑边，落在黑边上的点贴到画面边缘
+func WindowToScreen(settings common.Settings, x, y int) point.Point {
+	offset := settings.GetViewOffset()
+	scaling := settings.GetViewScaling()
+
+	r := point.Construct()
+	r.X = (int)((float32)(x-offset.X) * scaling)
+	r.Y = (int)((float32)(y-offset.Y) * scaling)
+
+	if r.X < 0 {
+		r.X = 0
+	} else if r.X >= settings.GetViewW() {
+		r.X = settings.GetViewW() - 1
+	}
+
+	if r.Y < 0 {
+		r.Y = 0
+	} else if r.Y >= settings.GetViewH() {
+		r.Y = settings.GetViewH() - 1
+	}
+
+	return r
+}
+
 // 屏幕上的点转换到地图上
+// x, y 是内部渲染分辨率的坐标，窗口坐标先经过WindowToScreen
 func ScreenToMap(settings common.Settings, eset common.EngineSettings, x, y int, camX, camY float32) fpoint.FPoint {
 
 	r := fpoint.Construct()
@@ -146,6 +146,10 @@ func (this *Widget) SetFocusable(focusable bool) {
 	this.focusable = focusable
 }
 
+func (this *Widget) GetFocusable() bool {
+	return this.focusable
+}
+
 func (this *Widget) GetInFocus() bool {
 	return this.inFocus
 }
@@ -176,8 +176,16 @@ func (this *Button) CheckTooltip(modules common.Modules, mouse point.Point) bool
 	font := modules.Font()
 	tooltipm := modules.Tooltipm()
 
+	show := inpt.UsingMouse(settings) && utils.IsWithinRect(this.GetPos(), mouse)
+
+	// 不使用鼠标时，聚焦的按钮在下方显示提示
+	if !inpt.UsingMouse(settings) && this.GetInFocus() {
+		show = true
+		mouse = point.Construct(this.GetPos().X, this.GetPos().Y+this.GetPos().H)
+	}
+
 	// 要在按钮范围内
-	if inpt.UsingMouse(settings) && utils.IsWithinRect(this.GetPos(), mouse) && this.tooltip != "" {
+	if show && this.tooltip != "" {
 		tipData := tooltipdata.Construct()
 		tipData.AddColorText(this.tooltip, font.GetColor(fontengine.COLOR_WIDGET_NORMAL))
 		newMouse := point.Construct(
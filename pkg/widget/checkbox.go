@@ -121,7 +121,15 @@ func (this *CheckBox) CheckTooltip(modules common.Modules, mouse point.Point) {
 	tipData := tooltipdata.Construct()
 	pos := this.GetPos()
 
-	if inpt.UsingMouse(settings) && utils.IsWithinRect(pos, mouse) && this.tooltip != "" {
+	show := inpt.UsingMouse(settings) && utils.IsWithinRect(pos, mouse)
+
+	// 不使用鼠标时，聚焦的组件在下方显示提示
+	if !inpt.UsingMouse(settings) && this.GetInFocus() {
+		show = true
+		mouse = point.Construct(pos.X, pos.Y+pos.H)
+	}
+
+	if show && this.tooltip != "" {
 		tipData.AddColorText(this.tooltip, font.GetColor(fontengine.COLOR_WIDGET_NORMAL))
 	}
 
@@ -26,6 +26,8 @@ func (obj Factory) New(type1 string) interface{} {
 		return &Slider{}
 	case "checkbox":
 		return &CheckBox{}
+	case "input":
+		return &Input{}
 	case "listbox":
 		return &ListBox{}
 	case "horizontallist":
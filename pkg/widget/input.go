@@ -0,0 +1,439 @@
+package widget
+
+import (
+	"monster/pkg/common"
+	"monster/pkg/common/color"
+	"monster/pkg/common/define/fontengine"
+	"monster/pkg/common/define/inputstate"
+	"monster/pkg/common/define/widget/input"
+	"monster/pkg/common/point"
+	"monster/pkg/utils"
+	"monster/pkg/utils/textedit"
+	"monster/pkg/widget/base"
+	"strings"
+)
+
+// 单行文本输入框
+type Input struct {
+	base.Widget
+	enabled     bool
+	editing     bool // 正在输入
+	textInputOn bool // 已开启系统文字输入
+	background  common.Sprite
+	buf         textedit.Buffer
+	scroll      int // 显示的第一个字符
+	cursorFrame int // 光标闪烁计数
+	fontStyle   string
+	color       color.Color
+}
+
+func NewInput(modules common.Modules, fname string) *Input {
+	in := &Input{}
+	in.Init(modules, fname)
+
+	return in
+}
+
+func (this *Input) Init(modules common.Modules, fname string) common.WidgetInput {
+	render := modules.Render()
+	mods := modules.Mods()
+	settings := modules.Settings()
+	font := modules.Font()
+
+	// base
+	this.Widget = base.ConstructWidget()
+
+	// self
+	this.enabled = true
+	this.buf = textedit.Construct()
+	this.fontStyle = "font_regular"
+	this.color = font.GetColor(fontengine.COLOR_WIDGET_NORMAL)
+	this.SetFocusable(true)
+
+	tmpfname := input.DEFAULT_FILE
+	if fname != "" {
+		tmpfname = fname
+	}
+
+	graphics, err := render.LoadImage(settings, mods, tmpfname)
+	if err != nil {
+		panic(err)
+	}
+	defer graphics.UnRef()
+
+	this.background, err = graphics.CreateSprite()
+	if err != nil {
+		panic(err)
+	}
+
+	gw, err := this.background.GetGraphicsWidth()
+	if err != nil {
+		panic(err)
+	}
+
+	gh, err := this.background.GetGraphicsHeight()
+	if err != nil {
+		panic(err)
+	}
+
+	// 上半部分为普通状态，下半部分为输入状态
+	this.SetPosW(gw)
+	this.SetPosH(gh / 2)
+	this.background.SetClip(0, 0, this.GetPos().W, this.GetPos().H)
+
+	return this
+}
+
+func (this *Input) Clear() {
+	if this.background != nil {
+		this.background.Close()
+		this.background = nil
+	}
+}
+
+func (this *Input) Close() {
+	this.Widget.Close(this)
+}
+
+func (this *Input) SetText(text string) {
+	this.buf.SetText(text)
+	this.scroll = 0
+}
+
+func (this *Input) GetText() string {
+	return this.buf.GetText()
+}
+
+// 最大字符数，按rune计算
+func (this *Input) SetMaxLength(n int) {
+	this.buf.SetMaxLength(n)
+}
+
+func (this *Input) SetEnabled(val bool) {
+	this.enabled = val
+	this.SetEnableTablistNav(val)
+}
+
+func (this *Input) IsEditing() bool {
+	return this.editing
+}
+
+// tablist 激活时开始输入
+func (this *Input) Activate() {
+	this.editing = true
+	this.cursorFrame = 0
+}
+
+func (this *Input) Deactivate() {
+	this.editing = false
+}
+
+func (this *Input) Defocus() {
+	this.Widget.Defocus()
+	this.editing = false
+}
+
+func (this *Input) GetNext(modules common.Modules) bool {
+	return false
+}
+
+func (this *Input) GetPrev(modules common.Modules) bool {
+	return false
+}
+
+func (this *Input) setEditing(modules common.Modules, editing bool) {
+	inpt := modules.Inpt()
+	settings := modules.Settings()
+
+	if editing {
+		inpt.StartTextInput()
+		inpt.SetTextInputRect(settings, this.GetPos())
+	} else {
+		inpt.StopTextInput()
+	}
+
+	this.editing = editing
+	this.textInputOn = editing
+	this.cursorFrame = 0
+
+	// 更换背景
+	pos := this.GetPos()
+	if this.background != nil {
+		if editing {
+			this.background.SetClip(0, pos.H, pos.W, pos.H)
+		} else {
+			this.background.SetClip(0, 0, pos.W, pos.H)
+		}
+	}
+}
+
+// 处理点击和输入，返回文本是否改变
+func (this *Input) Logic(modules common.Modules) bool {
+	inpt := modules.Inpt()
+	settings := modules.Settings()
+	font := modules.Font()
+
+	if !this.enabled {
+		if this.editing {
+			this.setEditing(modules, false)
+		}
+		return false
+	}
+
+	// 同步tablist导致的状态变化
+	if this.editing != this.textInputOn {
+		this.setEditing(modules, this.editing)
+	}
+
+	mouse := inpt.GetMouse()
+
+	// 点击输入框开始输入，点击外面结束
+	if inpt.UsingMouse(settings) && inpt.GetPressing(inputstate.MAIN1) && !inpt.GetLock(inputstate.MAIN1) {
+		if utils.IsWithinRect(this.GetPos(), mouse) {
+			inpt.SetLock(inputstate.MAIN1, true)
+			if !this.editing {
+				this.setEditing(modules, true)
+			}
+
+			font.SetFont(this.fontStyle)
+			this.moveCursorTo(font, mouse.X)
+		} else if this.editing {
+			this.setEditing(modules, false)
+		}
+	}
+
+	if !this.editing {
+		return false
+	}
+
+	this.cursorFrame++
+
+	// 方向键用于移动光标，不让tablist切换
+	for _, key := range []int{inputstate.LEFT, inputstate.RIGHT} {
+		if inpt.GetPressing(key) {
+			inpt.SetLock(key, true)
+		}
+	}
+
+	// 回车或取消结束输入
+	if inpt.GetPressing(inputstate.ACCEPT) && !inpt.GetLock(inputstate.ACCEPT) {
+		inpt.SetLock(inputstate.ACCEPT, true)
+		this.setEditing(modules, false)
+		return false
+	}
+
+	if inpt.GetPressing(inputstate.CANCEL) && !inpt.GetLock(inputstate.CANCEL) {
+		inpt.SetLock(inputstate.CANCEL, true)
+		this.setEditing(modules, false)
+		return false
+	}
+
+	changed := false
+
+	if text := filterText(inpt.GetInKeys()); text != "" {
+		changed = this.buf.Insert(text) || changed
+	}
+
+	for _, cmd := range inpt.GetTextEdit() {
+		switch cmd {
+		case inputstate.TEXTEDIT_LEFT:
+			this.buf.MoveLeft(false)
+		case inputstate.TEXTEDIT_RIGHT:
+			this.buf.MoveRight(false)
+		case inputstate.TEXTEDIT_HOME:
+			this.buf.Home(false)
+		case inputstate.TEXTEDIT_END:
+			this.buf.End(false)
+		case inputstate.TEXTEDIT_SELECT_LEFT:
+			this.buf.MoveLeft(true)
+		case inputstate.TEXTEDIT_SELECT_RIGHT:
+			this.buf.MoveRight(true)
+		case inputstate.TEXTEDIT_SELECT_HOME:
+			this.buf.Home(true)
+		case inputstate.TEXTEDIT_SELECT_END:
+			this.buf.End(true)
+		case inputstate.TEXTEDIT_SELECT_ALL:
+			this.buf.SelectAll()
+		case inputstate.TEXTEDIT_BACKSPACE:
+			changed = this.buf.Backspace() || changed
+		case inputstate.TEXTEDIT_DELETE:
+			changed = this.buf.Delete() || changed
+		case inputstate.TEXTEDIT_COPY:
+			if this.buf.HasSelection() {
+				inpt.SetClipboardText(this.buf.GetSelectedText())
+			}
+		case inputstate.TEXTEDIT_CUT:
+			if this.buf.HasSelection() {
+				inpt.SetClipboardText(this.buf.Cut())
+				changed = true
+			}
+		case inputstate.TEXTEDIT_PASTE:
+			if text := filterText(inpt.GetClipboardText()); text != "" {
+				changed = this.buf.Insert(text) || changed
+			}
+		}
+
+		// 有操作时光标常亮
+		this.cursorFrame = 0
+	}
+
+	return changed
+}
+
+// 单行输入，去掉换行和控制字符
+func filterText(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7F {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// 点击位置对应的字符位置
+func (this *Input) moveCursorTo(font common.FontEngine, x int) {
+	x -= this.GetPos().X + input.PADDING
+
+	// 从第一个显示的字符开始数
+	prefix := this.buf.TextBefore(this.scroll)
+	pos := this.scroll
+	for ; pos < this.buf.Len(); pos++ {
+		start := font.CalcWidth(this.buf.TextBefore(pos)[len(prefix):])
+		end := font.CalcWidth(this.buf.TextBefore(pos + 1)[len(prefix):])
+		if x < (start+end)/2 {
+			break
+		}
+	}
+
+	this.buf.SetCursor(pos, false)
+}
+
+// 保证光标在可见范围内
+func (this *Input) updateScroll(font common.FontEngine, width int) {
+	cursor := this.buf.GetCursor()
+	if cursor < this.scroll {
+		this.scroll = cursor
+	}
+
+	for this.scroll < cursor {
+		visible := this.buf.TextBefore(cursor)[len(this.buf.TextBefore(this.scroll)):]
+		if font.CalcWidth(visible) <= width {
+			break
+		}
+		this.scroll++
+	}
+}
+
+func (this *Input) Render(modules common.Modules) error {
+	render := modules.Render()
+	font := modules.Font()
+	eset := modules.Eset()
+	settings := modules.Settings()
+
+	pos := this.GetPos()
+
+	if this.background != nil {
+		this.background.SetLocalFrame(this.GetLocalFrame())
+		this.background.SetOffset(this.GetLocalOffset())
+		this.background.SetDestFromRect(pos)
+		err := render.Render(this.background)
+		if err != nil {
+			return err
+		}
+	}
+
+	font.SetFont(this.fontStyle)
+
+	innerW := pos.W - input.PADDING*2
+	if innerW <= 0 {
+		return nil
+	}
+
+	this.updateScroll(font, innerW)
+
+	textX := pos.X + input.PADDING
+	textY := pos.Y + (pos.H-font.GetFontHeight())/2
+	prefix := len(this.buf.TextBefore(this.scroll))
+
+	// 输入法组合中的文字插在光标处
+	composition, _ := modules.Inpt().GetComposition()
+	if !this.editing {
+		composition = ""
+	}
+
+	before := this.buf.TextBefore(this.buf.GetCursor())[prefix:]
+	after := this.buf.GetText()[len(this.buf.TextBefore(this.buf.GetCursor())):]
+	visible := font.TrimTextToWidth(before+composition+after, innerW, false, 0)
+
+	// 选区高亮
+	if this.editing && this.buf.HasSelection() {
+		start, end := this.buf.GetSelection()
+		if start < this.scroll {
+			start = this.scroll
+		}
+
+		x0 := textX + font.CalcWidth(this.buf.TextBefore(start)[prefix:])
+		x1 := textX + font.CalcWidth(this.buf.TextBefore(end)[prefix:])
+		if x1 > textX+innerW {
+			x1 = textX + innerW
+		}
+
+		selColor := eset.Get("widgets", "selection_rect_color").(color.Color)
+		for y := textY; y < textY+font.GetFontHeight(); y++ {
+			err := render.DrawLine(x0, y, x1, y, selColor)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if visible != "" {
+		err := font.Render(render, visible, textX, textY, fontengine.JUSTIFY_LEFT, nil, 0, this.color)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !this.editing {
+		return nil
+	}
+
+	cursorX := textX + font.CalcWidth(before)
+
+	// 组合文字下划线
+	if composition != "" {
+		compW := font.CalcWidth(composition)
+		y := textY + font.GetFontHeight()
+		err := render.DrawLine(cursorX, y, cursorX+compW, y, this.color)
+		if err != nil {
+			return err
+		}
+		cursorX += compW
+	}
+
+	// 光标每半秒闪烁
+	maxFPS := settings.Get("max_fps").(int)
+	if maxFPS <= 0 || (this.cursorFrame/(maxFPS/2+1))%2 == 0 {
+		if cursorX > textX+innerW {
+			cursorX = textX + innerW
+		}
+
+		err := render.DrawLine(cursorX, textY, cursorX, textY+font.GetFontHeight(), this.color)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 选择边框
+	if this.GetInFocus() {
+		topLeft := point.Construct(pos.X, pos.Y)
+		bottomRight := point.Construct(pos.X+pos.W, pos.Y+pos.H)
+
+		err := render.DrawRectangle(topLeft, bottomRight, eset.Get("widgets", "selection_rect_color").(color.Color))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -19,6 +19,7 @@ type Label struct {
 	maxWidth         int // 最大行像素宽
 	updateFlag       int
 	hidden           bool
+	markup           bool // 富文本，超过maxWidth时换行而不是截断
 	windowResizeFlag bool
 	alpha            uint8
 	label            common.Sprite // 展现内容
@@ -121,6 +122,13 @@ func (this *Label) SetFont(font string) {
 	}
 }
 
+func (this *Label) SetMarkup(markup bool) {
+	if this.markup != markup {
+		this.markup = markup
+		this.SetUpdateFlag(label.UPDATE_RECACHE)
+	}
+}
+
 func (this *Label) SetAlpha(alpha uint8) {
 	if this.alpha != alpha {
 		this.alpha = alpha
@@ -201,9 +209,13 @@ func (this *Label) RecacheTextSprite(device common.RenderDevice, font common.Fon
 		return nil
 	}
 
+	font.SetFont(this.fontStyle)
+	if this.markup {
+		return this.recacheMarkupSprite(device, font)
+	}
+
 	// 设置内容大小
 	tempText := this.text
-	font.SetFont(this.fontStyle)
 	this.bounds.W = font.CalcWidth(tempText)
 	this.bounds.H = font.GetFontHeight()
 
@@ -234,6 +246,43 @@ func (this *Label) RecacheTextSprite(device common.RenderDevice, font common.Fon
 	return nil
 }
 
+// 富文本按maxWidth换行，多行按justify对齐
+func (this *Label) recacheMarkupSprite(device common.RenderDevice, font common.FontEngine) error {
+	size := font.CalcMarkupSize(this.text, this.maxWidth)
+	this.bounds.W = size.X
+	this.bounds.H = size.Y
+
+	if size.X == 0 || size.Y == 0 {
+		return nil
+	}
+
+	image, err := device.CreateImage(this.bounds.W, this.bounds.H)
+	if err != nil {
+		return err
+	}
+	defer image.UnRef()
+
+	x := 0
+	switch this.justify {
+	case fontengine.JUSTIFY_RIGHT:
+		x = size.X
+	case fontengine.JUSTIFY_CENTER:
+		x = size.X / 2
+	}
+
+	err = font.RenderMarkupShadowed(device, this.text, x, 0, this.justify, image, this.maxWidth, this.color)
+	if err != nil {
+		return err
+	}
+
+	this.label, err = image.CreateSprite()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (this *Label) SetUpdateFlag(updateFlag int) {
 	if updateFlag > this.updateFlag || updateFlag == label.UPDATE_NONE {
 		this.updateFlag = updateFlag
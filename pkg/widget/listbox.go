@@ -42,6 +42,9 @@ type ListBox struct {
 	canSelect       bool
 	scrollbarOffset int
 	disableTextTrim bool
+	canDrag         bool // 能否拖动排序
+	dragIndex       int  // 正在拖动的项
+	dragged         bool
 }
 
 func NewListBox(modules common.Modules, height int, filename string) *ListBox {
@@ -98,6 +101,7 @@ func (this *ListBox) Init(modules common.Modules, height int, filename string) c
 
 	this.SetPosW(gw)
 	this.SetPosH(gh / 3)
+	this.SetFocusable(true)
 	this.SetScrollType(widget.SCROLL_VERTICAL)
 
 	return this
@@ -306,10 +310,32 @@ func (this *ListBox) CheckClickAt(modules common.Modules, x, y int) bool {
 		}
 	}
 
+	// 按住拖动排序
+	if this.canDrag && this.pressed && inpt.GetLock(inputstate.MAIN1) {
+		for i, _ := range this.rows {
+			if i+this.cursor < len(this.items) && utils.IsWithinRect(this.rows[i], mouse) && i+this.cursor != this.dragIndex {
+				this.moveItem(this.dragIndex, i+this.cursor)
+				this.dragIndex = i + this.cursor
+				this.dragged = true
+				this.Refresh(modules)
+				break
+			}
+		}
+	}
+
 	if inpt.GetLock(inputstate.MAIN1) {
 		return false
 	}
 
+	// 拖动结束 选中拖动的项
+	if this.pressed && this.dragged {
+		this.pressed = false
+		this.dragged = false
+		this.Select(this.dragIndex)
+		this.Refresh(modules)
+		return true
+	}
+
 	// 处理左键释放
 	if this.pressed && !inpt.GetLock(inputstate.MAIN1) && this.canSelect {
 		this.pressed = false
@@ -355,6 +381,8 @@ func (this *ListBox) CheckClickAt(modules common.Modules, x, y int) bool {
 			if utils.IsWithinRect(this.rows[i], mouse) {
 				inpt.SetLock(inputstate.MAIN1, true) // 锁住
 				this.pressed = true
+				this.dragIndex = i + this.cursor
+				this.dragged = false
 			}
 		}
 	}
@@ -397,6 +425,21 @@ func (this *ListBox) CheckTooltip(modules common.Modules, mouse point.Point) {
 	}
 }
 
+// 移动一项到新的位置
+func (this *ListBox) moveItem(from, to int) {
+	if from < 0 || from >= len(this.items) || to < 0 || to >= len(this.items) || from == to {
+		return
+	}
+
+	item := this.items[from]
+	if from < to {
+		copy(this.items[from:to], this.items[from+1:to+1])
+	} else {
+		copy(this.items[to+1:from+1], this.items[to:from])
+	}
+	this.items[to] = item
+}
+
 func (this *ListBox) Append(modules common.Modules, value, tooltip string) {
 	if value == "" {
 		return
@@ -546,6 +589,10 @@ func (this *ListBox) SetMultiSelect(val bool) {
 	this.multiSelect = true
 }
 
+func (this *ListBox) SetCanDrag(val bool) {
+	this.canDrag = val
+}
+
 func (this *ListBox) SetHeight(modules common.Modules, val int) {
 	if val < 2 {
 		val = 2
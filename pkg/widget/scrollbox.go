@@ -49,6 +49,7 @@ func (this *ScrollBox) Init(modules common.Modules, width, height int) common.Wi
 	this.scrollbar = NewScrollBar(modules, scrollbar.DEFAULT_FILE)
 	this.SetPosW(width) // 指定弹窗的大小
 	this.SetPosH(height)
+	this.SetFocusable(true)
 	this.SetScrollType(widget.SCROLL_VERTICAL) // 垂直
 
 	// 调整绘制目标精灵的大小和位置 (高度大于等于弹窗本身)
@@ -56,7 +57,8 @@ func (this *ScrollBox) Init(modules common.Modules, width, height int) common.Wi
 		panic(err)
 	}
 
-	this.tablist.SetScrollType(widget.SCROLL_TWO_DIRECTIONS)
+	// 子组件之间只上下移动，左右交给滑块等组件自己
+	this.tablist.SetScrollType(widget.SCROLL_VERTICAL)
 	return this
 }
 
@@ -79,6 +79,7 @@ func (this *Slider) Init(modules common.Modules, fname string) common.WidgetSlid
 	this.posKnob.W = gw / 8
 	this.posKnob.H = gh / 2
 
+	this.SetFocusable(true)
 	this.SetScrollType(widget.SCROLL_HORIZONTAL)
 
 	return this
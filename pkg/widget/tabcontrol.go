@@ -46,6 +46,7 @@ func (this *TabControl) Init(modules common.Modules) common.WidgetTabControl {
 		panic(err)
 	}
 
+	this.SetFocusable(true)
 	this.SetScrollType(widget.SCROLL_HORIZONTAL)
 
 	return this
@@ -90,21 +90,44 @@ func (this *Tablist) Add(widget common.Widget) {
 	}
 }
 
+// 移除后序号保持连续
 func (this *Tablist) Remove(widget common.Widget) {
 	if widget == nil {
 		return
 	}
 
-	for index, ptr := range this.widgets {
-		if widget == ptr {
-			delete(this.widgets, index)
-			break
+	for index := 0; index < len(this.widgets); index++ {
+		if widget != this.widgets[index] {
+			continue
+		}
+
+		widget.Defocus()
+
+		last := len(this.widgets) - 1
+		for i := index; i < last; i++ {
+			this.widgets[i] = this.widgets[i+1]
+		}
+		delete(this.widgets, last)
+
+		if this.current == index {
+			this.current = -1
+		} else if this.current > index {
+			this.current--
 		}
+
+		if this.previous == index {
+			this.previous = -1
+		} else if this.previous > index {
+			this.previous--
+		}
+		break
 	}
 }
 
 func (this *Tablist) Clear() {
+	this.Defocus()
 	this.widgets = map[int]common.Widget{}
+	this.previous = -1
 }
 
 // 将该widget设为当前选中的
@@ -154,6 +177,32 @@ func (this *Tablist) Defocus() {
 	this.current = -1
 }
 
+// 可以通过按键聚焦，文字等组件不能
+func (this *Tablist) isNavigable(w common.Widget) bool {
+	return w.GetFocusable() && w.GetEnableTablistNav()
+}
+
+// 自增或自减模式的下一个序号，跳过不能聚焦的组件
+func (this *Tablist) stepIndex(step int) (int, bool) {
+	size := len(this.widgets)
+	index := this.current
+
+	for i := 0; i < size; i++ {
+		index += step
+		if index >= size {
+			index = 0
+		} else if index < 0 {
+			index = size - 1
+		}
+
+		if this.isNavigable(this.widgets[index]) {
+			return index, true
+		}
+	}
+
+	return -1, false
+}
+
 func (this *Tablist) GetNextRelativeIndex(dir int) (int, bool) {
 	if this.current == -1 {
 		return -1, false
@@ -167,7 +216,7 @@ func (this *Tablist) GetNextRelativeIndex(dir int) (int, bool) {
 			continue
 		}
 
-		if !ptr.GetEnableTablistNav() {
+		if !this.isNavigable(ptr) {
 			continue
 		}
 
@@ -231,11 +280,12 @@ func (this *Tablist) GetNext(modules common.Modules, inner bool, dir int) (commo
 
 	if dir == tablist.WIDGET_SELECT_AUTO {
 		// 自增模式
-		this.current++
-
-		if this.current >= len(this.widgets) {
-			this.current = 0
+		next, ok := this.stepIndex(1)
+		if !ok {
+			this.current = -1
+			return nil, false
 		}
+		this.current = next
 
 	} else {
 		// 最小距离模式
@@ -243,10 +293,12 @@ func (this *Tablist) GetNext(modules common.Modules, inner bool, dir int) (commo
 			this.current = next
 		} else {
 			if this.nextTablist == nil {
-				this.current++
-				if this.current >= len(this.widgets) {
-					this.current = 0
+				next, ok := this.stepIndex(1)
+				if !ok {
+					this.current = -1
+					return nil, false
 				}
+				this.current = next
 			} else {
 				this.Defocus()
 				this.locked = true
@@ -280,24 +332,30 @@ func (this *Tablist) GetPrev(modules common.Modules, inner bool, dir int) (commo
 	}
 
 	if this.current == -1 {
-		this.current = 0
+		next, ok := this.stepIndex(1)
+		if !ok {
+			return nil, false
+		}
+		this.current = next
 	} else if dir == tablist.WIDGET_SELECT_AUTO {
 		//自增模式
-		this.current--
-
-		if this.current <= -1 {
-			this.current = len(this.widgets) - 1
+		next, ok := this.stepIndex(-1)
+		if !ok {
+			this.current = -1
+			return nil, false
 		}
+		this.current = next
 	} else {
 		if next, ok := this.GetNextRelativeIndex(dir); ok {
 			this.current = next
 		} else {
 			if this.prevTablist == nil {
-				this.current--
-
-				if this.current <= -1 {
-					this.current = len(this.widgets) - 1
+				next, ok := this.stepIndex(-1)
+				if !ok {
+					this.current = -1
+					return nil, false
 				}
+				this.current = next
 			} else {
 				// 清理自己，准备跳转到其他list
 				this.Defocus()
@@ -354,9 +412,19 @@ func (this *Tablist) Logic(modules common.Modules) error {
 		return nil
 	}
 
+	// 使用鼠标时不显示键盘导致的聚焦
+	if inpt.UsingMouse(settings) && !this.ignoreNoMouse && this.CurrentIsValid() {
+		this.Defocus()
+	}
+
 	// 使用键盘时
 	if !inpt.UsingMouse(settings) || this.ignoreNoMouse {
 
+		// 不使用鼠标时总有一个组件聚焦
+		if !inpt.UsingMouse(settings) && !this.CurrentIsValid() {
+			this.GetNext(modules, false, tablist.WIDGET_SELECT_AUTO)
+		}
+
 		// 组件自己的滚动方式
 		innerScrollType := widget.SCROLL_VERTICAL // 垂直方向
 		_ = innerScrollType
@@ -413,6 +481,28 @@ func (this *Tablist) Logic(modules common.Modules) error {
 			}
 		}
 
+		// 总体只能单方向移动时，另一方向交给组件自己处理，如标签切换和滑块
+		// 上面可能已跳到下个列表，current不再有效
+		if this.scrollType == widget.SCROLL_VERTICAL && this.CurrentIsValid() &&
+			this.widgets[this.current].GetScrollType() == widget.SCROLL_HORIZONTAL {
+			if inpt.GetPressing(this.MV_LEFT) && !inpt.GetLock(this.MV_LEFT) {
+				inpt.SetLock(this.MV_LEFT, true)
+				this.widgets[this.current].GetPrev(modules)
+			} else if inpt.GetPressing(this.MV_RIGHT) && !inpt.GetLock(this.MV_RIGHT) {
+				inpt.SetLock(this.MV_RIGHT, true)
+				this.widgets[this.current].GetNext(modules)
+			}
+		} else if this.scrollType == widget.SCROLL_HORIZONTAL && this.CurrentIsValid() &&
+			this.widgets[this.current].GetScrollType() == widget.SCROLL_VERTICAL {
+			if inpt.GetPressing(inputstate.UP) && !inpt.GetLock(inputstate.UP) {
+				inpt.SetLock(inputstate.UP, true)
+				this.widgets[this.current].GetPrev(modules)
+			} else if inpt.GetPressing(inputstate.DOWN) && !inpt.GetLock(inputstate.DOWN) {
+				inpt.SetLock(inputstate.DOWN, true)
+				this.widgets[this.current].GetNext(modules)
+			}
+		}
+
 		if inpt.GetPressing(this.ACTIVATE) && !inpt.GetLock(this.ACTIVATE) {
 			inpt.SetLock(this.ACTIVATE, true)
 			this.DeactivatePrevious()
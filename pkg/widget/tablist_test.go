@@ -0,0 +1,155 @@
+package widget
+
+import (
+	"monster/pkg/common"
+	"monster/pkg/common/define/widget/tablist"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeWidget struct {
+	common.Widget
+
+	focusable bool
+	inFocus   bool
+}
+
+func newFakeWidget(focusable bool) *fakeWidget {
+	return &fakeWidget{focusable: focusable}
+}
+
+func (this *fakeWidget) GetFocusable() bool {
+	return this.focusable
+}
+
+func (this *fakeWidget) GetEnableTablistNav() bool {
+	return true
+}
+
+func (this *fakeWidget) Focus() {
+	this.inFocus = true
+}
+
+func (this *fakeWidget) Defocus() {
+	this.inFocus = false
+}
+
+func TestTablistRemove(t *testing.T) {
+	r := require.New(t)
+
+	a, b, c := newFakeWidget(true), newFakeWidget(true), newFakeWidget(true)
+
+	tl := NewTablist()
+	tl.Add(a)
+	tl.Add(b)
+	tl.Add(c)
+	tl.Add(b) // 重复添加无效
+	r.Equal(3, tl.Size())
+
+	r.True(tl.SetCurrent(c))
+	c.Focus()
+
+	// 移除前面的组件，序号保持连续，聚焦不变
+	tl.Remove(a)
+	r.Equal(2, tl.Size())
+	r.Equal(1, tl.GetCurrent())
+
+	w, ok := tl.GetWidgetByIndex(0)
+	r.True(ok)
+	r.Equal(b, w)
+
+	w, ok = tl.GetWidgetByIndex(1)
+	r.True(ok)
+	r.Equal(c, w)
+
+	_, ok = tl.GetWidgetByIndex(2)
+	r.False(ok)
+
+	// 移除聚焦的组件
+	tl.Remove(c)
+	r.Equal(1, tl.Size())
+	r.Equal(-1, tl.GetCurrent())
+	r.False(c.inFocus)
+
+	// 不在列表里
+	tl.Remove(a)
+	tl.Remove(nil)
+	r.Equal(1, tl.Size())
+}
+
+func TestTablistStepIndex(t *testing.T) {
+	r := require.New(t)
+
+	a, label, b := newFakeWidget(true), newFakeWidget(false), newFakeWidget(true)
+
+	tl := NewTablist()
+
+	// 空列表
+	_, ok := tl.stepIndex(1)
+	r.False(ok)
+
+	tl.Add(a)
+	tl.Add(label)
+	tl.Add(b)
+
+	// 跳过不能聚焦的组件
+	index, ok := tl.stepIndex(1)
+	r.True(ok)
+	r.Equal(0, index)
+
+	tl.current = 0
+	index, ok = tl.stepIndex(1)
+	r.True(ok)
+	r.Equal(2, index)
+
+	// 两端循环
+	tl.current = 2
+	index, ok = tl.stepIndex(1)
+	r.True(ok)
+	r.Equal(0, index)
+
+	tl.current = 0
+	index, ok = tl.stepIndex(-1)
+	r.True(ok)
+	r.Equal(2, index)
+
+	// 全部不能聚焦
+	tl.Clear()
+	tl.Add(label)
+	_, ok = tl.stepIndex(1)
+	r.False(ok)
+}
+
+func TestTablistGetNextAuto(t *testing.T) {
+	r := require.New(t)
+
+	a, label, b := newFakeWidget(true), newFakeWidget(false), newFakeWidget(true)
+
+	tl := NewTablist()
+	tl.Add(a)
+	tl.Add(label)
+	tl.Add(b)
+
+	w, ok := tl.GetNext(nil, false, tablist.WIDGET_SELECT_AUTO)
+	r.True(ok)
+	r.Equal(a, w)
+	r.True(a.inFocus)
+
+	w, ok = tl.GetNext(nil, false, tablist.WIDGET_SELECT_AUTO)
+	r.True(ok)
+	r.Equal(b, w)
+	r.False(a.inFocus)
+	r.True(b.inFocus)
+
+	w, ok = tl.GetPrev(nil, false, tablist.WIDGET_SELECT_AUTO)
+	r.True(ok)
+	r.Equal(a, w)
+
+	// 没有可以聚焦的组件
+	tl.Clear()
+	tl.Add(label)
+	_, ok = tl.GetNext(nil, false, tablist.WIDGET_SELECT_AUTO)
+	r.False(ok)
+	r.False(tl.CurrentIsValid())
+}
@@ -205,8 +205,8 @@ func (this *Tooltip) CreateBuffer(eset common.EngineSettings, font common.FontEn
 
 	font.SetFont("font_regular")
 
-	// 计算多行宽和高
-	size := font.CalcSize(fulltext, eset.Get("tooltips", "tooltip_width").(int))
+	// 计算多行宽和高，支持富文本
+	size := font.CalcMarkupSize(fulltext, eset.Get("tooltips", "tooltip_width").(int))
 
 	// 清理之前的缓存
 	if this.spriteBuf != nil {
@@ -304,13 +304,13 @@ func (this *Tooltip) CreateBuffer(eset common.EngineSettings, font common.FontEn
 	colors := tip.Colors()
 	for index, line := range lines {
 		if this.background != nil {
-			err = font.RenderShadowed(renderDevice, line, eset.Get("tooltips", "tooltip_margin").(int), cursorY, fontengine.JUSTIFY_LEFT, graphics, size.X, colors[index])
+			err = font.RenderMarkupShadowed(renderDevice, line, eset.Get("tooltips", "tooltip_margin").(int), cursorY, fontengine.JUSTIFY_LEFT, graphics, size.X, colors[index])
 			if err != nil {
 				return err
 			}
 
 		} else {
-			err = font.Render(renderDevice, line, eset.Get("tooltips", "tooltip_margin").(int), cursorY, fontengine.JUSTIFY_LEFT, graphics, size.X, colors[index])
+			err = font.RenderMarkup(renderDevice, line, eset.Get("tooltips", "tooltip_margin").(int), cursorY, fontengine.JUSTIFY_LEFT, graphics, size.X, colors[index])
 			if err != nil {
 				return err
 			}